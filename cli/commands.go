@@ -3,6 +3,7 @@ package cli
 import (
 	"fmt"
 	"path/filepath"
+	"slices"
 	"strings"
 	"os"
 	"github.com/urfave/cli/v2"
@@ -22,6 +23,23 @@ var Commands = []*cli.Command{
 	listRecordsCommand(),
 	editRecordCommand(),
 	editTableCommand(),
+	renameCommand(),
+	removeFeatureCommand(),
+	removeComponentCommand(),
+	importCommand(),
+	replaceCommand(),
+	sequenceCommand(),
+	mstShowCommand(),
+	mstConflictsCommand(),
+	mstInvertCommand(),
+	rebaseCommand(),
+	embeddedCommand(),
+	gitCommand(),
+	snapshotCommand(),
+	statusCommand(),
+	restoreCommand(),
+	undoCommand(),
+	historyCommand(),
 }
 
 
@@ -41,7 +59,7 @@ func editTableCommand() *cli.Command {
             &cli.StringFlag{
                 Name:     "set",
                 Aliases:  []string{"s"},
-                Usage:    "Set clause (e.g., Property='NewValue',Value='Test')",
+                Usage:    "Set clause (e.g., Property='NewValue',Value='Test' or Attributes=+msidbComponentAttributes64bit,-Permanent)",
                 Required: true,
             },
             &cli.StringFlag{
@@ -217,6 +235,18 @@ func transformCommand() *cli.Command {
 				Usage:    "Path for output transform (.mst) file",
 				Required: true,
 			},
+			&cli.BoolFlag{
+				Name:  "binary",
+				Usage: "Write a Windows Installer transform for msiexec TRANSFORMS= instead of a text transform",
+			},
+			&cli.StringFlag{
+				Name:  "validate",
+				Usage: "Conditions the target package must meet, e.g. \"product upgrade-code language version minor >=\"",
+			},
+			&cli.StringFlag{
+				Name:  "suppress",
+				Usage: "Errors to ignore when applying: row-exists, row-missing, table-exists, table-missing, type-mismatch",
+			},
 		},
 		Action: func(c *cli.Context) error {
 			return core.SafeExecute("GenerateTransform", func() error {
@@ -232,7 +262,15 @@ func transformCommand() *cli.Command {
 				if err := validateOutputPath(output, ".mst"); err != nil {
 					return err
 				}
-				err := core.GenerateTransform(orig, mod, output)
+				var checks *core.TransformChecks
+				if c.IsSet("validate") || c.IsSet("suppress") {
+					parsed, err := core.ParseTransformChecks(c.String("validate"), c.String("suppress"))
+					if err != nil {
+						return err
+					}
+					checks = &parsed
+				}
+				err := core.GenerateTransform(orig, mod, output, c.Bool("binary"), checks)
 				if err == nil {
 					fmt.Printf("Transform created: %s\n", output)
 				}
@@ -249,6 +287,19 @@ func diffCommand() *cli.Command {
 		Aliases:   []string{"compare"},
 		Usage:     "Compare two MSI files for differences",
 		ArgsUsage: "<msi_file1> <msi_file2>",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "format",
+				Aliases: []string{"f"},
+				Value:   "summary",
+				Usage:   "Output format: " + strings.Join(core.DiffFormats, ", "),
+			},
+			&cli.StringFlag{
+				Name:    "output",
+				Aliases: []string{"o"},
+				Usage:   "Write the diff to this file instead of stdout",
+			},
+		},
 		Action: func(c *cli.Context) error {
 			return core.SafeExecute("CompareMSI", func() error {
 				if c.Args().Len() < 2 {
@@ -262,7 +313,11 @@ func diffCommand() *cli.Command {
 				if err := validateFileExists(msi2, "second MSI"); err != nil {
 					return err
 				}
-				return core.CompareMSI(msi1, msi2)
+				format := strings.ToLower(c.String("format"))
+				if !slices.Contains(core.DiffFormats, format) {
+					return fmt.Errorf("invalid format '%s'; expected one of %s", format, strings.Join(core.DiffFormats, ", "))
+				}
+				return core.CompareMSI(msi1, msi2, format, c.String("output"))
 			})
 		},
 	}
@@ -342,8 +397,8 @@ func applyTransformCommand() *cli.Command {
 	return &cli.Command{
 		Name:      "apply",
 		Aliases:   []string{"patch"},
-		Usage:     "Apply an MST transform file to an MSI database",
-		ArgsUsage: "<mst_file> <msi_file>",
+		Usage:     "Apply an MST transform file to an MSI database, or stack several onto a copy with --out",
+		ArgsUsage: "<mst_file> <msi_file> | --out <result_msi> <base_msi> <mst_file>...",
 		Flags: []cli.Flag{
 			&cli.BoolFlag{
 				Name:    "dry-run",
@@ -353,11 +408,60 @@ func applyTransformCommand() *cli.Command {
 			&cli.BoolFlag{
 				Name:    "interactive",
 				Aliases: []string{"i"},
-				Usage:   "Prompt for confirmation before applying changes",
+				Usage:   "Prompt before each query: yes, no, all (apply the rest) or quit",
+			},
+			&cli.StringFlag{
+				Name:  "on-conflict",
+				Value: "warn",
+				Usage: "When an operation conflicts with the package and the transform does not suppress it: skip, warn or fail",
+			},
+			&cli.StringFlag{
+				Name:  "out",
+				Usage: "Copy the base MSI here and apply the transforms to the copy in order, leaving the base untouched",
+			},
+			&cli.BoolFlag{
+				Name:  "diff",
+				Usage: "With --out, print the cumulative changes against the base",
 			},
 		},
 		Action: func(c *cli.Context) error {
 			return core.SafeExecute("ApplyTransform", func() error {
+				policy := core.ConflictPolicy(strings.ToLower(c.String("on-conflict")))
+				switch policy {
+				case core.ConflictSkip, core.ConflictWarn, core.ConflictFail:
+				default:
+					return fmt.Errorf("invalid on-conflict policy '%s': expected skip, warn or fail", c.String("on-conflict"))
+				}
+				if out := c.String("out"); out != "" {
+					if c.Args().Len() < 2 {
+						return fmt.Errorf("a base MSI and at least one MST file path are required")
+					}
+					if c.Bool("dry-run") || c.Bool("interactive") {
+						return fmt.Errorf("--dry-run and --interactive cannot be combined with --out")
+					}
+					basePath := c.Args().Get(0)
+					if err := validateFileExists(basePath, "base MSI"); err != nil {
+						return err
+					}
+					mstPaths := c.Args().Slice()[1:]
+					for _, p := range mstPaths {
+						if err := validateFileExists(p, "MST"); err != nil {
+							return err
+						}
+					}
+					if err := validateOutputPath(out, ".msi"); err != nil {
+						return err
+					}
+					err := core.ApplyTransforms(basePath, out, mstPaths, policy, c.Bool("diff"))
+					if err == nil {
+						fmt.Printf("Result written to: %s\n", out)
+					}
+					return err
+				}
+				if c.Bool("diff") {
+					return fmt.Errorf("--diff requires --out")
+				}
+
 				if c.Args().Len() < 2 {
 					return fmt.Errorf("MST and MSI file paths are required")
 				}
@@ -371,7 +475,7 @@ func applyTransformCommand() *cli.Command {
 				}
 				dryRun := c.Bool("dry-run")
 				interactive := c.Bool("interactive")
-				err := core.ApplyTransform(msiPath, mstPath, dryRun, interactive)
+				err := core.ApplyTransform(msiPath, mstPath, dryRun, interactive, policy)
 				if err == nil && !dryRun {
 					fmt.Printf("Transform applied to: %s\n", msiPath)
 				}
@@ -400,6 +504,10 @@ func listRecordsCommand() *cli.Command {
 				Aliases: []string{"v"},
 				Usage:   "Include column names in output",
 			},
+			&cli.BoolFlag{
+				Name:  "decode-flags",
+				Usage: "Show attribute bitfields (Component.Attributes, CustomAction.Type, ...) with their flag names",
+			},
 		},
 		Action: func(c *cli.Context) error {
 			return core.SafeExecute("ListRecords", func() error {
@@ -423,6 +531,11 @@ func listRecordsCommand() *cli.Command {
 						fmt.Printf("Table '%s' columns: %s\n", tableName, strings.Join(cols, ", "))
 					}
 				}
+				if c.Bool("decode-flags") {
+					if rows, err = core.DecodeFlagRows(msiPath, tableName, rows); err != nil {
+						return err
+					}
+				}
 				fmt.Printf("Records in table '%s' (%d rows):\n", tableName, len(rows))
 				fmt.Println(core.FormatRows(rows))
 				return nil
@@ -453,7 +566,7 @@ func editRecordCommand() *cli.Command {
 			&cli.StringFlag{
 				Name:     "set",
 				Aliases:  []string{"s"},
-				Usage:    "Set clause (e.g., 'field=value,field2=value2')",
+				Usage:    "Set clause (e.g., 'field=value,field2=value2'); bitfields accept flag names, e.g. Attributes=+Permanent,-64bit",
 				Required: true,
 			},
 			&cli.BoolFlag{
@@ -487,6 +600,800 @@ func editRecordCommand() *cli.Command {
 	}
 }
 
+// renameCommand renames an identifier and rewrites every reference to it.
+func renameCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "rename",
+		Aliases:   []string{"mv"},
+		Usage:     "Rename a Component, Feature, Directory, Property or other key across all referencing tables",
+		ArgsUsage: "<msi_file>",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "table",
+				Aliases:  []string{"t"},
+				Usage:    "Table whose primary key is renamed (e.g., Component)",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:     "from",
+				Usage:    "Current identifier",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:     "to",
+				Usage:    "New identifier",
+				Required: true,
+			},
+			&cli.BoolFlag{
+				Name:    "dry-run",
+				Aliases: []string{"n"},
+				Usage:   "Preview the touched cells without committing changes",
+			},
+			&cli.BoolFlag{
+				Name:    "interactive",
+				Aliases: []string{"i"},
+				Usage:   "Prompt for confirmation before applying changes",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			return core.SafeExecute("RenameIdentifier", func() error {
+				msiPath, err := validateMSIPath(c)
+				if err != nil {
+					return err
+				}
+				tableName := c.String("table")
+				from := c.String("from")
+				to := c.String("to")
+				if from == to {
+					return fmt.Errorf("--from and --to are identical")
+				}
+				dryRun := c.Bool("dry-run")
+				err = core.RenameIdentifier(msiPath, tableName, from, to, dryRun, c.Bool("interactive"))
+				if err == nil && !dryRun {
+					fmt.Printf("Renamed %s '%s' to '%s' in: %s\n", tableName, from, to, msiPath)
+				}
+				return err
+			})
+		},
+	}
+}
+
+// removeFeatureCommand removes a feature and everything only it owns.
+func removeFeatureCommand() *cli.Command {
+	return removalCommand("remove-feature", "feature", "Remove a feature, its sub-features and the rows they own",
+		core.RemoveFeature)
+}
+
+// removeComponentCommand removes a component and everything it owns.
+func removeComponentCommand() *cli.Command {
+	return removalCommand("remove-component", "component", "Remove a component and the rows it owns",
+		core.RemoveComponent)
+}
+
+// removalCommand builds the shared shape of the remove-feature and remove-component commands.
+func removalCommand(name, kind, usage string, remove func(msiPath, key, transformPath string, dryRun, interactive bool) error) *cli.Command {
+	return &cli.Command{
+		Name:      name,
+		Usage:     usage,
+		ArgsUsage: "<msi_file>",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     kind,
+				Usage:    fmt.Sprintf("Identifier of the %s to remove", kind),
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:    "output",
+				Aliases: []string{"o"},
+				Usage:   "Write the removal to this transform (.mst) file instead of modifying the MSI",
+			},
+			&cli.BoolFlag{
+				Name:    "dry-run",
+				Aliases: []string{"n"},
+				Usage:   "Show the removal plan without writing anything",
+			},
+			&cli.BoolFlag{
+				Name:    "interactive",
+				Aliases: []string{"i"},
+				Usage:   "Prompt for confirmation before applying changes",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			return core.SafeExecute("Remove", func() error {
+				msiPath, err := validateMSIPath(c)
+				if err != nil {
+					return err
+				}
+				output := c.String("output")
+				if output != "" {
+					if err := validateOutputPath(output, ".mst"); err != nil {
+						return err
+					}
+				}
+				dryRun := c.Bool("dry-run")
+				err = remove(msiPath, c.String(kind), output, dryRun, c.Bool("interactive"))
+				if err == nil && !dryRun && output == "" {
+					fmt.Printf("Removed %s '%s' from: %s\n", kind, c.String(kind), msiPath)
+				}
+				return err
+			})
+		},
+	}
+}
+
+// importCommand loads rows from a CSV or JSON file into a table.
+func importCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "import",
+		Usage:     "Import rows into a table from CSV or JSON, matching existing rows on the primary key",
+		ArgsUsage: "<msi_file> <data_file>",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "table",
+				Aliases:  []string{"t"},
+				Usage:    "Table to import into",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:  "mode",
+				Value: string(core.ImportInsert),
+				Usage: "insert (new rows only), upsert (insert or update) or replace (upsert and delete rows not in the file)",
+			},
+			&cli.BoolFlag{
+				Name:    "dry-run",
+				Aliases: []string{"n"},
+				Usage:   "Validate and preview the import without committing changes",
+			},
+			&cli.BoolFlag{
+				Name:    "interactive",
+				Aliases: []string{"i"},
+				Usage:   "Prompt for confirmation before applying changes",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			return core.SafeExecute("ImportTable", func() error {
+				if c.Args().Len() != 2 {
+					return fmt.Errorf("an MSI file and a data file are required")
+				}
+				msiPath := c.Args().Get(0)
+				dataPath := c.Args().Get(1)
+				if err := validateFileExists(msiPath, "MSI"); err != nil {
+					return err
+				}
+				if err := validateFileExists(dataPath, "data"); err != nil {
+					return err
+				}
+				mode := core.ImportMode(strings.ToLower(c.String("mode")))
+				switch mode {
+				case core.ImportInsert, core.ImportUpsert, core.ImportReplace:
+				default:
+					return fmt.Errorf("invalid mode '%s': expected insert, upsert or replace", c.String("mode"))
+				}
+				dryRun := c.Bool("dry-run")
+				result, err := core.ImportTable(msiPath, c.String("table"), dataPath, mode, dryRun, c.Bool("interactive"))
+				if err == nil && !dryRun {
+					fmt.Printf("Imported into '%s' (%s): %s\n", c.String("table"), result, msiPath)
+				}
+				return err
+			})
+		},
+	}
+}
+
+// replaceCommand finds and replaces text in string cells across tables.
+func replaceCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "replace",
+		Usage:     "Find and replace text (regular expression) in string columns across all tables",
+		ArgsUsage: "<msi_file>",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "find",
+				Usage:    "Regular expression to search for",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:     "replace",
+				Usage:    "Replacement text; $1, ${name} expand capture groups",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:  "tables",
+				Usage: "Comma-separated tables to search (default: all)",
+			},
+			&cli.StringFlag{
+				Name:  "columns",
+				Usage: "Comma-separated columns to search, as Column or Table.Column (default: all)",
+			},
+			&cli.StringFlag{
+				Name:    "output",
+				Aliases: []string{"o"},
+				Usage:   "Write the replacements to this transform (.mst) instead of modifying the MSI",
+			},
+			&cli.BoolFlag{
+				Name:    "dry-run",
+				Aliases: []string{"n"},
+				Usage:   "Preview the touched cells without writing anything",
+			},
+			&cli.BoolFlag{
+				Name:    "interactive",
+				Aliases: []string{"i"},
+				Usage:   "Prompt for confirmation before applying changes",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			return core.SafeExecute("ReplaceText", func() error {
+				msiPath, err := validateMSIPath(c)
+				if err != nil {
+					return err
+				}
+				output := c.String("output")
+				if output != "" {
+					if err := validateOutputPath(output, ".mst"); err != nil {
+						return err
+					}
+				}
+				dryRun := c.Bool("dry-run")
+				err = core.ReplaceText(msiPath, c.String("find"), c.String("replace"),
+					splitList(c.String("tables")), splitList(c.String("columns")), output, dryRun, c.Bool("interactive"))
+				if err == nil && !dryRun && output == "" {
+					fmt.Printf("Replacements committed to: %s\n", msiPath)
+				}
+				return err
+			})
+		},
+	}
+}
+
+// sequenceCommand schedules, reschedules and unschedules actions in sequence tables.
+func sequenceCommand() *cli.Command {
+	commonFlags := func() []cli.Flag {
+		return []cli.Flag{
+			&cli.StringFlag{
+				Name:    "table",
+				Aliases: []string{"t"},
+				Value:   "InstallExecuteSequence",
+				Usage:   "Sequence table to edit",
+			},
+			&cli.StringFlag{
+				Name:     "action",
+				Aliases:  []string{"a"},
+				Usage:    "Action (custom action, standard action or dialog)",
+				Required: true,
+			},
+			&cli.BoolFlag{
+				Name:    "dry-run",
+				Aliases: []string{"n"},
+				Usage:   "Preview the sequence changes without committing",
+			},
+			&cli.BoolFlag{
+				Name:    "interactive",
+				Aliases: []string{"i"},
+				Usage:   "Prompt for confirmation before applying changes",
+			},
+		}
+	}
+	placementFlags := []cli.Flag{
+		&cli.StringFlag{Name: "after", Usage: "Schedule right after this action"},
+		&cli.StringFlag{Name: "before", Usage: "Schedule right before this action"},
+		&cli.IntFlag{Name: "sequence", Usage: "Schedule at this sequence number"},
+	}
+	placement := func(c *cli.Context) core.SequencePlacement {
+		return core.SequencePlacement{After: c.String("after"), Before: c.String("before"), Sequence: c.Int("sequence")}
+	}
+	report := func(c *cli.Context, msiPath, verb string, err error) error {
+		if err == nil && !c.Bool("dry-run") {
+			fmt.Printf("%s '%s' in %s: %s\n", verb, c.String("action"), c.String("table"), msiPath)
+		}
+		return err
+	}
+
+	return &cli.Command{
+		Name:  "sequence",
+		Usage: "Add, move or remove actions in InstallExecuteSequence, InstallUISequence and the other sequence tables",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "add",
+				Usage:     "Schedule an action relative to another action or at a sequence number",
+				ArgsUsage: "<msi_file>",
+				Flags: append(append(commonFlags(), placementFlags...),
+					&cli.StringFlag{Name: "condition", Aliases: []string{"c"}, Usage: "Condition under which the action runs"}),
+				Action: func(c *cli.Context) error {
+					return core.SafeExecute("SequenceAdd", func() error {
+						msiPath, err := validateMSIPath(c)
+						if err != nil {
+							return err
+						}
+						err = core.SequenceAdd(msiPath, c.String("table"), c.String("action"), c.String("condition"),
+							placement(c), c.Bool("dry-run"), c.Bool("interactive"))
+						return report(c, msiPath, "Scheduled", err)
+					})
+				},
+			},
+			{
+				Name:      "move",
+				Usage:     "Reschedule an action relative to another action or at a sequence number",
+				ArgsUsage: "<msi_file>",
+				Flags:     append(commonFlags(), placementFlags...),
+				Action: func(c *cli.Context) error {
+					return core.SafeExecute("SequenceMove", func() error {
+						msiPath, err := validateMSIPath(c)
+						if err != nil {
+							return err
+						}
+						err = core.SequenceMove(msiPath, c.String("table"), c.String("action"),
+							placement(c), c.Bool("dry-run"), c.Bool("interactive"))
+						return report(c, msiPath, "Moved", err)
+					})
+				},
+			},
+			{
+				Name:      "remove",
+				Usage:     "Unschedule an action",
+				ArgsUsage: "<msi_file>",
+				Flags:     commonFlags(),
+				Action: func(c *cli.Context) error {
+					return core.SafeExecute("SequenceRemove", func() error {
+						msiPath, err := validateMSIPath(c)
+						if err != nil {
+							return err
+						}
+						err = core.SequenceRemove(msiPath, c.String("table"), c.String("action"),
+							c.Bool("dry-run"), c.Bool("interactive"))
+						return report(c, msiPath, "Unscheduled", err)
+					})
+				},
+			},
+		},
+	}
+}
+
+// mstShowCommand decodes a binary Windows Installer transform.
+func mstShowCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "mst-show",
+		Usage:     "Decode a binary transform into its summary information and table changes",
+		ArgsUsage: "<mst_file>",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "base",
+				Aliases: []string{"b"},
+				Usage:   "MSI the transform applies to, for the layout of custom tables",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			return core.SafeExecute("ShowTransform", func() error {
+				if c.Args().Len() != 1 {
+					return fmt.Errorf("exactly one MST file path is required")
+				}
+				mstPath := c.Args().Get(0)
+				if err := validateFileExists(mstPath, "MST"); err != nil {
+					return err
+				}
+				basePath := c.String("base")
+				if basePath != "" {
+					if err := validateFileExists(basePath, "MSI"); err != nil {
+						return err
+					}
+				}
+				return core.ShowTransform(mstPath, basePath)
+			})
+		},
+	}
+}
+
+// mstConflictsCommand reports conflicts between transforms applied together.
+func mstConflictsCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "mst-conflicts",
+		Usage:     "Report rows and cells changed by more than one transform, and operations that fail when they are stacked",
+		ArgsUsage: "<mst_file> <mst_file>...",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "base",
+				Aliases: []string{"b"},
+				Usage:   "MSI the transforms apply to, to check rows against its tables",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			return core.SafeExecute("ReportTransformConflicts", func() error {
+				if c.Args().Len() < 2 {
+					return fmt.Errorf("at least two MST file paths are required, in the order they are applied")
+				}
+				paths := c.Args().Slice()
+				for _, p := range paths {
+					if err := validateFileExists(p, "MST"); err != nil {
+						return err
+					}
+				}
+				basePath := c.String("base")
+				if basePath != "" {
+					if err := validateFileExists(basePath, "MSI"); err != nil {
+						return err
+					}
+				}
+				return core.ReportTransformConflicts(paths, basePath)
+			})
+		},
+	}
+}
+
+// mstInvertCommand writes the transform that rolls a transform back.
+func mstInvertCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "mst-invert",
+		Usage:     "Write the transform that undoes a transform, for rolling it back",
+		ArgsUsage: "<mst_file>",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "base",
+				Aliases:  []string{"b"},
+				Usage:    "MSI the transform applies to, for the rows it deletes and the values it overwrites",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:     "output",
+				Aliases:  []string{"out"},
+				Usage:    "Path for the inverse text transform (.mst)",
+				Required: true,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			return core.SafeExecute("InvertTransform", func() error {
+				if c.Args().Len() != 1 {
+					return fmt.Errorf("exactly one MST file path is required")
+				}
+				mstPath, basePath, output := c.Args().Get(0), c.String("base"), c.String("output")
+				if err := validateFileExists(mstPath, "MST"); err != nil {
+					return err
+				}
+				if err := validateFileExists(basePath, "MSI"); err != nil {
+					return err
+				}
+				if err := validateOutputPath(output, ".mst"); err != nil {
+					return err
+				}
+				return core.InvertTransform(mstPath, basePath, output)
+			})
+		},
+	}
+}
+
+// rebaseCommand replays a transform made against one release onto the next.
+func rebaseCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "rebase",
+		Usage: "Replay a customization transform onto a new release of the package (three-way merge)",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "original",
+				Aliases:  []string{"o"},
+				Usage:    "MSI the transform was made against",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:     "transform",
+				Aliases:  []string{"t"},
+				Usage:    "Text or binary transform to rebase",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:     "new",
+				Aliases:  []string{"n"},
+				Usage:    "New release to rebase the transform onto",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:     "output",
+				Aliases:  []string{"out"},
+				Usage:    "Path for the rebased text transform (.mst)",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:  "report",
+				Usage: "Write conflicts to this file instead of stdout",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			return core.SafeExecute("RebaseTransform", func() error {
+				orig, mst, next := c.String("original"), c.String("transform"), c.String("new")
+				output := c.String("output")
+				if err := validateFileExists(orig, "original MSI"); err != nil {
+					return err
+				}
+				if err := validateFileExists(mst, "MST"); err != nil {
+					return err
+				}
+				if err := validateFileExists(next, "new MSI"); err != nil {
+					return err
+				}
+				if err := validateOutputPath(output, ".mst"); err != nil {
+					return err
+				}
+				return core.RebaseTransform(orig, mst, next, output, c.String("report"))
+			})
+		},
+	}
+}
+
+// embeddedCommand manages transforms embedded in a package as substorages.
+func embeddedCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "embedded",
+		Usage: "List, extract and add transforms embedded in an MSI (TRANSFORMS=:name)",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "ls",
+				Usage:     "List the embedded storages with the summary of each transform",
+				ArgsUsage: "<msi_file>",
+				Action: func(c *cli.Context) error {
+					return core.SafeExecute("ListEmbedded", func() error {
+						if c.Args().Len() != 1 {
+							return fmt.Errorf("exactly one MSI file path is required")
+						}
+						msiPath := c.Args().Get(0)
+						if err := validateFileExists(msiPath, "MSI"); err != nil {
+							return err
+						}
+						return core.ListEmbedded(msiPath)
+					})
+				},
+			},
+			{
+				Name:      "extract",
+				Usage:     "Write embedded storages to <name>.mst files; all of them when no names are given",
+				ArgsUsage: "<msi_file> [name...]",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "dir",
+						Aliases: []string{"d"},
+						Value:   ".",
+						Usage:   "Directory to write the .mst files to",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					return core.SafeExecute("ExtractEmbedded", func() error {
+						if c.Args().Len() < 1 {
+							return fmt.Errorf("MSI file path is required")
+						}
+						msiPath := c.Args().Get(0)
+						if err := validateFileExists(msiPath, "MSI"); err != nil {
+							return err
+						}
+						dir := c.String("dir")
+						if err := validateDirExists(dir); err != nil {
+							return err
+						}
+						return core.ExtractEmbedded(msiPath, c.Args().Slice()[1:], dir)
+					})
+				},
+			},
+			{
+				Name:      "add",
+				Usage:     "Embed a binary transform in the MSI",
+				ArgsUsage: "<msi_file> <mst_file>",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "name",
+						Aliases: []string{"n"},
+						Usage:   "Storage name to embed the transform under (default: the MST file name)",
+					},
+					&cli.BoolFlag{
+						Name:  "replace",
+						Usage: "Overwrite an embedded storage of the same name",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					return core.SafeExecute("AddEmbedded", func() error {
+						if c.Args().Len() != 2 {
+							return fmt.Errorf("MSI and MST file paths are required")
+						}
+						msiPath, mstPath := c.Args().Get(0), c.Args().Get(1)
+						if err := validateFileExists(msiPath, "MSI"); err != nil {
+							return err
+						}
+						if err := validateFileExists(mstPath, "MST"); err != nil {
+							return err
+						}
+						return core.AddEmbedded(msiPath, mstPath, c.String("name"), c.Bool("replace"))
+					})
+				},
+			},
+		},
+	}
+}
+
+// gitCommand holds the drivers git calls to diff and merge MSI files.
+func gitCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "git",
+		Usage: "Git textconv and merge drivers for MSI files (see README)",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "textconv",
+				Usage:     "Print the summary information and every table as sorted text, for git diff",
+				ArgsUsage: "<msi_file>",
+				Action: func(c *cli.Context) error {
+					return core.SafeExecute("TextConv", func() error {
+						if c.Args().Len() != 1 {
+							return fmt.Errorf("exactly one MSI file path is required")
+						}
+						msiPath := c.Args().Get(0)
+						if err := validateFileExists(msiPath, "MSI"); err != nil {
+							return err
+						}
+						return core.TextConv(msiPath, os.Stdout)
+					})
+				},
+			},
+			{
+				Name:      "merge-driver",
+				Usage:     "Merge two MSIs by primary key against their common ancestor, writing the result to <current>",
+				ArgsUsage: "<ancestor> <current> <other>",
+				Action: func(c *cli.Context) error {
+					return core.SafeExecute("MergeMSI", func() error {
+						if c.Args().Len() != 3 {
+							return fmt.Errorf("ancestor, current and other MSI paths are required (%%O %%A %%B)")
+						}
+						paths := c.Args().Slice()
+						for _, p := range paths {
+							if err := validateFileExists(p, "MSI"); err != nil {
+								return err
+							}
+						}
+						return core.MergeMSI(paths[0], paths[1], paths[2])
+					})
+				},
+			},
+		},
+	}
+}
+
+// snapshotCommand writes a package's state to a directory of text files.
+func snapshotCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "snapshot",
+		Usage:     "Write every table, the summary information and stream hashes to a directory of text files",
+		ArgsUsage: "<msi_file> <dir>",
+		Action: func(c *cli.Context) error {
+			return core.SafeExecute("SnapshotMSI", func() error {
+				if c.Args().Len() != 2 {
+					return fmt.Errorf("MSI file path and snapshot directory are required")
+				}
+				msiPath := c.Args().Get(0)
+				if err := validateFileExists(msiPath, "MSI"); err != nil {
+					return err
+				}
+				return core.SnapshotMSI(msiPath, c.Args().Get(1))
+			})
+		},
+	}
+}
+
+// statusCommand reports how a package has drifted from a snapshot.
+func statusCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "status",
+		Usage:     "Report the tables, rows, summary properties and streams that differ from a snapshot",
+		ArgsUsage: "<msi_file> <dir>",
+		Action: func(c *cli.Context) error {
+			return core.SafeExecute("SnapshotStatus", func() error {
+				if c.Args().Len() != 2 {
+					return fmt.Errorf("MSI file path and snapshot directory are required")
+				}
+				msiPath, dir := c.Args().Get(0), c.Args().Get(1)
+				if err := validateFileExists(msiPath, "MSI"); err != nil {
+					return err
+				}
+				if err := validateDirExists(dir); err != nil {
+					return err
+				}
+				return core.SnapshotStatus(msiPath, dir)
+			})
+		},
+	}
+}
+
+// restoreCommand rebuilds a package's tables from a snapshot.
+func restoreCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "restore",
+		Usage:     "Rebuild the tables of an MSI from a snapshot",
+		ArgsUsage: "<msi_file> <dir>",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Show the changes without committing them",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			return core.SafeExecute("RestoreSnapshot", func() error {
+				if c.Args().Len() != 2 {
+					return fmt.Errorf("MSI file path and snapshot directory are required")
+				}
+				msiPath, dir := c.Args().Get(0), c.Args().Get(1)
+				if err := validateFileExists(msiPath, "MSI"); err != nil {
+					return err
+				}
+				if err := validateDirExists(dir); err != nil {
+					return err
+				}
+				return core.RestoreSnapshot(msiPath, dir, c.Bool("dry-run"))
+			})
+		},
+	}
+}
+
+// undoCommand rolls back the most recent journaled changes.
+func undoCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "undo",
+		Usage:     "Roll back the last committed change(s) recorded in the MSI's journal",
+		ArgsUsage: "<msi_file>",
+		Flags: []cli.Flag{
+			&cli.IntFlag{
+				Name:  "steps",
+				Value: 1,
+				Usage: "Number of journal entries to roll back",
+			},
+			&cli.BoolFlag{
+				Name:    "dry-run",
+				Aliases: []string{"n"},
+				Usage:   "Preview the rollback without committing changes",
+			},
+			&cli.BoolFlag{
+				Name:    "interactive",
+				Aliases: []string{"i"},
+				Usage:   "Prompt for confirmation before rolling back",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			return core.SafeExecute("Undo", func() error {
+				msiPath, err := validateMSIPath(c)
+				if err != nil {
+					return err
+				}
+				dryRun := c.Bool("dry-run")
+				err = core.Undo(msiPath, c.Int("steps"), dryRun, c.Bool("interactive"))
+				if err == nil && !dryRun {
+					fmt.Printf("Rolled back %d change(s) in: %s\n", c.Int("steps"), msiPath)
+				}
+				return err
+			})
+		},
+	}
+}
+
+// historyCommand lists the journaled changes of an MSI.
+func historyCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "history",
+		Aliases:   []string{"log"},
+		Usage:     "List the committed changes recorded in the MSI's journal",
+		ArgsUsage: "<msi_file>",
+		Action: func(c *cli.Context) error {
+			return core.SafeExecute("History", func() error {
+				msiPath, err := validateMSIPath(c)
+				if err != nil {
+					return err
+				}
+				return core.History(msiPath)
+			})
+		},
+	}
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 // validateMSIPath ensures a single MSI file path is provided and exists.
 func validateMSIPath(c *cli.Context) (string, error) {
 	if c.Args().Len() == 0 {
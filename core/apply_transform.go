@@ -2,27 +2,42 @@
 package core
 
 import (
-	"bufio"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"msicrafter/retro"
 )
 
-// ApplyTransform reads an MST diff file line by line, then updates the target MSI
-// with Insert or Delete queries. If dryRun is true, no commit is performed.
-// If interactive is true, we prompt before each query.
-func ApplyTransform(msiPath, mstFile string, dryRun, interactive bool) error {
+// ConflictPolicy decides what ApplyTransform does when an operation does not
+// fit the package: a delete or update of a missing row, an insert of an
+// existing one, a table or column that already exists or is missing, or a
+// value that does not match its column's type. Conflicts the transform
+// suppresses are always skipped silently.
+type ConflictPolicy string
+
+const (
+	ConflictSkip ConflictPolicy = "skip" // leave the operation out silently
+	ConflictWarn ConflictPolicy = "warn" // leave it out with a warning
+	ConflictFail ConflictPolicy = "fail" // abort without committing anything
+)
+
+// ApplyTransform reads a text or binary transform, then updates the target MSI
+// with its operations. If dryRun is true, no commit is performed.
+// If interactive is true, we prompt before each operation. The transform's
+// validation conditions are checked before anything is changed; conflicts
+// it does not suppress are handled according to onConflict.
+func ApplyTransform(msiPath, mstFile string, dryRun, interactive bool, onConflict ConflictPolicy) error {
 	return SafeExecute("ApplyTransform", func() error {
-		// Read lines from MST
-		lines, err := loadDiffLines(mstFile)
+		// Read operations from MST
+		transform, err := ReadTransform(mstFile, msiPath)
 		if err != nil {
 			return err
 		}
-		if len(lines) == 0 {
-			return fmt.Errorf("no diff lines in %s", mstFile)
+		if len(transform.Ops) == 0 {
+			return fmt.Errorf("no valid operations in %s", mstFile)
 		}
 
 		// Open MSI session
@@ -32,75 +47,465 @@ func ApplyTransform(msiPath, mstFile string, dryRun, interactive bool) error {
 		}
 		defer session.Close()
 
-		// Build and execute queries
-		var queries []string
-		for _, line := range lines {
-			op, table, vals, e := parseDiffLine(line)
-			if e != nil {
-				log.Printf("[WARN] skipping invalid diff line '%s': %v", line, e)
-				continue
+		stats, err := session.applyTransform(transform, dryRun, interactive, onConflict)
+		if err != nil {
+			return err
+		}
+		if stats.conflicts > 0 {
+			log.Printf("[INFO] Skipped %d conflicting operation(s).", stats.conflicts)
+		}
+		if stats.suppressed > 0 {
+			log.Printf("[INFO] Skipped %d operation(s) whose errors the transform suppresses.", stats.suppressed)
+		}
+		if !dryRun {
+			if err := session.Commit(); err != nil {
+				return fmt.Errorf("commit failed: %v", err)
 			}
-			q := buildSQL(op, table, vals)
-			if DebugMode {
-				fmt.Printf("[DEBUG] MST line => %s\n -> built query: %s\n", line, q)
+			log.Println("[INFO] Transform applied and committed.")
+		} else {
+			log.Println("[INFO] Dry run complete; no changes committed.")
+		}
+		return nil
+	})
+}
+
+// ApplyTransforms copies baseMSI to outMSI and applies the transforms to the
+// copy in order, committing after each one and printing what it changed;
+// baseMSI is never modified. Binary transforms are decoded against the
+// result so far. If showDiff is set, the cumulative changes against the
+// base are printed at the end. A failing transform removes the output.
+func ApplyTransforms(baseMSI, outMSI string, mstPaths []string, onConflict ConflictPolicy, showDiff bool) error {
+	return SafeExecute("ApplyTransforms", func() (err error) {
+		if baseInfo, statErr := os.Stat(baseMSI); statErr == nil {
+			if outInfo, statErr := os.Stat(outMSI); statErr == nil && os.SameFile(baseInfo, outInfo) {
+				return fmt.Errorf("output '%s' is the base package; choose another path", outMSI)
 			}
-			queries = append(queries, q)
 		}
-		if len(queries) == 0 {
-			return fmt.Errorf("no valid queries built from MST")
+		// A journal left by an earlier result would not match the copy.
+		if err := os.Remove(JournalPath(outMSI)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove stale journal: %v", err)
 		}
-
-		done := make(chan bool)
-		go retro.ShowSpinner("Applying MST transform...", done)
-
-		for _, q := range queries {
-			if interactive && !confirmQuery(q) {
-				log.Printf("[INFO] Skipped query: %s", q)
-				continue
+		if err := copyFile(baseMSI, outMSI); err != nil {
+			return err
+		}
+		defer func() {
+			if err != nil {
+				os.Remove(outMSI)
+				os.Remove(JournalPath(outMSI))
 			}
-			if dryRun {
-				log.Printf("[DRY-RUN] %s", q)
-				continue
+		}()
+
+		for i, path := range mstPaths {
+			transform, err := ReadTransform(path, outMSI)
+			if err != nil {
+				return err
 			}
-			_, err := session.ExecuteQuery(q)
+			stats, err := applyTransformFile(outMSI, transform, onConflict)
 			if err != nil {
-				close(done)
-				return fmt.Errorf("execute query '%s' failed: %v", q, err)
+				return fmt.Errorf("%s: %v", path, err)
+			}
+			line := fmt.Sprintf("[%d/%d] %s: %d operation(s) applied", i+1, len(mstPaths), filepath.Base(path), len(stats.applied))
+			if len(stats.applied) > 0 {
+				line += " (" + transformOpSummary(stats.applied) + ")"
 			}
+			if stats.conflicts > 0 {
+				line += fmt.Sprintf(", %d conflict(s) skipped", stats.conflicts)
+			}
+			if stats.suppressed > 0 {
+				line += fmt.Sprintf(", %d suppressed", stats.suppressed)
+			}
+			fmt.Println(line)
 		}
-		close(done)
 
-		if !dryRun {
-			if err := session.Commit(); err != nil {
-				return fmt.Errorf("commit failed: %v", err)
+		if showDiff {
+			diff, err := DiffMSI(baseMSI, outMSI)
+			if err != nil {
+				return err
 			}
-			log.Println("[INFO] Transform applied and committed.")
-		} else {
-			log.Println("[INFO] Dry run complete; no changes committed.")
+			fmt.Println()
+			return WriteDiff(os.Stdout, diff, "text")
 		}
 		return nil
 	})
 }
 
-func loadDiffLines(mstFile string) ([]string, error) {
-	f, err := os.Open(mstFile)
+// applyTransformFile applies a transform to the MSI at msiPath and commits.
+func applyTransformFile(msiPath string, transform *TextTransform, onConflict ConflictPolicy) (*transformStats, error) {
+	session, err := OpenMsiSession(msiPath, 1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open MSI session: %v", err)
+	}
+	defer session.Close()
+	stats, err := session.applyTransform(transform, false, false, onConflict)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open MST file: %v", err)
+		return nil, err
+	}
+	if err := session.Commit(); err != nil {
+		return nil, fmt.Errorf("commit failed: %v", err)
+	}
+	return stats, nil
+}
+
+// transformOpSummary counts operations per table, e.g.
+// "CREATE Custom, Property +1 ~2".
+func transformOpSummary(ops []TransformOp) string {
+	var parts []string
+	counts := map[string]map[ChangeOp]int{}
+	var tables []string
+	for _, op := range ops {
+		switch op.Op {
+		case OpCreate, OpDrop:
+			parts = append(parts, fmt.Sprintf("%s %s", op.Op, op.Table))
+			continue
+		case OpAlter:
+			parts = append(parts, fmt.Sprintf("ALTER %s.%s", op.Table, op.Columns[0].Name))
+			continue
+		}
+		if counts[op.Table] == nil {
+			counts[op.Table] = map[ChangeOp]int{}
+			tables = append(tables, op.Table)
+		}
+		counts[op.Table][op.Op]++
+	}
+	for _, table := range tables {
+		part := table
+		for _, op := range []ChangeOp{OpInsert, OpUpdate, OpDelete} {
+			if n := counts[table][op]; n > 0 {
+				part += fmt.Sprintf(" %s%d", op, n)
+			}
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// transformStats counts what applying a transform did.
+type transformStats struct {
+	applied    []TransformOp
+	conflicts  int // skipped by the conflict policy
+	suppressed int // skipped because the transform suppresses the error
+}
+
+// applyTransform validates the transform against the session's package and
+// applies its operations without committing them.
+func (s *MsiSession) applyTransform(transform *TextTransform, dryRun, interactive bool, onConflict ConflictPolicy) (*transformStats, error) {
+	ops := transform.Ops
+	if err := transform.Checks.validate(transform.Base, s.transformBase()); err != nil {
+		return nil, err
+	}
+	s.checkTransformBase(transform.Base)
+	if err := s.resolveDeleteKeys(ops); err != nil {
+		return nil, err
+	}
+
+	done := make(chan bool)
+	go retro.ShowSpinner("Applying MST transform...", done)
+	defer close(done)
+
+	stats := &transformStats{}
+	ask := interactive
+	for _, op := range ops {
+		found, err := s.transformConflicts(op)
+		if err != nil {
+			return nil, err
+		}
+		if len(found) > 0 {
+			conflict := unsuppressedConflict(found, transform.Checks.Suppress)
+			if conflict == "" {
+				stats.suppressed++
+				logInfo(fmt.Sprintf("suppressed, skipping: %s", found[0].msg))
+				continue
+			}
+			stats.conflicts++
+			switch onConflict {
+			case ConflictFail:
+				return nil, fmt.Errorf("conflict: %s; no changes committed", conflict)
+			case ConflictWarn:
+				logWarn(fmt.Sprintf("conflict, skipping: %s", conflict))
+			default:
+				logInfo(fmt.Sprintf("conflict, skipping: %s", conflict))
+			}
+			continue
+		}
+		if ask {
+			choice, err := Prompts.ConfirmEach(fmt.Sprintf("\nOperation:\n  %s\nApply?", op))
+			if err != nil {
+				return nil, err
+			}
+			switch choice {
+			case ChoiceNo:
+				log.Printf("[INFO] Skipped operation: %s", op)
+				continue
+			case ChoiceAll:
+				ask = false
+			case ChoiceQuit:
+				return nil, fmt.Errorf("transform cancelled by user; no changes committed")
+			}
+		}
+		if dryRun {
+			log.Printf("[DRY-RUN] %s", op)
+			continue
+		}
+		if err := s.applyTransformOp(op); err != nil {
+			return nil, fmt.Errorf("operation '%s' failed: %v", op, err)
+		}
+		stats.applied = append(stats.applied, op)
+	}
+	return stats, nil
+}
+
+// TransformOp is one operation of a text transform.
+type TransformOp struct {
+	Op      ChangeOp
+	Table   string
+	Values  []string          // full row, for inserts and v1 deletes
+	Key     []string          // primary key, for updates and v2 deletes
+	Set     map[string]string // new column values, for updates
+	Columns []ColumnInfo      // table columns for CREATE, the added column for ALTER
+}
+
+func (o TransformOp) String() string {
+	return formatTransformOp(o)
+}
+
+// applyTransformOp writes one transform operation to the database.
+// Inserts and updates bind values by column type, so integers and nulls
+// round-trip exactly.
+func (s *MsiSession) applyTransformOp(op TransformOp) error {
+	switch op.Op {
+	case OpInsert:
+		return s.InsertRow(op.Table, op.Values)
+	case OpUpdate:
+		return s.UpdateRow(op.Table, op.Key, op.Set)
+	case OpDelete:
+		return s.DeleteRow(op.Table, op.Key)
+	case OpCreate:
+		return s.ExecuteStatement(createTableSQL(op.Table, op.Columns))
+	case OpAlter:
+		return s.ExecuteStatement(fmt.Sprintf("ALTER TABLE `%s` ADD %s", op.Table, columnSQL(op.Columns[0])))
+	case OpDrop:
+		return s.ExecuteStatement(fmt.Sprintf("DROP TABLE `%s`", op.Table))
+	}
+	return fmt.Errorf("unknown operation '%s'", op.Op)
+}
+
+// resolveDeleteKeys fills in the primary key of v1 deletes, which carry the
+// whole row, from the table's key columns. Tables the transform creates
+// are skipped; they have no rows to delete yet.
+func (s *MsiSession) resolveDeleteKeys(ops []TransformOp) error {
+	for i, op := range ops {
+		if op.Op != OpDelete || op.Key != nil || !s.HasTable(op.Table) {
+			continue
+		}
+		schema, err := s.GetTableSchema(op.Table)
+		if err != nil {
+			return err
+		}
+		if ops[i].Key, err = rowKey(schema, op.Values); err != nil {
+			return fmt.Errorf("%s: %v", op, err)
+		}
+	}
+	return nil
+}
+
+// rowKey extracts the primary key from a full row of schema's table.
+func rowKey(schema *TableSchema, values []string) ([]string, error) {
+	if len(values) != len(schema.Columns) {
+		return nil, fmt.Errorf("row has %d values, table '%s' has %d columns", len(values), schema.Name, len(schema.Columns))
+	}
+	return schema.KeyValues(TableRow{Columns: values}), nil
+}
+
+// transformConflict is one reason an operation cannot apply as written,
+// with the MSITRANSFORM_ERROR_* bit (or transformErrorTypeMismatch) that
+// suppresses it.
+type transformConflict struct {
+	flag int
+	msg  string
+}
+
+// unsuppressedConflict returns the first conflict that suppress does not
+// cover, or "" when all of them are suppressed.
+func unsuppressedConflict(conflicts []transformConflict, suppress int) string {
+	for _, c := range conflicts {
+		if suppress&c.flag == 0 {
+			return c.msg
+		}
+	}
+	return ""
+}
+
+// transformConflicts describes why op cannot apply to the current database:
+// a delete or update whose row does not exist, an insert whose row does, a
+// CREATE of an existing table or ALTER of an existing column, an ALTER or
+// DROP of a missing table, or values and columns that do not match the
+// table's schema. Rows of tables that do not exist yet are not checked.
+func (s *MsiSession) transformConflicts(op TransformOp) ([]transformConflict, error) {
+	var conflicts []transformConflict
+	add := func(flag int, format string, args ...interface{}) {
+		conflicts = append(conflicts, transformConflict{flag, fmt.Sprintf("%s: ", op) + fmt.Sprintf(format, args...)})
+	}
+	exists := s.HasTable(op.Table)
+	var schema *TableSchema
+	if exists {
+		var err error
+		if schema, err = s.GetTableSchema(op.Table); err != nil {
+			return nil, err
+		}
+	}
+	switch op.Op {
+	case OpInsert:
+		if !exists {
+			return nil, nil
+		}
+		if len(op.Values) != len(schema.Columns) {
+			add(transformErrorTypeMismatch, "row has %d values, table '%s' has %d columns", len(op.Values), op.Table, len(schema.Columns))
+			return conflicts, nil
+		}
+		for i, col := range schema.Columns {
+			if err := checkColumnValue(col, op.Values[i]); err != nil {
+				add(transformErrorTypeMismatch, "%v", err)
+			}
+		}
+		row, err := s.FindRow(op.Table, schema.KeyValues(TableRow{Columns: op.Values}))
+		if err != nil {
+			return nil, err
+		}
+		if row != nil {
+			add(transformErrorAddExistingRow, "'%s' already has a row with key [%s]", op.Table, strings.Join(schema.KeyValues(*row), ","))
+		}
+	case OpDelete, OpUpdate:
+		flag := transformErrorDelMissingRow
+		if op.Op == OpUpdate {
+			flag = transformErrorUpdateMissingRow
+		}
+		if !exists {
+			add(flag, "table '%s' does not exist", op.Table)
+			return conflicts, nil
+		}
+		for _, name := range sortedKeys(op.Set) {
+			col, _, ok := schema.Column(name)
+			if !ok {
+				add(transformErrorTypeMismatch, "table '%s' has no column '%s'", op.Table, name)
+			} else if err := checkColumnValue(col, op.Set[name]); err != nil {
+				add(transformErrorTypeMismatch, "%v", err)
+			}
+		}
+		row, err := s.FindRow(op.Table, op.Key)
+		if err != nil {
+			return nil, err
+		}
+		if row == nil {
+			add(flag, "no '%s' row with key [%s]", op.Table, strings.Join(op.Key, ","))
+		}
+	case OpCreate:
+		if !exists {
+			return nil, nil
+		}
+		add(transformErrorAddExistingTable, "table '%s' already exists", op.Table)
+		if !sameColumns(schema.Columns, op.Columns) {
+			add(transformErrorTypeMismatch, "existing table '%s' has columns (%s)", op.Table, formatColumnDefs(schema.Columns))
+		}
+	case OpAlter:
+		if !exists {
+			add(transformErrorDelMissingTable, "table '%s' does not exist", op.Table)
+			return conflicts, nil
+		}
+		if col, _, ok := schema.Column(op.Columns[0].Name); ok {
+			add(transformErrorAddExistingTable, "table '%s' already has column '%s'", op.Table, col.Name)
+			if !sameColumns([]ColumnInfo{col}, op.Columns) {
+				add(transformErrorTypeMismatch, "existing column is %s", formatColumnDef(col))
+			}
+		}
+	case OpDrop:
+		if !exists {
+			add(transformErrorDelMissingTable, "table '%s' does not exist", op.Table)
+		}
+	}
+	return conflicts, nil
+}
+
+// sameColumns reports whether two column lists have the same names, types
+// and keys.
+func sameColumns(a, b []ColumnInfo) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Name != b[i].Name || a[i].Type != b[i].Type || a[i].Key != b[i].Key {
+			return false
+		}
+	}
+	return true
+}
+
+// checkTransformBase warns when the transform was generated against a
+// different package than the one it is applied to.
+func (s *MsiSession) checkTransformBase(base TransformBase) {
+	target := s.transformBase().fields()
+	for i, f := range base.fields() {
+		if f[1] != "" && target[i][1] != "" && !strings.EqualFold(f[1], target[i][1]) {
+			logWarn(fmt.Sprintf("transform targets %s %s but the package has %s", f[0], f[1], target[i][1]))
+		}
+	}
+}
+
+// columnSQL renders a column definition such as "s72" or "I2" as MSI SQL.
+func columnSQL(col ColumnInfo) string {
+	var typ string
+	switch {
+	case col.IsBinary():
+		typ = "OBJECT"
+	case col.IsInteger() && col.Width() == 2:
+		typ = "SHORT"
+	case col.IsInteger():
+		typ = "LONG"
+	case col.Width() == 0:
+		typ = "LONGCHAR"
+	default:
+		typ = fmt.Sprintf("CHAR(%d)", col.Width())
+	}
+	sql := fmt.Sprintf("`%s` %s", col.Name, typ)
+	if !col.IsNullable() {
+		sql += " NOT NULL"
+	}
+	if col.IsLocalizable() {
+		sql += " LOCALIZABLE"
+	}
+	return sql
+}
+
+// createTableSQL builds the CREATE TABLE statement for columns.
+func createTableSQL(table string, columns []ColumnInfo) string {
+	defs := make([]string, len(columns))
+	var keys []string
+	for i, col := range columns {
+		defs[i] = columnSQL(col)
+		if col.Key {
+			keys = append(keys, col.Name)
+		}
 	}
-	defer f.Close()
+	return fmt.Sprintf("CREATE TABLE `%s` (%s PRIMARY KEY %s)", table, strings.Join(defs, ", "), quotedColumns(keys))
+}
 
-	var lines []string
-	sc := bufio.NewScanner(f)
-	for sc.Scan() {
-		txt := strings.TrimSpace(sc.Text())
-		if txt != "" {
-			lines = append(lines, txt)
+// ParseTransformLine parses one line of a text transform: "+ Table => v1|v2"
+// inserts a row, "- Table => v1|v2" deletes one and
+// "~ Table => k1|k2 => Col=value|Col=value" updates the row with that
+// primary key.
+func ParseTransformLine(line string) (TransformOp, error) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, string(OpUpdate)) {
+		op, table, values, err := parseDiffLine(line)
+		if err != nil {
+			return TransformOp{}, err
 		}
+		return TransformOp{Op: ChangeOp(op), Table: table, Values: values}, nil
 	}
-	if err := sc.Err(); err != nil {
-		return nil, fmt.Errorf("error reading MST: %v", err)
+	table, key, set, err := parseUpdateLine(line)
+	if err != nil {
+		return TransformOp{}, err
 	}
-	return lines, nil
+	return TransformOp{Op: OpUpdate, Table: table, Key: key, Set: set}, nil
 }
 
 func parseDiffLine(line string) (op, table string, values []string, err error) {
@@ -137,35 +542,30 @@ func parseDiffLine(line string) (op, table string, values []string, err error) {
 	return
 }
 
-func buildSQL(op, table string, vals []string) string {
-	switch op {
-	case "+":
-		// Insert
-		parts := make([]string, len(vals))
-		for i, v := range vals {
-			parts[i] = fmt.Sprintf("'%s'", escape(v))
-		}
-		return fmt.Sprintf("INSERT INTO `%s` VALUES (%s)", table, strings.Join(parts, ","))
-	case "-":
-		// Delete
-		// Assume columns are COL1, COL2, etc., for simplicity
-		whereParts := make([]string, len(vals))
-		for i, v := range vals {
-			whereParts[i] = fmt.Sprintf("COL%d='%s'", i+1, escape(v))
+// parseUpdateLine parses "~ Table => k1|k2 => Col=value|Col=value".
+func parseUpdateLine(line string) (table string, key []string, set map[string]string, err error) {
+	parts := strings.SplitN(strings.TrimSpace(line)[1:], "=>", 3)
+	if len(parts) != 3 {
+		err = fmt.Errorf("update '%s' needs 'Table => key => Column=value'", line)
+		return
+	}
+	table = strings.TrimSpace(parts[0])
+	if table == "" {
+		err = fmt.Errorf("no table name found in '%s'", line)
+		return
+	}
+	for _, k := range strings.Split(parts[1], "|") {
+		key = append(key, strings.TrimSpace(k))
+	}
+	set = map[string]string{}
+	for _, assign := range strings.Split(parts[2], "|") {
+		col, value, ok := strings.Cut(assign, "=")
+		col = strings.TrimSpace(col)
+		if !ok || col == "" {
+			err = fmt.Errorf("invalid assignment '%s' in '%s': expected Column=value", strings.TrimSpace(assign), line)
+			return
 		}
-		return fmt.Sprintf("DELETE FROM `%s` WHERE %s", table, strings.Join(whereParts, " AND "))
+		set[col] = strings.TrimSpace(value)
 	}
-	return ""
-}
-
-func confirmQuery(q string) bool {
-	fmt.Printf("\nQuery:\n  %s\nApply? (y/n): ", q)
-	in := bufio.NewReader(os.Stdin)
-	line, _ := in.ReadString('\n')
-	line = strings.TrimSpace(strings.ToLower(line))
-	return line == "y" || line == "yes"
-}
-
-func escape(s string) string {
-	return strings.ReplaceAll(s, "'", "''")
+	return
 }
\ No newline at end of file
@@ -137,3 +137,65 @@ func TestParseDiffLine_LeadingTrailingPipes(t *testing.T) {
 	}
 }
 
+func TestParseTransformLine(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    TransformOp
+		wantErr bool
+	}{
+		{line: "+ Property => A|1", want: TransformOp{Op: OpInsert, Table: "Property", Values: []string{"A", "1"}}},
+		{line: "- Property => A|1", want: TransformOp{Op: OpDelete, Table: "Property", Values: []string{"A", "1"}}},
+		{line: "~ Registry => R1 => Value=x", want: TransformOp{Op: OpUpdate, Table: "Registry", Key: []string{"R1"}, Set: map[string]string{"Value": "x"}}},
+		{line: "~ Registry => R1", wantErr: true},
+		{line: "~ Registry => R1 => Value", wantErr: true},
+		{line: "~  => R1 => Value=x", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseTransformLine(tt.line)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: expected error %v, got %v", tt.line, tt.wantErr, err)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: expected %+v, got %+v", tt.line, tt.want, got)
+		}
+	}
+}
+
+func TestRowKey(t *testing.T) {
+	schema := &TableSchema{Name: "Registry", Columns: []ColumnInfo{
+		{Name: "Registry", Type: "s72", Key: true},
+		{Name: "Root", Type: "i2"},
+		{Name: "Key", Type: "l255"},
+	}}
+	key, err := rowKey(schema, []string{"Reg1", "2", `Software\Vendor`})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !reflect.DeepEqual(key, []string{"Reg1"}) {
+		t.Errorf("Expected key [Reg1], got %v", key)
+	}
+	if _, err := rowKey(schema, []string{"Reg1", "2"}); err == nil {
+		t.Error("Expected an error for a short row")
+	}
+}
+
+func TestTransformOpSummary(t *testing.T) {
+	ops := []TransformOp{
+		{Op: OpCreate, Table: "Custom"},
+		{Op: OpInsert, Table: "Property"},
+		{Op: OpUpdate, Table: "Property"},
+		{Op: OpInsert, Table: "Custom"},
+		{Op: OpAlter, Table: "Property", Columns: []ColumnInfo{{Name: "Extra", Type: "S0"}}},
+		{Op: OpUpdate, Table: "Property"},
+		{Op: OpDelete, Table: "Property"},
+		{Op: OpDrop, Table: "Legacy"},
+	}
+	want := "CREATE Custom, ALTER Property.Extra, DROP Legacy, Property +1 ~2 -1, Custom +1"
+	if got := transformOpSummary(ops); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	if got := transformOpSummary(nil); got != "" {
+		t.Errorf("Expected an empty summary, got %q", got)
+	}
+}
@@ -12,21 +12,27 @@ import (
 // naming it with the original filename and a timestamp.
 func BackupMSI(msiPath string) (string, error) {
 	backupPath := fmt.Sprintf("%s.bak.%s", msiPath, time.Now().Format("20060102_150405"))
-	srcFile, err := os.Open(msiPath)
-	if err != nil {
-		return "", fmt.Errorf("failed to open MSI for backup: %v", err)
+	if err := copyFile(msiPath, backupPath); err != nil {
+		return "", fmt.Errorf("failed to back up MSI: %v", err)
 	}
-	defer srcFile.Close()
+	return backupPath, nil
+}
 
-	dstFile, err := os.Create(backupPath)
+// copyFile copies src to dst, replacing dst if it exists.
+func copyFile(src, dst string) error {
+	srcFile, err := os.Open(src)
 	if err != nil {
-		return "", fmt.Errorf("failed to create backup file: %v", err)
+		return fmt.Errorf("failed to open '%s': %v", src, err)
 	}
-	defer dstFile.Close()
+	defer srcFile.Close()
 
-	_, err = io.Copy(dstFile, srcFile)
+	dstFile, err := os.Create(dst)
 	if err != nil {
-		return "", fmt.Errorf("failed to copy MSI to backup: %v", err)
+		return fmt.Errorf("failed to create '%s': %v", dst, err)
 	}
-	return backupPath, nil
+	if _, err := io.Copy(dstFile, srcFile); err != nil {
+		dstFile.Close()
+		return fmt.Errorf("failed to copy '%s' to '%s': %v", src, dst, err)
+	}
+	return dstFile.Close()
 }
@@ -0,0 +1,551 @@
+// core/cfb.go
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"unicode"
+	"unicode/utf16"
+)
+
+// Installer packages, patches and binary transforms are Compound File Binary
+// documents (structured storage). Reading them directly lets msicrafter look
+// inside transforms without going through the Windows Installer service.
+
+const (
+	cfbFreeSect   = 0xFFFFFFFF
+	cfbEndOfChain = 0xFFFFFFFE
+	cfbFatSect    = 0xFFFFFFFD
+	cfbDifSect    = 0xFFFFFFFC
+	cfbNoStream   = 0xFFFFFFFF
+
+	cfbTypeStorage = 1
+	cfbTypeStream  = 2
+	cfbTypeRoot    = 5
+
+	cfbHeaderSize   = 512
+	cfbDirEntrySize = 128
+	cfbHeaderDIFAT  = 109
+
+	cfbSectorSize     = 512 // sector size of the version 3 files we write
+	cfbMiniSectorSize = 64
+	cfbMiniCutoff     = 4096 // smaller streams live in the mini stream
+)
+
+var cfbSignature = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+
+// cfbStorage is a storage of a compound file held in memory: its streams
+// and nested storages by name.
+type cfbStorage struct {
+	CLSID    [16]byte
+	Streams  map[string][]byte
+	Storages map[string]*cfbStorage
+}
+
+func newCFBStorage() *cfbStorage {
+	return &cfbStorage{Streams: map[string][]byte{}, Storages: map[string]*cfbStorage{}}
+}
+
+// isCFB reports whether data starts with the compound file signature.
+func isCFB(data []byte) bool {
+	return len(data) >= len(cfbSignature) && bytes.Equal(data[:len(cfbSignature)], cfbSignature)
+}
+
+// cfbDirEntry is one entry of the directory stream.
+type cfbDirEntry struct {
+	name               string
+	kind               byte
+	left, right, child uint32
+	clsid              [16]byte
+	start              uint32
+	size               uint64
+}
+
+// cfbReader resolves sector chains of a compound file image.
+type cfbReader struct {
+	data           []byte
+	sectorSize     int
+	miniSectorSize int
+	miniCutoff     uint64
+	fat            []uint32
+	miniFat        []uint32
+	miniStream     []byte
+	entries        []cfbDirEntry
+}
+
+// readCFB parses a compound file image into an in-memory storage tree.
+func readCFB(data []byte) (*cfbStorage, error) {
+	if len(data) < cfbHeaderSize || !isCFB(data) {
+		return nil, fmt.Errorf("not a compound file")
+	}
+	le := binary.LittleEndian
+	major := le.Uint16(data[0x1A:])
+	sectorShift := le.Uint16(data[0x1E:])
+	miniShift := le.Uint16(data[0x20:])
+	if (sectorShift != 9 && sectorShift != 12) || miniShift != 6 {
+		return nil, fmt.Errorf("unsupported compound file sector size 2^%d", sectorShift)
+	}
+	r := &cfbReader{
+		data:           data,
+		sectorSize:     1 << sectorShift,
+		miniSectorSize: 1 << miniShift,
+		miniCutoff:     uint64(le.Uint32(data[0x38:])),
+	}
+	numFat := int(le.Uint32(data[0x2C:]))
+	dirStart := le.Uint32(data[0x30:])
+	miniFatStart := le.Uint32(data[0x3C:])
+	difatNext := le.Uint32(data[0x44:])
+	numDifat := int(le.Uint32(data[0x48:]))
+
+	// The header holds the first 109 FAT sector locations; the rest are in
+	// a chain of DIFAT sectors whose last entry points to the next one.
+	var fatSectors []uint32
+	for i := 0; i < cfbHeaderDIFAT; i++ {
+		if s := le.Uint32(data[0x4C+4*i:]); s != cfbFreeSect {
+			fatSectors = append(fatSectors, s)
+		}
+	}
+	perDifat := r.sectorSize/4 - 1
+	for i := 0; i < numDifat && difatNext < cfbDifSect; i++ {
+		sec, err := r.sector(difatNext)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read DIFAT: %v", err)
+		}
+		if len(sec) < r.sectorSize {
+			return nil, fmt.Errorf("failed to read DIFAT: sector %d is truncated", difatNext)
+		}
+		for j := 0; j < perDifat; j++ {
+			if s := le.Uint32(sec[4*j:]); s != cfbFreeSect {
+				fatSectors = append(fatSectors, s)
+			}
+		}
+		difatNext = le.Uint32(sec[4*perDifat:])
+	}
+	if len(fatSectors) > numFat {
+		fatSectors = fatSectors[:numFat]
+	}
+	for _, s := range fatSectors {
+		sec, err := r.sector(s)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read FAT: %v", err)
+		}
+		r.fat = append(r.fat, bytesToUint32s(sec)...)
+	}
+
+	dir, err := r.chain(dirStart, r.fat, r.sector)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %v", err)
+	}
+	for off := 0; off+cfbDirEntrySize <= len(dir); off += cfbDirEntrySize {
+		r.entries = append(r.entries, parseCFBDirEntry(dir[off:off+cfbDirEntrySize], major))
+	}
+	if len(r.entries) == 0 || r.entries[0].kind != cfbTypeRoot {
+		return nil, fmt.Errorf("compound file has no root entry")
+	}
+
+	if miniFatStart < cfbDifSect {
+		miniFat, err := r.chain(miniFatStart, r.fat, r.sector)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read mini FAT: %v", err)
+		}
+		r.miniFat = bytesToUint32s(miniFat)
+	}
+	root := r.entries[0]
+	if root.start < cfbDifSect {
+		ms, err := r.chain(root.start, r.fat, r.sector)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read mini stream: %v", err)
+		}
+		r.miniStream = ms
+	}
+	return r.storage(0, map[uint32]bool{})
+}
+
+func parseCFBDirEntry(b []byte, major uint16) cfbDirEntry {
+	le := binary.LittleEndian
+	e := cfbDirEntry{
+		kind:  b[0x42],
+		left:  le.Uint32(b[0x44:]),
+		right: le.Uint32(b[0x48:]),
+		child: le.Uint32(b[0x4C:]),
+		start: le.Uint32(b[0x74:]),
+		size:  le.Uint64(b[0x78:]),
+	}
+	copy(e.clsid[:], b[0x50:0x60])
+	if major == 3 {
+		// Version 3 files may leave garbage in the high half of the size.
+		e.size &= 0xFFFFFFFF
+	}
+	nameLen := int(le.Uint16(b[0x40:]))/2 - 1
+	if nameLen > 31 {
+		nameLen = 31
+	}
+	if nameLen > 0 {
+		units := make([]uint16, nameLen)
+		for i := range units {
+			units[i] = le.Uint16(b[2*i:])
+		}
+		e.name = string(utf16.Decode(units))
+	}
+	return e
+}
+
+// sector returns the contents of a regular sector. The final sector of a
+// file may be short when the writer did not pad it.
+func (r *cfbReader) sector(n uint32) ([]byte, error) {
+	off := (int(n) + 1) * r.sectorSize
+	if n >= cfbDifSect || off >= len(r.data) {
+		return nil, fmt.Errorf("sector %d is outside the file", n)
+	}
+	end := off + r.sectorSize
+	if end > len(r.data) {
+		end = len(r.data)
+	}
+	return r.data[off:end], nil
+}
+
+// miniSector returns the contents of a sector of the mini stream.
+func (r *cfbReader) miniSector(n uint32) ([]byte, error) {
+	off := int(n) * r.miniSectorSize
+	if off+r.miniSectorSize > len(r.miniStream) {
+		return nil, fmt.Errorf("mini sector %d is outside the mini stream", n)
+	}
+	return r.miniStream[off : off+r.miniSectorSize], nil
+}
+
+// chain concatenates the sectors of the chain starting at start.
+func (r *cfbReader) chain(start uint32, fat []uint32, read func(uint32) ([]byte, error)) ([]byte, error) {
+	var out []byte
+	for n, steps := start, 0; n != cfbEndOfChain; steps++ {
+		if int(n) >= len(fat) || steps > len(fat) {
+			return nil, fmt.Errorf("broken sector chain at %d", n)
+		}
+		sec, err := read(n)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, sec...)
+		n = fat[n]
+	}
+	return out, nil
+}
+
+// streamData reads the contents of a stream entry.
+func (r *cfbReader) streamData(e cfbDirEntry) ([]byte, error) {
+	if e.size == 0 {
+		return []byte{}, nil
+	}
+	var data []byte
+	var err error
+	if e.size < r.miniCutoff {
+		data, err = r.chain(e.start, r.miniFat, r.miniSector)
+	} else {
+		data, err = r.chain(e.start, r.fat, r.sector)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(data)) < e.size {
+		return nil, fmt.Errorf("stream is %d bytes, expected %d", len(data), e.size)
+	}
+	return data[:e.size], nil
+}
+
+// storage loads the storage entry id and everything below it.
+func (r *cfbReader) storage(id uint32, seen map[uint32]bool) (*cfbStorage, error) {
+	st := newCFBStorage()
+	st.CLSID = r.entries[id].clsid
+	var walk func(n uint32) error
+	walk = func(n uint32) error {
+		if n == cfbNoStream {
+			return nil
+		}
+		if int(n) >= len(r.entries) || seen[n] {
+			return fmt.Errorf("corrupt directory tree at entry %d", n)
+		}
+		seen[n] = true
+		e := r.entries[n]
+		if err := walk(e.left); err != nil {
+			return err
+		}
+		switch e.kind {
+		case cfbTypeStream:
+			data, err := r.streamData(e)
+			if err != nil {
+				return fmt.Errorf("failed to read stream %q: %v", e.name, err)
+			}
+			st.Streams[e.name] = data
+		case cfbTypeStorage:
+			sub, err := r.storage(n, seen)
+			if err != nil {
+				return err
+			}
+			st.Storages[e.name] = sub
+		}
+		return walk(e.right)
+	}
+	if err := walk(r.entries[id].child); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+func bytesToUint32s(b []byte) []uint32 {
+	out := make([]uint32, len(b)/4)
+	for i := range out {
+		out[i] = binary.LittleEndian.Uint32(b[4*i:])
+	}
+	return out
+}
+
+// cfbNameLess orders directory siblings: shorter names first, then by
+// upper-cased UTF-16 code units.
+func cfbNameLess(a, b string) bool {
+	ua, ub := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+	if len(ua) != len(ub) {
+		return len(ua) < len(ub)
+	}
+	for i := range ua {
+		ca, cb := unicode.ToUpper(rune(ua[i])), unicode.ToUpper(rune(ub[i]))
+		if ca != cb {
+			return ca < cb
+		}
+	}
+	return false
+}
+
+// writeCFB serializes a storage tree as a version 3 compound file with
+// 512-byte sectors. Streams under 4096 bytes go to the mini stream.
+func writeCFB(root *cfbStorage) []byte {
+	type node struct {
+		entry cfbDirEntry
+		data  []byte
+	}
+	nodes := []*node{{entry: cfbDirEntry{name: "Root Entry", kind: cfbTypeRoot, clsid: root.CLSID}}}
+	// balance links the sorted siblings into a binary search tree and
+	// returns its root. All entries are black, which readers accept.
+	var balance func(ids []uint32) uint32
+	balance = func(ids []uint32) uint32 {
+		if len(ids) == 0 {
+			return cfbNoStream
+		}
+		mid := len(ids) / 2
+		n := nodes[ids[mid]]
+		n.entry.left = balance(ids[:mid])
+		n.entry.right = balance(ids[mid+1:])
+		return ids[mid]
+	}
+	var build func(st *cfbStorage) uint32
+	build = func(st *cfbStorage) uint32 {
+		var names []string
+		for name := range st.Streams {
+			names = append(names, name)
+		}
+		for name := range st.Storages {
+			names = append(names, name)
+		}
+		sort.Slice(names, func(i, j int) bool { return cfbNameLess(names[i], names[j]) })
+		ids := make([]uint32, len(names))
+		for i, name := range names {
+			ids[i] = uint32(len(nodes))
+			n := &node{entry: cfbDirEntry{name: name, child: cfbNoStream}}
+			nodes = append(nodes, n)
+			if sub, ok := st.Storages[name]; ok {
+				n.entry.kind = cfbTypeStorage
+				n.entry.clsid = sub.CLSID
+				n.entry.child = build(sub)
+			} else {
+				n.entry.kind = cfbTypeStream
+				n.data = st.Streams[name]
+			}
+		}
+		return balance(ids)
+	}
+	nodes[0].entry.left, nodes[0].entry.right = cfbNoStream, cfbNoStream
+	nodes[0].entry.child = build(root)
+
+	// Small streams are packed into the mini stream in 64-byte sectors.
+	var miniStream []byte
+	var miniFat []uint32
+	type run struct {
+		node    *node
+		sectors int
+	}
+	var large []run
+	for _, n := range nodes[1:] {
+		size := len(n.data)
+		n.entry.size = uint64(size)
+		n.entry.start = cfbEndOfChain
+		if n.entry.kind != cfbTypeStream || size == 0 {
+			continue
+		}
+		if size >= cfbMiniCutoff {
+			large = append(large, run{n, sectorsFor(size, cfbSectorSize)})
+			continue
+		}
+		n.entry.start = uint32(len(miniFat))
+		count := sectorsFor(size, cfbMiniSectorSize)
+		for i := 1; i < count; i++ {
+			miniFat = append(miniFat, uint32(len(miniFat))+1)
+		}
+		miniFat = append(miniFat, cfbEndOfChain)
+		padded := make([]byte, count*cfbMiniSectorSize)
+		copy(padded, n.data)
+		miniStream = append(miniStream, padded...)
+	}
+
+	perSector := cfbSectorSize / 4
+	miniFatSectors := sectorsFor(4*len(miniFat), cfbSectorSize)
+	dirSectors := sectorsFor(len(nodes)*cfbDirEntrySize, cfbSectorSize)
+	miniStreamSectors := sectorsFor(len(miniStream), cfbSectorSize)
+	dataSectors := miniFatSectors + dirSectors + miniStreamSectors
+	for _, r := range large {
+		dataSectors += r.sectors
+	}
+	// The FAT must also cover its own sectors and the DIFAT sectors that
+	// list FAT sectors beyond the 109 the header holds.
+	fatSectors, difatSectors := 1, 0
+	for {
+		difatSectors = 0
+		if fatSectors > cfbHeaderDIFAT {
+			difatSectors = sectorsFor(4*(fatSectors-cfbHeaderDIFAT), cfbSectorSize-4)
+		}
+		if dataSectors+fatSectors+difatSectors <= fatSectors*perSector {
+			break
+		}
+		fatSectors++
+	}
+
+	fat := make([]uint32, fatSectors*perSector)
+	for i := range fat {
+		fat[i] = cfbFreeSect
+	}
+	next := uint32(0)
+	for i := 0; i < fatSectors; i++ {
+		fat[next] = cfbFatSect
+		next++
+	}
+	difatStart := next
+	for i := 0; i < difatSectors; i++ {
+		fat[next] = cfbDifSect
+		next++
+	}
+	chain := func(count int) uint32 {
+		if count == 0 {
+			return cfbEndOfChain
+		}
+		start := next
+		for i := 0; i < count; i++ {
+			fat[next] = next + 1
+			next++
+		}
+		fat[next-1] = cfbEndOfChain
+		return start
+	}
+	miniFatStart := chain(miniFatSectors)
+	dirStart := chain(dirSectors)
+	nodes[0].entry.start = chain(miniStreamSectors)
+	nodes[0].entry.size = uint64(len(miniStream))
+	for _, r := range large {
+		r.node.entry.start = chain(r.sectors)
+	}
+
+	le := binary.LittleEndian
+	out := make([]byte, cfbHeaderSize+int(next)*cfbSectorSize)
+	copy(out, cfbSignature)
+	le.PutUint16(out[0x18:], 0x3E)
+	le.PutUint16(out[0x1A:], 3)
+	le.PutUint16(out[0x1C:], 0xFFFE)
+	le.PutUint16(out[0x1E:], 9)
+	le.PutUint16(out[0x20:], 6)
+	le.PutUint32(out[0x2C:], uint32(fatSectors))
+	le.PutUint32(out[0x30:], dirStart)
+	le.PutUint32(out[0x38:], cfbMiniCutoff)
+	le.PutUint32(out[0x3C:], miniFatStart)
+	le.PutUint32(out[0x40:], uint32(miniFatSectors))
+	le.PutUint32(out[0x44:], cfbEndOfChain)
+	if difatSectors > 0 {
+		le.PutUint32(out[0x44:], difatStart)
+	}
+	le.PutUint32(out[0x48:], uint32(difatSectors))
+	sectorAt := func(n uint32) []byte {
+		off := cfbHeaderSize + int(n)*cfbSectorSize
+		return out[off : off+cfbSectorSize]
+	}
+	for i := 0; i < cfbHeaderDIFAT; i++ {
+		v := uint32(cfbFreeSect)
+		if i < fatSectors {
+			v = uint32(i)
+		}
+		le.PutUint32(out[0x4C+4*i:], v)
+	}
+	for d := 0; d < difatSectors; d++ {
+		sec := sectorAt(difatStart + uint32(d))
+		for j := 0; j < perSector-1; j++ {
+			v := uint32(cfbFreeSect)
+			if f := cfbHeaderDIFAT + d*(perSector-1) + j; f < fatSectors {
+				v = uint32(f)
+			}
+			le.PutUint32(sec[4*j:], v)
+		}
+		nextDifat := uint32(cfbEndOfChain)
+		if d+1 < difatSectors {
+			nextDifat = difatStart + uint32(d+1)
+		}
+		le.PutUint32(sec[4*(perSector-1):], nextDifat)
+	}
+	for i, v := range fat {
+		le.PutUint32(out[cfbHeaderSize+4*i:], v)
+	}
+	for i := 0; i < miniFatSectors*perSector; i++ {
+		v := uint32(cfbFreeSect)
+		if i < len(miniFat) {
+			v = miniFat[i]
+		}
+		le.PutUint32(out[cfbHeaderSize+int(miniFatStart)*cfbSectorSize+4*i:], v)
+	}
+	dir := out[cfbHeaderSize+int(dirStart)*cfbSectorSize:]
+	for i := 0; i < dirSectors*cfbSectorSize/cfbDirEntrySize; i++ {
+		e := cfbDirEntry{left: cfbNoStream, right: cfbNoStream, child: cfbNoStream}
+		if i < len(nodes) {
+			e = nodes[i].entry
+		}
+		putCFBDirEntry(dir[i*cfbDirEntrySize:], e)
+	}
+	if miniStreamSectors > 0 {
+		copy(out[cfbHeaderSize+int(nodes[0].entry.start)*cfbSectorSize:], miniStream)
+	}
+	for _, r := range large {
+		copy(out[cfbHeaderSize+int(r.node.entry.start)*cfbSectorSize:], r.node.data)
+	}
+	return out
+}
+
+func putCFBDirEntry(b []byte, e cfbDirEntry) {
+	le := binary.LittleEndian
+	units := utf16.Encode([]rune(e.name))
+	if len(units) > 31 {
+		units = units[:31]
+	}
+	for i, u := range units {
+		le.PutUint16(b[2*i:], u)
+	}
+	if e.kind != 0 {
+		le.PutUint16(b[0x40:], uint16(2*len(units)+2))
+		b[0x43] = 1 // black
+	}
+	b[0x42] = e.kind
+	le.PutUint32(b[0x44:], e.left)
+	le.PutUint32(b[0x48:], e.right)
+	le.PutUint32(b[0x4C:], e.child)
+	copy(b[0x50:], e.clsid[:])
+	le.PutUint32(b[0x74:], e.start)
+	le.PutUint64(b[0x78:], e.size)
+}
+
+// sectorsFor returns how many sectors of the given size hold n bytes.
+func sectorsFor(n, size int) int {
+	return (n + size - 1) / size
+}
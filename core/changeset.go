@@ -0,0 +1,155 @@
+// core/changeset.go
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ChangeOp identifies the kind of row change.
+type ChangeOp string
+
+const (
+	OpInsert ChangeOp = "+"
+	OpDelete ChangeOp = "-"
+	OpUpdate ChangeOp = "~"
+)
+
+// RowChange is a single row-level change to a table. Rows are carried in
+// full so a change can be replayed, reversed or written out without going
+// back to the database.
+type RowChange struct {
+	Op      ChangeOp `json:"op"`
+	Table   string   `json:"table"`
+	Columns []string `json:"columns"`       // column names in table order
+	Key     []string `json:"key"`           // primary key values
+	Old     []string `json:"old,omitempty"` // row before the change (deletes and updates)
+	New     []string `json:"new,omitempty"` // row after the change (inserts and updates)
+}
+
+// CellChange is a single column difference within an updated row.
+type CellChange struct {
+	Column string
+	Old    string
+	New    string
+}
+
+// Cells returns the columns whose values differ between Old and New.
+func (c RowChange) Cells() []CellChange {
+	var cells []CellChange
+	for i, name := range c.Columns {
+		var oldVal, newVal string
+		if i < len(c.Old) {
+			oldVal = c.Old[i]
+		}
+		if i < len(c.New) {
+			newVal = c.New[i]
+		}
+		if oldVal != newVal {
+			cells = append(cells, CellChange{Column: name, Old: oldVal, New: newVal})
+		}
+	}
+	return cells
+}
+
+func (c RowChange) String() string {
+	key := strings.Join(c.Key, ",")
+	switch c.Op {
+	case OpInsert:
+		return fmt.Sprintf("+ %s[%s]", c.Table, key)
+	case OpDelete:
+		return fmt.Sprintf("- %s[%s]", c.Table, key)
+	}
+	var parts []string
+	for _, cell := range c.Cells() {
+		parts = append(parts, fmt.Sprintf("%s: '%s' → '%s'", cell.Column, cell.Old, cell.New))
+	}
+	return fmt.Sprintf("~ %s[%s] %s", c.Table, key, strings.Join(parts, ", "))
+}
+
+// Invert returns the change that undoes c.
+func (c RowChange) Invert() RowChange {
+	inv := RowChange{Table: c.Table, Columns: c.Columns, Key: c.Key, Old: c.New, New: c.Old}
+	switch c.Op {
+	case OpInsert:
+		inv.Op = OpDelete
+	case OpDelete:
+		inv.Op = OpInsert
+	default:
+		inv.Op = OpUpdate
+	}
+	return inv
+}
+
+// InvertChanges returns the changes that undo changes, in reverse order.
+func InvertChanges(changes []RowChange) []RowChange {
+	inverse := make([]RowChange, len(changes))
+	for i, c := range changes {
+		inverse[len(changes)-1-i] = c.Invert()
+	}
+	return inverse
+}
+
+// DiffTableRows matches two versions of a table's rows on the primary key
+// and returns the deletes, updates and inserts that turn before into after,
+// each group in key order.
+func DiffTableRows(schema *TableSchema, before, after []TableRow) []RowChange {
+	oldRows := map[string]TableRow{}
+	for _, row := range before {
+		oldRows[schema.RowKey(row)] = row
+	}
+	newRows := map[string]TableRow{}
+	for _, row := range after {
+		newRows[schema.RowKey(row)] = row
+	}
+	columns := schema.ColumnNames()
+
+	var deletes, updates, inserts []RowChange
+	for _, key := range sortedKeys(oldRows) {
+		old := oldRows[key]
+		row, ok := newRows[key]
+		if !ok {
+			deletes = append(deletes, RowChange{Op: OpDelete, Table: schema.Name, Columns: columns,
+				Key: schema.KeyValues(old), Old: old.Columns})
+			continue
+		}
+		change := RowChange{Op: OpUpdate, Table: schema.Name, Columns: columns,
+			Key: schema.KeyValues(old), Old: old.Columns, New: row.Columns}
+		if len(change.Cells()) > 0 {
+			updates = append(updates, change)
+		}
+	}
+	for _, key := range sortedKeys(newRows) {
+		if _, ok := oldRows[key]; !ok {
+			row := newRows[key]
+			inserts = append(inserts, RowChange{Op: OpInsert, Table: schema.Name, Columns: columns,
+				Key: schema.KeyValues(row), New: row.Columns})
+		}
+	}
+	return append(append(deletes, updates...), inserts...)
+}
+
+// ApplyRowChanges writes changes to the session's database in order.
+func (s *MsiSession) ApplyRowChanges(changes []RowChange) error {
+	for _, c := range changes {
+		var err error
+		switch c.Op {
+		case OpInsert:
+			err = s.InsertRow(c.Table, c.New)
+		case OpDelete:
+			err = s.DeleteRow(c.Table, c.Key)
+		case OpUpdate:
+			updates := map[string]string{}
+			for _, cell := range c.Cells() {
+				updates[cell.Column] = cell.New
+			}
+			err = s.UpdateRow(c.Table, c.Key, updates)
+		default:
+			err = fmt.Errorf("unknown operation '%s'", c.Op)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to apply %s: %v", c, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+// core/changeset_test.go
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRowChangeCells(t *testing.T) {
+	change := RowChange{
+		Op:      OpUpdate,
+		Table:   "Registry",
+		Columns: []string{"Registry", "Root", "Key", "Name", "Value", "Component_"},
+		Key:     []string{"Reg1"},
+		Old:     []string{"Reg1", "2", `Software\Vendor`, "Url", "http://old", "Comp1"},
+		New:     []string{"Reg1", "2", `Software\Vendor`, "Url", "https://new", "Comp1"},
+	}
+	cells := change.Cells()
+	expected := []CellChange{{Column: "Value", Old: "http://old", New: "https://new"}}
+	if !reflect.DeepEqual(cells, expected) {
+		t.Errorf("Expected %v, got %v", expected, cells)
+	}
+}
+
+func TestDiffTableRows(t *testing.T) {
+	schema := &TableSchema{Name: "Property", Columns: []ColumnInfo{
+		{Name: "Property", Type: "s72", Key: true},
+		{Name: "Value", Type: "l0"},
+	}}
+	before := []TableRow{
+		{Columns: []string{"A", "1"}},
+		{Columns: []string{"B", "2"}},
+		{Columns: []string{"C", "3"}},
+	}
+	after := []TableRow{
+		{Columns: []string{"A", "1"}},
+		{Columns: []string{"B", "20"}},
+		{Columns: []string{"D", "4"}},
+	}
+	changes := DiffTableRows(schema, before, after)
+	var got []string
+	for _, c := range changes {
+		got = append(got, c.String())
+	}
+	expected := []string{"- Property[C]", "~ Property[B] Value: '2' → '20'", "+ Property[D]"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestInvertChanges(t *testing.T) {
+	columns := []string{"Property", "Value"}
+	changes := []RowChange{
+		{Op: OpInsert, Table: "Property", Columns: columns, Key: []string{"D"}, New: []string{"D", "4"}},
+		{Op: OpUpdate, Table: "Property", Columns: columns, Key: []string{"B"}, Old: []string{"B", "2"}, New: []string{"B", "20"}},
+	}
+	inverse := InvertChanges(changes)
+	if len(inverse) != 2 || inverse[0].Op != OpUpdate || inverse[1].Op != OpDelete {
+		t.Fatalf("Expected update then delete, got %v", inverse)
+	}
+	if !reflect.DeepEqual(inverse[0].New, []string{"B", "2"}) || !reflect.DeepEqual(inverse[1].Old, []string{"D", "4"}) {
+		t.Errorf("Unexpected inverse rows: %+v", inverse)
+	}
+	if !reflect.DeepEqual(InvertChanges(inverse), changes) {
+		t.Error("Expected inverting twice to give back the original changes")
+	}
+}
@@ -0,0 +1,384 @@
+// core/git_driver.go
+package core
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+// summaryPropertyNames names the summary information properties of an
+// installer package.
+var summaryPropertyNames = map[int]string{
+	1: "Codepage", 2: "Title", 3: "Subject", 4: "Author", 5: "Keywords", 6: "Comments",
+	7: "Template", 8: "LastSavedBy", 9: "RevisionNumber", 11: "LastPrinted",
+	12: "CreateTime", 13: "LastSaveTime", 14: "PageCount", 15: "WordCount",
+	16: "CharCount", 18: "CreatingApplication", 19: "Security",
+}
+
+// TextConv writes a text rendering of the package at msiPath for git diff:
+// the summary information, every table with its rows sorted, and a hash of
+// every stream and embedded storage. The same package always renders the
+// same way.
+func TextConv(msiPath string, w io.Writer) error {
+	return SafeExecute("TextConv", func() error {
+		m, err := readMsiStorageFile(msiPath)
+		if err != nil {
+			return err
+		}
+		return writeTextConv(w, m)
+	})
+}
+
+func writeTextConv(w io.Writer, m *msiStorage) error {
+	summary, err := summaryLines(m)
+	if err != nil {
+		return err
+	}
+	if len(summary) > 0 {
+		fmt.Fprintln(w, "[Summary Information]")
+		for _, line := range summary {
+			fmt.Fprintln(w, line)
+		}
+	}
+
+	schemas, err := m.readSchemas()
+	if err != nil {
+		return fmt.Errorf("failed to read schema: %v", err)
+	}
+	cellStreams := map[string]bool{}
+	for _, table := range sortedKeys(schemas) {
+		cols := schemas[table]
+		rows, err := m.readTable(table, cols)
+		if err != nil {
+			return err
+		}
+		schema := &TableSchema{Name: table, Columns: cols}
+		lines := make([]string, len(rows))
+		for i, row := range rows {
+			if hasBinaryColumn(schema) {
+				key := schema.KeyValues(TableRow{Columns: row})
+				name := cellStreamName(table, key)
+				if data, ok := m.root.Streams[name]; ok {
+					cellStreams[name] = true
+					for c, col := range cols {
+						if col.IsBinary() {
+							row[c] = streamDigest(data)
+						}
+					}
+				}
+			}
+			lines[i] = formatTuple(row)
+		}
+		sort.Strings(lines)
+		fmt.Fprintf(w, "\n[%s] (%s)\n", table, formatColumnDefs(cols))
+		for _, line := range lines {
+			fmt.Fprintln(w, line)
+		}
+	}
+
+	if streams := streamLines(m, cellStreams); len(streams) > 0 {
+		fmt.Fprintln(w, "\n[Streams]")
+		for _, line := range streams {
+			fmt.Fprintln(w, line)
+		}
+	}
+	if storages := storageLines(m); len(storages) > 0 {
+		fmt.Fprintln(w, "\n[Storages]")
+		for _, line := range storages {
+			fmt.Fprintln(w, line)
+		}
+	}
+	return nil
+}
+
+// summaryLines renders the summary information as "Name = value" lines in
+// property ID order.
+func summaryLines(m *msiStorage) ([]string, error) {
+	data, ok := m.root.Streams[summaryInfoStream]
+	if !ok {
+		return nil, nil
+	}
+	props, err := readPropertySet(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read summary information: %v", err)
+	}
+	pids := make([]int, 0, len(props))
+	for pid := range props {
+		pids = append(pids, pid)
+	}
+	sort.Ints(pids)
+	lines := make([]string, 0, len(pids))
+	for _, pid := range pids {
+		name, ok := summaryPropertyNames[pid]
+		if !ok {
+			name = fmt.Sprintf("PID %d", pid)
+		}
+		value := props[pid]
+		if t, ok := value.(time.Time); ok {
+			value = t.Format("2006-01-02 15:04:05")
+		}
+		lines = append(lines, fmt.Sprintf("%s = %s", name, textEscaper.Replace(fmt.Sprint(value))))
+	}
+	return lines, nil
+}
+
+// streamLines renders the streams that hold neither a table nor the
+// summary information, and are not in skip, as `"name" <digest>` lines.
+func streamLines(m *msiStorage, skip map[string]bool) []string {
+	digests := streamDigests(m, skip)
+	lines := make([]string, 0, len(digests))
+	for _, label := range sortedKeys(digests) {
+		lines = append(lines, fmt.Sprintf("%s %s", quoteTransformValue(label), digests[label]))
+	}
+	return lines
+}
+
+// streamDigests returns the digest of every stream that holds neither a
+// table nor the summary information, and is not in skip, by label.
+func streamDigests(m *msiStorage, skip map[string]bool) map[string]string {
+	digests := map[string]string{}
+	for name, data := range m.root.Streams {
+		if _, table := decodeStreamName(name); !table && !skip[name] && name != summaryInfoStream {
+			digests[streamLabel(name)] = streamDigest(data)
+		}
+	}
+	return digests
+}
+
+// storageLines renders the embedded storages as `"name" <digest>` lines.
+func storageLines(m *msiStorage) []string {
+	var lines []string
+	for _, name := range sortedKeys(m.root.Storages) {
+		lines = append(lines, fmt.Sprintf("%s %s", quoteTransformValue(name), storageDigest(m.root.Storages[name])))
+	}
+	return lines
+}
+
+// storageDigest stands in for an embedded storage: its size and a short
+// hash of its compound file.
+func storageDigest(sub *cfbStorage) string {
+	sum := sha256.Sum256(writeCFB(sub))
+	return fmt.Sprintf("<%d bytes sha256:%x>", storageSize(sub), sum[:8])
+}
+
+// cellStreamNames returns the names of the streams that hold binary cells
+// of table rows.
+func (m *msiStorage) cellStreamNames() (map[string]bool, error) {
+	schemas, err := m.readSchemas()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema: %v", err)
+	}
+	names := map[string]bool{}
+	for table, cols := range schemas {
+		schema := &TableSchema{Name: table, Columns: cols}
+		if !hasBinaryColumn(schema) {
+			continue
+		}
+		rows, err := m.readTable(table, cols)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			names[cellStreamName(table, schema.KeyValues(TableRow{Columns: row}))] = true
+		}
+	}
+	return names, nil
+}
+
+// streamLabel is the readable name of a stream.
+func streamLabel(name string) string {
+	label, _ := decodeStreamName(name)
+	return label
+}
+
+// streamDigest stands in for stream data: its size and a short hash.
+func streamDigest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return fmt.Sprintf("<%d bytes sha256:%x>", len(data), sum[:8])
+}
+
+// mergeSides name the packages of a merge in conflict reasons.
+var mergeSides = rebaseSides{base: "the merge base", other: "the current branch"}
+
+// MergeMSI merges the changes the package at theirsPath makes to basePath
+// into oursPath, as a git merge driver: rows are matched by primary key and
+// updates are merged cell by cell. Changes both sides make differently are
+// left as they are in oursPath, listed on stderr, and make the merge fail.
+// So do changes to stream data and embedded storages, which the merge
+// cannot write.
+func MergeMSI(basePath, oursPath, theirsPath string) error {
+	return SafeExecute("MergeMSI", func() error {
+		ops, result, err := mergeOps(basePath, oursPath, theirsPath)
+		if err != nil {
+			return err
+		}
+		if len(result.Ops) > 0 {
+			session, err := OpenMsiSession(oursPath, 1)
+			if err != nil {
+				return fmt.Errorf("failed to open MSI session: %v", err)
+			}
+			defer session.Close()
+			// Git owns the file; a journal next to it would be left behind.
+			session.noJournal = true
+			if _, err := session.applyTransform(&TextTransform{Version: 2, Ops: result.Ops}, false, false, ConflictFail); err != nil {
+				return err
+			}
+			if err := session.Commit(); err != nil {
+				return fmt.Errorf("commit failed: %v", err)
+			}
+		}
+		fmt.Fprintf(os.Stderr, "msicrafter: merged %d of %d change(s) from the other branch, %d already made, %d conflict(s)\n",
+			len(result.Ops), len(ops), result.Merged, len(result.Conflicts))
+		if len(result.Conflicts) == 0 {
+			return nil
+		}
+		writeConflicts(os.Stderr, result.Conflicts, "these changes of the other branch were not merged")
+		return fmt.Errorf("%d conflict(s) were not merged", len(result.Conflicts))
+	})
+}
+
+// mergeOps returns the other branch's changes to the base, and those
+// changes replayed onto the current branch.
+func mergeOps(basePath, oursPath, theirsPath string) ([]TransformOp, *RebaseResult, error) {
+	baseSession, err := OpenMsiSession(basePath, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open base MSI session: %v", err)
+	}
+	defer baseSession.Close()
+	oursSession, err := OpenMsiSession(oursPath, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open current MSI session: %v", err)
+	}
+	defer oursSession.Close()
+	theirsSession, err := OpenMsiSession(theirsPath, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open other MSI session: %v", err)
+	}
+	defer theirsSession.Close()
+
+	theirs, err := DiffSessions(baseSession, theirsSession)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Binary cells hold stream digests, so rebaseOps can tell which stream
+	// changes the current branch already has.
+	ops, err := binaryTransformOps(theirs)
+	if err != nil {
+		return nil, nil, err
+	}
+	ours, err := DiffSessions(baseSession, oursSession)
+	if err != nil {
+		return nil, nil, err
+	}
+	schemas := map[string][]ColumnInfo{}
+	for _, t := range theirs.Changed() {
+		if t.Status == TableAdded {
+			continue
+		}
+		schema, err := baseSession.GetTableSchema(t.Table)
+		if err != nil {
+			return nil, nil, err
+		}
+		schemas[t.Table] = schema.Columns
+	}
+	result, err := rebaseOps(ops, schemas, ours, mergeSides)
+	if err != nil {
+		return nil, nil, err
+	}
+	holdStreamOps(result, theirs)
+	streams, err := mergeStreamConflicts(basePath, oursPath, theirsPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	result.Conflicts = append(result.Conflicts, streams...)
+	return ops, result, nil
+}
+
+// holdStreamOps moves the operations that write stream data from the
+// result to its conflicts: rows are merged through SQL, which cannot set
+// stream data.
+func holdStreamOps(result *RebaseResult, theirs *MsiDiff) {
+	schemas := map[string]*TableSchema{}
+	for _, t := range theirs.Changed() {
+		schemas[t.Table] = t.schema
+	}
+	var kept []TransformOp
+	for _, op := range result.Ops {
+		if schema, ok := schemas[op.Table]; ok && writesStream(schema, op) {
+			result.conflict(op, "the other branch changes stream data of this row, which cannot be merged")
+			continue
+		}
+		kept = append(kept, op)
+	}
+	result.Ops = kept
+}
+
+// writesStream reports whether an insert or update sets a binary cell.
+func writesStream(schema *TableSchema, op TransformOp) bool {
+	for i, col := range schema.Columns {
+		if !col.IsBinary() {
+			continue
+		}
+		switch op.Op {
+		case OpInsert:
+			if i < len(op.Values) && op.Values[i] != "" {
+				return true
+			}
+		case OpUpdate:
+			if _, ok := op.Set[col.Name]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// mergeStreamConflicts lists the streams and embedded storages, other than
+// stream data of table rows, that the other branch changes and the current
+// branch doesn't have the same way.
+func mergeStreamConflicts(basePath, oursPath, theirsPath string) ([]RebaseConflict, error) {
+	var digests [3]map[string]string
+	for i, path := range []string{basePath, oursPath, theirsPath} {
+		m, err := readMsiStorageFile(path)
+		if err != nil {
+			return nil, err
+		}
+		cells, err := m.cellStreamNames()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		digests[i] = map[string]string{}
+		for label, digest := range streamDigests(m, cells) {
+			digests[i]["stream "+quoteTransformValue(label)] = digest
+		}
+		for name, sub := range m.root.Storages {
+			digests[i]["storage "+quoteTransformValue(name)] = storageDigest(sub)
+		}
+	}
+	base, ours, theirs := digests[0], digests[1], digests[2]
+	labels := map[string]bool{}
+	for _, d := range digests {
+		for label := range d {
+			labels[label] = true
+		}
+	}
+	var conflicts []RebaseConflict
+	for _, label := range sortedKeys(labels) {
+		if theirs[label] == base[label] || theirs[label] == ours[label] {
+			continue
+		}
+		change := "changes"
+		switch {
+		case base[label] == "":
+			change = "adds"
+		case theirs[label] == "":
+			change = "removes"
+		}
+		conflicts = append(conflicts, RebaseConflict{Reason: fmt.Sprintf("the other branch %s %s, which cannot be merged", change, label)})
+	}
+	return conflicts, nil
+}
@@ -0,0 +1,120 @@
+// core/git_driver_test.go
+package core
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWriteTextConv(t *testing.T) {
+	pool, data := testStringPool("Custom", "Id", "Value", "Binary", "Name", "Data", "b", "a", "x", "y", "Icon")
+	key := 0x8000 + columnTypeBits(ColumnInfo{Type: "s72", Key: true})
+	root := newCFBStorage()
+	root.Streams = map[string][]byte{
+		encodeStreamName(tableStringPool, true): pool,
+		encodeStreamName(tableStringData, true): data,
+		encodeStreamName(tableColumns, true): concat(
+			cells(4, 4, 1, 1),
+			cells(0x8001, 0x8002, 0x8001, 0x8002),
+			cells(5, 6, 2, 3),
+			cells(key, 0x8000+columnTypeBits(ColumnInfo{Type: "v0"}), key, 0x8000+columnTypeBits(ColumnInfo{Type: "S0"}))),
+		encodeStreamName("Custom", true):       cells(7, 8, 10, 9),
+		encodeStreamName("Binary", true):       cells(11, 1),
+		encodeStreamName("Binary.Icon", false): []byte("ICON"),
+		encodeStreamName("product.cab", false): []byte("CAB"),
+		summaryInfoStream:                      testPropertySet(map[int]interface{}{2: "Installation Database", 14: 500}),
+	}
+	root.Storages["1031"] = newCFBStorage()
+	m, err := readMsiStorage(writeCFB(root))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	var first, second bytes.Buffer
+	if err := writeTextConv(&first, m); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	expected := `[Summary Information]
+Title = Installation Database
+PageCount = 500
+
+[Binary] (Name s72 KEY, Data v0)
+("Icon", "<4 bytes sha256:386b0dcff4cf7bf0>")
+
+[Custom] (Id s72 KEY, Value S0)
+("a", "x")
+("b", "y")
+
+[Streams]
+"product.cab" <3 bytes sha256:362d88818ef27ef2>
+
+[Storages]
+"1031" <0 bytes sha256:443bdd7d94614d61>
+`
+	if first.String() != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, first.String())
+	}
+	writeTextConv(&second, m)
+	if first.String() != second.String() {
+		t.Error("Expected the same rendering twice")
+	}
+}
+
+func TestHoldStreamOps(t *testing.T) {
+	cols := []ColumnInfo{{Name: "Name", Type: "s72", Key: true}, {Name: "Data", Type: "v0"}, {Name: "Note", Type: "S0"}}
+	theirs := &MsiDiff{Tables: []TableDiff{{Table: "Binary", Status: TableChanged, schema: &TableSchema{Name: "Binary", Columns: cols}}}}
+	ops := []TransformOp{
+		{Op: OpUpdate, Table: "Binary", Key: []string{"CA"}, Set: map[string]string{"Data": streamDigest([]byte("v2"))}},
+		{Op: OpUpdate, Table: "Binary", Key: []string{"CA"}, Set: map[string]string{"Note": "x"}},
+		{Op: OpInsert, Table: "Binary", Values: []string{"NewCA", streamDigest([]byte("dll")), ""}},
+		{Op: OpInsert, Table: "Binary", Values: []string{"Empty", "", ""}},
+		{Op: OpDelete, Table: "Binary", Key: []string{"OldCA"}},
+		{Op: OpUpdate, Table: "Property", Key: []string{"P"}, Set: map[string]string{"Value": "1"}},
+	}
+	result := &RebaseResult{Ops: ops, sides: mergeSides}
+	holdStreamOps(result, theirs)
+	if len(result.Ops) != 4 || len(result.Conflicts) != 2 {
+		t.Fatalf("Expected 4 ops and 2 conflicts, got %v and %v", result.Ops, result.Conflicts)
+	}
+	if result.Conflicts[0].Op.Key[0] != "CA" || result.Conflicts[1].Op.Values[0] != "NewCA" {
+		t.Errorf("Unexpected conflicts: %v", result.Conflicts)
+	}
+}
+
+func TestMergeStreamConflicts(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, streams map[string]string) string {
+		root := newCFBStorage()
+		for label, data := range streams {
+			root.Streams[encodeStreamName(label, false)] = []byte(data)
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, writeCFB(root), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	base := write("base.msi", map[string]string{"product.cab": "v1", "docs.cab": "v1", "old.cab": "v1"})
+	ours := write("ours.msi", map[string]string{"product.cab": "v1", "docs.cab": "v2", "old.cab": "v1"})
+	theirs := write("theirs.msi", map[string]string{"product.cab": "v2", "docs.cab": "v2", "new.cab": "v1"})
+
+	conflicts, err := mergeStreamConflicts(base, ours, theirs)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	var reasons []string
+	for _, c := range conflicts {
+		reasons = append(reasons, c.Reason)
+	}
+	expected := []string{
+		`the other branch adds stream "new.cab", which cannot be merged`,
+		`the other branch removes stream "old.cab", which cannot be merged`,
+		`the other branch changes stream "product.cab", which cannot be merged`,
+	}
+	if !reflect.DeepEqual(reasons, expected) {
+		t.Errorf("Expected %v, got %v", expected, reasons)
+	}
+}
@@ -0,0 +1,376 @@
+// core/journal.go
+package core
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// JournalEntry records one committed change set: the forward changes with
+// the before-image of every touched row, and the inverse operations that undo them.
+type JournalEntry struct {
+	ID           int         `json:"id"`
+	Time         time.Time   `json:"time"`
+	Command      string      `json:"command"`
+	Changes      []RowChange `json:"changes"`
+	Inverse      []RowChange `json:"inverse"`
+	Irreversible string      `json:"irreversible,omitempty"` // why the entry cannot be undone, if it cannot
+}
+
+// Summary counts the entry's changes per table and operation, e.g. "Property ~2, Registry +1".
+func (e JournalEntry) Summary() string {
+	counts := map[string]int{}
+	for _, c := range e.Changes {
+		counts[c.Table+" "+string(c.Op)]++
+	}
+	var parts []string
+	for _, k := range sortedKeys(counts) {
+		parts = append(parts, fmt.Sprintf("%s%d", k, counts[k]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// JournalPath returns the journal file kept next to an MSI.
+func JournalPath(msiPath string) string {
+	return msiPath + ".journal"
+}
+
+// trackTable captures a table's rows before the session first writes to it,
+// so Commit can journal what changed.
+func (s *MsiSession) trackTable(table string) error {
+	if s.noJournal || table == "" {
+		return nil
+	}
+	if _, ok := s.tracked[table]; ok {
+		return nil
+	}
+	if s.tracked == nil {
+		s.tracked = map[string][]TableRow{}
+	}
+	rows, err := s.QueryWithParams(fmt.Sprintf("SELECT * FROM `%s`", table))
+	if err != nil {
+		return fmt.Errorf("failed to capture '%s' for the journal: %v", table, err)
+	}
+	s.tracked[table] = rows
+	return nil
+}
+
+// trackStatement tracks the table a write statement targets. Schema changes
+// cannot be reversed from row images and mark the pending entry irreversible.
+func (s *MsiSession) trackStatement(sql string) error {
+	verb, table := statementTarget(sql)
+	switch verb {
+	case "INSERT", "UPDATE", "DELETE":
+		return s.trackTable(table)
+	case "CREATE", "DROP", "ALTER":
+		if s.irreversible == "" {
+			s.irreversible = fmt.Sprintf("%s TABLE `%s`", verb, table)
+		}
+		delete(s.schemas, table)
+	}
+	return nil
+}
+
+// statementTarget returns the verb and table of an INSERT, UPDATE, DELETE,
+// CREATE, DROP or ALTER statement.
+func statementTarget(sql string) (string, string) {
+	fields := strings.Fields(sql)
+	if len(fields) < 2 {
+		return "", ""
+	}
+	verb := strings.ToUpper(fields[0])
+	idx := 1
+	switch verb {
+	case "INSERT":
+		idx = 2 // INSERT INTO t
+	case "DELETE":
+		idx = 2 // DELETE FROM t
+	case "CREATE", "DROP", "ALTER":
+		idx = 2 // CREATE TABLE t
+	case "UPDATE":
+	default:
+		return verb, ""
+	}
+	if idx >= len(fields) {
+		return verb, ""
+	}
+	table := fields[idx]
+	if i := strings.IndexByte(table, '('); i >= 0 {
+		table = table[:i]
+	}
+	return verb, strings.Trim(table, "`")
+}
+
+// pendingJournalEntry diffs the tracked tables against their committed
+// state and returns the entry to journal, or nil if nothing changed.
+// Called after the database commit and before the working copy is published.
+func (s *MsiSession) pendingJournalEntry() (*JournalEntry, error) {
+	defer func() {
+		s.tracked = nil
+		s.irreversible = ""
+	}()
+	if s.noJournal || (len(s.tracked) == 0 && s.irreversible == "") {
+		return nil, nil
+	}
+	var changes []RowChange
+	for _, table := range sortedKeys(s.tracked) {
+		schema, err := s.GetTableSchema(table)
+		if err != nil {
+			return nil, err
+		}
+		after, err := s.QueryWithParams(fmt.Sprintf("SELECT * FROM `%s`", table))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read '%s' for the journal: %v", table, err)
+		}
+		tableChanges := DiffTableRows(schema, s.tracked[table], after)
+		for _, c := range tableChanges {
+			if c.Op == OpDelete && hasBinaryColumn(schema) && s.irreversible == "" {
+				s.irreversible = fmt.Sprintf("stream data of deleted `%s` rows is not journaled", table)
+			}
+		}
+		changes = append(changes, tableChanges...)
+	}
+	if len(changes) == 0 && s.irreversible == "" {
+		return nil, nil
+	}
+	return &JournalEntry{
+		Time:         time.Now(),
+		Command:      commandLine(),
+		Changes:      changes,
+		Inverse:      InvertChanges(changes),
+		Irreversible: s.irreversible,
+	}, nil
+}
+
+func hasBinaryColumn(schema *TableSchema) bool {
+	for _, col := range schema.Columns {
+		if col.IsBinary() {
+			return true
+		}
+	}
+	return false
+}
+
+func commandLine() string {
+	if len(os.Args) == 0 {
+		return ""
+	}
+	return strings.Join(append([]string{filepath.Base(os.Args[0])}, os.Args[1:]...), " ")
+}
+
+// ReadJournal loads every entry of a journal, oldest first. A missing
+// journal yields no entries.
+func ReadJournal(path string) ([]JournalEntry, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %v", err)
+	}
+	defer f.Close()
+
+	var entries []JournalEntry
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 1024*1024), 256*1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		if strings.TrimSpace(sc.Text()) == "" {
+			continue
+		}
+		var e JournalEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("journal %s line %d: %v", path, line, err)
+		}
+		entries = append(entries, e)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("error reading journal: %v", err)
+	}
+	return entries, nil
+}
+
+// appendJournal adds an entry, numbering it after the last one.
+func appendJournal(path string, entry JournalEntry) error {
+	entries, err := ReadJournal(path)
+	if err != nil {
+		return err
+	}
+	entry.ID = 1
+	if len(entries) > 0 {
+		entry.ID = entries[len(entries)-1].ID + 1
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode journal entry: %v", err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open journal: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write journal: %v", err)
+	}
+	return nil
+}
+
+// writeJournal replaces the journal with entries, removing it when empty.
+func writeJournal(path string, entries []JournalEntry) error {
+	if len(entries) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove journal: %v", err)
+		}
+		return nil
+	}
+	var sb strings.Builder
+	for _, e := range entries {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("failed to encode journal entry: %v", err)
+		}
+		sb.Write(data)
+		sb.WriteByte('\n')
+	}
+	if err := os.WriteFile(path, []byte(sb.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write journal: %v", err)
+	}
+	return nil
+}
+
+// History prints the journal entries of an MSI, newest first.
+func History(msiPath string) error {
+	return SafeExecute("History", func() error {
+		entries, err := ReadJournal(JournalPath(msiPath))
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			fmt.Printf("No journal entries for %s\n", msiPath)
+			return nil
+		}
+		fmt.Printf("📜 History of %s (%d entries):\n", msiPath, len(entries))
+		for i := len(entries) - 1; i >= 0; i-- {
+			e := entries[i]
+			note := e.Summary()
+			if e.Irreversible != "" {
+				note += " [cannot undo: " + e.Irreversible + "]"
+			}
+			fmt.Printf("   #%-4d %s  %s\n         %s\n", e.ID, e.Time.Format("2006-01-02 15:04:05"), e.Command, note)
+		}
+		return nil
+	})
+}
+
+// Undo rolls back the last steps journal entries, newest first, and removes
+// them from the journal. Every row is checked against the state the entry
+// left it in, so changes made outside msicrafter are never overwritten.
+func Undo(msiPath string, steps int, dryRun, interactive bool) error {
+	return SafeExecute("Undo", func() error {
+		if steps < 1 {
+			return fmt.Errorf("steps must be at least 1, got %d", steps)
+		}
+		path := JournalPath(msiPath)
+		entries, err := ReadJournal(path)
+		if err != nil {
+			return err
+		}
+		if len(entries) < steps {
+			return fmt.Errorf("only %d journal entries to undo, asked for %d", len(entries), steps)
+		}
+		undone := entries[len(entries)-steps:]
+		var inverse []RowChange
+		for i := len(undone) - 1; i >= 0; i-- {
+			if undone[i].Irreversible != "" {
+				return fmt.Errorf("entry #%d cannot be undone: %s", undone[i].ID, undone[i].Irreversible)
+			}
+			fmt.Printf("Undoing #%d (%s): %s\n", undone[i].ID, undone[i].Command, undone[i].Summary())
+			inverse = append(inverse, undone[i].Inverse...)
+		}
+
+		session, err := OpenMsiSession(msiPath, 1)
+		if err != nil {
+			return fmt.Errorf("failed to open MSI session: %v", err)
+		}
+		defer session.Close()
+		session.noJournal = true
+
+		if err := session.checkUndoable(inverse); err != nil {
+			return err
+		}
+		for _, c := range inverse {
+			fmt.Printf("   %s\n", c)
+		}
+		if dryRun {
+			log.Println("[DRY-RUN] Undo previewed; no changes committed.")
+			return nil
+		}
+		if interactive {
+			if err := confirmOrCancel(fmt.Sprintf("Undo %d journal entr(ies)?", steps), "undo"); err != nil {
+				return err
+			}
+		}
+		if err := session.ApplyRowChanges(inverse); err != nil {
+			return err
+		}
+		if err := session.Commit(); err != nil {
+			return err
+		}
+		return writeJournal(path, entries[:len(entries)-steps])
+	})
+}
+
+// checkUndoable verifies each row is still as the journal left it before an
+// undo touches it. Rows changed earlier in the same undo are tracked in memory.
+func (s *MsiSession) checkUndoable(inverse []RowChange) error {
+	state := map[string][]string{}
+	known := map[string]bool{}
+	for _, c := range inverse {
+		id := c.Table + "\x00" + strings.Join(c.Key, "\x00")
+		if !known[id] {
+			row, err := s.FindRow(c.Table, c.Key)
+			if err != nil {
+				return err
+			}
+			if row != nil {
+				state[id] = row.Columns
+			}
+			known[id] = true
+		}
+		current, exists := state[id]
+		switch c.Op {
+		case OpInsert:
+			if exists {
+				return fmt.Errorf("cannot undo: %s[%s] was re-created since it was deleted", c.Table, strings.Join(c.Key, ","))
+			}
+			state[id] = c.New
+		case OpDelete, OpUpdate:
+			if !exists || !sameRow(current, c.Old) {
+				return fmt.Errorf("cannot undo: %s[%s] was changed outside the journal", c.Table, strings.Join(c.Key, ","))
+			}
+			if c.Op == OpDelete {
+				delete(state, id)
+			} else {
+				state[id] = c.New
+			}
+		}
+	}
+	return nil
+}
+
+func sameRow(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
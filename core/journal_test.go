@@ -0,0 +1,70 @@
+// core/journal_test.go
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStatementTarget(t *testing.T) {
+	tests := []struct {
+		sql   string
+		verb  string
+		table string
+	}{
+		{"INSERT INTO `Property` (`Property`, `Value`) VALUES (?, ?)", "INSERT", "Property"},
+		{"UPDATE `Registry` SET `Value`=? WHERE `Registry`=?", "UPDATE", "Registry"},
+		{"DELETE FROM `File` WHERE `File`=?", "DELETE", "File"},
+		{"CREATE TABLE `Custom`(`Id` CHAR(72) NOT NULL PRIMARY KEY `Id`)", "CREATE", "Custom"},
+		{"SELECT * FROM `Property`", "SELECT", ""},
+	}
+	for _, tt := range tests {
+		verb, table := statementTarget(tt.sql)
+		if verb != tt.verb || table != tt.table {
+			t.Errorf("statementTarget(%q) = %q, %q; expected %q, %q", tt.sql, verb, table, tt.verb, tt.table)
+		}
+	}
+}
+
+func TestJournalRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.msi.journal")
+	entries, err := ReadJournal(path)
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("Expected an empty journal, got %v (%v)", entries, err)
+	}
+
+	change := RowChange{Op: OpUpdate, Table: "Property", Columns: []string{"Property", "Value"},
+		Key: []string{"A"}, Old: []string{"A", "1"}, New: []string{"A", "2"}}
+	for i := 0; i < 2; i++ {
+		entry := JournalEntry{Time: time.Now(), Command: "msicrafter edit", Changes: []RowChange{change},
+			Inverse: InvertChanges([]RowChange{change})}
+		if err := appendJournal(path, entry); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+	}
+	entries, err = ReadJournal(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(entries) != 2 || entries[0].ID != 1 || entries[1].ID != 2 {
+		t.Fatalf("Expected entries #1 and #2, got %+v", entries)
+	}
+	if entries[1].Summary() != "Property ~1" || entries[1].Inverse[0].New[1] != "1" {
+		t.Errorf("Unexpected entry contents: %+v", entries[1])
+	}
+
+	if err := writeJournal(path, entries[:1]); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if entries, _ = ReadJournal(path); len(entries) != 1 {
+		t.Errorf("Expected 1 entry after truncating, got %d", len(entries))
+	}
+	if err := writeJournal(path, nil); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Expected an empty journal to be removed")
+	}
+}
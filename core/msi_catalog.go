@@ -0,0 +1,533 @@
+// core/msi_catalog.go
+package core
+
+import (
+	"strings"
+)
+
+// catalogColumn describes a column of a standard Windows Installer table,
+// combining what a package records in _Columns and _Validation.
+type catalogColumn struct {
+	Name      string
+	Type      string
+	Key       bool
+	KeyTable  string // referenced tables separated by ';'
+	KeyColumn int    // 1-based column in KeyTable
+	Category  string
+}
+
+// standardTables is a catalog of the commonly used tables from the Windows
+// Installer SDK schema. It stands in for _Validation when a package ships
+// without one and supplies column types when no database is at hand.
+var standardTables = map[string][]catalogColumn{
+	"ActionText": {
+		{"Action", "s72", true, "", 0, "Identifier"},
+		{"Description", "L0", false, "", 0, "Text"},
+		{"Template", "L0", false, "", 0, "Template"},
+	},
+	"AdminExecuteSequence": sequenceTableColumns,
+	"AdminUISequence":      sequenceTableColumns,
+	"AdvtExecuteSequence":  sequenceTableColumns,
+	"AppId": {
+		{"AppId", "s38", true, "", 0, "Guid"},
+		{"RemoteServerName", "S255", false, "", 0, "Formatted"},
+		{"LocalService", "S255", false, "", 0, "Text"},
+		{"ServiceParameters", "S255", false, "", 0, "Text"},
+		{"DllSurrogate", "S255", false, "", 0, "Text"},
+		{"ActivateAtStorage", "I2", false, "", 0, ""},
+		{"RunAsInteractiveUser", "I2", false, "", 0, ""},
+	},
+	"AppSearch": {
+		{"Property", "s72", true, "", 0, "Identifier"},
+		{"Signature_", "s72", true, "", 0, "Identifier"},
+	},
+	"Binary": {
+		{"Name", "s72", true, "", 0, "Identifier"},
+		{"Data", "v0", false, "", 0, "Binary"},
+	},
+	"BindImage": {
+		{"File_", "s72", true, "File", 1, "Identifier"},
+		{"Path", "S255", false, "", 0, "Paths"},
+	},
+	"CheckBox": {
+		{"Property", "s72", true, "", 0, "Identifier"},
+		{"Value", "S64", false, "", 0, "Formatted"},
+	},
+	"Class": {
+		{"CLSID", "s38", true, "", 0, "Guid"},
+		{"Context", "s32", true, "", 0, "Identifier"},
+		{"Component_", "s72", true, "Component", 1, "Identifier"},
+		{"ProgId_Default", "S255", false, "ProgId", 1, "Text"},
+		{"Description", "L255", false, "", 0, "Text"},
+		{"AppId_", "S38", false, "AppId", 1, "Guid"},
+		{"FileTypeMask", "S255", false, "", 0, "Text"},
+		{"Icon_", "S72", false, "Icon", 1, "Identifier"},
+		{"IconIndex", "I2", false, "", 0, ""},
+		{"DefInprocHandler", "S32", false, "", 0, "Filename"},
+		{"Argument", "S255", false, "", 0, "Formatted"},
+		{"Feature_", "s38", false, "Feature", 1, "Identifier"},
+		{"Attributes", "I2", false, "", 0, ""},
+	},
+	"ComboBox": {
+		{"Property", "s72", true, "", 0, "Identifier"},
+		{"Order", "i2", true, "", 0, ""},
+		{"Value", "s64", false, "", 0, "Formatted"},
+		{"Text", "L64", false, "", 0, "Formatted"},
+	},
+	"Component": {
+		{"Component", "s72", true, "", 0, "Identifier"},
+		{"ComponentId", "S38", false, "", 0, "Guid"},
+		{"Directory_", "s72", false, "Directory", 1, "Identifier"},
+		{"Attributes", "i2", false, "", 0, ""},
+		{"Condition", "S255", false, "", 0, "Condition"},
+		{"KeyPath", "S72", false, "File;Registry;ODBCDataSource", 1, "Identifier"},
+	},
+	"Condition": {
+		{"Feature_", "s38", true, "Feature", 1, "Identifier"},
+		{"Level", "i2", true, "", 0, ""},
+		{"Condition", "S255", false, "", 0, "Condition"},
+	},
+	"Control": {
+		{"Dialog_", "s72", true, "Dialog", 1, "Identifier"},
+		{"Control", "s50", true, "", 0, "Identifier"},
+		{"Type", "s20", false, "", 0, "Identifier"},
+		{"X", "i2", false, "", 0, ""},
+		{"Y", "i2", false, "", 0, ""},
+		{"Width", "i2", false, "", 0, ""},
+		{"Height", "i2", false, "", 0, ""},
+		{"Attributes", "I4", false, "", 0, ""},
+		{"Property", "S72", false, "", 0, "Identifier"},
+		{"Text", "L0", false, "", 0, "Formatted"},
+		{"Control_Next", "S50", false, "Control", 2, "Identifier"},
+		{"Help", "L50", false, "", 0, "Text"},
+	},
+	"ControlCondition": {
+		{"Dialog_", "s72", true, "Dialog", 1, "Identifier"},
+		{"Control_", "s50", true, "Control", 2, "Identifier"},
+		{"Action", "s50", true, "", 0, ""},
+		{"Condition", "s255", true, "", 0, "Condition"},
+	},
+	"ControlEvent": {
+		{"Dialog_", "s72", true, "Dialog", 1, "Identifier"},
+		{"Control_", "s50", true, "Control", 2, "Identifier"},
+		{"Event", "s50", true, "", 0, "Formatted"},
+		{"Argument", "s255", true, "", 0, "Formatted"},
+		{"Condition", "S255", true, "", 0, "Condition"},
+		{"Ordering", "I2", false, "", 0, ""},
+	},
+	"CreateFolder": {
+		{"Directory_", "s72", true, "Directory", 1, "Identifier"},
+		{"Component_", "s72", true, "Component", 1, "Identifier"},
+	},
+	"CustomAction": {
+		{"Action", "s72", true, "", 0, "Identifier"},
+		{"Type", "i2", false, "", 0, ""},
+		{"Source", "S72", false, "", 0, "CustomSource"},
+		{"Target", "S255", false, "", 0, "Formatted"},
+		{"ExtendedType", "I4", false, "", 0, ""},
+	},
+	"Dialog": {
+		{"Dialog", "s72", true, "", 0, "Identifier"},
+		{"HCentering", "i2", false, "", 0, ""},
+		{"VCentering", "i2", false, "", 0, ""},
+		{"Width", "i2", false, "", 0, ""},
+		{"Height", "i2", false, "", 0, ""},
+		{"Attributes", "I4", false, "", 0, ""},
+		{"Title", "L128", false, "", 0, "Formatted"},
+		{"Control_First", "s50", false, "Control", 2, "Identifier"},
+		{"Control_Default", "S50", false, "Control", 2, "Identifier"},
+		{"Control_Cancel", "S50", false, "Control", 2, "Identifier"},
+	},
+	"Directory": {
+		{"Directory", "s72", true, "", 0, "Identifier"},
+		{"Directory_Parent", "S72", false, "Directory", 1, "Identifier"},
+		{"DefaultDir", "l255", false, "", 0, "DefaultDir"},
+	},
+	"DrLocator": {
+		{"Signature_", "s72", true, "", 0, "Identifier"},
+		{"Parent", "S72", true, "", 0, "Identifier"},
+		{"Path", "S255", true, "", 0, "AnyPath"},
+		{"Depth", "I2", false, "", 0, ""},
+	},
+	"DuplicateFile": {
+		{"FileKey", "s72", true, "", 0, "Identifier"},
+		{"Component_", "s72", false, "Component", 1, "Identifier"},
+		{"File_", "s72", false, "File", 1, "Identifier"},
+		{"DestName", "L255", false, "", 0, "Filename"},
+		{"DestFolder", "S72", false, "", 0, "Identifier"},
+	},
+	"Environment": {
+		{"Environment", "s72", true, "", 0, "Identifier"},
+		{"Name", "l255", false, "", 0, "Text"},
+		{"Value", "L255", false, "", 0, "Formatted"},
+		{"Component_", "s72", false, "Component", 1, "Identifier"},
+	},
+	"Error": {
+		{"Error", "i2", true, "", 0, ""},
+		{"Message", "L0", false, "", 0, "Template"},
+	},
+	"EventMapping": {
+		{"Dialog_", "s72", true, "Dialog", 1, "Identifier"},
+		{"Control_", "s50", true, "Control", 2, "Identifier"},
+		{"Event", "s50", true, "", 0, "Identifier"},
+		{"Attribute", "s50", false, "", 0, "Identifier"},
+	},
+	"Extension": {
+		{"Extension", "s255", true, "", 0, "Text"},
+		{"Component_", "s72", true, "Component", 1, "Identifier"},
+		{"ProgId_", "S255", false, "ProgId", 1, "Text"},
+		{"MIME_", "S64", false, "MIME", 1, "Text"},
+		{"Feature_", "s38", false, "Feature", 1, "Identifier"},
+	},
+	"Feature": {
+		{"Feature", "s38", true, "", 0, "Identifier"},
+		{"Feature_Parent", "S38", false, "Feature", 1, "Identifier"},
+		{"Title", "L64", false, "", 0, "Text"},
+		{"Description", "L255", false, "", 0, "Text"},
+		{"Display", "I2", false, "", 0, ""},
+		{"Level", "i2", false, "", 0, ""},
+		{"Directory_", "S72", false, "Directory", 1, "UpperCase"},
+		{"Attributes", "i2", false, "", 0, ""},
+	},
+	"FeatureComponents": {
+		{"Feature_", "s38", true, "Feature", 1, "Identifier"},
+		{"Component_", "s72", true, "Component", 1, "Identifier"},
+	},
+	"File": {
+		{"File", "s72", true, "", 0, "Identifier"},
+		{"Component_", "s72", false, "Component", 1, "Identifier"},
+		{"FileName", "l255", false, "", 0, "Filename"},
+		{"FileSize", "i4", false, "", 0, ""},
+		{"Version", "S72", false, "File", 1, "Version"},
+		{"Language", "S20", false, "", 0, "Language"},
+		{"Attributes", "I2", false, "", 0, ""},
+		{"Sequence", "i4", false, "", 0, ""},
+	},
+	"Font": {
+		{"File_", "s72", true, "File", 1, "Identifier"},
+		{"FontTitle", "S128", false, "", 0, "Text"},
+	},
+	"Icon": {
+		{"Name", "s72", true, "", 0, "Identifier"},
+		{"Data", "v0", false, "", 0, "Binary"},
+	},
+	"IniFile": {
+		{"IniFile", "s72", true, "", 0, "Identifier"},
+		{"FileName", "l255", false, "", 0, "Filename"},
+		{"DirProperty", "S72", false, "", 0, "Identifier"},
+		{"Section", "l96", false, "", 0, "Formatted"},
+		{"Key", "l128", false, "", 0, "Formatted"},
+		{"Value", "l255", false, "", 0, "Formatted"},
+		{"Action", "i2", false, "", 0, ""},
+		{"Component_", "s72", false, "Component", 1, "Identifier"},
+	},
+	"InstallExecuteSequence": sequenceTableColumns,
+	"InstallUISequence":      sequenceTableColumns,
+	"IsolatedComponent": {
+		{"Component_Shared", "s72", true, "Component", 1, "Identifier"},
+		{"Component_Application", "s72", true, "Component", 1, "Identifier"},
+	},
+	"LaunchCondition": {
+		{"Condition", "s255", true, "", 0, "Condition"},
+		{"Description", "l255", false, "", 0, "Formatted"},
+	},
+	"ListBox": {
+		{"Property", "s72", true, "", 0, "Identifier"},
+		{"Order", "i2", true, "", 0, ""},
+		{"Value", "s64", false, "", 0, "Formatted"},
+		{"Text", "L64", false, "", 0, "Text"},
+	},
+	"ListView": {
+		{"Property", "s72", true, "", 0, "Identifier"},
+		{"Order", "i2", true, "", 0, ""},
+		{"Value", "s64", false, "", 0, "Identifier"},
+		{"Text", "L64", false, "", 0, "Text"},
+		{"Binary_", "S72", false, "Binary", 1, "Identifier"},
+	},
+	"LockPermissions": {
+		{"LockObject", "s72", true, "", 0, "Identifier"},
+		{"Table", "s32", true, "", 0, "Identifier"},
+		{"Domain", "S255", true, "", 0, "Formatted"},
+		{"User", "s255", true, "", 0, "Formatted"},
+		{"Permission", "I4", false, "", 0, ""},
+	},
+	"Media": {
+		{"DiskId", "i2", true, "", 0, ""},
+		{"LastSequence", "i4", false, "", 0, ""},
+		{"DiskPrompt", "L64", false, "", 0, "Text"},
+		{"Cabinet", "S255", false, "", 0, "Cabinet"},
+		{"VolumeLabel", "S32", false, "", 0, "Text"},
+		{"Source", "S72", false, "", 0, "Property"},
+	},
+	"MIME": {
+		{"ContentType", "s64", true, "", 0, "Text"},
+		{"Extension_", "s255", false, "Extension", 1, "Text"},
+		{"CLSID", "S38", false, "Class", 1, "Guid"},
+	},
+	"MoveFile": {
+		{"FileKey", "s72", true, "", 0, "Identifier"},
+		{"Component_", "s72", false, "Component", 1, "Identifier"},
+		{"SourceName", "L255", false, "", 0, "Text"},
+		{"DestName", "L255", false, "", 0, "Filename"},
+		{"SourceFolder", "S72", false, "", 0, "Identifier"},
+		{"DestFolder", "s72", false, "", 0, "Identifier"},
+		{"Options", "i2", false, "", 0, ""},
+	},
+	"MsiAssembly": {
+		{"Component_", "s72", true, "Component", 1, "Identifier"},
+		{"Feature_", "s38", false, "Feature", 1, "Identifier"},
+		{"File_Manifest", "S72", false, "File", 1, "Identifier"},
+		{"File_Application", "S72", false, "File", 1, "Identifier"},
+		{"Attributes", "I2", false, "", 0, ""},
+	},
+	"MsiAssemblyName": {
+		{"Component_", "s72", true, "Component", 1, "Identifier"},
+		{"Name", "s255", true, "", 0, "Text"},
+		{"Value", "s255", false, "", 0, "Text"},
+	},
+	"MsiFileHash": {
+		{"File_", "s72", true, "File", 1, "Identifier"},
+		{"Options", "i2", false, "", 0, ""},
+		{"HashPart1", "i4", false, "", 0, ""},
+		{"HashPart2", "i4", false, "", 0, ""},
+		{"HashPart3", "i4", false, "", 0, ""},
+		{"HashPart4", "i4", false, "", 0, ""},
+	},
+	"ODBCDataSource": {
+		{"DataSource", "s72", true, "", 0, "Identifier"},
+		{"Component_", "s72", false, "Component", 1, "Identifier"},
+		{"Description", "s255", false, "", 0, "Text"},
+		{"DriverDescription", "s255", false, "", 0, "Text"},
+		{"Registration", "i2", false, "", 0, ""},
+	},
+	"ProgId": {
+		{"ProgId", "s255", true, "", 0, "Text"},
+		{"ProgId_Parent", "S255", false, "ProgId", 1, "Text"},
+		{"Class_", "S38", false, "Class", 1, "Guid"},
+		{"Description", "L255", false, "", 0, "Text"},
+		{"Icon_", "S72", false, "Icon", 1, "Identifier"},
+		{"IconIndex", "I2", false, "", 0, ""},
+	},
+	"Property": {
+		{"Property", "s72", true, "", 0, "Identifier"},
+		{"Value", "l0", false, "", 0, "Text"},
+	},
+	"PublishComponent": {
+		{"ComponentId", "s38", true, "", 0, "Guid"},
+		{"Qualifier", "s255", true, "", 0, "Text"},
+		{"Component_", "s72", true, "Component", 1, "Identifier"},
+		{"AppData", "L255", false, "", 0, "Text"},
+		{"Feature_", "s38", false, "Feature", 1, "Identifier"},
+	},
+	"RadioButton": {
+		{"Property", "s72", true, "", 0, "Identifier"},
+		{"Order", "i2", true, "", 0, ""},
+		{"Value", "s64", false, "", 0, "Formatted"},
+		{"X", "i2", false, "", 0, ""},
+		{"Y", "i2", false, "", 0, ""},
+		{"Width", "i2", false, "", 0, ""},
+		{"Height", "i2", false, "", 0, ""},
+		{"Text", "L64", false, "", 0, "Text"},
+		{"Help", "L50", false, "", 0, "Text"},
+	},
+	"Registry": {
+		{"Registry", "s72", true, "", 0, "Identifier"},
+		{"Root", "i2", false, "", 0, ""},
+		{"Key", "l255", false, "", 0, "RegPath"},
+		{"Name", "L255", false, "", 0, "Formatted"},
+		{"Value", "L0", false, "", 0, "Formatted"},
+		{"Component_", "s72", false, "Component", 1, "Identifier"},
+	},
+	"RegLocator": {
+		{"Signature_", "s72", true, "", 0, "Identifier"},
+		{"Root", "i2", false, "", 0, ""},
+		{"Key", "s255", false, "", 0, "RegPath"},
+		{"Name", "S255", false, "", 0, "Formatted"},
+		{"Type", "I2", false, "", 0, ""},
+	},
+	"RemoveFile": {
+		{"FileKey", "s72", true, "", 0, "Identifier"},
+		{"Component_", "s72", false, "Component", 1, "Identifier"},
+		{"FileName", "L255", false, "", 0, "WildCardFilename"},
+		{"DirProperty", "s72", false, "", 0, "Identifier"},
+		{"InstallMode", "i2", false, "", 0, ""},
+	},
+	"RemoveIniFile": {
+		{"RemoveIniFile", "s72", true, "", 0, "Identifier"},
+		{"FileName", "l255", false, "", 0, "Filename"},
+		{"DirProperty", "S72", false, "", 0, "Identifier"},
+		{"Section", "l96", false, "", 0, "Formatted"},
+		{"Key", "l128", false, "", 0, "Formatted"},
+		{"Value", "L255", false, "", 0, "Formatted"},
+		{"Action", "i2", false, "", 0, ""},
+		{"Component_", "s72", false, "Component", 1, "Identifier"},
+	},
+	"RemoveRegistry": {
+		{"RemoveRegistry", "s72", true, "", 0, "Identifier"},
+		{"Root", "i2", false, "", 0, ""},
+		{"Key", "l255", false, "", 0, "RegPath"},
+		{"Name", "L255", false, "", 0, "Formatted"},
+		{"Component_", "s72", false, "Component", 1, "Identifier"},
+	},
+	"ReserveCost": {
+		{"ReserveKey", "s72", true, "", 0, "Identifier"},
+		{"Component_", "s72", false, "Component", 1, "Identifier"},
+		{"ReserveFolder", "S72", false, "", 0, "Identifier"},
+		{"ReserveLocal", "i4", false, "", 0, ""},
+		{"ReserveSource", "i4", false, "", 0, ""},
+	},
+	"SelfReg": {
+		{"File_", "s72", true, "File", 1, "Identifier"},
+		{"Cost", "I2", false, "", 0, ""},
+	},
+	"ServiceControl": {
+		{"ServiceControl", "s72", true, "", 0, "Identifier"},
+		{"Name", "l255", false, "", 0, "Formatted"},
+		{"Event", "i2", false, "", 0, ""},
+		{"Arguments", "L255", false, "", 0, "Formatted"},
+		{"Wait", "I2", false, "", 0, ""},
+		{"Component_", "s72", false, "Component", 1, "Identifier"},
+	},
+	"ServiceInstall": {
+		{"ServiceInstall", "s72", true, "", 0, "Identifier"},
+		{"Name", "s255", false, "", 0, "Formatted"},
+		{"DisplayName", "L255", false, "", 0, "Formatted"},
+		{"ServiceType", "i4", false, "", 0, ""},
+		{"StartType", "i4", false, "", 0, ""},
+		{"ErrorControl", "i4", false, "", 0, ""},
+		{"LoadOrderGroup", "S255", false, "", 0, "Formatted"},
+		{"Dependencies", "S255", false, "", 0, "Formatted"},
+		{"StartName", "S255", false, "", 0, "Formatted"},
+		{"Password", "S255", false, "", 0, "Formatted"},
+		{"Arguments", "S255", false, "", 0, "Formatted"},
+		{"Component_", "s72", false, "Component", 1, "Identifier"},
+		{"Description", "L255", false, "", 0, "Text"},
+	},
+	"Shortcut": {
+		{"Shortcut", "s72", true, "", 0, "Identifier"},
+		{"Directory_", "s72", false, "Directory", 1, "Identifier"},
+		{"Name", "l128", false, "", 0, "Filename"},
+		{"Component_", "s72", false, "Component", 1, "Identifier"},
+		{"Target", "s72", false, "", 0, "Shortcut"},
+		{"Arguments", "S255", false, "", 0, "Formatted"},
+		{"Description", "L255", false, "", 0, "Text"},
+		{"Hotkey", "I2", false, "", 0, ""},
+		{"Icon_", "S72", false, "Icon", 1, "Identifier"},
+		{"IconIndex", "I2", false, "", 0, ""},
+		{"ShowCmd", "I2", false, "", 0, ""},
+		{"WkDir", "S72", false, "", 0, "Identifier"},
+		{"DisplayResourceDLL", "S255", false, "", 0, "Formatted"},
+		{"DisplayResourceId", "I2", false, "", 0, ""},
+		{"DescriptionResourceDLL", "S255", false, "", 0, "Formatted"},
+		{"DescriptionResourceId", "I2", false, "", 0, ""},
+	},
+	"Signature": {
+		{"Signature", "s72", true, "", 0, "Identifier"},
+		{"FileName", "s255", false, "", 0, "Filename"},
+		{"MinVersion", "S20", false, "", 0, "Text"},
+		{"MaxVersion", "S20", false, "", 0, "Text"},
+		{"MinSize", "I4", false, "", 0, ""},
+		{"MaxSize", "I4", false, "", 0, ""},
+		{"MinDate", "I4", false, "", 0, ""},
+		{"MaxDate", "I4", false, "", 0, ""},
+		{"Languages", "S255", false, "", 0, "Language"},
+	},
+	"TextStyle": {
+		{"TextStyle", "s72", true, "", 0, "Identifier"},
+		{"FaceName", "s32", false, "", 0, "Text"},
+		{"Size", "i2", false, "", 0, ""},
+		{"Color", "I4", false, "", 0, ""},
+		{"StyleBits", "I2", false, "", 0, ""},
+	},
+	"TypeLib": {
+		{"LibID", "s38", true, "", 0, "Guid"},
+		{"Language", "i2", true, "", 0, ""},
+		{"Component_", "s72", true, "Component", 1, "Identifier"},
+		{"Version", "I4", false, "", 0, ""},
+		{"Description", "L128", false, "", 0, "Text"},
+		{"Directory_", "S72", false, "Directory", 1, "Identifier"},
+		{"Feature_", "s38", false, "Feature", 1, "Identifier"},
+		{"Cost", "I4", false, "", 0, ""},
+	},
+	"UIText": {
+		{"Key", "s72", true, "", 0, "Identifier"},
+		{"Text", "L255", false, "", 0, "Text"},
+	},
+	"Upgrade": {
+		{"UpgradeCode", "s38", true, "", 0, "Guid"},
+		{"VersionMin", "S20", true, "", 0, "Text"},
+		{"VersionMax", "S20", true, "", 0, "Text"},
+		{"Language", "S255", true, "", 0, "Language"},
+		{"Attributes", "i4", true, "", 0, ""},
+		{"Remove", "S255", false, "", 0, "Formatted"},
+		{"ActionProperty", "s72", false, "", 0, "UpperCase"},
+	},
+	"Verb": {
+		{"Extension_", "s255", true, "Extension", 1, "Text"},
+		{"Verb", "s32", true, "", 0, "Text"},
+		{"Sequence", "I2", false, "", 0, ""},
+		{"Command", "L255", false, "", 0, "Formatted"},
+		{"Argument", "L255", false, "", 0, "Formatted"},
+	},
+}
+
+// sequenceTableColumns is shared by the five action sequence tables.
+var sequenceTableColumns = []catalogColumn{
+	{"Action", "s72", true, "", 0, "Identifier"},
+	{"Condition", "S255", false, "", 0, "Condition"},
+	{"Sequence", "I2", false, "", 0, ""},
+}
+
+// propertyReferenceColumns lists columns that name a property or directory
+// without declaring a KeyTable, so renames of either must visit them.
+var propertyReferenceColumns = map[string][]string{
+	"AppSearch":     {"Property"},
+	"CheckBox":      {"Property"},
+	"ComboBox":      {"Property"},
+	"Control":       {"Property"},
+	"DuplicateFile": {"DestFolder"},
+	"IniFile":       {"DirProperty"},
+	"ListBox":       {"Property"},
+	"ListView":      {"Property"},
+	"Media":         {"Source"},
+	"MoveFile":      {"SourceFolder", "DestFolder"},
+	"RadioButton":   {"Property"},
+	"RemoveFile":    {"DirProperty"},
+	"RemoveIniFile": {"DirProperty"},
+	"ReserveCost":   {"ReserveFolder"},
+	"Shortcut":      {"WkDir"},
+	"Upgrade":       {"ActionProperty"},
+}
+
+// StandardTableSchema returns the catalog schema for a standard table.
+func StandardTableSchema(table string) (*TableSchema, bool) {
+	cols, ok := standardTables[table]
+	if !ok {
+		return nil, false
+	}
+	schema := &TableSchema{Name: table}
+	for _, c := range cols {
+		schema.Columns = append(schema.Columns, ColumnInfo{Name: c.Name, Type: c.Type, Key: c.Key})
+	}
+	return schema, true
+}
+
+// standardValidationRules returns catalog rules for a table in the shape of _Validation rows.
+func standardValidationRules(table string) map[string]ValidationRule {
+	rules := map[string]ValidationRule{}
+	for _, c := range standardTables[table] {
+		nullable := "N"
+		if c.Type != "" && strings.ToUpper(c.Type[:1]) == c.Type[:1] {
+			nullable = "Y"
+		}
+		rules[c.Name] = ValidationRule{
+			Table:     table,
+			Column:    c.Name,
+			Nullable:  nullable,
+			KeyTable:  c.KeyTable,
+			KeyColumn: c.KeyColumn,
+			Category:  c.Category,
+		}
+	}
+	return rules
+}
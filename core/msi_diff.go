@@ -0,0 +1,336 @@
+// core/msi_diff.go
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TableStatus says how a table differs between two packages.
+type TableStatus string
+
+const (
+	TableAdded     TableStatus = "added"     // only in the new package
+	TableRemoved   TableStatus = "removed"   // only in the old package
+	TableChanged   TableStatus = "changed"   // in both, with schema or row differences
+	TableUnchanged TableStatus = "unchanged" // in both and identical
+)
+
+// ColumnChange is a schema difference of one column. OldType is empty for
+// added columns and NewType for removed ones.
+type ColumnChange struct {
+	Column  string `json:"column"`
+	OldType string `json:"oldType,omitempty"`
+	NewType string `json:"newType,omitempty"`
+	OldKey  bool   `json:"oldKey,omitempty"`
+	NewKey  bool   `json:"newKey,omitempty"`
+}
+
+func (c ColumnChange) String() string {
+	switch {
+	case c.OldType == "":
+		return fmt.Sprintf("+ column %s (%s)", c.Column, describeColumnType(c.NewType, c.NewKey))
+	case c.NewType == "":
+		return fmt.Sprintf("- column %s (%s)", c.Column, describeColumnType(c.OldType, c.OldKey))
+	}
+	return fmt.Sprintf("~ column %s: %s → %s", c.Column, describeColumnType(c.OldType, c.OldKey), describeColumnType(c.NewType, c.NewKey))
+}
+
+func describeColumnType(typ string, key bool) string {
+	if key {
+		return typ + ", key"
+	}
+	return typ
+}
+
+// TableDiff is the difference of one table. Rows of both packages are
+// matched on the primary key; with schema changes they are compared column
+// by column over the union of both packages' columns (Columns), with
+// missing columns read as empty.
+type TableDiff struct {
+	Table     string         `json:"table"`
+	Status    TableStatus    `json:"status"`
+	Columns   []string       `json:"columns"`
+	Schema    []ColumnChange `json:"schema,omitempty"`
+	Changes   []RowChange    `json:"changes,omitempty"`
+	Unchanged int            `json:"unchanged"` // matched rows with no differences
+
+	schema *TableSchema // columns of Columns with their types and keys
+}
+
+// Counts returns the number of added, removed and changed rows.
+func (d TableDiff) Counts() (added, removed, changed int) {
+	for _, c := range d.Changes {
+		switch c.Op {
+		case OpInsert:
+			added++
+		case OpDelete:
+			removed++
+		case OpUpdate:
+			changed++
+		}
+	}
+	return added, removed, changed
+}
+
+// Summary renders the counts as "+added -removed ~changed".
+func (d TableDiff) Summary() string {
+	added, removed, changed := d.Counts()
+	s := fmt.Sprintf("+%d -%d ~%d", added, removed, changed)
+	if len(d.Schema) > 0 {
+		s += fmt.Sprintf(", %d column change(s)", len(d.Schema))
+	}
+	return s
+}
+
+// MsiDiff is the full difference between two packages, table by table in
+// name order. Unchanged tables are included with their row count.
+type MsiDiff struct {
+	Old    string      `json:"old"`
+	New    string      `json:"new"`
+	Tables []TableDiff `json:"tables"`
+}
+
+// Changed returns the tables that differ.
+func (d *MsiDiff) Changed() []TableDiff {
+	var changed []TableDiff
+	for _, t := range d.Tables {
+		if t.Status != TableUnchanged {
+			changed = append(changed, t)
+		}
+	}
+	return changed
+}
+
+// Changes returns every row change of every table, in table order.
+func (d *MsiDiff) Changes() []RowChange {
+	var changes []RowChange
+	for _, t := range d.Tables {
+		changes = append(changes, t.Changes...)
+	}
+	return changes
+}
+
+// DiffMSI compares two packages row by row on their primary keys.
+func DiffMSI(oldPath, newPath string) (*MsiDiff, error) {
+	oldSession, err := OpenMsiSession(oldPath, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open MSI1 session: %v", err)
+	}
+	defer oldSession.Close()
+	newSession, err := OpenMsiSession(newPath, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open MSI2 session: %v", err)
+	}
+	defer newSession.Close()
+
+	diff, err := DiffSessions(oldSession, newSession)
+	if err != nil {
+		return nil, err
+	}
+	diff.Old, diff.New = oldPath, newPath
+	return diff, nil
+}
+
+// DiffSessions compares every table of two open databases. Binary cells
+// are compared by a digest of their stream data.
+func DiffSessions(oldSession, newSession *MsiSession) (*MsiDiff, error) {
+	oldTables, err := diffSnapshot(oldSession)
+	if err == nil {
+		err = fillStreamDigests(oldSession.msiPath, oldTables)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read MSI1: %v", err)
+	}
+	newTables, err := diffSnapshot(newSession)
+	if err == nil {
+		err = fillStreamDigests(newSession.msiPath, newTables)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read MSI2: %v", err)
+	}
+	return diffTables(oldTables, newTables), nil
+}
+
+// fillStreamDigests sets the binary cells of tables, which the automation
+// interface reads as empty, to the digest of their stream data in the
+// package at msiPath, so that a changed stream shows up as a changed cell.
+func fillStreamDigests(msiPath string, tables map[string]*tableSnapshot) error {
+	var m *msiStorage
+	for _, name := range sortedKeys(tables) {
+		t := tables[name]
+		if !hasBinaryColumn(t.schema) || len(t.rows) == 0 {
+			continue
+		}
+		if m == nil {
+			var err error
+			if m, err = readMsiStorageFile(msiPath); err != nil {
+				return err
+			}
+		}
+		setStreamDigests(m, t)
+	}
+	return nil
+}
+
+// setStreamDigests sets the binary cells of a table's rows to the digest
+// of their stream in m; rows without a stream keep an empty cell.
+func setStreamDigests(m *msiStorage, t *tableSnapshot) {
+	for _, row := range t.rows {
+		data, ok := m.cellStream(t.schema.Name, t.schema.KeyValues(row))
+		if !ok {
+			continue
+		}
+		for i, col := range t.schema.Columns {
+			if col.IsBinary() && i < len(row.Columns) {
+				row.Columns[i] = streamDigest(data)
+			}
+		}
+	}
+}
+
+// diffTables compares two sets of tables by name.
+func diffTables(oldTables, newTables map[string]*tableSnapshot) *MsiDiff {
+	names := map[string]bool{}
+	for name := range oldTables {
+		names[name] = true
+	}
+	for name := range newTables {
+		names[name] = true
+	}
+	diff := &MsiDiff{}
+	for _, name := range sortedKeys(names) {
+		diff.Tables = append(diff.Tables, diffTable(name, oldTables[name], newTables[name]))
+	}
+	return diff
+}
+
+// tableSnapshot is a table's schema and rows.
+type tableSnapshot struct {
+	schema *TableSchema
+	rows   []TableRow
+}
+
+// diffSnapshot reads every user table of a database. System tables such
+// as _Validation are compared like any other; _Tables and _Columns are
+// covered by the schema comparison.
+func diffSnapshot(s *MsiSession) (map[string]*tableSnapshot, error) {
+	names, err := s.TableNames()
+	if err != nil {
+		return nil, err
+	}
+	tables := map[string]*tableSnapshot{}
+	for _, name := range names {
+		if name == "_Tables" || name == "_Columns" {
+			continue
+		}
+		schema, err := s.GetTableSchema(name)
+		if err != nil {
+			return nil, err
+		}
+		rows, err := s.QueryWithParams(fmt.Sprintf("SELECT * FROM `%s`", name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read '%s': %v", name, err)
+		}
+		tables[name] = &tableSnapshot{schema: schema, rows: rows}
+	}
+	return tables, nil
+}
+
+// diffTable compares one table. Either side may be nil for a table that
+// exists in only one package.
+func diffTable(name string, oldTable, newTable *tableSnapshot) TableDiff {
+	switch {
+	case oldTable == nil:
+		d := TableDiff{Table: name, Status: TableAdded, Columns: newTable.schema.ColumnNames(), schema: newTable.schema}
+		d.Changes = DiffTableRows(newTable.schema, nil, newTable.rows)
+		return d
+	case newTable == nil:
+		d := TableDiff{Table: name, Status: TableRemoved, Columns: oldTable.schema.ColumnNames(), schema: oldTable.schema}
+		d.Changes = DiffTableRows(oldTable.schema, oldTable.rows, nil)
+		return d
+	}
+
+	merged, schemaChanges := mergeSchemas(oldTable.schema, newTable.schema)
+	oldRows := projectRows(oldTable.schema, merged, oldTable.rows)
+	newRows := projectRows(newTable.schema, merged, newTable.rows)
+	d := TableDiff{
+		Table:   name,
+		Status:  TableUnchanged,
+		Columns: merged.ColumnNames(),
+		Schema:  schemaChanges,
+		Changes: DiffTableRows(merged, oldRows, newRows),
+		schema:  merged,
+	}
+	matched := map[string]bool{}
+	for _, row := range newRows {
+		matched[merged.RowKey(row)] = true
+	}
+	for _, row := range oldRows {
+		if matched[merged.RowKey(row)] {
+			d.Unchanged++
+		}
+	}
+	_, _, changed := d.Counts()
+	d.Unchanged -= changed
+	if len(d.Schema) > 0 || len(d.Changes) > 0 {
+		d.Status = TableChanged
+	}
+	return d
+}
+
+// mergeSchemas returns the union of two versions of a table's columns (the
+// new columns in order, then columns only the old version has) and the
+// column-level differences. Rows are matched on the new primary key unless
+// it uses a column the old version lacks.
+func mergeSchemas(oldSchema, newSchema *TableSchema) (*TableSchema, []ColumnChange) {
+	var changes []ColumnChange
+	merged := &TableSchema{Name: newSchema.Name}
+	keyInOld := true
+	for _, col := range newSchema.Columns {
+		old, _, ok := oldSchema.Column(col.Name)
+		switch {
+		case !ok:
+			changes = append(changes, ColumnChange{Column: col.Name, NewType: col.Type, NewKey: col.Key})
+			if col.Key {
+				keyInOld = false
+			}
+		case !strings.EqualFold(old.Type, col.Type) || old.Key != col.Key:
+			changes = append(changes, ColumnChange{Column: col.Name, OldType: old.Type, NewType: col.Type, OldKey: old.Key, NewKey: col.Key})
+		}
+		merged.Columns = append(merged.Columns, col)
+	}
+	for _, col := range oldSchema.Columns {
+		if _, _, ok := newSchema.Column(col.Name); !ok {
+			changes = append(changes, ColumnChange{Column: col.Name, OldType: col.Type, OldKey: col.Key})
+			merged.Columns = append(merged.Columns, ColumnInfo{Name: col.Name, Type: col.Type})
+		}
+	}
+	if !keyInOld {
+		for i := range merged.Columns {
+			old, _, ok := oldSchema.Column(merged.Columns[i].Name)
+			merged.Columns[i].Key = ok && old.Key
+		}
+	}
+	return merged, changes
+}
+
+// projectRows lays rows of schema out in the merged column order, leaving
+// columns the schema lacks empty.
+func projectRows(schema, merged *TableSchema, rows []TableRow) []TableRow {
+	idx := make([]int, len(merged.Columns))
+	for i, col := range merged.Columns {
+		_, idx[i], _ = schema.Column(col.Name)
+	}
+	projected := make([]TableRow, len(rows))
+	for r, row := range rows {
+		cols := make([]string, len(idx))
+		for i, j := range idx {
+			if j >= 0 && j < len(row.Columns) {
+				cols[i] = row.Columns[j]
+			}
+		}
+		projected[r] = TableRow{Columns: cols}
+	}
+	return projected
+}
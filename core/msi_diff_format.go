@@ -0,0 +1,401 @@
+// core/msi_diff_format.go
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"strings"
+)
+
+// DiffFormats lists the output formats of WriteDiff.
+var DiffFormats = []string{"summary", "text", "json", "jsonpatch", "html"}
+
+// WriteDiff renders a diff in one of DiffFormats:
+//
+//	summary    per-table counts with every row and schema change (default)
+//	text       unified-diff style, one line per old and new row
+//	json       table → row key → column → {old, new}
+//	jsonpatch  RFC 6902 operations against the JSON form of the database
+//	html       self-contained side-by-side report
+func WriteDiff(w io.Writer, d *MsiDiff, format string) error {
+	switch strings.ToLower(format) {
+	case "", "summary":
+		return writeDiffSummary(w, d)
+	case "text":
+		return writeDiffText(w, d)
+	case "json":
+		return writeDiffJSON(w, d)
+	case "jsonpatch":
+		return writeDiffJSONPatch(w, d)
+	case "html":
+		return writeDiffHTML(w, d)
+	}
+	return fmt.Errorf("unknown diff format '%s'; expected one of %s", format, strings.Join(DiffFormats, ", "))
+}
+
+// WriteDiffFile renders a diff to path, or to stdout when path is empty.
+func WriteDiffFile(path string, d *MsiDiff, format string) error {
+	if path == "" {
+		return WriteDiff(os.Stdout, d, format)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create '%s': %v", path, err)
+	}
+	if err := WriteDiff(f, d, format); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write '%s': %v", path, err)
+	}
+	return nil
+}
+
+// diffRowKey joins a primary key into the row key used by the JSON formats.
+func diffRowKey(key []string) string {
+	return strings.Join(key, "|")
+}
+
+func writeDiffSummary(w io.Writer, d *MsiDiff) error {
+	fmt.Fprintf(w, "📊 Differences between %s and %s:\n", d.Old, d.New)
+	changed := d.Changed()
+	if len(changed) == 0 {
+		fmt.Fprintln(w, "   No differences.")
+		return nil
+	}
+	var added, removed, updated int
+	for _, t := range changed {
+		a, r, u := t.Counts()
+		added, removed, updated = added+a, removed+r, updated+u
+		switch t.Status {
+		case TableAdded:
+			fmt.Fprintf(w, "\nTable '%s' only in MSI2 (%d row(s))\n", t.Table, a)
+		case TableRemoved:
+			fmt.Fprintf(w, "\nTable '%s' only in MSI1 (%d row(s))\n", t.Table, r)
+		default:
+			fmt.Fprintf(w, "\nTable '%s': %s\n", t.Table, t.Summary())
+		}
+		for _, c := range t.Schema {
+			fmt.Fprintf(w, "   %s\n", c)
+		}
+		if t.Status != TableChanged {
+			continue
+		}
+		for _, c := range t.Changes {
+			fmt.Fprintf(w, "   %s\n", c)
+		}
+	}
+	fmt.Fprintf(w, "\nSummary: %d of %d table(s) differ; %d row(s) added, %d removed, %d changed.\n",
+		len(changed), len(d.Tables), added, removed, updated)
+	return nil
+}
+
+// textEscaper keeps each row on one tab-separated line.
+var textEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+func textRow(values []string) string {
+	escaped := make([]string, len(values))
+	for i, v := range values {
+		escaped[i] = textEscaper.Replace(v)
+	}
+	return strings.Join(escaped, "\t")
+}
+
+// writeDiffText writes a unified-diff style view: a hunk per changed table
+// headed by its column names, removed rows as '-' lines, added rows as '+'
+// lines and changed rows as a '-' line followed by a '+' line.
+func writeDiffText(w io.Writer, d *MsiDiff) error {
+	fmt.Fprintf(w, "--- %s\n+++ %s\n", d.Old, d.New)
+	for _, t := range d.Changed() {
+		status := ""
+		if t.Status != TableChanged {
+			status = " (" + string(t.Status) + ")"
+		}
+		fmt.Fprintf(w, "@@ %s%s %s @@\n", t.Table, status, t.Summary())
+		fmt.Fprintf(w, " %s\n", textRow(t.Columns))
+		for _, c := range t.Schema {
+			fmt.Fprintf(w, "# %s\n", c)
+		}
+		for _, c := range t.Changes {
+			if c.Op != OpInsert {
+				fmt.Fprintf(w, "-%s\n", textRow(c.Old))
+			}
+			if c.Op != OpDelete {
+				fmt.Fprintf(w, "+%s\n", textRow(c.New))
+			}
+		}
+	}
+	return nil
+}
+
+type diffJSONDoc struct {
+	Old     string                   `json:"old"`
+	New     string                   `json:"new"`
+	Summary diffJSONSummary          `json:"summary"`
+	Tables  map[string]diffJSONTable `json:"tables"`
+}
+
+type diffJSONSummary struct {
+	Tables        int `json:"tables"`
+	ChangedTables int `json:"changedTables"`
+	Added         int `json:"added"`
+	Removed       int `json:"removed"`
+	Changed       int `json:"changed"`
+}
+
+type diffJSONTable struct {
+	Status    TableStatus            `json:"status"`
+	Added     int                    `json:"added"`
+	Removed   int                    `json:"removed"`
+	Changed   int                    `json:"changed"`
+	Unchanged int                    `json:"unchanged"`
+	Schema    []ColumnChange         `json:"schema,omitempty"`
+	Rows      map[string]diffJSONRow `json:"rows,omitempty"`
+}
+
+type diffJSONRow struct {
+	Op      ChangeOp                `json:"op"`
+	Key     []string                `json:"key"`
+	Columns map[string]diffJSONCell `json:"columns"`
+}
+
+type diffJSONCell struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// writeDiffJSON writes changed tables keyed by name, their changed rows
+// keyed by primary key (parts joined by '|') and each row's differing
+// columns with old and new values. Added and removed rows list every
+// non-null column.
+func writeDiffJSON(w io.Writer, d *MsiDiff) error {
+	doc := diffJSONDoc{Old: d.Old, New: d.New, Tables: map[string]diffJSONTable{}}
+	doc.Summary.Tables = len(d.Tables)
+	for _, t := range d.Changed() {
+		a, r, u := t.Counts()
+		doc.Summary.ChangedTables++
+		doc.Summary.Added += a
+		doc.Summary.Removed += r
+		doc.Summary.Changed += u
+		table := diffJSONTable{Status: t.Status, Added: a, Removed: r, Changed: u, Unchanged: t.Unchanged, Schema: t.Schema}
+		for _, c := range t.Changes {
+			if table.Rows == nil {
+				table.Rows = map[string]diffJSONRow{}
+			}
+			row := diffJSONRow{Op: c.Op, Key: c.Key, Columns: map[string]diffJSONCell{}}
+			for _, cell := range c.Cells() {
+				col, _, _ := t.schema.Column(cell.Column)
+				row.Columns[cell.Column] = diffJSONCell{Old: jsonValue(col, cell.Old), New: jsonValue(col, cell.New)}
+			}
+			table.Rows[diffRowKey(c.Key)] = row
+		}
+		doc.Tables[t.Table] = table
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// JSONPatchOp is one RFC 6902 operation.
+type JSONPatchOp struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// jsonPointer builds an RFC 6901 pointer from unescaped tokens.
+func jsonPointer(tokens ...string) string {
+	var sb strings.Builder
+	for _, t := range tokens {
+		sb.WriteByte('/')
+		sb.WriteString(pointerEscaper.Replace(t))
+	}
+	return sb.String()
+}
+
+// jsonRowObject renders a row as the JSON document represents it: every
+// non-empty column by name.
+func jsonRowObject(schema *TableSchema, values []string) map[string]interface{} {
+	obj := map[string]interface{}{}
+	for i, col := range schema.Columns {
+		if i < len(values) && values[i] != "" {
+			obj[col.Name] = jsonValue(col, values[i])
+		}
+	}
+	return obj
+}
+
+// DiffJSONPatch returns the RFC 6902 patch turning the old package into the
+// new one, in the JSON form {table: {rowKey: {column: value}}} where row
+// keys join the primary key with '|' and empty (null) cells are omitted.
+func DiffJSONPatch(d *MsiDiff) []JSONPatchOp {
+	var ops []JSONPatchOp
+	for _, t := range d.Changed() {
+		switch t.Status {
+		case TableRemoved:
+			ops = append(ops, JSONPatchOp{Op: "remove", Path: jsonPointer(t.Table)})
+			continue
+		case TableAdded:
+			rows := map[string]interface{}{}
+			for _, c := range t.Changes {
+				rows[diffRowKey(c.Key)] = jsonRowObject(t.schema, c.New)
+			}
+			ops = append(ops, JSONPatchOp{Op: "add", Path: jsonPointer(t.Table), Value: rows})
+			continue
+		}
+		for _, c := range t.Changes {
+			key := diffRowKey(c.Key)
+			switch c.Op {
+			case OpDelete:
+				ops = append(ops, JSONPatchOp{Op: "remove", Path: jsonPointer(t.Table, key)})
+			case OpInsert:
+				ops = append(ops, JSONPatchOp{Op: "add", Path: jsonPointer(t.Table, key), Value: jsonRowObject(t.schema, c.New)})
+			case OpUpdate:
+				for _, cell := range c.Cells() {
+					col, _, _ := t.schema.Column(cell.Column)
+					path := jsonPointer(t.Table, key, cell.Column)
+					switch {
+					case cell.Old == "":
+						ops = append(ops, JSONPatchOp{Op: "add", Path: path, Value: jsonValue(col, cell.New)})
+					case cell.New == "":
+						ops = append(ops, JSONPatchOp{Op: "remove", Path: path})
+					default:
+						ops = append(ops, JSONPatchOp{Op: "replace", Path: path, Value: jsonValue(col, cell.New)})
+					}
+				}
+			}
+		}
+	}
+	return ops
+}
+
+func writeDiffJSONPatch(w io.Writer, d *MsiDiff) error {
+	ops := DiffJSONPatch(d)
+	if ops == nil {
+		ops = []JSONPatchOp{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(ops)
+}
+
+// htmlCell is one side of a cell in the side-by-side report.
+type htmlCell struct {
+	Value   string
+	Changed bool
+}
+
+type htmlRow struct {
+	Op  string
+	Key string
+	Old []htmlCell // nil for added rows
+	New []htmlCell // nil for removed rows
+}
+
+type htmlTable struct {
+	TableDiff
+	Anchor string
+	Rows   []htmlRow
+}
+
+func htmlTables(d *MsiDiff) []htmlTable {
+	tables := make([]htmlTable, 0, len(d.Tables))
+	for i, t := range d.Tables {
+		ht := htmlTable{TableDiff: t, Anchor: fmt.Sprintf("t%d", i)}
+		for _, c := range t.Changes {
+			row := htmlRow{Op: string(c.Op), Key: strings.Join(c.Key, ", ")}
+			changed := map[string]bool{}
+			for _, cell := range c.Cells() {
+				changed[cell.Column] = c.Op == OpUpdate
+			}
+			side := func(values []string) []htmlCell {
+				if values == nil {
+					return nil
+				}
+				cells := make([]htmlCell, len(t.Columns))
+				for j, name := range t.Columns {
+					if j < len(values) {
+						cells[j] = htmlCell{Value: values[j], Changed: changed[name]}
+					}
+				}
+				return cells
+			}
+			row.Old, row.New = side(c.Old), side(c.New)
+			ht.Rows = append(ht.Rows, row)
+		}
+		tables = append(tables, ht)
+	}
+	return tables
+}
+
+var diffHTMLTemplate = template.Must(template.New("diff").Funcs(template.FuncMap{
+	"blank": func(n int) []struct{} { return make([]struct{}, n) },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>msicrafter diff: {{.Old}} → {{.New}}</title>
+<style>
+body { margin: 0; font: 13px/1.4 Consolas, Menlo, monospace; color: #222; }
+nav { position: fixed; top: 0; bottom: 0; left: 0; width: 240px; overflow-y: auto; background: #1d1f21; color: #c5c8c6; padding: 12px; box-sizing: border-box; }
+nav a { display: block; color: #81a2be; text-decoration: none; padding: 1px 0; }
+nav a.unchanged { color: #707880; }
+nav .n { float: right; color: #b5bd68; }
+main { margin-left: 240px; padding: 12px 20px; }
+details { margin: 8px 0; border: 1px solid #ccc; border-radius: 4px; }
+summary { cursor: pointer; padding: 6px 10px; background: #f3f3f3; font-weight: bold; }
+.badge { font-weight: normal; color: #666; margin-left: 8px; }
+.schema { margin: 6px 10px; color: #8a5a00; }
+table { border-collapse: collapse; margin: 6px 10px 10px; }
+th, td { border: 1px solid #ddd; padding: 2px 6px; vertical-align: top; white-space: pre-wrap; }
+th { background: #fafafa; }
+th.side { background: #e8e8e8; }
+td.sep, th.sep { border: none; width: 12px; }
+tr.add td.new { background: #e6ffec; }
+tr.del td.old { background: #ffebe9; }
+td.chg.old { background: #ffc1c0; }
+td.chg.new { background: #abf2bc; }
+</style>
+</head>
+<body>
+<nav>
+<strong>{{len .Changed}} of {{len .Diff.Tables}} tables differ</strong>
+{{range .Tables}}<a href="#{{.Anchor}}"{{if eq .Status "unchanged"}} class="unchanged"{{end}}>{{.Table}}{{if ne .Status "unchanged"}}<span class="n">{{.Summary}}</span>{{end}}</a>
+{{end}}</nav>
+<main>
+<h1>{{.Old}} → {{.New}}</h1>
+{{range .Tables}}{{$cols := .Columns}}<details id="{{.Anchor}}"{{if ne .Status "unchanged"}} open{{end}}>
+<summary>{{.Table}}<span class="badge">{{.Status}}{{if eq .Status "unchanged"}}, {{.Unchanged}} row(s){{else}}: {{.Summary}}{{end}}</span></summary>
+{{range .Schema}}<div class="schema">{{.}}</div>
+{{end}}{{if .Rows}}<table>
+<tr><th rowspan="2">key</th><th class="side" colspan="{{len $cols}}">MSI1</th><th class="sep"></th><th class="side" colspan="{{len $cols}}">MSI2</th></tr>
+<tr>{{range $cols}}<th>{{.}}</th>{{end}}<th class="sep"></th>{{range $cols}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr class="{{if eq .Op "+"}}add{{else if eq .Op "-"}}del{{else}}upd{{end}}"><td>{{.Op}} {{.Key}}</td>{{if .Old}}{{range .Old}}<td class="old{{if .Changed}} chg{{end}}">{{.Value}}</td>{{end}}{{else}}{{range blank (len $cols)}}<td class="old"></td>{{end}}{{end}}<td class="sep"></td>{{if .New}}{{range .New}}<td class="new{{if .Changed}} chg{{end}}">{{.Value}}</td>{{end}}{{else}}{{range blank (len $cols)}}<td class="new"></td>{{end}}{{end}}</tr>
+{{end}}</table>
+{{end}}</details>
+{{end}}</main>
+</body>
+</html>
+`))
+
+// writeDiffHTML writes a standalone HTML report: a navigation list of all
+// tables, then each table with its old and new rows side by side. Changed
+// cells are highlighted and unchanged tables start collapsed.
+func writeDiffHTML(w io.Writer, d *MsiDiff) error {
+	data := struct {
+		Old, New string
+		Diff     *MsiDiff
+		Changed  []TableDiff
+		Tables   []htmlTable
+	}{d.Old, d.New, d, d.Changed(), htmlTables(d)}
+	if err := diffHTMLTemplate.Execute(w, data); err != nil {
+		return fmt.Errorf("failed to render HTML diff: %v", err)
+	}
+	return nil
+}
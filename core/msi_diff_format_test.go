@@ -0,0 +1,108 @@
+// core/msi_diff_format_test.go
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func sampleDiff() *MsiDiff {
+	registry := []ColumnInfo{
+		{Name: "Registry", Type: "s72", Key: true},
+		{Name: "Root", Type: "i2"},
+		{Name: "Value", Type: "L0"},
+	}
+	oldReg := snapshot("Registry", registry, []string{"A", "2", "x"}, []string{"B/1", "2", ""}, []string{"C", "2", "gone"})
+	newReg := snapshot("Registry", registry, []string{"A", "1", "x"}, []string{"B/1", "2", "set"}, []string{"D", "2", "<new>"})
+	props := &tableSnapshot{schema: &TableSchema{Name: "Property", Columns: []ColumnInfo{
+		{Name: "Property", Type: "s72", Key: true},
+		{Name: "Value", Type: "l0"},
+	}}, rows: []TableRow{{Columns: []string{"P", "1"}}}}
+	extra := &tableSnapshot{schema: &TableSchema{Name: "Extra", Columns: []ColumnInfo{
+		{Name: "Id", Type: "s72", Key: true},
+	}}, rows: []TableRow{{Columns: []string{"E"}}}}
+	return &MsiDiff{Old: "v1.msi", New: "v2.msi", Tables: []TableDiff{
+		diffTable("Extra", nil, extra),
+		diffTable("Property", props, props),
+		diffTable("Registry", oldReg, newReg),
+	}}
+}
+
+func TestDiffJSONPatch(t *testing.T) {
+	ops := DiffJSONPatch(sampleDiff())
+	expected := []JSONPatchOp{
+		{Op: "add", Path: "/Extra", Value: map[string]interface{}{"E": map[string]interface{}{"Id": "E"}}},
+		{Op: "remove", Path: "/Registry/C"},
+		{Op: "replace", Path: "/Registry/A/Root", Value: 1},
+		{Op: "add", Path: "/Registry/B~11/Value", Value: "set"},
+		{Op: "add", Path: "/Registry/D", Value: map[string]interface{}{"Registry": "D", "Root": 2, "Value": "<new>"}},
+	}
+	if !reflect.DeepEqual(ops, expected) {
+		t.Errorf("Expected %v, got %v", expected, ops)
+	}
+}
+
+func TestWriteDiffJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteDiff(&buf, sampleDiff(), "json"); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	var doc diffJSONDoc
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if doc.Summary.Tables != 3 || doc.Summary.ChangedTables != 2 || doc.Summary.Added != 2 || doc.Summary.Changed != 2 {
+		t.Errorf("Unexpected summary: %+v", doc.Summary)
+	}
+	if _, ok := doc.Tables["Property"]; ok {
+		t.Error("Expected unchanged tables to be left out")
+	}
+	row := doc.Tables["Registry"].Rows["A"]
+	if row.Op != OpUpdate || len(row.Columns) != 1 || row.Columns["Root"].New != float64(1) {
+		t.Errorf("Unexpected row A: %+v", row)
+	}
+}
+
+func TestWriteDiffText(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteDiff(&buf, sampleDiff(), "text"); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	expected := "--- v1.msi\n+++ v2.msi\n" +
+		"@@ Extra (added) +1 -0 ~0 @@\n Id\n+E\n" +
+		"@@ Registry +1 -1 ~2 @@\n Registry\tRoot\tValue\n" +
+		"-C\t2\tgone\n" +
+		"-A\t2\tx\n+A\t1\tx\n" +
+		"-B/1\t2\t\n+B/1\t2\tset\n" +
+		"+D\t2\t<new>\n"
+	if buf.String() != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, buf.String())
+	}
+}
+
+func TestWriteDiffHTML(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteDiff(&buf, sampleDiff(), "html"); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		`<details id="t1">`,      // unchanged Property starts collapsed
+		`<details id="t2" open>`, // changed Registry starts open
+		`&lt;new&gt;`,
+		`<td class="new chg">1</td>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected HTML to contain %q", want)
+		}
+	}
+}
+
+func TestWriteDiff_UnknownFormat(t *testing.T) {
+	if err := WriteDiff(&bytes.Buffer{}, sampleDiff(), "yaml"); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,154 @@
+// core/msi_diff_test.go
+package core
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func snapshot(name string, cols []ColumnInfo, rows ...[]string) *tableSnapshot {
+	s := &tableSnapshot{schema: &TableSchema{Name: name, Columns: cols}}
+	for _, r := range rows {
+		s.rows = append(s.rows, TableRow{Columns: r})
+	}
+	return s
+}
+
+func TestDiffTable_AddedRemoved(t *testing.T) {
+	cols := []ColumnInfo{{Name: "Registry", Type: "s72", Key: true}}
+	table := snapshot("Registry", cols, []string{"A"}, []string{"B"})
+
+	added := diffTable("Registry", nil, table)
+	if a, _, _ := added.Counts(); added.Status != TableAdded || a != 2 {
+		t.Errorf("Expected an added table with 2 rows, got %s %s", added.Status, added.Summary())
+	}
+	removed := diffTable("Registry", table, nil)
+	if _, r, _ := removed.Counts(); removed.Status != TableRemoved || r != 2 {
+		t.Errorf("Expected a removed table with 2 rows, got %s %s", removed.Status, removed.Summary())
+	}
+}
+
+func TestDiffTable_Schema(t *testing.T) {
+	oldTable := snapshot("Registry", []ColumnInfo{
+		{Name: "Registry", Type: "s72", Key: true},
+		{Name: "Value", Type: "S72"},
+		{Name: "Legacy", Type: "I2"},
+	}, []string{"A", "x", "1"})
+	newTable := snapshot("Registry", []ColumnInfo{
+		{Name: "Registry", Type: "s72", Key: true},
+		{Name: "Value", Type: "S255"},
+		{Name: "Extra", Type: "S0"},
+	}, []string{"A", "x", "new"})
+
+	d := diffTable("Registry", oldTable, newTable)
+	expectedSchema := []ColumnChange{
+		{Column: "Value", OldType: "S72", NewType: "S255"},
+		{Column: "Extra", NewType: "S0"},
+		{Column: "Legacy", OldType: "I2"},
+	}
+	if !reflect.DeepEqual(d.Schema, expectedSchema) {
+		t.Errorf("Expected schema changes %v, got %v", expectedSchema, d.Schema)
+	}
+	if !reflect.DeepEqual(d.Columns, []string{"Registry", "Value", "Extra", "Legacy"}) {
+		t.Errorf("Unexpected merged columns: %v", d.Columns)
+	}
+	if len(d.Changes) != 1 || d.Changes[0].Op != OpUpdate {
+		t.Fatalf("Expected one updated row, got %v", d.Changes)
+	}
+	expectedCells := []CellChange{
+		{Column: "Extra", Old: "", New: "new"},
+		{Column: "Legacy", Old: "1", New: ""},
+	}
+	if !reflect.DeepEqual(d.Changes[0].Cells(), expectedCells) {
+		t.Errorf("Expected cells %v, got %v", expectedCells, d.Changes[0].Cells())
+	}
+}
+
+func TestMergeSchemas_NewKeyColumn(t *testing.T) {
+	oldSchema := &TableSchema{Name: "T", Columns: []ColumnInfo{
+		{Name: "A", Type: "s72", Key: true},
+	}}
+	newSchema := &TableSchema{Name: "T", Columns: []ColumnInfo{
+		{Name: "A", Type: "s72", Key: true},
+		{Name: "B", Type: "s72", Key: true},
+	}}
+	merged, _ := mergeSchemas(oldSchema, newSchema)
+	if keys := merged.KeyColumns(); len(keys) != 1 || keys[0].Name != "A" {
+		t.Errorf("Expected rows to match on the old key A, got %v", keys)
+	}
+}
+
+func TestTransformOps(t *testing.T) {
+	ops, err := transformOps(sampleDiff())
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	var got []string
+	for _, op := range ops {
+		got = append(got, formatTransformOp(op))
+	}
+	expected := []string{
+		`CREATE Extra (Id s72 KEY)`,
+		`+ Extra ("E")`,
+		`- Registry ("C")`,
+		`~ Registry ("A") Root="1"`,
+		`~ Registry ("B/1") Value="set"`,
+		`+ Registry ("D", "2", "<new>")`,
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected:\n%s\nGot:\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+
+	cols := []ColumnInfo{{Name: "Registry", Type: "s72", Key: true}, {Name: "Value", Type: "S72"}}
+	extended := append(cols, ColumnInfo{Name: "Extra", Type: "S0"})
+	alter, err := transformOps(&MsiDiff{Tables: []TableDiff{
+		diffTable("Registry", snapshot("Registry", cols, []string{"A", "x"}), snapshot("Registry", extended, []string{"A", "x", "y"})),
+	}})
+	if err != nil || len(alter) != 2 || formatTransformOp(alter[0]) != "ALTER Registry ADD Extra S0" {
+		t.Errorf("Expected an ALTER and an update, got %v (%v)", alter, err)
+	}
+	if _, err := transformOps(&MsiDiff{Tables: []TableDiff{
+		diffTable("Registry", snapshot("Registry", extended), snapshot("Registry", cols)),
+	}}); err == nil {
+		t.Error("Expected a removed column to be rejected")
+	}
+
+	binary := snapshot("Binary", []ColumnInfo{
+		{Name: "Name", Type: "s72", Key: true},
+		{Name: "Data", Type: "v0"},
+	}, []string{"Icon", ""})
+	added := &MsiDiff{Tables: []TableDiff{diffTable("Binary", snapshot("Binary", binary.schema.Columns), binary)}}
+	if _, err := transformOps(added); err == nil {
+		t.Error("Expected new rows with stream data to be rejected")
+	}
+	if ops, err := binaryTransformOps(added); err != nil || len(ops) != 1 {
+		t.Errorf("Expected a binary transform to carry new rows with stream data, got %v (%v)", ops, err)
+	}
+}
+
+func TestSetStreamDigests(t *testing.T) {
+	cols := []ColumnInfo{{Name: "Name", Type: "s72", Key: true}, {Name: "Data", Type: "v0"}}
+	storage := func(dll string) *msiStorage {
+		m := &msiStorage{root: newCFBStorage()}
+		m.root.Streams[cellStreamName("Binary", []string{"CA"})] = []byte(dll)
+		return m
+	}
+	withDigests := func(m *msiStorage) *tableSnapshot {
+		snap := snapshot("Binary", cols, []string{"CA", ""}, []string{"Empty", ""})
+		setStreamDigests(m, snap)
+		return snap
+	}
+	oldTable, newTable := withDigests(storage("v1")), withDigests(storage("v2"))
+	if oldTable.rows[0].Columns[1] != streamDigest([]byte("v1")) || oldTable.rows[1].Columns[1] != "" {
+		t.Errorf("Unexpected digests: %v", oldTable.rows)
+	}
+
+	d := diffTable("Binary", oldTable, newTable)
+	if d.Status != TableChanged || len(d.Changes) != 1 || d.Changes[0].Op != OpUpdate {
+		t.Fatalf("Expected the changed stream to update its row, got %v", d.Changes)
+	}
+	if _, err := transformOps(&MsiDiff{Tables: []TableDiff{d}}); err == nil {
+		t.Error("Expected changed stream data to be rejected")
+	}
+}
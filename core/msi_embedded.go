@@ -0,0 +1,242 @@
+// core/msi_embedded.go
+package core
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-ole/go-ole/oleutil"
+)
+
+// Packages embed transforms as substorages of the database, which
+// msiexec applies with TRANSFORMS=:<name>. They are rows of the _Storages
+// table, which Windows Installer can write but not read back, so they are
+// read straight from the compound file.
+
+// maxEmbeddedNameLength is the width of the _Storages.Name column.
+const maxEmbeddedNameLength = 62
+
+// EmbeddedStorage is a substorage of a package.
+type EmbeddedStorage struct {
+	Name      string
+	Size      int               // bytes in its streams
+	Transform bool              // the storage is an installer transform
+	Summary   *TransformSummary // nil unless Transform
+}
+
+// embeddedStorages lists the substorages of a package, sorted by name.
+func embeddedStorages(m *msiStorage) ([]EmbeddedStorage, error) {
+	var list []EmbeddedStorage
+	for _, name := range sortedKeys(m.root.Storages) {
+		sub := m.root.Storages[name]
+		e := EmbeddedStorage{Name: name, Size: storageSize(sub), Transform: sub.CLSID == msiTransformCLSID}
+		if data, ok := sub.Streams[summaryInfoStream]; ok && e.Transform {
+			props, err := readPropertySet(data)
+			if err != nil {
+				return nil, fmt.Errorf("embedded storage '%s': failed to read summary information: %v", name, err)
+			}
+			summary := transformSummaryFromProps(props)
+			e.Summary = &summary
+		}
+		list = append(list, e)
+	}
+	return list, nil
+}
+
+func storageSize(s *cfbStorage) int {
+	n := 0
+	for _, data := range s.Streams {
+		n += len(data)
+	}
+	for _, sub := range s.Storages {
+		n += storageSize(sub)
+	}
+	return n
+}
+
+// WriteEmbeddedStorages prints the substorages of a package with the
+// products and languages of the transforms among them.
+func WriteEmbeddedStorages(w io.Writer, list []EmbeddedStorage) {
+	if len(list) == 0 {
+		fmt.Fprintln(w, "No embedded storages.")
+		return
+	}
+	for _, e := range list {
+		if !e.Transform {
+			fmt.Fprintf(w, "%s  (%d bytes, not a transform)\n", e.Name, e.Size)
+			continue
+		}
+		fmt.Fprintf(w, "%s  (%d bytes)\n", e.Name, e.Size)
+		if e.Summary == nil {
+			continue
+		}
+		s := e.Summary
+		fmt.Fprintf(w, "  Base:       %s %s (%s)\n", s.BaseProductCode, s.BaseVersion, s.BasePlatform)
+		fmt.Fprintf(w, "  Target:     %s %s (%s)\n", s.TargetProductCode, s.TargetVersion, s.TargetPlatform)
+		if s.Validation != 0 {
+			fmt.Fprintf(w, "  Validation: %s\n", strings.Join(transformValidationFlags.Decode(s.Validation), " | "))
+		}
+	}
+}
+
+// ListEmbedded prints the substorages of a package.
+func ListEmbedded(msiPath string) error {
+	return SafeExecute("ListEmbedded", func() error {
+		m, err := readMsiStorageFile(msiPath)
+		if err != nil {
+			return err
+		}
+		list, err := embeddedStorages(m)
+		if err != nil {
+			return err
+		}
+		WriteEmbeddedStorages(os.Stdout, list)
+		return nil
+	})
+}
+
+// ExtractEmbedded writes the named substorages of a package, or all of
+// them when names is empty, to <name>.mst files in dir.
+func ExtractEmbedded(msiPath string, names []string, dir string) error {
+	return SafeExecute("ExtractEmbedded", func() error {
+		m, err := readMsiStorageFile(msiPath)
+		if err != nil {
+			return err
+		}
+		if len(names) == 0 {
+			names = sortedKeys(m.root.Storages)
+			if len(names) == 0 {
+				return fmt.Errorf("'%s' has no embedded storages", msiPath)
+			}
+		}
+		for _, name := range names {
+			sub, ok := m.root.Storages[name]
+			if !ok {
+				return fmt.Errorf("'%s' has no embedded storage '%s'", msiPath, name)
+			}
+			path := filepath.Join(dir, embeddedFileName(name))
+			if err := os.WriteFile(path, writeCFB(sub), 0644); err != nil {
+				return fmt.Errorf("failed to write '%s': %v", path, err)
+			}
+			fmt.Printf("Extracted '%s' to %s\n", name, path)
+		}
+		return nil
+	})
+}
+
+// embeddedFileName is the file an embedded storage is extracted to.
+// Storage names may hold characters Windows file names cannot.
+func embeddedFileName(name string) string {
+	clean := strings.Map(func(r rune) rune {
+		if r < 0x20 || strings.ContainsRune(`<>:"/\|?*`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if !strings.HasSuffix(strings.ToLower(clean), ".mst") {
+		clean += ".mst"
+	}
+	return clean
+}
+
+// AddEmbedded embeds the binary transform at mstPath in the package under
+// name, replacing an existing storage of that name only if replace is set.
+func AddEmbedded(msiPath, mstPath, name string, replace bool) error {
+	return SafeExecute("AddEmbedded", func() error {
+		if name == "" {
+			name = filepath.Base(mstPath)
+		}
+		if len(name) > maxEmbeddedNameLength {
+			return fmt.Errorf("embedded storage name '%s' is longer than %d characters", name, maxEmbeddedNameLength)
+		}
+		data, err := os.ReadFile(mstPath)
+		if err != nil {
+			return fmt.Errorf("failed to open MST file: %v", err)
+		}
+		if !isCFB(data) {
+			return fmt.Errorf("'%s' is a text transform; write a binary one with 'transform --binary' to embed it", mstPath)
+		}
+		mst, err := readCFB(data)
+		if err != nil {
+			return fmt.Errorf("%s: %v", mstPath, err)
+		}
+		if mst.CLSID != msiTransformCLSID {
+			return fmt.Errorf("'%s' is not a Windows Installer transform", mstPath)
+		}
+		m, err := readMsiStorageFile(msiPath)
+		if err != nil {
+			return err
+		}
+		_, exists := m.root.Storages[name]
+		if exists && !replace {
+			return fmt.Errorf("'%s' already embeds '%s'; pass --replace to overwrite it", msiPath, name)
+		}
+
+		session, err := OpenMsiSession(msiPath, 1)
+		if err != nil {
+			return fmt.Errorf("failed to open MSI session: %v", err)
+		}
+		defer session.Close()
+		if err := session.embedStorage(name, mstPath, exists); err != nil {
+			return err
+		}
+		if err := session.Commit(); err != nil {
+			return err
+		}
+		fmt.Printf("Embedded '%s' in '%s'; apply it with TRANSFORMS=:%s\n", mstPath, msiPath, name)
+		return nil
+	})
+}
+
+// embedStorage inserts the compound file at path into _Storages as name,
+// deleting the existing storage first when replace is set.
+func (s *MsiSession) embedStorage(name, path string, replace bool) error {
+	if s.mode != 1 {
+		return fmt.Errorf("statement not allowed in read-only mode")
+	}
+	if s.irreversible == "" {
+		s.irreversible = fmt.Sprintf("embedded storage '%s' is not journaled", name)
+	}
+	if replace {
+		view, err := s.openView("DELETE FROM `_Storages` WHERE `Name`=?")
+		if err != nil {
+			return err
+		}
+		err = s.executeView(view, []interface{}{name})
+		s.closeView(view)
+		if err != nil {
+			return fmt.Errorf("failed to remove embedded storage '%s': %v", name, err)
+		}
+	}
+
+	view, err := s.openView("SELECT `Name`, `Data` FROM `_Storages`")
+	if err != nil {
+		return err
+	}
+	defer s.closeView(view)
+	if _, err := oleutil.CallMethod(view, "Execute"); err != nil {
+		return fmt.Errorf("failed to open _Storages: %v", err)
+	}
+	recRaw, err := oleutil.CallMethod(s.installer, "CreateRecord", 2)
+	if err != nil {
+		return fmt.Errorf("failed to create record: %v", err)
+	}
+	rec := recRaw.ToIDispatch()
+	if rec == nil {
+		return fmt.Errorf("create record returned nil")
+	}
+	defer rec.Release()
+	if _, err := oleutil.PutProperty(rec, "StringData", 1, name); err != nil {
+		return fmt.Errorf("failed to set storage name: %v", err)
+	}
+	if _, err := oleutil.CallMethod(rec, "SetStream", 2, path); err != nil {
+		return fmt.Errorf("failed to read '%s' into the record: %v", path, err)
+	}
+	if _, err := oleutil.CallMethod(view, "Modify", msiViewModifyInsert, rec); err != nil {
+		return fmt.Errorf("failed to embed '%s': %v", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+// core/msi_embedded_test.go
+package core
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestEmbeddedStorages(t *testing.T) {
+	summary := TransformSummary{
+		BasePlatform:      "x64;1033",
+		TargetPlatform:    "x64;1031",
+		BaseProductCode:   "{11111111-1111-1111-1111-111111111111}",
+		BaseVersion:       "1.0.0",
+		TargetProductCode: "{11111111-1111-1111-1111-111111111111}",
+		TargetVersion:     "1.0.0",
+		Validation:        defaultTransformValidation,
+	}
+	props, err := transformSummaryProps(summary)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	mst := newCFBStorage()
+	mst.CLSID = msiTransformCLSID
+	mst.Streams[summaryInfoStream] = writePropertySet(props)
+	other := newCFBStorage()
+	other.Streams["data"] = []byte("12345")
+	root := newCFBStorage()
+	root.Storages["1031"] = mst
+	root.Storages["Other"] = other
+
+	list, err := embeddedStorages(&msiStorage{root: root})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(list) != 2 || list[0].Name != "1031" || !list[0].Transform || list[1].Transform || list[1].Size != 5 {
+		t.Fatalf("Unexpected storages: %+v", list)
+	}
+	if list[0].Summary == nil || list[0].Summary.TargetPlatform != "x64;1031" {
+		t.Errorf("Expected the transform summary, got %+v", list[0].Summary)
+	}
+
+	var buf bytes.Buffer
+	WriteEmbeddedStorages(&buf, list)
+	for _, want := range []string{"1031  (", "Target:     {11111111-1111-1111-1111-111111111111} 1.0.0 (x64;1031)", "Other  (5 bytes, not a transform)"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected %q in:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestEmbeddedFileName(t *testing.T) {
+	tests := map[string]string{
+		"1033":       "1033.mst",
+		"de-DE.mst":  "de-DE.mst",
+		"a/b:c":      "a_b_c.mst",
+		"Custom.MST": "Custom.MST",
+	}
+	for name, want := range tests {
+		if got := embeddedFileName(name); got != want {
+			t.Errorf("%q: expected %q, got %q", name, want, got)
+		}
+	}
+}
@@ -10,17 +10,15 @@ import (
 	"log"
 	"os"
 	"path/filepath"
-
-	"github.com/go-ole/go-ole"
-	"github.com/go-ole/go-ole/oleutil"
+	"strconv"
 )
 
 // ExportMSI exports MSI tables to CSV or JSON files and compresses them into a zip archive.
+// The files use the same layout ImportTable reads, so an export can be edited and imported back.
 func ExportMSI(msiPath, format, outputZip string) error {
-	if err := ole.CoInitialize(0); err != nil {
-		return fmt.Errorf("failed to initialize COM: %v", err)
+	if format != "csv" && format != "json" {
+		return fmt.Errorf("unsupported format: %s", format)
 	}
-	defer ole.CoUninitialize()
 
 	// Create a temporary directory to store exported files.
 	tmpDir, err := os.MkdirTemp("", "msi_export")
@@ -29,57 +27,33 @@ func ExportMSI(msiPath, format, outputZip string) error {
 	}
 	defer os.RemoveAll(tmpDir)
 
-	obj, err := oleutil.CreateObject("WindowsInstaller.Installer")
-	if err != nil {
-		return fmt.Errorf("COM object error: %v", err)
-	}
-	inst, err := obj.QueryInterface(ole.IID_IDispatch)
+	session, err := OpenMsiSession(msiPath, 0)
 	if err != nil {
-		return fmt.Errorf("QueryInterface error: %v", err)
+		return fmt.Errorf("failed to open MSI session: %v", err)
 	}
-	defer inst.Release()
+	defer session.Close()
 
-	dbRaw, err := oleutil.CallMethod(inst, "OpenDatabase", msiPath, 0)
+	tableNames, err := session.TableNames()
 	if err != nil {
-		return fmt.Errorf("OpenDatabase error: %v", err)
-	}
-	db := dbRaw.ToIDispatch()
-	defer db.Release()
-
-	viewDisp, err := oleutil.CallMethod(db, "OpenView", "SELECT * FROM `_Tables`")
-	if err != nil {
-		return fmt.Errorf("OpenView error: %v", err)
-	}
-	view := viewDisp.ToIDispatch()
-	defer view.Release()
-
-	_, _ = oleutil.CallMethod(view, "Execute", nil)
-
-	var tableNames []string
-	for {
-		recordDisp, err := oleutil.CallMethod(view, "Fetch")
-		if err != nil || recordDisp.Value() == nil {
-			break
-		}
-		record := recordDisp.ToIDispatch()
-		tableName, _ := oleutil.CallMethod(record, "StringData", 1)
-		tableNames = append(tableNames, tableName.ToString())
-		record.Release()
+		return err
 	}
-
-	// For demonstration, create dummy export files per table.
 	for _, table := range tableNames {
+		schema, err := session.GetTableSchema(table)
+		if err != nil {
+			return fmt.Errorf("failed to read schema for '%s': %v", table, err)
+		}
+		rows, err := session.ExecuteQuery(fmt.Sprintf("SELECT * FROM `%s`", table))
+		if err != nil {
+			return fmt.Errorf("failed to read '%s': %v", table, err)
+		}
 		filePath := filepath.Join(tmpDir, fmt.Sprintf("%s.%s", table, format))
 		if format == "csv" {
-			if err := exportDummyCSV(filePath, table); err != nil {
-				return err
-			}
-		} else if format == "json" {
-			if err := exportDummyJSON(filePath, table); err != nil {
-				return err
-			}
+			err = exportTableCSV(filePath, schema, rows)
 		} else {
-			return fmt.Errorf("unsupported format: %s", format)
+			err = exportTableJSON(filePath, schema, rows)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to export '%s': %v", table, err)
 		}
 	}
 
@@ -93,7 +67,8 @@ func ExportMSI(msiPath, format, outputZip string) error {
 	return nil
 }
 
-func exportDummyCSV(filePath, table string) error {
+// exportTableCSV writes a header of column names followed by one line per row.
+func exportTableCSV(filePath string, schema *TableSchema, rows []TableRow) error {
 	file, err := os.Create(filePath)
 	if err != nil {
 		return err
@@ -101,16 +76,28 @@ func exportDummyCSV(filePath, table string) error {
 	defer file.Close()
 
 	writer := csv.NewWriter(file)
-	// Dummy header and data row.
-	writer.Write([]string{"Column1", "Column2", "Column3"})
-	writer.Write([]string{table + "_data1", table + "_data2", table + "_data3"})
+	writer.Write(schema.ColumnNames())
+	for _, row := range rows {
+		writer.Write(row.Columns)
+	}
 	writer.Flush()
 	return writer.Error()
 }
 
-func exportDummyJSON(filePath, table string) error {
-	data := []map[string]string{
-		{"Column1": table + "_data1", "Column2": table + "_data2", "Column3": table + "_data3"},
+// exportTableJSON writes an array of objects keyed by column name. Integer
+// columns are written as numbers and empty values as null.
+func exportTableJSON(filePath string, schema *TableSchema, rows []TableRow) error {
+	data := make([]map[string]interface{}, 0, len(rows))
+	for _, row := range rows {
+		obj := map[string]interface{}{}
+		for i, col := range schema.Columns {
+			var value string
+			if i < len(row.Columns) {
+				value = row.Columns[i]
+			}
+			obj[col.Name] = jsonValue(col, value)
+		}
+		data = append(data, obj)
 	}
 	file, err := os.Create(filePath)
 	if err != nil {
@@ -118,9 +105,21 @@ func exportDummyJSON(filePath, table string) error {
 	}
 	defer file.Close()
 	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
 	return encoder.Encode(data)
 }
 
+// jsonValue renders a cell for JSON: integers as numbers, empty as null.
+func jsonValue(col ColumnInfo, value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	if n, err := strconv.Atoi(value); err == nil && col.IsInteger() {
+		return n
+	}
+	return value
+}
+
 func zipDirectory(srcDir, outputZip string) error {
 	zipFile, err := os.Create(outputZip)
 	if err != nil {
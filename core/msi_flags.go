@@ -0,0 +1,253 @@
+// core/msi_flags.go
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Flag is one named value of an attribute bitfield. Mask is the set of bits
+// the flag occupies; it equals Value for single-bit flags and is wider for
+// enumerations such as Component.Attributes' run-from option.
+type Flag struct {
+	Name     string
+	Value    int
+	Mask     int
+	Requires int // bits that must be set for the flag to apply
+	Excludes int // bits that must be clear for the flag to apply
+}
+
+// applies reports whether the flag is meaningful for value v.
+func (f Flag) applies(v int) bool {
+	return v&f.Requires == f.Requires && v&f.Excludes == 0
+}
+
+// FlagSet is the dictionary of one bitfield column. Flags are written as
+// Prefix+Name (msidbComponentAttributes64bit) or by Name alone (64bit).
+type FlagSet struct {
+	Prefix string
+	Flags  []Flag
+}
+
+func bit(name string, value int) Flag { return Flag{Name: name, Value: value, Mask: value} }
+
+func choice(name string, value, mask int) Flag { return Flag{Name: name, Value: value, Mask: mask} }
+
+// flagDictionary maps table and column to the bitfield's flag names.
+var flagDictionary = map[string]map[string]FlagSet{
+	"Component": {"Attributes": {Prefix: "msidbComponentAttributes", Flags: []Flag{
+		choice("LocalOnly", 0x0, 0x3), choice("SourceOnly", 0x1, 0x3), choice("Optional", 0x2, 0x3),
+		bit("RegistryKeyPath", 0x4), bit("SharedDllRefCount", 0x8), bit("Permanent", 0x10),
+		bit("ODBCDataSource", 0x20), bit("Transitive", 0x40), bit("NeverOverwrite", 0x80),
+		bit("64bit", 0x100), bit("DisableRegistryReflection", 0x200),
+		bit("UninstallOnSupersedence", 0x400), bit("Shared", 0x800),
+	}}},
+	"File": {"Attributes": {Prefix: "msidbFileAttributes", Flags: []Flag{
+		bit("ReadOnly", 0x1), bit("Hidden", 0x2), bit("System", 0x4), bit("Vital", 0x200),
+		bit("Checksum", 0x400), bit("PatchAdded", 0x1000), bit("Noncompressed", 0x2000),
+		bit("Compressed", 0x4000),
+	}}},
+	"Feature": {"Attributes": {Prefix: "msidbFeatureAttributes", Flags: []Flag{
+		choice("FavorLocal", 0x0, 0x3), choice("FavorSource", 0x1, 0x3), choice("FollowParent", 0x2, 0x3),
+		bit("FavorAdvertise", 0x4), bit("DisallowAdvertise", 0x8), bit("UIDisallowAbsent", 0x10),
+		bit("NoUnsupportedAdvertise", 0x20),
+	}}},
+	"CustomAction": {"Type": {Prefix: "msidbCustomActionType", Flags: []Flag{
+		choice("Dll", 0x1, 0x7), choice("Exe", 0x2, 0x7), choice("TextData", 0x3, 0x7),
+		choice("JScript", 0x5, 0x7), choice("VBScript", 0x6, 0x7), choice("Install", 0x7, 0x7),
+		choice("BinaryData", 0x0, 0x30), choice("SourceFile", 0x10, 0x30),
+		choice("Directory", 0x20, 0x30), choice("Property", 0x30, 0x30),
+		bit("Continue", 0x40), bit("Async", 0x80),
+		{Name: "FirstSequence", Value: 0x100, Mask: 0x300, Excludes: 0x400},
+		{Name: "OncePerProcess", Value: 0x200, Mask: 0x300, Excludes: 0x400},
+		{Name: "ClientRepeat", Value: 0x300, Mask: 0x300, Excludes: 0x400},
+		bit("InScript", 0x400),
+		{Name: "Rollback", Value: 0x100, Mask: 0x100, Requires: 0x400},
+		{Name: "Commit", Value: 0x200, Mask: 0x200, Requires: 0x400},
+		bit("NoImpersonate", 0x800), bit("64BitScript", 0x1000), bit("HideTarget", 0x2000),
+		bit("TSAware", 0x4000), bit("PatchUninstall", 0x8000),
+	}}},
+	"Control": {"Attributes": {Prefix: "msidbControlAttributes", Flags: []Flag{
+		bit("Visible", 0x1), bit("Enabled", 0x2), bit("Sunken", 0x4), bit("Indirect", 0x8),
+		bit("Integer", 0x10), bit("RTLRO", 0x20), bit("RightAligned", 0x40), bit("LeftScroll", 0x80),
+	}}},
+	"ServiceInstall": {"ServiceType": {Prefix: "SERVICE_", Flags: []Flag{
+		bit("WIN32_OWN_PROCESS", 0x10), bit("WIN32_SHARE_PROCESS", 0x20), bit("INTERACTIVE_PROCESS", 0x100),
+	}}},
+}
+
+// LookupFlagSet returns the flag dictionary of a bitfield column.
+func LookupFlagSet(table, column string) (FlagSet, bool) {
+	set, ok := flagDictionary[table][column]
+	return set, ok
+}
+
+// lookup finds a flag by full or short name, ignoring case.
+func (fs FlagSet) lookup(name string) (Flag, bool) {
+	for _, f := range fs.Flags {
+		if strings.EqualFold(name, f.Name) || strings.EqualFold(name, fs.Prefix+f.Name) {
+			return f, true
+		}
+	}
+	return Flag{}, false
+}
+
+// Names returns the full names of every flag in the set.
+func (fs FlagSet) Names() []string {
+	names := make([]string, len(fs.Flags))
+	for i, f := range fs.Flags {
+		names[i] = fs.Prefix + f.Name
+	}
+	return names
+}
+
+// Decode lists the flags set in v, followed by any leftover bits in hex.
+func (fs FlagSet) Decode(v int) []string {
+	var names []string
+	covered := 0
+	for _, f := range fs.Flags {
+		if f.applies(v) && v&f.Mask == f.Value && covered&f.Mask == 0 {
+			names = append(names, fs.Prefix+f.Name)
+			covered |= f.Mask
+		}
+	}
+	if rest := v &^ covered; rest != 0 {
+		names = append(names, fmt.Sprintf("0x%X", rest))
+	}
+	return names
+}
+
+// ResolveFlagValue turns a symbolic bitfield value into an integer string.
+// spec is either an absolute list of flags joined by '|' or a comma-separated
+// list of +flag/-flag edits applied to current. Plain integers and columns
+// without a dictionary are returned unchanged with resolved set to false.
+func ResolveFlagValue(table, column, current, spec string) (value string, resolved bool, err error) {
+	fs, ok := LookupFlagSet(table, column)
+	if !ok {
+		return spec, false, nil
+	}
+	spec = strings.TrimSpace(spec)
+	if _, err := strconv.Atoi(spec); err == nil || spec == "" {
+		return spec, false, nil
+	}
+	unknown := func(name string) error {
+		return fmt.Errorf("unknown flag '%s' for %s.%s; known flags: %s", name, table, column, strings.Join(fs.Names(), ", "))
+	}
+
+	if !strings.HasPrefix(spec, "+") && !strings.HasPrefix(spec, "-") {
+		v := 0
+		for _, name := range strings.Split(spec, "|") {
+			f, ok := fs.lookup(strings.TrimSpace(name))
+			if !ok {
+				return "", false, unknown(name)
+			}
+			v = v&^f.Mask | f.Value
+		}
+		return strconv.Itoa(v), true, nil
+	}
+
+	v := 0
+	if strings.TrimSpace(current) != "" {
+		if v, err = strconv.Atoi(strings.TrimSpace(current)); err != nil {
+			return "", false, fmt.Errorf("%s.%s holds '%s', not an integer", table, column, current)
+		}
+	}
+	for _, edit := range strings.Split(spec, ",") {
+		edit = strings.TrimSpace(edit)
+		if len(edit) < 2 || (edit[0] != '+' && edit[0] != '-') {
+			return "", false, fmt.Errorf("invalid flag edit '%s': expected +flag or -flag", edit)
+		}
+		f, ok := fs.lookup(edit[1:])
+		if !ok {
+			return "", false, unknown(edit[1:])
+		}
+		if edit[0] == '+' {
+			v = v&^f.Mask | f.Value
+		} else if v&f.Mask == f.Value {
+			v &^= f.Mask
+		}
+	}
+	return strconv.Itoa(v), true, nil
+}
+
+// isRelativeFlagEdit reports whether a set value edits flags relative to the
+// current value and so must be resolved row by row.
+func isRelativeFlagEdit(table, column, value string) bool {
+	if _, ok := LookupFlagSet(table, column); !ok {
+		return false
+	}
+	value = strings.TrimSpace(value)
+	if _, err := strconv.Atoi(value); err == nil {
+		return false
+	}
+	return strings.HasPrefix(value, "+") || strings.HasPrefix(value, "-")
+}
+
+// resolveFlagFields resolves every symbolic bitfield value in fields against
+// row, the current contents of the row being edited (nil for absolute values only).
+func resolveFlagFields(schema *TableSchema, row *TableRow, fields map[string]string) (map[string]string, error) {
+	resolved := make(map[string]string, len(fields))
+	for name, spec := range fields {
+		current := ""
+		if _, idx, ok := schema.Column(name); ok && row != nil && idx < len(row.Columns) {
+			current = row.Columns[idx]
+		}
+		if row == nil && isRelativeFlagEdit(schema.Name, name, spec) {
+			return nil, fmt.Errorf("%s.%s: +flag/-flag edits need an existing row", schema.Name, name)
+		}
+		value, _, err := ResolveFlagValue(schema.Name, name, current, spec)
+		if err != nil {
+			return nil, err
+		}
+		resolved[name] = value
+	}
+	return resolved, nil
+}
+
+// DecodeFlagRows returns a copy of rows with every bitfield column of table
+// shown as "value (flag|flag)".
+func DecodeFlagRows(msiPath, table string, rows []TableRow) ([]TableRow, error) {
+	session, err := OpenMsiSession(msiPath, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open MSI session: %v", err)
+	}
+	defer session.Close()
+	schema, err := session.GetTableSchema(table)
+	if err != nil {
+		return nil, err
+	}
+	return decodeFlagRows(schema, rows), nil
+}
+
+func decodeFlagRows(schema *TableSchema, rows []TableRow) []TableRow {
+	sets := map[int]FlagSet{}
+	for i, col := range schema.Columns {
+		if fs, ok := LookupFlagSet(schema.Name, col.Name); ok {
+			sets[i] = fs
+		}
+	}
+	idxs := make([]int, 0, len(sets))
+	for i := range sets {
+		idxs = append(idxs, i)
+	}
+	sort.Ints(idxs)
+
+	decoded := make([]TableRow, len(rows))
+	for r, row := range rows {
+		cols := append([]string(nil), row.Columns...)
+		for _, i := range idxs {
+			if i >= len(cols) {
+				continue
+			}
+			if v, err := strconv.Atoi(cols[i]); err == nil {
+				if names := sets[i].Decode(v); len(names) > 0 {
+					cols[i] = fmt.Sprintf("%d (%s)", v, strings.Join(names, "|"))
+				}
+			}
+		}
+		decoded[r] = TableRow{Columns: cols}
+	}
+	return decoded
+}
@@ -0,0 +1,82 @@
+// core/msi_flags_test.go
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResolveFlagValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		table    string
+		column   string
+		current  string
+		spec     string
+		expected string
+		wantErr  bool
+	}{
+		{"add and remove", "Component", "Attributes", "16", "+msidbComponentAttributes64bit,-msidbComponentAttributesPermanent", "256", false},
+		{"short names", "Component", "Attributes", "0", "+64bit,+permanent", "272", false},
+		{"remove unset flag", "Component", "Attributes", "256", "-Permanent", "256", false},
+		{"enum replaces field", "Component", "Attributes", "1", "+Optional", "2", false},
+		{"absolute", "Component", "Attributes", "999", "Permanent|64bit", "272", false},
+		{"integer passes through", "Component", "Attributes", "0", "260", "260", false},
+		{"negative integer", "Control", "Attributes", "0", "-1", "-1", false},
+		{"deferred custom action", "CustomAction", "Type", "1", "+InScript,+NoImpersonate", "3073", false},
+		{"unknown flag", "Component", "Attributes", "0", "+Bogus", "", true},
+		{"non-integer current", "File", "Attributes", "abc", "+Vital", "", true},
+		{"no dictionary", "Registry", "Name", "", "-", "-", false},
+	}
+	for _, tt := range tests {
+		got, _, err := ResolveFlagValue(tt.table, tt.column, tt.current, tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("%s: got %q, expected %q", tt.name, got, tt.expected)
+		}
+	}
+}
+
+func TestFlagSetDecode(t *testing.T) {
+	fs, _ := LookupFlagSet("Component", "Attributes")
+	got := fs.Decode(0x104 | 0x10000)
+	expected := []string{"msidbComponentAttributesLocalOnly", "msidbComponentAttributesRegistryKeyPath", "msidbComponentAttributes64bit", "0x10000"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+
+	ca, _ := LookupFlagSet("CustomAction", "Type")
+	got = ca.Decode(0x501)
+	expected = []string{"msidbCustomActionTypeDll", "msidbCustomActionTypeBinaryData", "msidbCustomActionTypeInScript", "msidbCustomActionTypeRollback"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestParseSetFields_FlagEdits(t *testing.T) {
+	fields, err := parseSetFields("Attributes=+Permanent,-64bit,KeyPath=File1")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if fields["Attributes"] != "+Permanent,-64bit" || fields["KeyPath"] != "File1" {
+		t.Errorf("Unexpected fields: %v", fields)
+	}
+}
+
+func TestDecodeFlagRows(t *testing.T) {
+	schema := &TableSchema{Name: "File", Columns: []ColumnInfo{
+		{Name: "File", Type: "s72", Key: true},
+		{Name: "Attributes", Type: "I2"},
+	}}
+	rows := []TableRow{{Columns: []string{"File1", "512"}}, {Columns: []string{"File2", ""}}}
+	decoded := decodeFlagRows(schema, rows)
+	if decoded[0].Columns[1] != "512 (msidbFileAttributesVital)" || decoded[1].Columns[1] != "" {
+		t.Errorf("Unexpected decoded rows: %v", decoded)
+	}
+	if rows[0].Columns[1] != "512" {
+		t.Error("Expected the original rows to be left untouched")
+	}
+}
@@ -0,0 +1,374 @@
+// core/msi_import.go
+package core
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ImportMode selects how imported rows are merged into a table.
+type ImportMode string
+
+const (
+	ImportInsert  ImportMode = "insert"  // add new rows; existing keys are an error
+	ImportUpsert  ImportMode = "upsert"  // add new rows and update existing ones
+	ImportReplace ImportMode = "replace" // upsert, then delete rows missing from the import
+)
+
+// ImportResult counts what an import did (or would do in a dry run).
+type ImportResult struct {
+	Inserted  int
+	Updated   int
+	Unchanged int
+	Deleted   int
+}
+
+func (r ImportResult) String() string {
+	return fmt.Sprintf("%d inserted, %d updated, %d unchanged, %d deleted", r.Inserted, r.Updated, r.Unchanged, r.Deleted)
+}
+
+// ImportTable loads rows from a CSV or JSON file into a table. Every row is
+// coerced and validated before anything is written; the import is committed
+// as a single transaction.
+func ImportTable(msiPath, table, dataPath string, mode ImportMode, dryRun, interactive bool) (*ImportResult, error) {
+	var result *ImportResult
+	err := SafeExecute("ImportTable", func() error {
+		records, err := readImportRecords(dataPath)
+		if err != nil {
+			return err
+		}
+		if len(records) == 0 {
+			return fmt.Errorf("no rows found in %s", dataPath)
+		}
+
+		session, err := OpenMsiSession(msiPath, 1)
+		if err != nil {
+			return fmt.Errorf("failed to open MSI session: %v", err)
+		}
+		defer session.Close()
+
+		changes, res, err := session.PlanImport(table, records, mode)
+		if err != nil {
+			return err
+		}
+		result = res
+		for _, c := range changes {
+			fmt.Printf("   %s\n", c)
+		}
+		fmt.Printf("Import into '%s': %s\n", table, res)
+
+		if dryRun {
+			log.Println("[DRY-RUN] Import validated; no changes committed.")
+			return nil
+		}
+		if len(changes) == 0 {
+			return nil
+		}
+		if interactive {
+			if err := confirmOrCancel(fmt.Sprintf("Apply %d change(s) to '%s'?", len(changes), table), "import"); err != nil {
+				return err
+			}
+		}
+		if err := session.ApplyRowChanges(changes); err != nil {
+			return err
+		}
+		return session.Commit()
+	})
+	return result, err
+}
+
+// PlanImport matches records against the table on its primary key and
+// returns the row changes needed, after validating every value.
+func (s *MsiSession) PlanImport(table string, records []map[string]string, mode ImportMode) ([]RowChange, *ImportResult, error) {
+	if mode != ImportInsert && mode != ImportUpsert && mode != ImportReplace {
+		return nil, nil, fmt.Errorf("unknown import mode '%s'", mode)
+	}
+	schema, err := s.GetTableSchema(table)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read schema for '%s': %v", table, err)
+	}
+	if len(schema.KeyColumns()) == 0 {
+		return nil, nil, fmt.Errorf("table '%s' has no primary key to match rows on", table)
+	}
+	rules, err := s.LoadValidationRules(table)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	existingRows, err := s.ExecuteQuery(fmt.Sprintf("SELECT * FROM `%s`", table))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read '%s': %v", table, err)
+	}
+	existing := map[string]TableRow{}
+	for _, row := range existingRows {
+		existing[schema.RowKey(row)] = row
+	}
+
+	var violations ValidationErrors
+	var rows []importRow
+	seen := map[string]int{}
+	for n, rec := range records {
+		imp, errs := coerceImportRecord(schema, rules, rec, n+1)
+		violations = append(violations, errs...)
+		if len(errs) > 0 {
+			continue
+		}
+		row := imp.row
+		key := schema.RowKey(row)
+		if first, dup := seen[key]; dup {
+			violations = append(violations, ValidationError{table, "(key)", "Unique", strings.Join(schema.KeyValues(row), ","),
+				fmt.Sprintf("row %d repeats the key of row %d", n+1, first)})
+			continue
+		}
+		seen[key] = n + 1
+		if _, ok := existing[key]; ok && mode == ImportInsert {
+			violations = append(violations, ValidationError{table, "(key)", "Unique", strings.Join(schema.KeyValues(row), ","),
+				fmt.Sprintf("row %d already exists; use --mode upsert or replace", n+1)})
+			continue
+		}
+		if _, ok := existing[key]; !ok {
+			// New rows must satisfy every column, including those the file omits.
+			for i, col := range schema.Columns {
+				if imp.provided[i] || col.IsBinary() {
+					continue
+				}
+				if v := validateValue(col, ValidationRule{Table: table, Column: col.Name, Nullable: rules[col.Name].Nullable}, ""); v != nil {
+					v.Reason = fmt.Sprintf("row %d: %s", n+1, v.Reason)
+					violations = append(violations, *v)
+				}
+			}
+		}
+		rows = append(rows, imp)
+	}
+
+	// Foreign keys may be satisfied by the database or by other imported rows.
+	for _, imp := range rows {
+		for i, col := range schema.Columns {
+			rule, ok := rules[col.Name]
+			value := imp.row.Columns[i]
+			if !ok || value == "" || rule.KeyTable == "" || rule.KeyColumn < 1 {
+				continue
+			}
+			if rule.KeyTable == table && rule.KeyColumn <= len(schema.Columns) && importedValue(rows, rule.KeyColumn-1, value) {
+				continue
+			}
+			found, err := s.keyExists(rule.KeyTable, rule.KeyColumn, value)
+			if err != nil {
+				return nil, nil, err
+			}
+			if !found {
+				violations = append(violations, ValidationError{table, col.Name, "KeyTable", value,
+					fmt.Sprintf("row %d: no row in %s with column %d equal to it", imp.num, rule.KeyTable, rule.KeyColumn)})
+			}
+		}
+	}
+	if len(violations) > 0 {
+		return nil, nil, fmt.Errorf("import validation failed:\n%v", violations)
+	}
+
+	result := &ImportResult{}
+	var changes []RowChange
+	for _, imp := range rows {
+		row := imp.row
+		key := schema.RowKey(row)
+		old, ok := existing[key]
+		if !ok {
+			changes = append(changes, RowChange{Op: OpInsert, Table: table, Columns: schema.ColumnNames(),
+				Key: schema.KeyValues(row), New: row.Columns})
+			result.Inserted++
+			continue
+		}
+		merged := mergeImportRow(schema, old, imp)
+		change := RowChange{Op: OpUpdate, Table: table, Columns: schema.ColumnNames(),
+			Key: schema.KeyValues(row), Old: old.Columns, New: merged}
+		if len(change.Cells()) == 0 {
+			result.Unchanged++
+			continue
+		}
+		changes = append(changes, change)
+		result.Updated++
+	}
+	if mode == ImportReplace {
+		for _, row := range existingRows {
+			if _, ok := seen[schema.RowKey(row)]; !ok {
+				changes = append(changes, RowChange{Op: OpDelete, Table: table, Columns: schema.ColumnNames(),
+					Key: schema.KeyValues(row), Old: row.Columns})
+				result.Deleted++
+			}
+		}
+	}
+	return changes, result, nil
+}
+
+// importRow is an imported record coerced to a full table row.
+type importRow struct {
+	row      TableRow
+	provided []bool // columns present in the import file
+	num      int    // 1-based record number for error messages
+}
+
+// coerceImportRecord converts one imported record into a full table row,
+// normalising integers and validating each provided value against its column.
+// Columns absent from the record are left empty.
+func coerceImportRecord(schema *TableSchema, rules map[string]ValidationRule, rec map[string]string, rowNum int) (importRow, ValidationErrors) {
+	var errs ValidationErrors
+	for _, name := range sortedKeys(rec) {
+		if _, _, ok := schema.Column(name); !ok {
+			errs = append(errs, ValidationError{schema.Name, name, "Column", rec[name], fmt.Sprintf("row %d: no such column", rowNum)})
+		}
+	}
+	imp := importRow{
+		row:      TableRow{Columns: make([]string, len(schema.Columns))},
+		provided: make([]bool, len(schema.Columns)),
+		num:      rowNum,
+	}
+	for i, col := range schema.Columns {
+		raw, provided := rec[col.Name]
+		imp.provided[i] = provided
+		value, err := coerceValue(col, raw)
+		if err != nil {
+			errs = append(errs, ValidationError{schema.Name, col.Name, "Type", raw, fmt.Sprintf("row %d: %v", rowNum, err)})
+			continue
+		}
+		imp.row.Columns[i] = value
+		if col.IsBinary() || (!provided && !col.Key) {
+			continue
+		}
+		rule, ok := rules[col.Name]
+		if !ok {
+			rule = ValidationRule{Table: schema.Name, Column: col.Name}
+		}
+		// Foreign keys are checked once all rows are known.
+		rule.KeyTable = ""
+		if v := validateValue(col, rule, value); v != nil {
+			v.Reason = fmt.Sprintf("row %d: %s", rowNum, v.Reason)
+			errs = append(errs, *v)
+		}
+	}
+	return imp, errs
+}
+
+// coerceValue normalises an imported value for a column: integers accept
+// decimal, hex (0x) and whole-number floats such as JSON's 1.0.
+func coerceValue(col ColumnInfo, value string) (string, error) {
+	if !col.IsInteger() {
+		return value, nil
+	}
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", nil
+	}
+	if n, err := strconv.ParseInt(value, 0, 32); err == nil {
+		return strconv.FormatInt(n, 10), nil
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil && f == float64(int32(f)) {
+		return strconv.Itoa(int(int32(f))), nil
+	}
+	return "", fmt.Errorf("'%s' is not an integer", value)
+}
+
+// mergeImportRow overlays the columns an import provides on an existing row.
+// Stream columns cannot be imported and always keep their current value.
+func mergeImportRow(schema *TableSchema, old TableRow, imp importRow) []string {
+	merged := make([]string, len(schema.Columns))
+	for i, col := range schema.Columns {
+		if i < len(old.Columns) {
+			merged[i] = old.Columns[i]
+		}
+		if imp.provided[i] && !col.IsBinary() {
+			merged[i] = imp.row.Columns[i]
+		}
+	}
+	return merged
+}
+
+// importedValue reports whether any imported row holds value in column idx.
+func importedValue(rows []importRow, idx int, value string) bool {
+	for _, r := range rows {
+		if idx < len(r.row.Columns) && r.row.Columns[idx] == value {
+			return true
+		}
+	}
+	return false
+}
+
+// readImportRecords reads a CSV file (header row of column names) or a JSON
+// array of objects, chosen by file extension.
+func readImportRecords(path string) ([]map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open import file: %v", err)
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return parseCSVRecords(f)
+	case ".json":
+		return parseJSONRecords(f)
+	}
+	return nil, fmt.Errorf("unsupported import file '%s': expected .csv or .json", path)
+}
+
+func parseCSVRecords(r io.Reader) ([]map[string]string, error) {
+	reader := csv.NewReader(r)
+	lines, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CSV: %v", err)
+	}
+	if len(lines) == 0 {
+		return nil, nil
+	}
+	header := lines[0]
+	var records []map[string]string
+	for _, line := range lines[1:] {
+		rec := map[string]string{}
+		for i, name := range header {
+			if i < len(line) {
+				rec[strings.TrimSpace(name)] = line[i]
+			}
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+func parseJSONRecords(r io.Reader) ([]map[string]string, error) {
+	var raw []map[string]interface{}
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: expected an array of objects: %v", err)
+	}
+	records := make([]map[string]string, 0, len(raw))
+	for i, obj := range raw {
+		rec := map[string]string{}
+		for name, v := range obj {
+			switch val := v.(type) {
+			case nil:
+				rec[name] = ""
+			case string:
+				rec[name] = val
+			case json.Number:
+				rec[name] = val.String()
+			case bool:
+				if val {
+					rec[name] = "1"
+				} else {
+					rec[name] = "0"
+				}
+			default:
+				return nil, fmt.Errorf("row %d: column '%s' must be a string, number or null", i+1, name)
+			}
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
@@ -0,0 +1,99 @@
+// core/msi_import_test.go
+package core
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCoerceValue(t *testing.T) {
+	intCol := ColumnInfo{Name: "Attributes", Type: "i2"}
+	strCol := ColumnInfo{Name: "Value", Type: "S255"}
+	tests := []struct {
+		col      ColumnInfo
+		input    string
+		expected string
+		wantErr  bool
+	}{
+		{intCol, "42", "42", false},
+		{intCol, " 0x10 ", "16", false},
+		{intCol, "3.0", "3", false},
+		{intCol, "", "", false},
+		{intCol, "3.5", "", true},
+		{intCol, "abc", "", true},
+		{strCol, " keep spaces ", " keep spaces ", false},
+	}
+	for _, tt := range tests {
+		got, err := coerceValue(tt.col, tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("coerceValue(%q): error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("coerceValue(%q) = %q, expected %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestParseCSVRecords(t *testing.T) {
+	input := "Property,Value\nProductName,\"My App, Pro\"\nARPNOMODIFY,1\n"
+	records, err := parseCSVRecords(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	expected := []map[string]string{
+		{"Property": "ProductName", "Value": "My App, Pro"},
+		{"Property": "ARPNOMODIFY", "Value": "1"},
+	}
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("Expected %v, got %v", expected, records)
+	}
+}
+
+func TestParseJSONRecords(t *testing.T) {
+	input := `[{"Registry": "Reg1", "Root": 2, "Name": null, "Enabled": true}]`
+	records, err := parseJSONRecords(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	expected := []map[string]string{{"Registry": "Reg1", "Root": "2", "Name": "", "Enabled": "1"}}
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("Expected %v, got %v", expected, records)
+	}
+
+	if _, err := parseJSONRecords(strings.NewReader(`{"Registry": "Reg1"}`)); err == nil {
+		t.Error("Expected an error for a JSON object instead of an array")
+	}
+	if _, err := parseJSONRecords(strings.NewReader(`[{"Root": [1]}]`)); err == nil {
+		t.Error("Expected an error for a nested array value")
+	}
+}
+
+func TestCoerceImportRecord(t *testing.T) {
+	schema := &TableSchema{Name: "Property", Columns: []ColumnInfo{
+		{Name: "Property", Type: "s72", Key: true},
+		{Name: "Value", Type: "l0"},
+	}}
+	imp, errs := coerceImportRecord(schema, nil, map[string]string{"Property": "ARPNOMODIFY"}, 1)
+	if len(errs) != 0 {
+		t.Fatalf("Expected no errors, got: %v", errs)
+	}
+	if !reflect.DeepEqual(imp.provided, []bool{true, false}) {
+		t.Errorf("Expected only the key to be provided, got %v", imp.provided)
+	}
+
+	old := TableRow{Columns: []string{"ARPNOMODIFY", "1"}}
+	if merged := mergeImportRow(schema, old, imp); !reflect.DeepEqual(merged, old.Columns) {
+		t.Errorf("Expected omitted columns to keep their value, got %v", merged)
+	}
+
+	_, errs = coerceImportRecord(schema, nil, map[string]string{"Property": "X", "Bogus": "1"}, 2)
+	if len(errs) != 1 || errs[0].Column != "Bogus" {
+		t.Errorf("Expected one error for the unknown column, got %v", errs)
+	}
+	_, errs = coerceImportRecord(schema, nil, map[string]string{"Value": "1"}, 3)
+	if len(errs) != 1 || errs[0].Column != "Property" {
+		t.Errorf("Expected one error for the missing key, got %v", errs)
+	}
+}
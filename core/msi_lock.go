@@ -0,0 +1,208 @@
+// core/msi_lock.go
+package core
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// LockWait is how long a write session waits for another process's lock on
+// the same MSI before giving up. Zero fails immediately.
+var LockWait time.Duration
+
+// lockPollInterval is how often a waiting session retries the lock.
+const lockPollInterval = 250 * time.Millisecond
+
+// fileLock is an advisory lock file next to an MSI recording its owner.
+type fileLock struct {
+	path string
+}
+
+// lockOwner describes the process holding a lock.
+type lockOwner struct {
+	Host  string
+	PID   int
+	Since time.Time
+}
+
+func (o lockOwner) String() string {
+	return fmt.Sprintf("%s (PID %d) since %s", o.Host, o.PID, o.Since.Format("2006-01-02 15:04:05"))
+}
+
+// LockPath returns the lock file used for an MSI.
+func LockPath(msiPath string) string {
+	return msiPath + ".lock"
+}
+
+// acquireLock creates the lock file for msiPath, waiting up to wait for a
+// current owner to release it. Locks left by dead processes on this host are
+// taken over.
+func acquireLock(msiPath string, wait time.Duration) (*fileLock, error) {
+	path := LockPath(msiPath)
+	deadline := time.Now().Add(wait)
+	for {
+		err := writeLockFile(path)
+		if err == nil {
+			return &fileLock{path: path}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to create lock file '%s': %v", path, err)
+		}
+
+		owner, readErr := readLockFile(path)
+		if readErr == nil && isStaleLock(owner) {
+			logWarn(fmt.Sprintf("Removing stale lock on '%s' held by %s", msiPath, owner))
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			if readErr != nil {
+				return nil, fmt.Errorf("'%s' is locked (%s); remove it if no msicrafter is running", msiPath, path)
+			}
+			if wait > 0 {
+				return nil, fmt.Errorf("'%s' is still locked by %s after waiting %s", msiPath, owner, wait)
+			}
+			return nil, fmt.Errorf("'%s' is locked by %s; use --wait to wait for it", msiPath, owner)
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
+
+// release removes the lock file.
+func (l *fileLock) release() error {
+	if l == nil {
+		return nil
+	}
+	if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove lock file '%s': %v", l.path, err)
+	}
+	return nil
+}
+
+// writeLockFile creates the lock file exclusively, failing if it exists.
+func writeLockFile(path string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	host, _ := os.Hostname()
+	_, err = fmt.Fprintf(f, "host=%s\npid=%d\nsince=%s\n", host, os.Getpid(), time.Now().Format(time.RFC3339))
+	return err
+}
+
+// readLockFile parses the owner recorded in a lock file.
+func readLockFile(path string) (lockOwner, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return lockOwner{}, err
+	}
+	return parseLockOwner(string(data))
+}
+
+func parseLockOwner(text string) (lockOwner, error) {
+	var owner lockOwner
+	for _, line := range strings.Split(text, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "host":
+			owner.Host = value
+		case "pid":
+			owner.PID, _ = strconv.Atoi(value)
+		case "since":
+			owner.Since, _ = time.Parse(time.RFC3339, value)
+		}
+	}
+	if owner.PID == 0 {
+		return owner, fmt.Errorf("lock file has no owner PID")
+	}
+	return owner, nil
+}
+
+// isStaleLock reports whether the lock's owner is a process on this host
+// that no longer exists. Locks from other hosts are never considered stale.
+func isStaleLock(owner lockOwner) bool {
+	host, _ := os.Hostname()
+	if !strings.EqualFold(owner.Host, host) || owner.PID == os.Getpid() {
+		return false
+	}
+	return !processAlive(owner.PID)
+}
+
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	defer p.Release()
+	if runtime.GOOS == "windows" {
+		// FindProcess opens a handle on Windows and fails for missing processes.
+		return true
+	}
+	return p.Signal(syscall.Signal(0)) == nil
+}
+
+// createWorkingCopy copies msiPath to a temp file in the same directory, so
+// it can later be renamed over the original atomically.
+func createWorkingCopy(msiPath string) (string, error) {
+	src, err := os.Open(msiPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open '%s': %v", msiPath, err)
+	}
+	defer src.Close()
+
+	dir, base := filepath.Split(msiPath)
+	tmp, err := os.CreateTemp(dir, "."+base+".*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("failed to create working copy of '%s': %v", msiPath, err)
+	}
+	if _, err := io.Copy(tmp, src); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to copy '%s': %v", msiPath, err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to write working copy of '%s': %v", msiPath, err)
+	}
+	return tmp.Name(), nil
+}
+
+// publishWorkingCopy flushes the working copy to disk and renames it over
+// the original, so readers see either the old or the new package.
+func publishWorkingCopy(workPath, msiPath string) error {
+	f, err := os.OpenFile(workPath, os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open working copy: %v", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to flush working copy: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close working copy: %v", err)
+	}
+	if info, err := os.Stat(msiPath); err == nil {
+		os.Chmod(workPath, info.Mode())
+	}
+	if err := os.Rename(workPath, msiPath); err != nil {
+		return fmt.Errorf("failed to replace '%s': %v", msiPath, err)
+	}
+	if runtime.GOOS != "windows" {
+		if dir, err := os.Open(filepath.Dir(msiPath)); err == nil {
+			dir.Sync()
+			dir.Close()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+// core/msi_lock_test.go
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAcquireLock(t *testing.T) {
+	msiPath := filepath.Join(t.TempDir(), "app.msi")
+	lock, err := acquireLock(msiPath, 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	owner, err := readLockFile(LockPath(msiPath))
+	if err != nil || owner.PID != os.Getpid() {
+		t.Errorf("Expected the lock to record this process, got %+v (%v)", owner, err)
+	}
+
+	_, err = acquireLock(msiPath, 300*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "still locked") {
+		t.Errorf("Expected a second lock to time out, got: %v", err)
+	}
+
+	if err := lock.release(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if _, err := os.Stat(LockPath(msiPath)); !os.IsNotExist(err) {
+		t.Error("Expected the lock file to be removed")
+	}
+}
+
+func TestAcquireLock_Stale(t *testing.T) {
+	msiPath := filepath.Join(t.TempDir(), "app.msi")
+	host, _ := os.Hostname()
+	stale := "host=" + host + "\npid=999999999\nsince=2024-01-01T00:00:00Z\n"
+	if err := os.WriteFile(LockPath(msiPath), []byte(stale), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lock, err := acquireLock(msiPath, 0)
+	if err != nil {
+		t.Fatalf("Expected a stale lock to be taken over, got: %v", err)
+	}
+	lock.release()
+}
+
+func TestParseLockOwner(t *testing.T) {
+	owner, err := parseLockOwner("host=build01\npid=4242\nsince=2024-05-06T07:08:09Z\n")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if owner.Host != "build01" || owner.PID != 4242 || owner.Since.Year() != 2024 {
+		t.Errorf("Unexpected owner: %+v", owner)
+	}
+	if _, err := parseLockOwner("garbage"); err == nil {
+		t.Error("Expected an error for a lock file without a PID")
+	}
+}
+
+func TestWorkingCopyPublish(t *testing.T) {
+	dir := t.TempDir()
+	msiPath := filepath.Join(dir, "app.msi")
+	if err := os.WriteFile(msiPath, []byte("original"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	workPath, err := createWorkingCopy(msiPath)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if filepath.Dir(workPath) != dir {
+		t.Errorf("Expected the working copy next to the MSI, got %s", workPath)
+	}
+	if err := os.WriteFile(workPath, []byte("edited"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(msiPath); string(data) != "original" {
+		t.Error("Expected the original to be untouched before publishing")
+	}
+	if err := publishWorkingCopy(workPath, msiPath); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if data, _ := os.ReadFile(msiPath); string(data) != "edited" {
+		t.Errorf("Expected the published contents, got %q", data)
+	}
+	if _, err := os.Stat(workPath); !os.IsNotExist(err) {
+		t.Error("Expected the working copy to be gone after publishing")
+	}
+}
@@ -0,0 +1,312 @@
+// core/msi_rebase.go
+package core
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// RebaseConflict is a change the transform and the new release make
+// differently. Op is the transform's side, which the rebased transform
+// leaves out; it is empty for changes no operation expresses.
+type RebaseConflict struct {
+	Op     TransformOp
+	Reason string
+}
+
+// RebaseResult is a transform replayed onto a new release.
+type RebaseResult struct {
+	Ops       []TransformOp
+	Merged    int // operations and updated cells the new release already has
+	Conflicts []RebaseConflict
+
+	sides rebaseSides
+}
+
+// rebaseSides name the package a transform was made against and the one
+// it is replayed onto, in conflict reasons.
+type rebaseSides struct {
+	base, other string
+}
+
+var releaseSides = rebaseSides{base: "the original package", other: "the new release"}
+
+// RebaseTransform replays the transform at mstPath, made against oldMSI,
+// onto newMSI and writes the result to outputMST as a text transform.
+// Changes the new release already makes are dropped; changes both sides make
+// differently are left out and written to reportPath (stdout when empty) as
+// commented transform lines, and the command fails once everything is
+// written.
+func RebaseTransform(oldMSI, mstPath, newMSI, outputMST, reportPath string) error {
+	return SafeExecute("RebaseTransform", func() error {
+		transform, err := ReadTransform(mstPath, oldMSI)
+		if err != nil {
+			return err
+		}
+		oldSession, err := OpenMsiSession(oldMSI, 0)
+		if err != nil {
+			return fmt.Errorf("failed to open original MSI session: %v", err)
+		}
+		defer oldSession.Close()
+		newSession, err := OpenMsiSession(newMSI, 0)
+		if err != nil {
+			return fmt.Errorf("failed to open new MSI session: %v", err)
+		}
+		defer newSession.Close()
+
+		diff, err := DiffSessions(oldSession, newSession)
+		if err != nil {
+			return err
+		}
+		oldSchemas := map[string][]ColumnInfo{}
+		for _, op := range transform.Ops {
+			if _, ok := oldSchemas[op.Table]; ok || !oldSession.HasTable(op.Table) {
+				continue
+			}
+			schema, err := oldSession.GetTableSchema(op.Table)
+			if err != nil {
+				return err
+			}
+			oldSchemas[op.Table] = schema.Columns
+		}
+		result, err := rebaseOps(transform.Ops, oldSchemas, diff, releaseSides)
+		if err != nil {
+			return err
+		}
+
+		base := newSession.transformBase()
+		if err := transform.Checks.requireBase(base); err != nil {
+			return fmt.Errorf("'%s': %v", newMSI, err)
+		}
+		rebased := &TextTransform{Version: 2, Base: base, Checks: transform.Checks, Ops: result.Ops}
+		if err := rebased.WriteFile(outputMST); err != nil {
+			return err
+		}
+		fmt.Printf("Rebased %d operation(s) onto '%s': %d written, %d change(s) already in the new release, %d conflict(s)\n",
+			len(transform.Ops), newMSI, len(result.Ops), result.Merged, len(result.Conflicts))
+		if len(result.Conflicts) == 0 {
+			return nil
+		}
+
+		if reportPath == "" {
+			WriteRebaseReport(os.Stdout, result.Conflicts)
+			return fmt.Errorf("%d conflict(s) were left out of '%s'", len(result.Conflicts), outputMST)
+		}
+		f, err := os.Create(reportPath)
+		if err != nil {
+			return fmt.Errorf("failed to write conflict report: %v", err)
+		}
+		WriteRebaseReport(f, result.Conflicts)
+		if err := f.Close(); err != nil {
+			return fmt.Errorf("failed to write conflict report: %v", err)
+		}
+		return fmt.Errorf("%d conflict(s) were left out of '%s'; see %s", len(result.Conflicts), outputMST, reportPath)
+	})
+}
+
+func (r *RebaseResult) conflict(op TransformOp, format string, args ...interface{}) {
+	r.Conflicts = append(r.Conflicts, RebaseConflict{Op: op, Reason: fmt.Sprintf(format, args...)})
+}
+
+// WriteRebaseReport lists conflicts as commented reasons followed by the
+// transform's operation, ready to be copied into the transform once
+// resolved.
+func WriteRebaseReport(w io.Writer, conflicts []RebaseConflict) {
+	writeConflicts(w, conflicts, "these operations were left out of the rebased transform")
+}
+
+func writeConflicts(w io.Writer, conflicts []RebaseConflict, what string) {
+	fmt.Fprintf(w, "# %d conflict(s); %s.\n", len(conflicts), what)
+	for _, c := range conflicts {
+		if c.Op.Op == "" {
+			fmt.Fprintf(w, "\n# %s\n", c.Reason)
+			continue
+		}
+		fmt.Fprintf(w, "\n# %s\n%s\n", c.Reason, c.Op)
+	}
+}
+
+// rebaseOps replays ours, a transform of the original package whose
+// tables have oldSchemas, onto the release that theirs diffs the original
+// against; sides name the two in conflict reasons. Operations on rows,
+// tables and columns the release leaves alone are kept as they are;
+// updates are merged cell by cell.
+func rebaseOps(ours []TransformOp, oldSchemas map[string][]ColumnInfo, theirs *MsiDiff, sides rebaseSides) (*RebaseResult, error) {
+	release := map[string]TableDiff{}
+	rows := map[string]map[string]RowChange{}
+	for _, t := range theirs.Tables {
+		release[t.Table] = t
+		rows[t.Table] = map[string]RowChange{}
+		for _, c := range t.Changes {
+			rows[t.Table][strings.Join(c.Key, "\x00")] = c
+		}
+	}
+	// columns tracks each table as the transform sees it.
+	columns := map[string][]ColumnInfo{}
+	for table, cols := range oldSchemas {
+		columns[table] = append([]ColumnInfo(nil), cols...)
+	}
+
+	result := &RebaseResult{sides: sides}
+	for _, op := range ours {
+		t, inRelease := release[op.Table]
+		switch op.Op {
+		case OpCreate:
+			columns[op.Table] = append([]ColumnInfo(nil), op.Columns...)
+			if inRelease && t.Status == TableAdded {
+				if sameColumns(t.schema.Columns, op.Columns) {
+					result.Merged++
+				} else {
+					result.conflict(op, "%s also adds table '%s', with columns (%s)", result.sides.other, op.Table, formatColumnDefs(t.schema.Columns))
+				}
+				continue
+			}
+		case OpAlter:
+			col := op.Columns[0]
+			columns[op.Table] = append(columns[op.Table], col)
+			if inRelease && t.Status == TableRemoved {
+				result.conflict(op, "%s removes table '%s'", result.sides.other, op.Table)
+				continue
+			}
+			if added, ok := addedColumn(t, col.Name); inRelease && ok {
+				if sameColumns([]ColumnInfo{added}, op.Columns) {
+					result.Merged++
+				} else {
+					result.conflict(op, "%s also adds column %s.%s as %s", result.sides.other, op.Table, col.Name, formatColumnDef(added))
+				}
+				continue
+			}
+		case OpDrop:
+			if inRelease && t.Status == TableRemoved {
+				result.Merged++
+				continue
+			}
+			if inRelease && t.Status == TableChanged {
+				result.conflict(op, "%s changes table '%s': %s", result.sides.other, op.Table, t.Summary())
+				continue
+			}
+		case OpInsert, OpDelete, OpUpdate:
+			if inRelease && t.Status == TableRemoved {
+				result.conflict(op, "%s removes table '%s'", result.sides.other, op.Table)
+				continue
+			}
+			key := op.Key
+			if key == nil {
+				cols, ok := columns[op.Table]
+				if !ok {
+					return nil, fmt.Errorf("%s: table '%s' is not in %s", op, op.Table, sides.base)
+				}
+				var err error
+				if key, err = rowKey(&TableSchema{Name: op.Table, Columns: cols}, op.Values); err != nil {
+					return nil, fmt.Errorf("%s: %v", op, err)
+				}
+				if op.Op == OpDelete {
+					op.Key, op.Values = key, nil
+				}
+			}
+			change, ok := rows[op.Table][strings.Join(key, "\x00")]
+			if !ok {
+				break
+			}
+			rebaseRowOp(op, columns[op.Table], change, result)
+			continue
+		}
+		result.Ops = append(result.Ops, op)
+	}
+	return result, nil
+}
+
+// rebaseRowOp merges a row operation of the transform with the new
+// release's change to the same row.
+func rebaseRowOp(op TransformOp, cols []ColumnInfo, change RowChange, result *RebaseResult) {
+	row := fmt.Sprintf("%s [%s]", op.Table, strings.Join(change.Key, ","))
+	switch op.Op {
+	case OpInsert:
+		if change.Op != OpInsert {
+			result.conflict(op, "%s is in %s and %s %s it", row, result.sides.base, result.sides.other, rebaseVerb(change.Op))
+			return
+		}
+		var differ []string
+		for i, col := range cols {
+			if v, ok := rowValue(change, change.New, col.Name); ok && i < len(op.Values) && v != op.Values[i] {
+				differ = append(differ, col.Name+"="+quoteTransformValue(v))
+			}
+		}
+		if len(differ) == 0 {
+			result.Merged++
+			return
+		}
+		result.conflict(op, "%s also adds %s, with %s", result.sides.other, row, strings.Join(differ, " "))
+	case OpDelete:
+		switch change.Op {
+		case OpDelete:
+			result.Merged++
+		case OpUpdate:
+			var cells []string
+			for _, c := range change.Cells() {
+				cells = append(cells, c.Column+"="+quoteTransformValue(c.New))
+			}
+			result.conflict(op, "%s updates %s: %s", result.sides.other, row, strings.Join(cells, " "))
+		default:
+			result.conflict(op, "%s adds %s", result.sides.other, row)
+		}
+	case OpUpdate:
+		if change.Op != OpUpdate {
+			result.conflict(op, "%s %s %s", result.sides.other, rebaseVerb(change.Op), row)
+			return
+		}
+		keep := map[string]string{}
+		for _, name := range sortedKeys(op.Set) {
+			v := op.Set[name]
+			before, ok := rowValue(change, change.Old, name)
+			after, _ := rowValue(change, change.New, name)
+			switch {
+			case !ok || before == after:
+				keep[name] = v
+			case after == v:
+				result.Merged++
+			default:
+				cell := TransformOp{Op: OpUpdate, Table: op.Table, Key: op.Key, Set: map[string]string{name: v}}
+				result.conflict(cell, "%s.%s was %s in %s and is %s in %s", row, name, quoteTransformValue(before), result.sides.base, quoteTransformValue(after), result.sides.other)
+			}
+		}
+		if len(keep) > 0 {
+			result.Ops = append(result.Ops, TransformOp{Op: OpUpdate, Table: op.Table, Key: op.Key, Set: keep})
+		}
+	}
+}
+
+func rebaseVerb(op ChangeOp) string {
+	switch op {
+	case OpInsert:
+		return "adds"
+	case OpDelete:
+		return "deletes"
+	}
+	return "updates"
+}
+
+// rowValue returns column's value in row, the Old or New side of change.
+func rowValue(change RowChange, row []string, column string) (string, bool) {
+	for i, name := range change.Columns {
+		if name == column {
+			if i < len(row) {
+				return row[i], true
+			}
+			return "", true
+		}
+	}
+	return "", false
+}
+
+// addedColumn returns a column the new release adds to a table.
+func addedColumn(t TableDiff, name string) (ColumnInfo, bool) {
+	for _, c := range t.Schema {
+		if c.Column == name && c.OldType == "" && c.NewType != "" {
+			return ColumnInfo{Name: name, Type: c.NewType, Key: c.NewKey}, true
+		}
+	}
+	return ColumnInfo{}, false
+}
@@ -0,0 +1,106 @@
+// core/msi_rebase_test.go
+package core
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRebaseOps(t *testing.T) {
+	property := []ColumnInfo{{Name: "Property", Type: "s72", Key: true}, {Name: "Value", Type: "l0"}}
+	registry := []ColumnInfo{{Name: "Registry", Type: "s72", Key: true}, {Name: "Key", Type: "l255"}}
+	custom := []ColumnInfo{{Name: "Id", Type: "s72", Key: true}}
+	theirs := &MsiDiff{Tables: []TableDiff{
+		diffTable("Custom", nil, snapshot("Custom", custom)),
+		diffTable("Property",
+			snapshot("Property", property, []string{"A", "1"}, []string{"B", "2"}, []string{"C", "3"}, []string{"F", "5"}, []string{"H", "8"}),
+			snapshot("Property", property, []string{"A", "1"}, []string{"B", "20"}, []string{"E", "5"}, []string{"F", "6"}, []string{"G", "8"}, []string{"H", "9"})),
+		diffTable("Registry", snapshot("Registry", registry, []string{"R1", "Software"}), nil),
+	}}
+	ours := []TransformOp{
+		{Op: OpCreate, Table: "Custom", Columns: custom},
+		{Op: OpUpdate, Table: "Property", Key: []string{"A"}, Set: map[string]string{"Value": "ours"}},
+		{Op: OpUpdate, Table: "Property", Key: []string{"B"}, Set: map[string]string{"Value": "custom"}},
+		{Op: OpUpdate, Table: "Property", Key: []string{"F"}, Set: map[string]string{"Value": "6"}},
+		{Op: OpDelete, Table: "Property", Values: []string{"C", "3"}},
+		{Op: OpInsert, Table: "Property", Values: []string{"E", "5"}},
+		{Op: OpInsert, Table: "Property", Values: []string{"G", "7"}},
+		{Op: OpDelete, Table: "Property", Key: []string{"H"}},
+		{Op: OpInsert, Table: "Registry", Values: []string{"R2", "Other"}},
+		{Op: OpDrop, Table: "Registry"},
+	}
+	result, err := rebaseOps(ours, map[string][]ColumnInfo{"Property": property, "Registry": registry}, theirs, releaseSides)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if !reflect.DeepEqual(result.Ops, ours[1:2]) {
+		t.Errorf("Expected only the untouched update to remain, got %v", result.Ops)
+	}
+	if result.Merged != 5 {
+		t.Errorf("Expected 5 changes already in the release, got %d", result.Merged)
+	}
+	var got []string
+	for _, c := range result.Conflicts {
+		got = append(got, c.Op.String())
+	}
+	expected := []string{
+		`~ Property ("B") Value="custom"`,
+		`+ Property ("G", "7")`,
+		`- Property ("H")`,
+		`+ Registry ("R2", "Other")`,
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected conflicts %v, got %v", expected, got)
+	}
+
+	var buf bytes.Buffer
+	WriteRebaseReport(&buf, result.Conflicts)
+	for _, want := range []string{
+		`# Property [B].Value was "2" in the original package and is "20" in the new release`,
+		`# the new release also adds Property [G], with Value="8"`,
+		`# the new release updates Property [H]: Value="9"`,
+		`# the new release removes table 'Registry'`,
+	} {
+		if !strings.Contains(buf.String(), want+"\n") {
+			t.Errorf("Expected the report to contain %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestRebaseOps_Schema(t *testing.T) {
+	property := []ColumnInfo{{Name: "Property", Type: "s72", Key: true}, {Name: "Value", Type: "l0"}}
+	withNote := append(append([]ColumnInfo(nil), property...), ColumnInfo{Name: "Note", Type: "S64"})
+	theirs := &MsiDiff{Tables: []TableDiff{
+		diffTable("Custom", nil, snapshot("Custom", []ColumnInfo{{Name: "Id", Type: "s72", Key: true}, {Name: "N", Type: "I2"}})),
+		diffTable("Property", snapshot("Property", property), snapshot("Property", withNote)),
+	}}
+	tests := []struct {
+		name     string
+		op       TransformOp
+		kept     bool
+		conflict bool
+	}{
+		{"same column", TransformOp{Op: OpAlter, Table: "Property", Columns: []ColumnInfo{{Name: "Note", Type: "S64"}}}, false, false},
+		{"different column type", TransformOp{Op: OpAlter, Table: "Property", Columns: []ColumnInfo{{Name: "Note", Type: "S255"}}}, false, true},
+		{"other column", TransformOp{Op: OpAlter, Table: "Property", Columns: []ColumnInfo{{Name: "Extra", Type: "S64"}}}, true, false},
+		{"table with other columns", TransformOp{Op: OpCreate, Table: "Custom", Columns: []ColumnInfo{{Name: "Id", Type: "s72", Key: true}}}, false, true},
+		{"changed table dropped", TransformOp{Op: OpDrop, Table: "Property"}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := rebaseOps([]TransformOp{tt.op}, map[string][]ColumnInfo{"Property": property}, theirs, releaseSides)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if kept := len(result.Ops) == 1; kept != tt.kept {
+				t.Errorf("Expected kept=%v, got %v", tt.kept, result.Ops)
+			}
+			if conflict := len(result.Conflicts) == 1; conflict != tt.conflict {
+				t.Errorf("Expected conflict=%v, got %v", tt.conflict, result.Conflicts)
+			}
+		})
+	}
+}
@@ -0,0 +1,332 @@
+// core/msi_remove.go
+package core
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// RemovalPlan lists every row that goes away when a feature or component is
+// removed, plus the components kept because other features still use them.
+type RemovalPlan struct {
+	Changes []RowChange
+	Shared  map[string][]string // kept component → features that still include it
+}
+
+// Summary returns the number of rows removed per table.
+func (p *RemovalPlan) Summary() map[string]int {
+	counts := map[string]int{}
+	for _, c := range p.Changes {
+		counts[c.Table]++
+	}
+	return counts
+}
+
+// RemoveFeature removes a feature, its sub-features and every row they own.
+// If transformPath is set, the deletions are written there and the MSI is left untouched.
+func RemoveFeature(msiPath, feature, transformPath string, dryRun, interactive bool) error {
+	return SafeExecute("RemoveFeature", func() error {
+		return removeRows(msiPath, "Feature", feature, transformPath, dryRun, interactive)
+	})
+}
+
+// RemoveComponent removes a component and every row it owns.
+// If transformPath is set, the deletions are written there and the MSI is left untouched.
+func RemoveComponent(msiPath, component, transformPath string, dryRun, interactive bool) error {
+	return SafeExecute("RemoveComponent", func() error {
+		return removeRows(msiPath, "Component", component, transformPath, dryRun, interactive)
+	})
+}
+
+func removeRows(msiPath, table, key, transformPath string, dryRun, interactive bool) error {
+	mode := 1
+	if transformPath != "" || dryRun {
+		mode = 0
+	}
+	session, err := OpenMsiSession(msiPath, mode)
+	if err != nil {
+		return fmt.Errorf("failed to open MSI session: %v", err)
+	}
+	defer session.Close()
+
+	plan, err := session.PlanRemoval(table, key)
+	if err != nil {
+		return err
+	}
+	printRemovalPlan(plan)
+
+	if dryRun {
+		log.Println("[DRY-RUN] Removal planned; no changes written.")
+		return nil
+	}
+	if interactive {
+		if err := confirmOrCancel(fmt.Sprintf("Remove %d row(s)?", len(plan.Changes)), "removal"); err != nil {
+			return err
+		}
+	}
+
+	if transformPath != "" {
+		if err := WriteTextTransform(transformPath, session.transformBase(), plan.Changes); err != nil {
+			return err
+		}
+		log.Printf("[INFO] Removal written to transform: %s", transformPath)
+		return nil
+	}
+	if err := session.ApplyRowChanges(plan.Changes); err != nil {
+		return err
+	}
+	return session.Commit()
+}
+
+func printRemovalPlan(plan *RemovalPlan) {
+	summary := plan.Summary()
+	tables := sortedKeys(summary)
+	fmt.Printf("Removal plan (%d rows):\n", len(plan.Changes))
+	for _, t := range tables {
+		fmt.Printf("   %-24s %d row(s)\n", t, summary[t])
+		for _, c := range plan.Changes {
+			if c.Table == t {
+				fmt.Printf("      - %s\n", strings.Join(c.Key, ", "))
+			}
+		}
+	}
+	for _, comp := range sortedKeys(plan.Shared) {
+		fmt.Printf("   Keeping shared component '%s' (still used by %s)\n", comp, strings.Join(plan.Shared[comp], ", "))
+	}
+}
+
+// PlanRemoval computes the closure of rows owned by a Feature or Component.
+// Removing a feature also removes its sub-features and the components no
+// remaining feature includes. Rows whose required (non-nullable) foreign key
+// points at a removed row are removed in turn until nothing else changes.
+func (s *MsiSession) PlanRemoval(table, key string) (*RemovalPlan, error) {
+	if table != "Feature" && table != "Component" {
+		return nil, fmt.Errorf("removal supports Feature and Component, not '%s'", table)
+	}
+	rules, err := s.validationCatalog()
+	if err != nil {
+		return nil, err
+	}
+	c := &removalClosure{session: s, rows: map[string][]TableRow{}, removed: map[string]map[string]TableRow{}}
+
+	root, err := s.FindRow(table, []string{key})
+	if err != nil {
+		return nil, err
+	}
+	if root == nil {
+		return nil, fmt.Errorf("no row '%s' in table '%s'", key, table)
+	}
+	tables, err := s.TableNames()
+	if err != nil {
+		return nil, err
+	}
+	return c.plan(table, *root, tables, rules)
+}
+
+// plan marks root and everything it owns, then lists the marked rows as deletions.
+func (c *removalClosure) plan(table string, root TableRow, tables []string, rules map[string]map[string]ValidationRule) (*RemovalPlan, error) {
+	if err := c.remove(table, root); err != nil {
+		return nil, err
+	}
+
+	plan := &RemovalPlan{Shared: map[string][]string{}}
+	if table == "Feature" {
+		if err := c.removeSubFeatures(); err != nil {
+			return nil, err
+		}
+		if err := c.removeOrphanedComponents(plan.Shared); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Strings(tables)
+	for changed := true; changed; {
+		changed = false
+		for _, t := range tables {
+			if strings.HasPrefix(t, "_") {
+				continue
+			}
+			added, err := c.removeDependents(t, rules[t])
+			if err != nil {
+				return nil, err
+			}
+			changed = changed || added
+		}
+	}
+
+	for _, t := range tables {
+		schema, err := c.session.GetTableSchema(t)
+		if err != nil || len(c.removed[t]) == 0 {
+			continue
+		}
+		for _, k := range sortedKeys(c.removed[t]) {
+			row := c.removed[t][k]
+			plan.Changes = append(plan.Changes, RowChange{
+				Op:      OpDelete,
+				Table:   t,
+				Columns: schema.ColumnNames(),
+				Key:     schema.KeyValues(row),
+				Old:     row.Columns,
+			})
+		}
+	}
+	return plan, nil
+}
+
+// removalClosure tracks rows read and rows marked for removal while planning.
+type removalClosure struct {
+	session *MsiSession
+	rows    map[string][]TableRow
+	removed map[string]map[string]TableRow // table → row key → row
+}
+
+func (c *removalClosure) tableRows(table string) ([]TableRow, error) {
+	if rows, ok := c.rows[table]; ok {
+		return rows, nil
+	}
+	if !c.session.HasTable(table) {
+		c.rows[table] = nil
+		return nil, nil
+	}
+	rows, err := c.session.ExecuteQuery(fmt.Sprintf("SELECT * FROM `%s`", table))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read '%s': %v", table, err)
+	}
+	c.rows[table] = rows
+	return rows, nil
+}
+
+// remove marks a row for removal.
+func (c *removalClosure) remove(table string, row TableRow) error {
+	schema, err := c.session.GetTableSchema(table)
+	if err != nil {
+		return err
+	}
+	if c.removed[table] == nil {
+		c.removed[table] = map[string]TableRow{}
+	}
+	c.removed[table][schema.RowKey(row)] = row
+	return nil
+}
+
+func (c *removalClosure) isRemoved(table, key string) bool {
+	_, ok := c.removed[table][key]
+	return ok
+}
+
+// removeSubFeatures marks every descendant of a removed feature.
+func (c *removalClosure) removeSubFeatures() error {
+	rows, err := c.tableRows("Feature")
+	if err != nil {
+		return err
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, row := range rows {
+			if len(row.Columns) < 2 || c.isRemoved("Feature", row.Columns[0]) {
+				continue
+			}
+			if row.Columns[1] != "" && c.isRemoved("Feature", row.Columns[1]) {
+				if err := c.remove("Feature", row); err != nil {
+					return err
+				}
+				changed = true
+			}
+		}
+	}
+	return nil
+}
+
+// removeOrphanedComponents marks components whose every feature is removed
+// and records the components that stay because another feature shares them.
+func (c *removalClosure) removeOrphanedComponents(shared map[string][]string) error {
+	links, err := c.tableRows("FeatureComponents")
+	if err != nil {
+		return err
+	}
+	features := map[string][]string{}
+	for _, link := range links {
+		if len(link.Columns) >= 2 {
+			features[link.Columns[1]] = append(features[link.Columns[1]], link.Columns[0])
+		}
+	}
+	components, err := c.tableRows("Component")
+	if err != nil {
+		return err
+	}
+	for _, comp := range components {
+		if len(comp.Columns) == 0 {
+			continue
+		}
+		owners := features[comp.Columns[0]]
+		var kept []string
+		touched := false
+		for _, f := range owners {
+			if c.isRemoved("Feature", f) {
+				touched = true
+			} else {
+				kept = append(kept, f)
+			}
+		}
+		if !touched {
+			continue
+		}
+		if len(kept) > 0 {
+			shared[comp.Columns[0]] = kept
+			continue
+		}
+		if err := c.remove("Component", comp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeDependents marks rows of table whose required foreign keys point at
+// removed rows. Advertised shortcuts targeting a removed feature go too.
+func (c *removalClosure) removeDependents(table string, rules map[string]ValidationRule) (bool, error) {
+	schema, err := c.session.GetTableSchema(table)
+	if err != nil {
+		return false, err
+	}
+	type ref struct {
+		idx      int
+		keyTable string
+	}
+	var refs []ref
+	for i, col := range schema.Columns {
+		rule := rules[col.Name]
+		required := col.Key || !col.IsNullable()
+		if required && rule.KeyColumn == 1 && !strings.Contains(rule.KeyTable, ";") && len(c.removed[rule.KeyTable]) > 0 {
+			refs = append(refs, ref{i, rule.KeyTable})
+		}
+		if table == "Shortcut" && col.Name == "Target" && len(c.removed["Feature"]) > 0 {
+			refs = append(refs, ref{i, "Feature"})
+		}
+	}
+	if len(refs) == 0 {
+		return false, nil
+	}
+	rows, err := c.tableRows(table)
+	if err != nil {
+		return false, err
+	}
+	added := false
+	for _, row := range rows {
+		if c.isRemoved(table, schema.RowKey(row)) {
+			continue
+		}
+		for _, r := range refs {
+			if r.idx < len(row.Columns) && c.isRemoved(r.keyTable, row.Columns[r.idx]) {
+				if err := c.remove(table, row); err != nil {
+					return false, err
+				}
+				added = true
+				break
+			}
+		}
+	}
+	return added, nil
+}
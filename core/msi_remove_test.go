@@ -0,0 +1,151 @@
+// core/msi_remove_test.go
+package core
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// removalFixture builds a closure over in-memory rows of standard tables, so
+// the planner runs without a database.
+func removalFixture(t *testing.T, rows map[string][][]string) (*removalClosure, []string, map[string]map[string]ValidationRule) {
+	t.Helper()
+	session := &MsiSession{schemas: map[string]*TableSchema{}}
+	c := &removalClosure{session: session, rows: map[string][]TableRow{}, removed: map[string]map[string]TableRow{}}
+	rules := map[string]map[string]ValidationRule{}
+	var tables []string
+	for table, data := range rows {
+		schema, ok := StandardTableSchema(table)
+		if !ok {
+			t.Fatalf("no catalog schema for %s", table)
+		}
+		session.schemas[table] = schema
+		rules[table] = standardValidationRules(table)
+		for _, r := range data {
+			c.rows[table] = append(c.rows[table], TableRow{Columns: r})
+		}
+		tables = append(tables, table)
+	}
+	return c, tables, rules
+}
+
+func featureRow(name, parent string) []string {
+	return []string{name, parent, name, "", "1", "1", "", "0"}
+}
+
+func fileRow(name, component string) []string {
+	return []string{name, component, name, "100", "", "", "", "1"}
+}
+
+// removedKeys returns the sorted primary keys of the planned deletions in table.
+func removedKeys(plan *RemovalPlan, table string) []string {
+	var keys []string
+	for _, c := range plan.Changes {
+		if c.Table == table {
+			keys = append(keys, c.Key[0])
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestPlanRemoval_Feature(t *testing.T) {
+	c, tables, rules := removalFixture(t, map[string][][]string{
+		"Feature": {
+			featureRow("Main", ""),
+			featureRow("Tools", ""),
+			featureRow("Tools_Sub", "Tools"),
+			featureRow("Tools_SubSub", "Tools_Sub"),
+		},
+		"FeatureComponents": {
+			{"Main", "CoreComp"},
+			{"Tools", "SharedComp"},
+			{"Main", "SharedComp"},
+			{"Tools_Sub", "ToolComp"},
+			{"Tools_SubSub", "DeepComp"},
+		},
+		"Component": {
+			{"CoreComp", "{00000000-0000-0000-0000-000000000001}", "INSTALLDIR", "0", "", "core.dll"},
+			{"SharedComp", "{00000000-0000-0000-0000-000000000002}", "INSTALLDIR", "0", "", "shared.dll"},
+			{"ToolComp", "{00000000-0000-0000-0000-000000000003}", "INSTALLDIR", "0", "", "tool.dll"},
+			{"DeepComp", "{00000000-0000-0000-0000-000000000004}", "INSTALLDIR", "0", "", "deep.dll"},
+		},
+		"File": {
+			fileRow("core.dll", "CoreComp"),
+			fileRow("shared.dll", "SharedComp"),
+			fileRow("tool.dll", "ToolComp"),
+			fileRow("deep.dll", "DeepComp"),
+		},
+	})
+
+	plan, err := c.plan("Feature", TableRow{Columns: featureRow("Tools", "")}, tables, rules)
+	if err != nil {
+		t.Fatalf("plan failed: %v", err)
+	}
+
+	want := map[string][]string{
+		"Feature":           {"Tools", "Tools_Sub", "Tools_SubSub"},
+		"FeatureComponents": {"Tools", "Tools_Sub", "Tools_SubSub"},
+		"Component":         {"DeepComp", "ToolComp"},
+		"File":              {"deep.dll", "tool.dll"},
+	}
+	for table, keys := range want {
+		if got := removedKeys(plan, table); !reflect.DeepEqual(got, keys) {
+			t.Errorf("%s: removed %v, expected %v", table, got, keys)
+		}
+	}
+	if got := plan.Shared["SharedComp"]; !reflect.DeepEqual(got, []string{"Main"}) {
+		t.Errorf("Expected SharedComp kept for Main, got %v", plan.Shared)
+	}
+	if len(plan.Shared) != 1 {
+		t.Errorf("Expected only SharedComp to be kept as shared, got %v", plan.Shared)
+	}
+}
+
+func TestPlanRemoval_ComponentDependents(t *testing.T) {
+	c, tables, rules := removalFixture(t, map[string][][]string{
+		"FeatureComponents": {
+			{"Main", "AppComp"},
+			{"Main", "OtherComp"},
+		},
+		"Component": {
+			{"AppComp", "{00000000-0000-0000-0000-000000000001}", "INSTALLDIR", "0", "", "app.exe"},
+			{"OtherComp", "{00000000-0000-0000-0000-000000000002}", "INSTALLDIR", "0", "", "other.exe"},
+		},
+		"File": {
+			fileRow("app.exe", "AppComp"),
+			fileRow("other.exe", "OtherComp"),
+		},
+		"Registry": {
+			{"RegApp", "2", "Software\\App", "Path", "[INSTALLDIR]", "AppComp"},
+			{"RegOther", "2", "Software\\Other", "Path", "[INSTALLDIR]", "OtherComp"},
+		},
+	})
+
+	root := TableRow{Columns: []string{"AppComp", "{00000000-0000-0000-0000-000000000001}", "INSTALLDIR", "0", "", "app.exe"}}
+	plan, err := c.plan("Component", root, tables, rules)
+	if err != nil {
+		t.Fatalf("plan failed: %v", err)
+	}
+
+	want := map[string][]string{
+		"Component":         {"AppComp"},
+		"FeatureComponents": {"Main"},
+		"File":              {"app.exe"},
+		"Registry":          {"RegApp"},
+	}
+	for table, keys := range want {
+		if got := removedKeys(plan, table); !reflect.DeepEqual(got, keys) {
+			t.Errorf("%s: removed %v, expected %v", table, got, keys)
+		}
+	}
+	for _, ch := range plan.Changes {
+		if ch.Op != OpDelete {
+			t.Errorf("Expected only deletions, got %v on %s", ch.Op, ch.Table)
+		}
+		if ch.Table == "FeatureComponents" && ch.Key[1] != "AppComp" {
+			t.Errorf("Removed the wrong FeatureComponents link: %v", ch.Key)
+		}
+	}
+}
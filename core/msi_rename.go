@@ -0,0 +1,344 @@
+// core/msi_rename.go
+package core
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CellEdit is a planned change to a single cell, addressed by table,
+// primary key and column.
+type CellEdit struct {
+	Table  string
+	Key    []string
+	Column string
+	Old    string
+	New    string
+}
+
+func (e CellEdit) String() string {
+	return fmt.Sprintf("%s[%s].%s: '%s' → '%s'", e.Table, strings.Join(e.Key, ","), e.Column, e.Old, e.New)
+}
+
+// renameTokenPrefixes lists, per renamable table, the prefixes that mark a
+// reference to one of its keys inside formatted strings and conditions.
+// An empty prefix means the bare identifier, as used for properties.
+var renameTokenPrefixes = map[string]struct{ Formatted, Condition []string }{
+	"Property":  {Formatted: []string{""}, Condition: []string{""}},
+	"Directory": {Formatted: []string{""}, Condition: []string{""}},
+	"Component": {Formatted: []string{"$"}, Condition: []string{"$", "?"}},
+	"Feature":   {Condition: []string{"&", "!"}},
+	"File":      {Formatted: []string{"#", "!"}},
+}
+
+// formattedCategories are _Validation categories whose values are formatted strings.
+var formattedCategories = map[string]bool{
+	"Formatted": true, "FormattedSDDLText": true, "KeyFormatted": true, "Template": true,
+	"RegPath": true, "Path": true, "Paths": true, "AnyPath": true, "Shortcut": true,
+}
+
+// customSourceTables maps the source bits of CustomAction.Type to the table named by Source.
+var customSourceTables = []string{"Binary", "File", "Directory", "Property"}
+
+// RenameIdentifier renames a primary key value and rewrites every reference to it.
+func RenameIdentifier(msiPath, table, from, to string, dryRun, interactive bool) error {
+	return SafeExecute("RenameIdentifier", func() error {
+		session, err := OpenMsiSession(msiPath, 1)
+		if err != nil {
+			return fmt.Errorf("failed to open MSI session: %v", err)
+		}
+		defer session.Close()
+
+		edits, err := session.PlanRename(table, from, to)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Renaming %s '%s' → '%s' touches %d cell(s):\n", table, from, to, len(edits))
+		for _, e := range edits {
+			fmt.Printf("   %s\n", e)
+		}
+		if dryRun {
+			log.Println("[DRY-RUN] Rename previewed; no changes committed.")
+			return nil
+		}
+		if interactive {
+			if err := confirmOrCancel("Apply rename?", "rename"); err != nil {
+				return err
+			}
+		}
+
+		if err := session.ApplyCellEdits(edits); err != nil {
+			return err
+		}
+		return session.Commit()
+	})
+}
+
+// PlanRename computes every cell that must change to rename the key from → to
+// in table: the key itself, foreign keys found through _Validation and the
+// catalog, property-style references, and tokens inside formatted strings and
+// conditions.
+func (s *MsiSession) PlanRename(table, from, to string) ([]CellEdit, error) {
+	schema, err := s.GetTableSchema(table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema for '%s': %v", table, err)
+	}
+	keys := schema.KeyColumns()
+	if len(keys) != 1 {
+		return nil, fmt.Errorf("rename supports tables with a single-column primary key; '%s' has %d", table, len(keys))
+	}
+	if err := checkIdentifier(to); err != nil {
+		return nil, fmt.Errorf("invalid new identifier '%s': %v", to, err)
+	}
+	if row, err := s.FindRow(table, []string{from}); err != nil {
+		return nil, err
+	} else if row == nil {
+		return nil, fmt.Errorf("no row '%s' in table '%s'", from, table)
+	}
+	if row, err := s.FindRow(table, []string{to}); err != nil {
+		return nil, err
+	} else if row != nil {
+		return nil, fmt.Errorf("table '%s' already has a row '%s'", table, to)
+	}
+
+	rules, err := s.validationCatalog()
+	if err != nil {
+		return nil, err
+	}
+	tables, err := s.TableNames()
+	if err != nil {
+		return nil, err
+	}
+
+	planner := &renamePlanner{seen: map[string]bool{}}
+	planner.add(CellEdit{Table: table, Key: []string{from}, Column: keys[0].Name, Old: from, New: to})
+
+	propertyLike := table == "Property" || table == "Directory"
+	prefixes := renameTokenPrefixes[table]
+	sort.Strings(tables)
+	for _, t := range tables {
+		if strings.HasPrefix(t, "_") {
+			continue
+		}
+		tSchema, err := s.GetTableSchema(t)
+		if err != nil {
+			return nil, err
+		}
+		var exact, formatted, condition []string
+		for _, col := range tSchema.Columns {
+			rule := rules[t][col.Name]
+			switch {
+			case referencesTable(rule, table):
+				exact = append(exact, col.Name)
+			case propertyLike && contains(propertyReferenceColumns[t], col.Name):
+				exact = append(exact, col.Name)
+			case rule.Category == "Condition" && len(prefixes.Condition) > 0:
+				condition = append(condition, col.Name)
+			case formattedCategories[rule.Category] && len(prefixes.Formatted) > 0:
+				formatted = append(formatted, col.Name)
+			}
+		}
+		customSource := t == "CustomAction" && contains(customSourceTables, table)
+		if len(exact) == 0 && len(formatted) == 0 && len(condition) == 0 && !customSource {
+			continue
+		}
+
+		rows, err := s.ExecuteQuery(fmt.Sprintf("SELECT * FROM `%s`", t))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read '%s': %v", t, err)
+		}
+		for _, row := range rows {
+			cell := func(name string) string {
+				_, idx, _ := tSchema.Column(name)
+				if idx < 0 || idx >= len(row.Columns) {
+					return ""
+				}
+				return row.Columns[idx]
+			}
+			key := tSchema.KeyValues(row)
+			for _, c := range exact {
+				if cell(c) == from {
+					planner.add(CellEdit{Table: t, Key: key, Column: c, Old: from, New: to})
+				}
+			}
+			for _, c := range formatted {
+				old := cell(c)
+				if updated := renameFormattedRefs(old, prefixes.Formatted, from, to); updated != old {
+					planner.add(CellEdit{Table: t, Key: key, Column: c, Old: old, New: updated})
+				}
+			}
+			for _, c := range condition {
+				old := cell(c)
+				if updated := renameConditionRefs(old, prefixes.Condition, from, to); updated != old {
+					planner.add(CellEdit{Table: t, Key: key, Column: c, Old: old, New: updated})
+				}
+			}
+			if customSource && cell("Source") == from {
+				if typ, err := strconv.Atoi(cell("Type")); err == nil && customSourceTables[(typ>>4)&3] == table {
+					planner.add(CellEdit{Table: t, Key: key, Column: "Source", Old: from, New: to})
+				}
+			}
+		}
+	}
+	return planner.edits, nil
+}
+
+// renamePlanner accumulates cell edits, merging repeated hits on the same cell.
+type renamePlanner struct {
+	edits []CellEdit
+	seen  map[string]bool
+}
+
+func (p *renamePlanner) add(e CellEdit) {
+	id := e.Table + "\x00" + strings.Join(e.Key, "\x00") + "\x00" + e.Column
+	if p.seen[id] {
+		return
+	}
+	p.seen[id] = true
+	p.edits = append(p.edits, e)
+}
+
+// ApplyCellEdits writes planned cell edits, one UpdateRow per touched row.
+// Edits to the same row are applied together so key changes happen once.
+func (s *MsiSession) ApplyCellEdits(edits []CellEdit) error {
+	type rowID struct{ table, key string }
+	var order []rowID
+	grouped := map[rowID][]CellEdit{}
+	for _, e := range edits {
+		id := rowID{e.Table, strings.Join(e.Key, "\x00")}
+		if _, ok := grouped[id]; !ok {
+			order = append(order, id)
+		}
+		grouped[id] = append(grouped[id], e)
+	}
+	for _, id := range order {
+		group := grouped[id]
+		changes := map[string]string{}
+		for _, e := range group {
+			changes[e.Column] = e.New
+		}
+		if err := s.UpdateRow(group[0].Table, group[0].Key, changes); err != nil {
+			return fmt.Errorf("failed to update %s[%s]: %v", group[0].Table, strings.Join(group[0].Key, ","), err)
+		}
+	}
+	return nil
+}
+
+// validationCatalog returns validation rules for every table, keyed by table
+// and column. Rows from the package's _Validation take precedence over the
+// built-in catalog of standard tables.
+func (s *MsiSession) validationCatalog() (map[string]map[string]ValidationRule, error) {
+	all := map[string]map[string]ValidationRule{}
+	for table := range standardTables {
+		all[table] = standardValidationRules(table)
+	}
+	if !s.HasTable("_Validation") {
+		return all, nil
+	}
+	rows, err := s.QueryWithParams(validationQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read _Validation: %v", err)
+	}
+	for _, row := range rows {
+		rule, ok := parseValidationRow(row)
+		if !ok {
+			continue
+		}
+		if all[rule.Table] == nil {
+			all[rule.Table] = map[string]ValidationRule{}
+		}
+		all[rule.Table][rule.Column] = rule
+	}
+	return all, nil
+}
+
+// referencesTable reports whether a rule declares a foreign key to the first
+// column of table.
+func referencesTable(rule ValidationRule, table string) bool {
+	if rule.KeyColumn != 1 {
+		return false
+	}
+	for _, kt := range strings.Split(rule.KeyTable, ";") {
+		if strings.TrimSpace(kt) == table {
+			return true
+		}
+	}
+	return false
+}
+
+var formattedRefPattern = regexp.MustCompile(`\[([^\[\]]*)\]`)
+
+// renameFormattedRefs rewrites [prefix+from] tokens in a formatted string.
+func renameFormattedRefs(text string, prefixes []string, from, to string) string {
+	if !strings.Contains(text, from) {
+		return text
+	}
+	return formattedRefPattern.ReplaceAllStringFunc(text, func(tok string) string {
+		inner := tok[1 : len(tok)-1]
+		for _, p := range prefixes {
+			if inner == p+from {
+				return "[" + p + to + "]"
+			}
+		}
+		return tok
+	})
+}
+
+// renameConditionRefs rewrites prefix+from identifiers in a condition,
+// leaving quoted string literals untouched.
+func renameConditionRefs(cond string, prefixes []string, from, to string) string {
+	if !strings.Contains(cond, from) {
+		return cond
+	}
+	var sb strings.Builder
+	inQuote := false
+	for i := 0; i < len(cond); {
+		c := cond[i]
+		if c == '"' {
+			inQuote = !inQuote
+			sb.WriteByte(c)
+			i++
+			continue
+		}
+		if inQuote || (i > 0 && isIdentChar(cond[i-1])) {
+			sb.WriteByte(c)
+			i++
+			continue
+		}
+		start := i
+		prefix := ""
+		if strings.IndexByte("$?&!%", c) >= 0 {
+			prefix = string(c)
+			i++
+		}
+		j := i
+		for j < len(cond) && isIdentChar(cond[j]) {
+			j++
+		}
+		if j == i {
+			sb.WriteString(cond[start:i])
+			if start == i {
+				sb.WriteByte(c)
+				i++
+			}
+			continue
+		}
+		ident := cond[i:j]
+		if ident == from && contains(prefixes, prefix) {
+			sb.WriteString(prefix + to)
+		} else {
+			sb.WriteString(cond[start:j])
+		}
+		i = j
+	}
+	return sb.String()
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '.' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
@@ -0,0 +1,59 @@
+// core/msi_rename_test.go
+package core
+
+import (
+	"testing"
+)
+
+func TestRenameFormattedRefs(t *testing.T) {
+	cases := []struct {
+		text     string
+		prefixes []string
+		want     string
+	}{
+		{"[INSTALLDIR]bin\\app.exe", []string{""}, "[NEWDIR]bin\\app.exe"},
+		{"[INSTALLDIR2]bin", []string{""}, "[INSTALLDIR2]bin"},
+		{"\"[#INSTALLDIR]\" [!INSTALLDIR]", []string{"#", "!"}, "\"[#NEWDIR]\" [!NEWDIR]"},
+		{"[$INSTALLDIR] [INSTALLDIR]", []string{"$"}, "[$NEWDIR] [INSTALLDIR]"},
+		{"no references", []string{""}, "no references"},
+	}
+	for _, tc := range cases {
+		got := renameFormattedRefs(tc.text, tc.prefixes, "INSTALLDIR", "NEWDIR")
+		if got != tc.want {
+			t.Errorf("renameFormattedRefs(%q) = %q, want %q", tc.text, got, tc.want)
+		}
+	}
+}
+
+func TestRenameConditionRefs(t *testing.T) {
+	cases := []struct {
+		cond     string
+		prefixes []string
+		want     string
+	}{
+		{"NOT OLDPROP AND OLDPROP2", []string{""}, "NOT NEWPROP AND OLDPROP2"},
+		{`OLDPROP = "OLDPROP"`, []string{""}, `NEWPROP = "OLDPROP"`},
+		{"($OLDPROP=3) OR (?OLDPROP=3)", []string{"$", "?"}, "($NEWPROP=3) OR (?NEWPROP=3)"},
+		{"&OLDPROP=3 AND OLDPROP", []string{"&", "!"}, "&NEWPROP=3 AND OLDPROP"},
+		{"XOLDPROP OR OLDPROP.X", []string{""}, "XOLDPROP OR OLDPROP.X"},
+	}
+	for _, tc := range cases {
+		got := renameConditionRefs(tc.cond, tc.prefixes, "OLDPROP", "NEWPROP")
+		if got != tc.want {
+			t.Errorf("renameConditionRefs(%q) = %q, want %q", tc.cond, got, tc.want)
+		}
+	}
+}
+
+func TestReferencesTable(t *testing.T) {
+	rule := ValidationRule{KeyTable: "File;Registry;ODBCDataSource", KeyColumn: 1}
+	if !referencesTable(rule, "Registry") {
+		t.Errorf("Expected KeyPath rule to reference Registry")
+	}
+	if referencesTable(rule, "Component") {
+		t.Errorf("Did not expect KeyPath rule to reference Component")
+	}
+	if referencesTable(ValidationRule{KeyTable: "Control", KeyColumn: 2}, "Control") {
+		t.Errorf("Did not expect a second-column key to count as a reference")
+	}
+}
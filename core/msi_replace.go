@@ -0,0 +1,174 @@
+// core/msi_replace.go
+package core
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// structuredCategories are _Validation categories whose values are names or
+// codes rather than free text; find-and-replace never touches them.
+var structuredCategories = map[string]bool{
+	"Identifier": true, "Guid": true, "GUID": true, "Version": true, "Language": true, "Cabinet": true,
+	"Property": true, "UpperCase": true, "LowerCase": true, "CustomSource": true,
+	"Integer": true, "DoubleInteger": true, "TimeDate": true, "Binary": true,
+}
+
+// ReplaceText replaces every match of pattern in the string cells of an MSI.
+// tables and columns narrow the search; columns may be given as Column or
+// Table.Column. If transformPath is set, the edits are written there and the
+// MSI is left untouched.
+func ReplaceText(msiPath, pattern, replacement string, tables, columns []string, transformPath string, dryRun, interactive bool) error {
+	return SafeExecute("ReplaceText", func() error {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid --find pattern: %v", err)
+		}
+		mode := 1
+		if transformPath != "" || dryRun {
+			mode = 0
+		}
+		session, err := OpenMsiSession(msiPath, mode)
+		if err != nil {
+			return fmt.Errorf("failed to open MSI session: %v", err)
+		}
+		defer session.Close()
+
+		changes, err := session.PlanReplace(re, replacement, tables, columns)
+		if err != nil {
+			return err
+		}
+		cells := 0
+		for _, c := range changes {
+			for _, cell := range c.Cells() {
+				fmt.Printf("   %s\n", CellEdit{Table: c.Table, Key: c.Key, Column: cell.Column, Old: cell.Old, New: cell.New})
+				cells++
+			}
+		}
+		fmt.Printf("Replacing /%s/ touches %d cell(s) in %d row(s)\n", pattern, cells, len(changes))
+
+		if dryRun {
+			log.Println("[DRY-RUN] Replacement previewed; no changes written.")
+			return nil
+		}
+		if len(changes) == 0 {
+			return nil
+		}
+		if interactive {
+			if err := confirmOrCancel(fmt.Sprintf("Apply %d replacement(s)?", cells), "replacement"); err != nil {
+				return err
+			}
+		}
+
+		if transformPath != "" {
+			if err := WriteTextTransform(transformPath, session.transformBase(), changes); err != nil {
+				return err
+			}
+			log.Printf("[INFO] Replacements written to transform: %s", transformPath)
+			return nil
+		}
+		if err := session.ApplyRowChanges(changes); err != nil {
+			return err
+		}
+		return session.Commit()
+	})
+}
+
+// PlanReplace returns one update per row that contains a match. Only
+// non-key string columns are searched, and foreign keys and columns whose
+// category holds identifiers or codes are skipped. Every rewritten row is
+// validated before it is returned.
+func (s *MsiSession) PlanReplace(re *regexp.Regexp, replacement string, tables, columns []string) ([]RowChange, error) {
+	all, err := s.TableNames()
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tables {
+		if !contains(all, t) {
+			return nil, fmt.Errorf("no table '%s' in the database", t)
+		}
+	}
+	rules, err := s.validationCatalog()
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(all)
+	var changes []RowChange
+	var violations ValidationErrors
+	for _, t := range all {
+		if strings.HasPrefix(t, "_") || (len(tables) > 0 && !contains(tables, t)) {
+			continue
+		}
+		schema, err := s.GetTableSchema(t)
+		if err != nil {
+			return nil, err
+		}
+		var targets []int
+		for i, col := range schema.Columns {
+			if replaceableColumn(col, rules[t][col.Name]) && columnSelected(t, col.Name, columns) {
+				targets = append(targets, i)
+			}
+		}
+		if len(targets) == 0 {
+			continue
+		}
+
+		rows, err := s.ExecuteQuery(fmt.Sprintf("SELECT * FROM `%s`", t))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read '%s': %v", t, err)
+		}
+		for _, row := range rows {
+			updated := append([]string(nil), row.Columns...)
+			fields := map[string]string{}
+			for _, i := range targets {
+				if i >= len(row.Columns) || !re.MatchString(row.Columns[i]) {
+					continue
+				}
+				updated[i] = re.ReplaceAllString(row.Columns[i], replacement)
+				if updated[i] != row.Columns[i] {
+					fields[schema.Columns[i].Name] = updated[i]
+				}
+			}
+			if len(fields) == 0 {
+				continue
+			}
+			if err := s.ValidateEdit(t, fields); err != nil {
+				if errs, ok := err.(ValidationErrors); ok {
+					violations = append(violations, errs...)
+					continue
+				}
+				return nil, err
+			}
+			changes = append(changes, RowChange{Op: OpUpdate, Table: t, Columns: schema.ColumnNames(),
+				Key: schema.KeyValues(row), Old: row.Columns, New: updated})
+		}
+	}
+	if len(violations) > 0 {
+		return nil, fmt.Errorf("replacement produces invalid values:\n%v", violations)
+	}
+	return changes, nil
+}
+
+// replaceableColumn reports whether a column holds free text that
+// find-and-replace may rewrite.
+func replaceableColumn(col ColumnInfo, rule ValidationRule) bool {
+	if col.Key || !col.IsString() {
+		return false
+	}
+	if rule.KeyTable != "" || rule.Set != "" {
+		return false
+	}
+	return !structuredCategories[rule.Category]
+}
+
+// columnSelected reports whether table.column passes a --columns filter.
+func columnSelected(table, column string, columns []string) bool {
+	if len(columns) == 0 {
+		return true
+	}
+	return contains(columns, column) || contains(columns, table+"."+column)
+}
@@ -0,0 +1,43 @@
+// core/msi_replace_test.go
+package core
+
+import "testing"
+
+func TestReplaceableColumn(t *testing.T) {
+	tests := []struct {
+		name     string
+		col      ColumnInfo
+		rule     ValidationRule
+		expected bool
+	}{
+		{"free text", ColumnInfo{Name: "Value", Type: "L0"}, ValidationRule{Category: "Formatted"}, true},
+		{"no rule", ColumnInfo{Name: "Description", Type: "L255"}, ValidationRule{}, true},
+		{"key column", ColumnInfo{Name: "Registry", Type: "s72", Key: true}, ValidationRule{}, false},
+		{"integer", ColumnInfo{Name: "Root", Type: "i2"}, ValidationRule{}, false},
+		{"binary", ColumnInfo{Name: "Data", Type: "v0"}, ValidationRule{}, false},
+		{"foreign key", ColumnInfo{Name: "Component_", Type: "s72"}, ValidationRule{KeyTable: "Component", KeyColumn: 1}, false},
+		{"guid", ColumnInfo{Name: "ComponentId", Type: "S38"}, ValidationRule{Category: "Guid"}, false},
+		{"guid upper case", ColumnInfo{Name: "UpgradeCode", Type: "S38"}, ValidationRule{Category: "GUID"}, false},
+		{"value set", ColumnInfo{Name: "Action", Type: "s1"}, ValidationRule{Set: "+;-"}, false},
+	}
+	for _, tt := range tests {
+		if got := replaceableColumn(tt.col, tt.rule); got != tt.expected {
+			t.Errorf("%s: replaceableColumn = %v, expected %v", tt.name, got, tt.expected)
+		}
+	}
+}
+
+func TestColumnSelected(t *testing.T) {
+	if !columnSelected("Registry", "Value", nil) {
+		t.Error("Expected every column to be selected without a filter")
+	}
+	if !columnSelected("Registry", "Value", []string{"Value"}) {
+		t.Error("Expected a bare column name to match")
+	}
+	if !columnSelected("Registry", "Value", []string{"Registry.Value"}) {
+		t.Error("Expected a qualified column name to match")
+	}
+	if columnSelected("IniFile", "Value", []string{"Registry.Value"}) {
+		t.Error("Expected a column of another table not to match")
+	}
+}
@@ -0,0 +1,277 @@
+// core/msi_rows.go
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-ole/go-ole/oleutil"
+)
+
+// msiViewModifyInsert is the View.Modify mode that inserts a record.
+const msiViewModifyInsert = 1
+
+// TableNames returns the names of all tables in the database, including
+// system tables such as _Validation.
+func (s *MsiSession) TableNames() ([]string, error) {
+	rows, err := s.QueryWithParams("SELECT `Name` FROM `_Tables`")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query _Tables: %v", err)
+	}
+	names := make([]string, 0, len(rows))
+	for _, row := range rows {
+		if len(row.Columns) > 0 && row.Columns[0] != "" {
+			names = append(names, row.Columns[0])
+		}
+	}
+	return names, nil
+}
+
+// HasTable reports whether the database contains the named table.
+func (s *MsiSession) HasTable(table string) bool {
+	rows, err := s.QueryWithParams("SELECT `Name` FROM `_Tables` WHERE `Name`=?", table)
+	return err == nil && len(rows) > 0
+}
+
+// ExecuteStatement runs a statement that returns no rows (INSERT, UPDATE,
+// DELETE, CREATE, ALTER, DROP), binding params to its ? markers.
+func (s *MsiSession) ExecuteStatement(sql string, params ...interface{}) error {
+	if s.closed {
+		return fmt.Errorf("session is closed")
+	}
+	if s.mode != 1 {
+		return fmt.Errorf("statement not allowed in read-only mode")
+	}
+	if err := s.trackStatement(sql); err != nil {
+		return err
+	}
+	view, err := s.openView(sql)
+	if err != nil {
+		return err
+	}
+	defer s.closeView(view)
+	if err := s.executeView(view, params); err != nil {
+		return fmt.Errorf("failed to execute '%s': %v", sql, err)
+	}
+	if DebugMode {
+		logInfo(fmt.Sprintf("Executed '%s' with %d params", sql, len(params)))
+	}
+	return nil
+}
+
+// FindRow returns the row of table whose primary key equals key, or nil if there is none.
+func (s *MsiSession) FindRow(table string, key []string) (*TableRow, error) {
+	schema, err := s.GetTableSchema(table)
+	if err != nil {
+		return nil, err
+	}
+	where, params, err := keyCondition(schema, key)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.QueryWithParams(fmt.Sprintf("SELECT * FROM `%s` WHERE %s", table, where), params...)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	return &rows[0], nil
+}
+
+// InsertRow inserts a full row given as one string per column. Empty strings
+// insert NULL; binary columns are always inserted empty.
+func (s *MsiSession) InsertRow(table string, values []string) error {
+	schema, err := s.GetTableSchema(table)
+	if err != nil {
+		return err
+	}
+	if len(values) != len(schema.Columns) {
+		return fmt.Errorf("table '%s' has %d columns, got %d values", table, len(schema.Columns), len(values))
+	}
+	params := make([]interface{}, len(values))
+	marks := make([]string, len(values))
+	for i, col := range schema.Columns {
+		p, err := columnParam(col, values[i])
+		if err != nil {
+			return fmt.Errorf("%s.%s: %v", table, col.Name, err)
+		}
+		params[i] = p
+		marks[i] = "?"
+	}
+	sql := fmt.Sprintf("INSERT INTO `%s` (%s) VALUES (%s)", table, quotedColumns(schema.ColumnNames()), strings.Join(marks, ", "))
+	return s.ExecuteStatement(sql, params...)
+}
+
+// DeleteRow deletes the row of table whose primary key equals key.
+func (s *MsiSession) DeleteRow(table string, key []string) error {
+	schema, err := s.GetTableSchema(table)
+	if err != nil {
+		return err
+	}
+	where, params, err := keyCondition(schema, key)
+	if err != nil {
+		return err
+	}
+	return s.ExecuteStatement(fmt.Sprintf("DELETE FROM `%s` WHERE %s", table, where), params...)
+}
+
+// UpdateRow sets the given columns on the row whose primary key equals key.
+// MSI SQL cannot UPDATE key columns, so a change to the key re-inserts the
+// fetched record under its new key and deletes the old row; stream columns
+// travel with the record.
+func (s *MsiSession) UpdateRow(table string, key []string, changes map[string]string) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	schema, err := s.GetTableSchema(table)
+	if err != nil {
+		return err
+	}
+	if err := s.trackTable(table); err != nil {
+		return err
+	}
+	rekey := false
+	for name := range changes {
+		col, _, ok := schema.Column(name)
+		if !ok {
+			return fmt.Errorf("table '%s' has no column '%s'", table, name)
+		}
+		if col.Key {
+			rekey = true
+		}
+	}
+	if rekey {
+		return s.rekeyRow(schema, key, changes)
+	}
+
+	sets, params, err := setList(schema, changes)
+	if err != nil {
+		return err
+	}
+	where, keyParams, err := keyCondition(schema, key)
+	if err != nil {
+		return err
+	}
+	sql := fmt.Sprintf("UPDATE `%s` SET %s WHERE %s", table, sets, where)
+	return s.ExecuteStatement(sql, append(params, keyParams...)...)
+}
+
+// rekeyRow moves a row to a new primary key, applying changes on the way.
+func (s *MsiSession) rekeyRow(schema *TableSchema, key []string, changes map[string]string) error {
+	where, params, err := keyCondition(schema, key)
+	if err != nil {
+		return err
+	}
+	view, err := s.openView(fmt.Sprintf("SELECT * FROM `%s` WHERE %s", schema.Name, where))
+	if err != nil {
+		return err
+	}
+	defer s.closeView(view)
+	if err := s.executeView(view, params); err != nil {
+		return fmt.Errorf("failed to locate row %v in '%s': %v", key, schema.Name, err)
+	}
+	recRaw, err := oleutil.CallMethod(view, "Fetch")
+	if err != nil || recRaw.Value() == nil {
+		return fmt.Errorf("row %v not found in '%s'", key, schema.Name)
+	}
+	rec := recRaw.ToIDispatch()
+	if rec == nil {
+		return fmt.Errorf("fetch returned nil dispatch for row %v in '%s'", key, schema.Name)
+	}
+	defer rec.Release()
+
+	for name, value := range changes {
+		col, idx, _ := schema.Column(name)
+		if col.IsInteger() && value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("%s.%s: expected an integer, got '%s'", schema.Name, name, value)
+			}
+			_, err = oleutil.PutProperty(rec, "IntegerData", idx+1, int32(n))
+			if err != nil {
+				return fmt.Errorf("failed to set %s.%s: %v", schema.Name, name, err)
+			}
+			continue
+		}
+		if _, err := oleutil.PutProperty(rec, "StringData", idx+1, value); err != nil {
+			return fmt.Errorf("failed to set %s.%s: %v", schema.Name, name, err)
+		}
+	}
+	if _, err := oleutil.CallMethod(view, "Modify", msiViewModifyInsert, rec); err != nil {
+		return fmt.Errorf("failed to insert re-keyed row into '%s': %v", schema.Name, err)
+	}
+	return s.ExecuteStatement(fmt.Sprintf("DELETE FROM `%s` WHERE %s", schema.Name, where), params...)
+}
+
+// setList builds the SET list of an UPDATE, binding each value as a typed
+// parameter so values containing quotes need no escaping.
+func setList(schema *TableSchema, changes map[string]string) (string, []interface{}, error) {
+	var sets []string
+	var params []interface{}
+	for _, name := range sortedKeys(changes) {
+		col, _, ok := schema.Column(name)
+		if !ok {
+			return "", nil, fmt.Errorf("table '%s' has no column '%s'", schema.Name, name)
+		}
+		p, err := columnParam(col, changes[name])
+		if err != nil {
+			return "", nil, fmt.Errorf("%s.%s: %v", schema.Name, name, err)
+		}
+		sets = append(sets, fmt.Sprintf("`%s`=?", name))
+		params = append(params, p)
+	}
+	return strings.Join(sets, ", "), params, nil
+}
+
+// keyCondition builds a WHERE clause matching a row by primary key.
+// NULL key values (allowed in a few tables) are matched with IS NULL.
+func keyCondition(schema *TableSchema, key []string) (string, []interface{}, error) {
+	keys := schema.KeyColumns()
+	if len(keys) == 0 {
+		return "", nil, fmt.Errorf("table '%s' has no primary key", schema.Name)
+	}
+	if len(key) != len(keys) {
+		return "", nil, fmt.Errorf("table '%s' has %d key columns, got %d key values", schema.Name, len(keys), len(key))
+	}
+	var conds []string
+	var params []interface{}
+	for i, col := range keys {
+		if key[i] == "" {
+			conds = append(conds, fmt.Sprintf("`%s` IS NULL", col.Name))
+			continue
+		}
+		p, err := columnParam(col, key[i])
+		if err != nil {
+			return "", nil, fmt.Errorf("%s.%s: %v", schema.Name, col.Name, err)
+		}
+		conds = append(conds, fmt.Sprintf("`%s`=?", col.Name))
+		params = append(params, p)
+	}
+	return strings.Join(conds, " AND "), params, nil
+}
+
+// columnParam converts a string value into a typed parameter for col.
+func columnParam(col ColumnInfo, value string) (interface{}, error) {
+	if value == "" || col.IsBinary() {
+		return nil, nil
+	}
+	if col.IsInteger() {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("expected an integer, got '%s'", value)
+		}
+		return n, nil
+	}
+	return value, nil
+}
+
+// quotedColumns returns a comma-separated list of back-quoted column names.
+func quotedColumns(names []string) string {
+	quoted := make([]string, len(names))
+	for i, n := range names {
+		quoted[i] = "`" + n + "`"
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,219 @@
+// core/msi_schema.go
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/go-ole/go-ole"
+	"github.com/go-ole/go-ole/oleutil"
+)
+
+// ColumnInfo describes a single column of an MSI table.
+type ColumnInfo struct {
+	Name string
+	Type string // MSI column definition as reported by View.ColumnInfo, e.g. "s72", "I2", "v0"
+	Key  bool   // true if the column is part of the primary key
+}
+
+// TableSchema describes the columns and primary key of an MSI table.
+type TableSchema struct {
+	Name    string
+	Columns []ColumnInfo
+}
+
+// typeCode returns the lower-case type letter of the column definition.
+func (c ColumnInfo) typeCode() byte {
+	if c.Type == "" {
+		return 's'
+	}
+	return byte(unicode.ToLower(rune(c.Type[0])))
+}
+
+// IsInteger reports whether the column holds 16- or 32-bit integers.
+func (c ColumnInfo) IsInteger() bool {
+	return c.typeCode() == 'i' || c.typeCode() == 'j'
+}
+
+// IsBinary reports whether the column holds stream data.
+func (c ColumnInfo) IsBinary() bool {
+	return c.typeCode() == 'v'
+}
+
+// IsString reports whether the column holds (possibly localizable) text.
+func (c ColumnInfo) IsString() bool {
+	return !c.IsInteger() && !c.IsBinary()
+}
+
+// IsLocalizable reports whether the column holds localizable text.
+func (c ColumnInfo) IsLocalizable() bool {
+	return c.typeCode() == 'l'
+}
+
+// IsNullable reports whether the column accepts null values.
+// MSI marks nullable columns with an upper-case type letter.
+func (c ColumnInfo) IsNullable() bool {
+	return c.Type != "" && unicode.IsUpper(rune(c.Type[0]))
+}
+
+// Width returns the declared width: the maximum length for strings
+// (0 meaning unlimited) or the byte size for integers.
+func (c ColumnInfo) Width() int {
+	if len(c.Type) < 2 {
+		return 0
+	}
+	w, err := strconv.Atoi(c.Type[1:])
+	if err != nil {
+		return 0
+	}
+	return w
+}
+
+// Column looks up a column by name and returns it with its zero-based index.
+func (t *TableSchema) Column(name string) (ColumnInfo, int, bool) {
+	for i, c := range t.Columns {
+		if c.Name == name {
+			return c, i, true
+		}
+	}
+	return ColumnInfo{}, -1, false
+}
+
+// ColumnNames returns the column names in table order.
+func (t *TableSchema) ColumnNames() []string {
+	names := make([]string, len(t.Columns))
+	for i, c := range t.Columns {
+		names[i] = c.Name
+	}
+	return names
+}
+
+// KeyColumns returns the primary key columns in table order.
+func (t *TableSchema) KeyColumns() []ColumnInfo {
+	var keys []ColumnInfo
+	for _, c := range t.Columns {
+		if c.Key {
+			keys = append(keys, c)
+		}
+	}
+	return keys
+}
+
+// KeyValues extracts the primary key values from a row of this table.
+func (t *TableSchema) KeyValues(row TableRow) []string {
+	var key []string
+	for i, c := range t.Columns {
+		if c.Key && i < len(row.Columns) {
+			key = append(key, row.Columns[i])
+		}
+	}
+	return key
+}
+
+// RowKey returns a single string identifying a row by its primary key,
+// suitable for use as a map key.
+func (t *TableSchema) RowKey(row TableRow) string {
+	return strings.Join(t.KeyValues(row), "\x00")
+}
+
+// GetTableSchema returns column names, types and primary keys for a table.
+// Results are cached for the lifetime of the session.
+func (s *MsiSession) GetTableSchema(tableName string) (*TableSchema, error) {
+	if s.closed {
+		return nil, fmt.Errorf("session is closed")
+	}
+	if schema, ok := s.schemas[tableName]; ok {
+		return schema, nil
+	}
+	var schema *TableSchema
+	err := SafeExecute("GetTableSchema", func() error {
+		view, err := s.openView(fmt.Sprintf("SELECT * FROM `%s`", tableName))
+		if err != nil {
+			return err
+		}
+		defer s.closeView(view)
+
+		names, err := recordStrings(view, 0)
+		if err != nil {
+			return fmt.Errorf("failed to read column names for '%s': %v", tableName, err)
+		}
+		types, err := recordStrings(view, 1)
+		if err != nil {
+			return fmt.Errorf("failed to read column types for '%s': %v", tableName, err)
+		}
+		if len(names) != len(types) {
+			return fmt.Errorf("column info mismatch for '%s': %d names, %d types", tableName, len(names), len(types))
+		}
+
+		db, err := s.database()
+		if err != nil {
+			return err
+		}
+		keyRaw, err := oleutil.GetProperty(db, "PrimaryKeys", tableName)
+		if err != nil {
+			return fmt.Errorf("failed to read primary keys for '%s': %v", tableName, err)
+		}
+		keyRec := keyRaw.ToIDispatch()
+		if keyRec == nil {
+			return fmt.Errorf("primary keys for '%s' returned nil", tableName)
+		}
+		defer keyRec.Release()
+		keyCount, err := oleutil.GetProperty(keyRec, "FieldCount")
+		if err != nil {
+			return fmt.Errorf("failed to count primary keys for '%s': %v", tableName, err)
+		}
+		keys := map[string]bool{}
+		for i := 1; i <= int(keyCount.Val); i++ {
+			v, err := oleutil.GetProperty(keyRec, "StringData", i)
+			if err == nil {
+				keys[v.ToString()] = true
+			}
+		}
+
+		schema = &TableSchema{Name: tableName}
+		for i, name := range names {
+			schema.Columns = append(schema.Columns, ColumnInfo{Name: name, Type: types[i], Key: keys[name]})
+		}
+		if DebugMode {
+			logInfo(fmt.Sprintf("Schema for '%s': %d columns, %d keys", tableName, len(schema.Columns), len(keys)))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if s.schemas == nil {
+		s.schemas = map[string]*TableSchema{}
+	}
+	s.schemas[tableName] = schema
+	return schema, nil
+}
+
+// recordStrings reads View.ColumnInfo(infoType) and returns its fields as strings.
+// infoType 0 returns column names, 1 returns column definitions.
+func recordStrings(view *ole.IDispatch, infoType int) ([]string, error) {
+	raw, err := oleutil.GetProperty(view, "ColumnInfo", infoType)
+	if err != nil {
+		return nil, err
+	}
+	rec := raw.ToIDispatch()
+	if rec == nil {
+		return nil, fmt.Errorf("ColumnInfo returned nil")
+	}
+	defer rec.Release()
+	count, err := oleutil.GetProperty(rec, "FieldCount")
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, 0, int(count.Val))
+	for i := 1; i <= int(count.Val); i++ {
+		v, err := oleutil.GetProperty(rec, "StringData", i)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v.ToString())
+	}
+	return out, nil
+}
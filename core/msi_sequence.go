@@ -0,0 +1,389 @@
+// core/msi_sequence.go
+package core
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// msidbCustomActionTypeInScript marks a deferred (in-script) custom action.
+const msidbCustomActionTypeInScript = 0x400
+
+// sequenceTables lists the action sequence tables; execute sequences run
+// the install script between InstallInitialize and InstallFinalize.
+var sequenceTables = map[string]struct{ Execute bool }{
+	"InstallExecuteSequence": {Execute: true},
+	"InstallUISequence":      {},
+	"AdminExecuteSequence":   {Execute: true},
+	"AdminUISequence":        {},
+	"AdvtExecuteSequence":    {Execute: true},
+}
+
+// standardActionSequence holds the suggested sequence numbers of the
+// standard actions, used to place actions relative to ones the table lacks.
+var standardActionSequence = map[string]int{
+	"FindRelatedProducts": 25, "AppSearch": 50, "LaunchConditions": 100, "CCPSearch": 500,
+	"RMCCPSearch": 600, "ValidateProductID": 700, "CostInitialize": 800, "FileCost": 900,
+	"IsolateComponents": 950, "CostFinalize": 1000, "SetODBCFolders": 1100,
+	"MigrateFeatureStates": 1200, "ExecuteAction": 1300, "InstallValidate": 1400,
+	"InstallInitialize": 1500, "AllocateRegistrySpace": 1550, "ProcessComponents": 1600,
+	"UnpublishComponents": 1700, "UnpublishFeatures": 1800, "StopServices": 1900,
+	"DeleteServices": 2000, "UnregisterComPlus": 2100, "SelfUnregModules": 2200,
+	"UnregisterTypeLibraries": 2300, "RemoveODBC": 2400, "UnregisterFonts": 2500,
+	"RemoveRegistryValues": 2600, "UnregisterClassInfo": 2700, "UnregisterExtensionInfo": 2800,
+	"UnregisterProgIdInfo": 2900, "UnregisterMIMEInfo": 3000, "RemoveIniValues": 3100,
+	"RemoveShortcuts": 3200, "RemoveEnvironmentStrings": 3300, "RemoveDuplicateFiles": 3400,
+	"RemoveFiles": 3500, "RemoveFolders": 3600, "CreateFolders": 3700, "MoveFiles": 3800,
+	"InstallFiles": 4000, "PatchFiles": 4090, "DuplicateFiles": 4210, "BindImage": 4300,
+	"CreateShortcuts": 4500, "RegisterClassInfo": 4600, "RegisterExtensionInfo": 4700,
+	"RegisterProgIdInfo": 4800, "RegisterMIMEInfo": 4900, "WriteRegistryValues": 5000,
+	"WriteIniValues": 5100, "WriteEnvironmentStrings": 5200, "RegisterFonts": 5300,
+	"InstallODBC": 5400, "RegisterTypeLibraries": 5500, "SelfRegModules": 5600,
+	"RegisterComPlus": 5700, "InstallServices": 5800, "StartServices": 5900,
+	"RegisterUser": 6000, "RegisterProduct": 6100, "PublishComponents": 6200,
+	"PublishFeatures": 6300, "PublishProduct": 6400, "InstallFinalize": 6600,
+}
+
+// SequencePlacement says where an action goes: after or before another
+// action, or at an explicit sequence number.
+type SequencePlacement struct {
+	After    string
+	Before   string
+	Sequence int
+}
+
+func (p SequencePlacement) String() string {
+	switch {
+	case p.After != "":
+		return "after " + p.After
+	case p.Before != "":
+		return "before " + p.Before
+	}
+	return fmt.Sprintf("at %d", p.Sequence)
+}
+
+// sequenceRow is one row of a sequence table.
+type sequenceRow struct {
+	Action    string
+	Condition string
+	Sequence  int // 0 when the sequence is null
+}
+
+// SequenceAdd schedules an action in a sequence table.
+func SequenceAdd(msiPath, table, action, condition string, place SequencePlacement, dryRun, interactive bool) error {
+	return SafeExecute("SequenceAdd", func() error {
+		return editSequence(msiPath, dryRun, interactive, func(s *MsiSession) ([]RowChange, error) {
+			return s.PlanSequenceAdd(table, action, condition, place)
+		})
+	})
+}
+
+// SequenceMove reschedules an action already in a sequence table.
+func SequenceMove(msiPath, table, action string, place SequencePlacement, dryRun, interactive bool) error {
+	return SafeExecute("SequenceMove", func() error {
+		return editSequence(msiPath, dryRun, interactive, func(s *MsiSession) ([]RowChange, error) {
+			return s.PlanSequenceMove(table, action, place)
+		})
+	})
+}
+
+// SequenceRemove removes an action from a sequence table.
+func SequenceRemove(msiPath, table, action string, dryRun, interactive bool) error {
+	return SafeExecute("SequenceRemove", func() error {
+		return editSequence(msiPath, dryRun, interactive, func(s *MsiSession) ([]RowChange, error) {
+			return s.PlanSequenceRemove(table, action)
+		})
+	})
+}
+
+func editSequence(msiPath string, dryRun, interactive bool, plan func(*MsiSession) ([]RowChange, error)) error {
+	session, err := OpenMsiSession(msiPath, 1)
+	if err != nil {
+		return fmt.Errorf("failed to open MSI session: %v", err)
+	}
+	defer session.Close()
+
+	changes, err := plan(session)
+	if err != nil {
+		return err
+	}
+	for _, c := range changes {
+		fmt.Printf("   %s\n", c)
+	}
+	if dryRun {
+		log.Println("[DRY-RUN] Sequence change previewed; no changes committed.")
+		return nil
+	}
+	if interactive {
+		if err := confirmOrCancel(fmt.Sprintf("Apply %d sequence change(s)?", len(changes)), "sequence change"); err != nil {
+			return err
+		}
+	}
+	if err := session.ApplyRowChanges(changes); err != nil {
+		return err
+	}
+	return session.Commit()
+}
+
+// PlanSequenceAdd returns the insert for a new action plus any renumbering
+// of neighbours needed to make room for it.
+func (s *MsiSession) PlanSequenceAdd(table, action, condition string, place SequencePlacement) ([]RowChange, error) {
+	schema, rows, err := s.readSequence(table)
+	if err != nil {
+		return nil, err
+	}
+	if findSequenceRow(rows, action) != nil {
+		return nil, fmt.Errorf("'%s' is already scheduled in %s; use sequence move", action, table)
+	}
+	deferred, err := s.checkSequenceAction(table, action)
+	if err != nil {
+		return nil, err
+	}
+	seq, shifts, err := placeAction(rows, action, place)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDeferredRange(table, action, deferred, seq, rows, shifts); err != nil {
+		return nil, err
+	}
+	changes := sequenceShiftChanges(schema, rows, shifts)
+	changes = append(changes, RowChange{Op: OpInsert, Table: table, Columns: schema.ColumnNames(),
+		Key: []string{action}, New: sequenceValues(schema, sequenceRow{action, condition, seq})})
+	return changes, nil
+}
+
+// PlanSequenceMove returns the updates that move an action to a new place.
+func (s *MsiSession) PlanSequenceMove(table, action string, place SequencePlacement) ([]RowChange, error) {
+	schema, rows, err := s.readSequence(table)
+	if err != nil {
+		return nil, err
+	}
+	current := findSequenceRow(rows, action)
+	if current == nil {
+		return nil, fmt.Errorf("'%s' is not scheduled in %s", action, table)
+	}
+	deferred, err := s.checkSequenceAction(table, action)
+	if err != nil {
+		return nil, err
+	}
+	seq, shifts, err := placeAction(rows, action, place)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDeferredRange(table, action, deferred, seq, rows, shifts); err != nil {
+		return nil, err
+	}
+	moved := *current
+	moved.Sequence = seq
+	changes := sequenceShiftChanges(schema, rows, shifts)
+	if seq != current.Sequence {
+		changes = append(changes, RowChange{Op: OpUpdate, Table: table, Columns: schema.ColumnNames(), Key: []string{action},
+			Old: sequenceValues(schema, *current), New: sequenceValues(schema, moved)})
+	}
+	return changes, nil
+}
+
+// PlanSequenceRemove returns the delete that unschedules an action.
+func (s *MsiSession) PlanSequenceRemove(table, action string) ([]RowChange, error) {
+	schema, rows, err := s.readSequence(table)
+	if err != nil {
+		return nil, err
+	}
+	current := findSequenceRow(rows, action)
+	if current == nil {
+		return nil, fmt.Errorf("'%s' is not scheduled in %s", action, table)
+	}
+	return []RowChange{{Op: OpDelete, Table: table, Columns: schema.ColumnNames(), Key: []string{action},
+		Old: sequenceValues(schema, *current)}}, nil
+}
+
+// readSequence reads every row of a sequence table.
+func (s *MsiSession) readSequence(table string) (*TableSchema, []sequenceRow, error) {
+	if _, ok := sequenceTables[table]; !ok {
+		return nil, nil, fmt.Errorf("'%s' is not a sequence table", table)
+	}
+	if !s.HasTable(table) {
+		return nil, nil, fmt.Errorf("no table '%s' in the database", table)
+	}
+	schema, err := s.GetTableSchema(table)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows, err := s.QueryWithParams(fmt.Sprintf("SELECT `Action`, `Condition`, `Sequence` FROM `%s`", table))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read '%s': %v", table, err)
+	}
+	seqRows := make([]sequenceRow, 0, len(rows))
+	for _, row := range rows {
+		if len(row.Columns) < 3 {
+			continue
+		}
+		n, _ := strconv.Atoi(row.Columns[2])
+		seqRows = append(seqRows, sequenceRow{row.Columns[0], row.Columns[1], n})
+	}
+	return schema, seqRows, nil
+}
+
+// checkSequenceAction makes sure action names something that can be
+// scheduled and reports whether it is a deferred custom action.
+func (s *MsiSession) checkSequenceAction(table, action string) (bool, error) {
+	if _, ok := standardActionSequence[action]; ok {
+		return false, nil
+	}
+	if s.HasTable("CustomAction") {
+		row, err := s.FindRow("CustomAction", []string{action})
+		if err != nil {
+			return false, err
+		}
+		if row != nil {
+			typ, _ := strconv.Atoi(row.Columns[1])
+			return typ&msidbCustomActionTypeInScript != 0, nil
+		}
+	}
+	if !sequenceTables[table].Execute && s.HasTable("Dialog") {
+		if row, err := s.FindRow("Dialog", []string{action}); err == nil && row != nil {
+			return false, nil
+		}
+	}
+	return false, fmt.Errorf("'%s' is not a standard action, custom action or dialog", action)
+}
+
+// placeAction resolves a placement to a free sequence number for action.
+// With --after the action takes the number following the anchor, with
+// --before the one preceding it. When that number is taken, the anchor's
+// neighbours are shifted up by one until the first gap; shifts maps each
+// renumbered action to its new sequence.
+func placeAction(rows []sequenceRow, action string, place SequencePlacement) (int, map[string]int, error) {
+	occupied := map[int]string{}
+	for _, r := range rows {
+		if r.Action != action && r.Sequence > 0 {
+			occupied[r.Sequence] = r.Action
+		}
+	}
+
+	anchorSequence := func(name string) (int, error) {
+		if name == action {
+			return 0, fmt.Errorf("cannot place '%s' relative to itself", action)
+		}
+		if r := findSequenceRow(rows, name); r != nil {
+			if r.Sequence <= 0 {
+				return 0, fmt.Errorf("'%s' has no positive sequence number to place against", name)
+			}
+			return r.Sequence, nil
+		}
+		if n, ok := standardActionSequence[name]; ok {
+			return n, nil
+		}
+		return 0, fmt.Errorf("'%s' is neither scheduled in the table nor a standard action", name)
+	}
+
+	var target int
+	switch {
+	case place.After != "" && place.Before != "":
+		return 0, nil, fmt.Errorf("use only one of --after and --before")
+	case place.After != "":
+		anchor, err := anchorSequence(place.After)
+		if err != nil {
+			return 0, nil, err
+		}
+		target = anchor + 1
+	case place.Before != "":
+		anchor, err := anchorSequence(place.Before)
+		if err != nil {
+			return 0, nil, err
+		}
+		target = anchor - 1
+		if _, taken := occupied[target]; taken || target < 1 {
+			// No gap below the anchor: take its slot and push it up.
+			target = anchor
+		}
+	case place.Sequence > 0:
+		target = place.Sequence
+	default:
+		return 0, nil, fmt.Errorf("one of --after, --before or --sequence is required")
+	}
+
+	shifts := map[string]int{}
+	for n := target; ; n++ {
+		name, taken := occupied[n]
+		if !taken {
+			break
+		}
+		shifts[name] = n + 1
+	}
+	if len(shifts) > 0 && target+len(shifts) > 32767 {
+		return 0, nil, fmt.Errorf("no room to renumber after sequence %d", target)
+	}
+	return target, shifts, nil
+}
+
+// checkDeferredRange refuses to schedule a deferred custom action outside
+// InstallInitialize..InstallFinalize of an execute sequence.
+func checkDeferredRange(table, action string, deferred bool, seq int, rows []sequenceRow, shifts map[string]int) error {
+	if !deferred {
+		return nil
+	}
+	if !sequenceTables[table].Execute {
+		return fmt.Errorf("'%s' is a deferred custom action and cannot run in %s", action, table)
+	}
+	bound := func(name string) int {
+		if n, ok := shifts[name]; ok {
+			return n
+		}
+		if r := findSequenceRow(rows, name); r != nil && r.Sequence > 0 {
+			return r.Sequence
+		}
+		return standardActionSequence[name]
+	}
+	start, end := bound("InstallInitialize"), bound("InstallFinalize")
+	if seq <= start || seq >= end {
+		return fmt.Errorf("'%s' is a deferred custom action and must be sequenced between InstallInitialize (%d) and InstallFinalize (%d), not at %d",
+			action, start, end, seq)
+	}
+	return nil
+}
+
+// sequenceShiftChanges turns renumbering into row updates, lowest first.
+func sequenceShiftChanges(schema *TableSchema, rows []sequenceRow, shifts map[string]int) []RowChange {
+	names := sortedKeys(shifts)
+	sort.SliceStable(names, func(i, j int) bool { return shifts[names[i]] < shifts[names[j]] })
+	var changes []RowChange
+	for _, name := range names {
+		old := *findSequenceRow(rows, name)
+		moved := old
+		moved.Sequence = shifts[name]
+		changes = append(changes, RowChange{Op: OpUpdate, Table: schema.Name, Columns: schema.ColumnNames(),
+			Key: []string{name}, Old: sequenceValues(schema, old), New: sequenceValues(schema, moved)})
+	}
+	return changes
+}
+
+// sequenceValues lays a sequence row out in the table's column order.
+func sequenceValues(schema *TableSchema, r sequenceRow) []string {
+	values := make([]string, len(schema.Columns))
+	for i, col := range schema.Columns {
+		switch strings.ToLower(col.Name) {
+		case "action":
+			values[i] = r.Action
+		case "condition":
+			values[i] = r.Condition
+		case "sequence":
+			if r.Sequence != 0 {
+				values[i] = strconv.Itoa(r.Sequence)
+			}
+		}
+	}
+	return values
+}
+
+func findSequenceRow(rows []sequenceRow, action string) *sequenceRow {
+	for i := range rows {
+		if rows[i].Action == action {
+			return &rows[i]
+		}
+	}
+	return nil
+}
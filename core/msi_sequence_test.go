@@ -0,0 +1,83 @@
+// core/msi_sequence_test.go
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPlaceAction(t *testing.T) {
+	rows := []sequenceRow{
+		{Action: "InstallInitialize", Sequence: 1500},
+		{Action: "InstallFiles", Sequence: 4000},
+		{Action: "CA_A", Sequence: 4001},
+		{Action: "CA_B", Sequence: 4002},
+		{Action: "CreateShortcuts", Sequence: 4500},
+		{Action: "InstallFinalize", Sequence: 6600},
+		{Action: "ExitDialog", Sequence: -1},
+	}
+	tests := []struct {
+		name    string
+		place   SequencePlacement
+		seq     int
+		shifts  map[string]int
+		wantErr bool
+	}{
+		{"gap after", SequencePlacement{After: "CreateShortcuts"}, 4501, map[string]int{}, false},
+		{"no gap after", SequencePlacement{After: "InstallFiles"}, 4001, map[string]int{"CA_A": 4002, "CA_B": 4003}, false},
+		{"gap before", SequencePlacement{Before: "CreateShortcuts"}, 4499, map[string]int{}, false},
+		{"no gap before", SequencePlacement{Before: "CA_B"}, 4002, map[string]int{"CA_B": 4003}, false},
+		{"standard action not in table", SequencePlacement{After: "WriteRegistryValues"}, 5001, map[string]int{}, false},
+		{"explicit sequence", SequencePlacement{Sequence: 3000}, 3000, map[string]int{}, false},
+		{"unknown anchor", SequencePlacement{After: "Bogus"}, 0, nil, true},
+		{"negative anchor", SequencePlacement{After: "ExitDialog"}, 0, nil, true},
+		{"both anchors", SequencePlacement{After: "InstallFiles", Before: "CreateShortcuts"}, 0, nil, true},
+		{"no placement", SequencePlacement{}, 0, nil, true},
+	}
+	for _, tt := range tests {
+		seq, shifts, err := placeAction(rows, "MyCA", tt.place)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if seq != tt.seq || !reflect.DeepEqual(shifts, tt.shifts) {
+			t.Errorf("%s: got %d %v, expected %d %v", tt.name, seq, shifts, tt.seq, tt.shifts)
+		}
+	}
+}
+
+func TestPlaceAction_MoveIgnoresOwnRow(t *testing.T) {
+	rows := []sequenceRow{
+		{Action: "InstallFiles", Sequence: 4000},
+		{Action: "MyCA", Sequence: 4001},
+	}
+	seq, shifts, err := placeAction(rows, "MyCA", SequencePlacement{After: "InstallFiles"})
+	if err != nil || seq != 4001 || len(shifts) != 0 {
+		t.Errorf("Expected 4001 with no shifts, got %d %v (%v)", seq, shifts, err)
+	}
+}
+
+func TestCheckDeferredRange(t *testing.T) {
+	rows := []sequenceRow{
+		{Action: "InstallInitialize", Sequence: 1500},
+		{Action: "InstallFinalize", Sequence: 6600},
+	}
+	if err := checkDeferredRange("InstallExecuteSequence", "MyCA", true, 4001, rows, nil); err != nil {
+		t.Errorf("Expected no error inside the script, got: %v", err)
+	}
+	if err := checkDeferredRange("InstallExecuteSequence", "MyCA", true, 1000, rows, nil); err == nil {
+		t.Error("Expected an error before InstallInitialize")
+	}
+	if err := checkDeferredRange("InstallExecuteSequence", "MyCA", true, 6600, rows, map[string]int{"InstallFinalize": 6601}); err != nil {
+		t.Errorf("Expected shifted InstallFinalize to be honoured, got: %v", err)
+	}
+	if err := checkDeferredRange("InstallUISequence", "MyCA", true, 4001, rows, nil); err == nil {
+		t.Error("Expected an error for a deferred action in a UI sequence")
+	}
+	if err := checkDeferredRange("InstallExecuteSequence", "MyCA", false, 100, rows, nil); err != nil {
+		t.Errorf("Expected immediate actions to be placed anywhere, got: %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package core
 
 import (
 	"fmt"
+	"os"
 	"strconv"
 	"strings"
 	"sync"
@@ -63,11 +64,29 @@ type MsiSession struct {
 	mode       int
 	closed     bool
 	localCOM   bool // Tracks if this session initialized COM
+	schemas    map[string]*TableSchema
+
+	tracked      map[string][]TableRow // before-images of tables written since the last commit
+	irreversible string                // why the pending journal entry cannot be undone
+	noJournal    bool                  // set while undoing, so an undo is not journaled itself
+
+	workPath string    // temp copy a read-write session edits until Commit publishes it
+	lock     *fileLock // advisory lock held by read-write sessions
 }
 
 // OpenMsiSession opens an MSI database in the specified mode (0=read-only, 1=read-write).
+// A read-write session locks the MSI and edits a temp copy in the same
+// directory; Commit atomically replaces the original with it.
 func OpenMsiSession(msiPath string, mode int) (*MsiSession, error) {
 	var session *MsiSession
+	var lock *fileLock
+	if mode == 1 {
+		l, err := acquireLock(msiPath, LockWait)
+		if err != nil {
+			return nil, err
+		}
+		lock = l
+	}
 	err := SafeExecuteWithRetry("OpenMsiSession", 3, func() error {
 		if mode != 0 && mode != 1 {
 			return fmt.Errorf("invalid mode %d: must be 0 (read-only) or 1 (read-write)", mode)
@@ -105,23 +124,16 @@ func OpenMsiSession(msiPath string, mode int) (*MsiSession, error) {
 			return fmt.Errorf("failed to query interface: %v", err)
 		}
 
-		dbRaw, err := oleutil.CallMethod(inst, "OpenDatabase", msiPath, mode)
-		if err != nil {
-			inst.Release()
-			return fmt.Errorf("failed to open database '%s': %v", msiPath, err)
+		session = &MsiSession{
+			installer: inst,
+			msiPath:   msiPath,
+			mode:      mode,
+			localCOM:  localCOM,
+			lock:      lock,
 		}
-		db := dbRaw.ToIDispatch()
-		if db == nil {
+		if _, err = session.database(); err != nil {
 			inst.Release()
-			return fmt.Errorf("open database '%s' returned nil", msiPath)
-		}
-
-		session = &MsiSession{
-			dbDispatch: db,
-			installer:  inst,
-			msiPath:    msiPath,
-			mode:       mode,
-			localCOM:   localCOM,
+			return err
 		}
 		if DebugMode {
 			logInfo(fmt.Sprintf("Opened MSI session for '%s' (mode=%d, localCOM=%v)", msiPath, mode, localCOM))
@@ -129,11 +141,52 @@ func OpenMsiSession(msiPath string, mode int) (*MsiSession, error) {
 		return nil
 	})
 	if err != nil {
+		lock.release()
 		return nil, err
 	}
 	return session, nil
 }
 
+// database returns the open database handle, reopening it after a commit
+// has published the previous working copy.
+func (s *MsiSession) database() (*ole.IDispatch, error) {
+	if s.dbDispatch != nil {
+		return s.dbDispatch, nil
+	}
+	dbPath := s.msiPath
+	if s.mode == 1 {
+		workPath, err := createWorkingCopy(s.msiPath)
+		if err != nil {
+			return nil, err
+		}
+		s.workPath = workPath
+		dbPath = workPath
+	}
+	dbRaw, err := oleutil.CallMethod(s.installer, "OpenDatabase", dbPath, s.mode)
+	if err != nil {
+		s.discardWorkingCopy()
+		return nil, fmt.Errorf("failed to open database '%s': %v", s.msiPath, err)
+	}
+	db := dbRaw.ToIDispatch()
+	if db == nil {
+		s.discardWorkingCopy()
+		return nil, fmt.Errorf("open database '%s' returned nil", s.msiPath)
+	}
+	s.dbDispatch = db
+	return db, nil
+}
+
+// discardWorkingCopy removes the working copy, dropping uncommitted changes.
+func (s *MsiSession) discardWorkingCopy() {
+	if s.workPath == "" {
+		return
+	}
+	if err := os.Remove(s.workPath); err != nil && DebugMode {
+		logWarn(fmt.Sprintf("Failed to remove working copy '%s': %v", s.workPath, err))
+	}
+	s.workPath = ""
+}
+
 // Close releases COM resources for this session.
 func (s *MsiSession) Close() error {
 	if s.closed {
@@ -148,6 +201,11 @@ func (s *MsiSession) Close() error {
 			s.installer.Release()
 			s.installer = nil
 		}
+		s.discardWorkingCopy()
+		if err := s.lock.release(); err != nil {
+			logWarn(err.Error())
+		}
+		s.lock = nil
 		if s.localCOM {
 			ole.CoUninitialize()
 			if DebugMode {
@@ -224,6 +282,85 @@ func (s *MsiSession) ExecuteQuery(sql string) ([]TableRow, error) {
 	return rows, nil
 }
 
+// QueryWithParams runs a SQL query whose ? markers are bound to params.
+// A nil param binds NULL, an int binds an integer and anything else binds its string form.
+func (s *MsiSession) QueryWithParams(sql string, params ...interface{}) ([]TableRow, error) {
+	if s.closed {
+		return nil, fmt.Errorf("session is closed")
+	}
+
+	view, err := s.openView(sql)
+	if err != nil {
+		return nil, err
+	}
+	defer s.closeView(view)
+
+	if err := s.executeView(view, params); err != nil {
+		return nil, fmt.Errorf("failed to execute query '%s': %v", sql, err)
+	}
+
+	var rows []TableRow
+	for {
+		recRaw, err := oleutil.CallMethod(view, "Fetch")
+		if err != nil || recRaw.Value() == nil {
+			break
+		}
+		rec := recRaw.ToIDispatch()
+		if rec == nil {
+			continue
+		}
+		count, err := oleutil.GetProperty(rec, "FieldCount")
+		if err != nil {
+			rec.Release()
+			return nil, fmt.Errorf("failed to read field count for '%s': %v", sql, err)
+		}
+		cols := make([]string, 0, int(count.Val))
+		for i := 1; i <= int(count.Val); i++ {
+			valRaw, err := oleutil.CallMethod(rec, "StringData", i)
+			if err != nil || valRaw == nil {
+				cols = append(cols, "")
+				continue
+			}
+			cols = append(cols, valRaw.ToString())
+		}
+		rec.Release()
+		rows = append(rows, TableRow{Columns: cols})
+	}
+	return rows, nil
+}
+
+// executeView executes a view, binding params to its ? markers when present.
+func (s *MsiSession) executeView(view *ole.IDispatch, params []interface{}) error {
+	if len(params) == 0 {
+		_, err := oleutil.CallMethod(view, "Execute")
+		return err
+	}
+	recRaw, err := oleutil.CallMethod(s.installer, "CreateRecord", len(params))
+	if err != nil {
+		return fmt.Errorf("failed to create parameter record: %v", err)
+	}
+	rec := recRaw.ToIDispatch()
+	if rec == nil {
+		return fmt.Errorf("create parameter record returned nil")
+	}
+	defer rec.Release()
+	for i, p := range params {
+		switch v := p.(type) {
+		case nil:
+			continue
+		case int:
+			_, err = oleutil.PutProperty(rec, "IntegerData", i+1, int32(v))
+		default:
+			_, err = oleutil.PutProperty(rec, "StringData", i+1, fmt.Sprint(v))
+		}
+		if err != nil {
+			return fmt.Errorf("failed to bind parameter %d: %v", i+1, err)
+		}
+	}
+	_, err = oleutil.CallMethod(view, "Execute", rec)
+	return err
+}
+
 // openView creates a new view for a SQL query.
 func (s *MsiSession) openView(sql string) (*ole.IDispatch, error) {
 	if s.closed {
@@ -231,7 +368,11 @@ func (s *MsiSession) openView(sql string) (*ole.IDispatch, error) {
 	}
 	var view *ole.IDispatch
 	err := SafeExecute("OpenView", func() error {
-		viewRaw, err := oleutil.CallMethod(s.dbDispatch, "OpenView", sql)
+		db, err := s.database()
+		if err != nil {
+			return err
+		}
+		viewRaw, err := oleutil.CallMethod(db, "OpenView", sql)
 		if err != nil {
 			return fmt.Errorf("failed to open view for '%s': %v", sql, err)
 		}
@@ -273,10 +414,30 @@ func (s *MsiSession) Commit() error {
 		return fmt.Errorf("commit not allowed in read-only mode")
 	}
 	return SafeExecute("CommitMsiSession", func() error {
-		_, err := oleutil.CallMethod(s.dbDispatch, "Commit")
+		db, err := s.database()
 		if err != nil {
+			return err
+		}
+		if _, err := oleutil.CallMethod(db, "Commit"); err != nil {
+			return fmt.Errorf("failed to commit changes for '%s': %v", s.msiPath, err)
+		}
+		entry, journalErr := s.pendingJournalEntry()
+
+		// Release the working copy before moving it over the original.
+		db.Release()
+		s.dbDispatch = nil
+		if err := publishWorkingCopy(s.workPath, s.msiPath); err != nil {
+			s.discardWorkingCopy()
 			return fmt.Errorf("failed to commit changes for '%s': %v", s.msiPath, err)
 		}
+		s.workPath = ""
+
+		if journalErr == nil && entry != nil {
+			journalErr = appendJournal(JournalPath(s.msiPath), *entry)
+		}
+		if journalErr != nil {
+			logWarn(fmt.Sprintf("Changes committed but not journaled for '%s': %v", s.msiPath, journalErr))
+		}
 		if DebugMode {
 			logInfo(fmt.Sprintf("Committed changes for '%s'", s.msiPath))
 		}
@@ -374,16 +535,31 @@ func (s *MsiSession) EditTable(tableName, setClause, whereClause string, dryRun,
 		return fmt.Errorf("edit not allowed in read-only mode")
 	}
 	return SafeExecute("EditTable", func() error {
-		setPairs := strings.Split(setClause, ",")
-		var setFields []string
-		for _, pair := range setPairs {
-			parts := strings.SplitN(strings.TrimSpace(pair), "=", 2)
-			if len(parts) != 2 {
-				return fmt.Errorf("invalid set clause: %s", pair)
+		fields, err := parseSetFields(setClause)
+		if err != nil {
+			return err
+		}
+		schema, err := s.GetTableSchema(tableName)
+		if err != nil {
+			return fmt.Errorf("failed to read schema for '%s': %v", tableName, err)
+		}
+		for name, value := range fields {
+			if isRelativeFlagEdit(tableName, name, value) {
+				return s.editFlagsPerRow(schema, fields, whereClause, dryRun, interactive)
 			}
-			setFields = append(setFields, fmt.Sprintf("`%s`='%s'", parts[0], parts[1]))
 		}
-		sql := fmt.Sprintf("UPDATE `%s` SET %s", tableName, strings.Join(setFields, ", "))
+		if fields, err = resolveFlagFields(schema, nil, fields); err != nil {
+			return err
+		}
+		if err := s.ValidateEdit(tableName, fields); err != nil {
+			return fmt.Errorf("validation failed: %v", err)
+		}
+
+		sets, params, err := setList(schema, fields)
+		if err != nil {
+			return err
+		}
+		sql := fmt.Sprintf("UPDATE `%s` SET %s", tableName, sets)
 		if whereClause != "" {
 			sql += fmt.Sprintf(" WHERE %s", whereClause)
 		}
@@ -401,21 +577,21 @@ func (s *MsiSession) EditTable(tableName, setClause, whereClause string, dryRun,
 		}
 
 		if interactive {
-			fmt.Print("Apply changes? [y/N]: ")
-			var response string
-			fmt.Scanln(&response)
-			if strings.ToLower(response) != "y" {
-				return fmt.Errorf("update cancelled by user")
+			if err := confirmOrCancel("Apply changes?", "update"); err != nil {
+				return err
 			}
 		}
 
 		if !dryRun {
+			if err := s.trackTable(tableName); err != nil {
+				return err
+			}
 			view, err := s.openView(sql)
 			if err != nil {
 				return fmt.Errorf("failed to prepare update: %v", err)
 			}
 			defer s.closeView(view)
-			if _, err := oleutil.CallMethod(view, "Execute"); err != nil {
+			if err := s.executeView(view, params); err != nil {
 				return fmt.Errorf("failed to execute update: %v", err)
 			}
 			return s.Commit()
@@ -424,6 +600,59 @@ func (s *MsiSession) EditTable(tableName, setClause, whereClause string, dryRun,
 	})
 }
 
+// editFlagsPerRow applies +flag/-flag edits, which depend on each row's
+// current value, one matching row at a time.
+func (s *MsiSession) editFlagsPerRow(schema *TableSchema, fields map[string]string, whereClause string, dryRun, interactive bool) error {
+	sql := fmt.Sprintf("SELECT * FROM `%s`", schema.Name)
+	if whereClause != "" {
+		sql += fmt.Sprintf(" WHERE %s", whereClause)
+	}
+	rows, err := s.ExecuteQuery(sql)
+	if err != nil {
+		return fmt.Errorf("failed to read rows to edit: %v", err)
+	}
+	var changes []RowChange
+	for i := range rows {
+		resolved, err := resolveFlagFields(schema, &rows[i], fields)
+		if err != nil {
+			return err
+		}
+		if err := s.ValidateEdit(schema.Name, resolved); err != nil {
+			return fmt.Errorf("validation failed: %v", err)
+		}
+		updated := append([]string(nil), rows[i].Columns...)
+		for name, value := range resolved {
+			if _, idx, ok := schema.Column(name); ok && idx < len(updated) {
+				updated[idx] = value
+			}
+		}
+		change := RowChange{Op: OpUpdate, Table: schema.Name, Columns: schema.ColumnNames(),
+			Key: schema.KeyValues(rows[i]), Old: rows[i].Columns, New: updated}
+		if len(change.Cells()) > 0 {
+			changes = append(changes, change)
+		}
+	}
+
+	if dryRun || interactive {
+		fmt.Printf("Preview changes for '%s':\n", schema.Name)
+		for _, c := range changes {
+			fmt.Printf("   %s\n", c)
+		}
+	}
+	if interactive {
+		if err := confirmOrCancel("Apply changes?", "update"); err != nil {
+			return err
+		}
+	}
+	if dryRun || len(changes) == 0 {
+		return nil
+	}
+	if err := s.ApplyRowChanges(changes); err != nil {
+		return err
+	}
+	return s.Commit()
+}
+
 // EditTable is a convenience function to edit a table without manually managing a session.
 func EditTable(msiPath, tableName, setClause, whereClause string, dryRun, interactive bool) error {
 	return SafeExecute("EditTable", func() error {
@@ -458,50 +687,34 @@ func (s *MsiSession) EditRecord(tableName string, rowNum int, setClause string,
 			return fmt.Errorf("invalid row number %d; table has %d rows", rowNum, len(rows))
 		}
 
-		cols, err := s.GetColumnNames(tableName)
+		fields, err := parseSetFields(setClause)
 		if err != nil {
-			return fmt.Errorf("failed to get columns for '%s': %v", tableName, err)
+			return err
 		}
-		if len(cols) == 0 {
-			return fmt.Errorf("no columns found for '%s'", tableName)
+		schema, err := s.GetTableSchema(tableName)
+		if err != nil {
+			return fmt.Errorf("failed to read schema for '%s': %v", tableName, err)
 		}
-		pkColumn := cols[0]
-		pkValue := rows[rowNum-1].Columns[0]
-		if pkValue == "" {
-			return fmt.Errorf("primary key value is empty for row %d", rowNum)
+		row := rows[rowNum-1]
+		if fields, err = resolveFlagFields(schema, &row, fields); err != nil {
+			return err
 		}
-
-		setPairs := strings.Split(setClause, ",")
-		var setFields []string
-		for _, pair := range setPairs {
-			parts := strings.SplitN(strings.TrimSpace(pair), "=", 2)
-			if len(parts) != 2 {
-				return fmt.Errorf("invalid set clause: %s", pair)
-			}
-			setFields = append(setFields, fmt.Sprintf("`%s`='%s'", parts[0], parts[1]))
+		if err := s.ValidateEdit(tableName, fields); err != nil {
+			return fmt.Errorf("validation failed: %v", err)
 		}
-		sql := fmt.Sprintf("UPDATE `%s` SET %s WHERE `%s`='%s'", tableName, strings.Join(setFields, ", "), pkColumn, pkValue)
 
 		if dryRun || interactive {
-			fmt.Printf("Preview: Would update row %d in '%s':\n%s\n", rowNum, tableName, FormatRows([]TableRow{rows[rowNum-1]}))
+			fmt.Printf("Preview: Would update row %d in '%s':\n%s\n", rowNum, tableName, FormatRows([]TableRow{row}))
 		}
 
 		if interactive {
-			fmt.Print("Apply changes? [y/N]: ")
-			var response string
-			fmt.Scanln(&response)
-			if strings.ToLower(response) != "y" {
-				return fmt.Errorf("update cancelled by user")
+			if err := confirmOrCancel("Apply changes?", "update"); err != nil {
+				return err
 			}
 		}
 
 		if !dryRun {
-			view, err := s.openView(sql)
-			if err != nil {
-				return fmt.Errorf("failed to prepare update: %v", err)
-			}
-			defer s.closeView(view)
-			if _, err := oleutil.CallMethod(view, "Execute"); err != nil {
+			if err := s.UpdateRow(tableName, schema.KeyValues(row), fields); err != nil {
 				return fmt.Errorf("failed to execute update: %v", err)
 			}
 			return s.Commit()
@@ -510,6 +723,33 @@ func (s *MsiSession) EditRecord(tableName string, rowNum int, setClause string,
 	})
 }
 
+// parseSetFields parses a "field=value,field2=value2" set clause into a map,
+// trimming whitespace and any single quotes around each value. A segment
+// starting with + or - continues the previous flag edit, as in
+// "Attributes=+Permanent,-64bit".
+func parseSetFields(setClause string) (map[string]string, error) {
+	fields := map[string]string{}
+	last := ""
+	for _, pair := range strings.Split(setClause, ",") {
+		trimmed := strings.TrimSpace(pair)
+		if last != "" && !strings.Contains(trimmed, "=") && (strings.HasPrefix(trimmed, "+") || strings.HasPrefix(trimmed, "-")) {
+			fields[last] += "," + trimmed
+			continue
+		}
+		parts := strings.SplitN(trimmed, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid set clause: %s", pair)
+		}
+		value := strings.TrimSpace(parts[1])
+		if len(value) >= 2 && strings.HasPrefix(value, "'") && strings.HasSuffix(value, "'") {
+			value = value[1 : len(value)-1]
+		}
+		last = strings.TrimSpace(parts[0])
+		fields[last] = value
+	}
+	return fields, nil
+}
+
 // extractTableName parses the table name from a SQL query.
 func extractTableName(sql string) string {
 	sql = strings.ToUpper(strings.TrimSpace(sql))
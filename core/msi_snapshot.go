@@ -0,0 +1,366 @@
+// core/msi_snapshot.go
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// A snapshot is a directory holding a package's state as text:
+//
+//	tables/<Table>.txt  the table as a text transform that creates it,
+//	                    its rows sorted
+//	summary.txt         the summary information, one property per line
+//	streams.txt         size and hash of every stream and embedded storage
+const (
+	snapshotTablesDir   = "tables"
+	snapshotSummaryFile = "summary.txt"
+	snapshotStreamsFile = "streams.txt"
+)
+
+// SnapshotMSI writes the tables, summary information and stream hashes of
+// the package at msiPath to dir. Table files of an earlier snapshot whose
+// tables are gone are removed.
+func SnapshotMSI(msiPath, dir string) error {
+	return SafeExecute("SnapshotMSI", func() error {
+		session, err := OpenMsiSession(msiPath, 0)
+		if err != nil {
+			return fmt.Errorf("failed to open MSI session: %v", err)
+		}
+		defer session.Close()
+		tables, err := diffSnapshot(session)
+		if err != nil {
+			return err
+		}
+		m, err := readMsiStorageFile(msiPath)
+		if err != nil {
+			return err
+		}
+		summary, err := summaryLines(m)
+		if err != nil {
+			return err
+		}
+
+		tablesDir := filepath.Join(dir, snapshotTablesDir)
+		if err := os.MkdirAll(tablesDir, 0755); err != nil {
+			return fmt.Errorf("failed to create snapshot directory: %v", err)
+		}
+		stale, err := filepath.Glob(filepath.Join(tablesDir, "*.txt"))
+		if err != nil {
+			return err
+		}
+		for _, path := range stale {
+			if _, ok := tables[strings.TrimSuffix(filepath.Base(path), ".txt")]; !ok {
+				if err := os.Remove(path); err != nil {
+					return fmt.Errorf("failed to remove stale table file: %v", err)
+				}
+			}
+		}
+		for _, name := range sortedKeys(tables) {
+			if err := snapshotTableTransform(tables[name]).WriteFile(filepath.Join(tablesDir, name+".txt")); err != nil {
+				return err
+			}
+		}
+		if err := writeSnapshotLines(filepath.Join(dir, snapshotSummaryFile), summary); err != nil {
+			return err
+		}
+		if err := writeSnapshotLines(filepath.Join(dir, snapshotStreamsFile), append(streamLines(m, nil), storageLines(m)...)); err != nil {
+			return err
+		}
+		fmt.Printf("Snapshot of %d table(s) written to %s\n", len(tables), dir)
+		return nil
+	})
+}
+
+// snapshotTableTransform renders a table as the transform that creates it
+// and inserts its rows in sorted order.
+func snapshotTableTransform(t *tableSnapshot) *TextTransform {
+	rows := make([]TransformOp, len(t.rows))
+	for i, row := range t.rows {
+		rows[i] = TransformOp{Op: OpInsert, Table: t.schema.Name, Values: row.Columns}
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return formatTuple(rows[i].Values) < formatTuple(rows[j].Values) })
+	ops := append([]TransformOp{{Op: OpCreate, Table: t.schema.Name, Columns: t.schema.Columns}}, rows...)
+	return &TextTransform{Version: 2, Ops: ops}
+}
+
+func writeSnapshotLines(path string, lines []string) error {
+	var buf bytes.Buffer
+	for _, line := range lines {
+		buf.WriteString(line + "\n")
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write '%s': %v", path, err)
+	}
+	return nil
+}
+
+// readSnapshotTables reads the table files of a snapshot.
+func readSnapshotTables(dir string) (map[string]*tableSnapshot, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, snapshotTablesDir, "*.txt"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("'%s' is not a snapshot: it has no %s/*.txt table files", dir, snapshotTablesDir)
+	}
+	tables := map[string]*tableSnapshot{}
+	for _, path := range paths {
+		t, err := ReadTextTransform(path)
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimSuffix(filepath.Base(path), ".txt")
+		table, err := snapshotTable(name, t.Ops)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		tables[name] = table
+	}
+	return tables, nil
+}
+
+// snapshotTable rebuilds a table from the operations of its snapshot file.
+func snapshotTable(name string, ops []TransformOp) (*tableSnapshot, error) {
+	if len(ops) == 0 || ops[0].Op != OpCreate || ops[0].Table != name {
+		return nil, fmt.Errorf("expected the file to start with CREATE %s", name)
+	}
+	t := &tableSnapshot{schema: &TableSchema{Name: name, Columns: ops[0].Columns}}
+	for _, op := range ops[1:] {
+		if op.Op != OpInsert || op.Table != name {
+			return nil, fmt.Errorf("%s: expected only inserts into %s after CREATE", op, name)
+		}
+		if len(op.Values) != len(t.schema.Columns) {
+			return nil, fmt.Errorf("%s: row has %d values, table has %d columns", op, len(op.Values), len(t.schema.Columns))
+		}
+		t.rows = append(t.rows, TableRow{Columns: op.Values})
+	}
+	return t, nil
+}
+
+// readSnapshotLines reads a summary or streams file; a missing file reads
+// as empty.
+func readSnapshotLines(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read '%s': %v", path, err)
+	}
+	return strings.FieldsFunc(string(data), func(r rune) bool { return r == '\n' || r == '\r' }), nil
+}
+
+// driftLines compares lines of a snapshot with the current ones, split
+// into key and value by cut, and returns "+ key", "- key" and
+// "~ key: old → new" lines in key order.
+func driftLines(snapshot, current []string, cut func(string) (string, string)) []string {
+	split := func(lines []string) map[string]string {
+		m := map[string]string{}
+		for _, line := range lines {
+			key, value := cut(line)
+			m[key] = value
+		}
+		return m
+	}
+	was, is := split(snapshot), split(current)
+	keys := map[string]bool{}
+	for k := range was {
+		keys[k] = true
+	}
+	for k := range is {
+		keys[k] = true
+	}
+	var drift []string
+	for _, k := range sortedKeys(keys) {
+		old, inOld := was[k]
+		now, inNew := is[k]
+		switch {
+		case !inOld:
+			drift = append(drift, "+ "+k)
+		case !inNew:
+			drift = append(drift, "- "+k)
+		case old != now:
+			drift = append(drift, fmt.Sprintf("~ %s: %s → %s", k, old, now))
+		}
+	}
+	return drift
+}
+
+// cutSummaryLine splits "Name = value".
+func cutSummaryLine(line string) (string, string) {
+	name, value, _ := strings.Cut(line, " = ")
+	return name, value
+}
+
+// cutStreamLine splits `"name" <digest>`.
+func cutStreamLine(line string) (string, string) {
+	if i := strings.LastIndex(line, " <"); i >= 0 {
+		return line[:i], line[i+1:]
+	}
+	return line, ""
+}
+
+// snapshotDrift is how a package differs from a snapshot.
+type snapshotDrift struct {
+	tables  *MsiDiff
+	summary []string
+	streams []string
+}
+
+func (d *snapshotDrift) clean() bool {
+	return len(d.tables.Changed()) == 0 && len(d.summary) == 0 && len(d.streams) == 0
+}
+
+// driftFromSnapshot compares the package open in session, stored at
+// msiPath, with the snapshot in dir.
+func driftFromSnapshot(session *MsiSession, msiPath, dir string) (*snapshotDrift, error) {
+	snapshot, err := readSnapshotTables(dir)
+	if err != nil {
+		return nil, err
+	}
+	current, err := diffSnapshot(session)
+	if err != nil {
+		return nil, err
+	}
+	d := &snapshotDrift{tables: diffTables(snapshot, current)}
+	d.tables.Old, d.tables.New = dir, msiPath
+
+	m, err := readMsiStorageFile(msiPath)
+	if err != nil {
+		return nil, err
+	}
+	summary, err := summaryLines(m)
+	if err != nil {
+		return nil, err
+	}
+	was, err := readSnapshotLines(filepath.Join(dir, snapshotSummaryFile))
+	if err != nil {
+		return nil, err
+	}
+	d.summary = driftLines(was, summary, cutSummaryLine)
+	if was, err = readSnapshotLines(filepath.Join(dir, snapshotStreamsFile)); err != nil {
+		return nil, err
+	}
+	d.streams = driftLines(was, append(streamLines(m, nil), storageLines(m)...), cutStreamLine)
+	return d, nil
+}
+
+// writeSnapshotDrift prints the drift of a package from a snapshot.
+func writeSnapshotDrift(w io.Writer, d *snapshotDrift) error {
+	if d.clean() {
+		fmt.Fprintf(w, "%s matches the snapshot in %s.\n", d.tables.New, d.tables.Old)
+		return nil
+	}
+	if len(d.tables.Changed()) > 0 {
+		if err := WriteDiff(w, d.tables, "summary"); err != nil {
+			return err
+		}
+	}
+	for _, section := range []struct {
+		title string
+		lines []string
+	}{{"Summary information", d.summary}, {"Streams", d.streams}} {
+		if len(section.lines) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s:\n", section.title)
+		for _, line := range section.lines {
+			fmt.Fprintf(w, "   %s\n", line)
+		}
+	}
+	return nil
+}
+
+// SnapshotStatus reports the tables, rows, summary properties and streams
+// of the package at msiPath that have drifted from the snapshot in dir.
+func SnapshotStatus(msiPath, dir string) error {
+	return SafeExecute("SnapshotStatus", func() error {
+		session, err := OpenMsiSession(msiPath, 0)
+		if err != nil {
+			return fmt.Errorf("failed to open MSI session: %v", err)
+		}
+		defer session.Close()
+		d, err := driftFromSnapshot(session, msiPath, dir)
+		if err != nil {
+			return err
+		}
+		return writeSnapshotDrift(os.Stdout, d)
+	})
+}
+
+// RestoreSnapshot rebuilds the tables of the package at msiPath from the
+// snapshot in dir: tables are created or dropped and rows inserted, updated
+// or deleted until they match. The summary information and stream data
+// are not restored; rows with stream data are inserted without it. With
+// dryRun set the changes are listed but not committed.
+func RestoreSnapshot(msiPath, dir string, dryRun bool) error {
+	return SafeExecute("RestoreSnapshot", func() error {
+		snapshot, err := readSnapshotTables(dir)
+		if err != nil {
+			return err
+		}
+		session, err := OpenMsiSession(msiPath, 1)
+		if err != nil {
+			return fmt.Errorf("failed to open MSI session: %v", err)
+		}
+		defer session.Close()
+		current, err := diffSnapshot(session)
+		if err != nil {
+			return err
+		}
+		ops, streamless, err := restoreOps(current, snapshot)
+		if err != nil {
+			return fmt.Errorf("cannot restore from '%s': %v", dir, err)
+		}
+		for _, table := range sortedKeys(streamless) {
+			logWarn(fmt.Sprintf("%d row(s) of '%s' are restored without their stream data, which the snapshot only hashes", streamless[table], table))
+		}
+		if len(ops) == 0 {
+			fmt.Printf("%s already matches the tables of the snapshot in %s.\n", msiPath, dir)
+		} else {
+			if _, err := session.applyTransform(&TextTransform{Version: 2, Ops: ops}, dryRun, false, ConflictFail); err != nil {
+				return err
+			}
+			if dryRun {
+				return nil
+			}
+			if err := session.Commit(); err != nil {
+				return fmt.Errorf("commit failed: %v", err)
+			}
+			fmt.Printf("Restored %s from %s: %s\n", msiPath, dir, transformOpSummary(ops))
+		}
+
+		d, err := driftFromSnapshot(session, msiPath, dir)
+		if err != nil {
+			return err
+		}
+		if len(d.summary) > 0 || len(d.streams) > 0 {
+			logWarn(fmt.Sprintf("%d summary information and stream difference(s) from the snapshot remain; restore rebuilds tables only", len(d.summary)+len(d.streams)))
+		}
+		return nil
+	})
+}
+
+// restoreOps returns the operations that turn the current tables into the
+// snapshot's, and the number of rows per table inserted without their
+// stream data. Binary cells are empty on both sides, so only inserts of
+// rows with a binary column lose data.
+func restoreOps(current, snapshot map[string]*tableSnapshot) ([]TransformOp, map[string]int, error) {
+	ops, err := diffOps(diffTables(current, snapshot), "snapshot restore", true)
+	if err != nil {
+		return nil, nil, err
+	}
+	streamless := map[string]int{}
+	for _, op := range ops {
+		if t := snapshot[op.Table]; op.Op == OpInsert && t != nil && hasBinaryColumn(t.schema) {
+			streamless[op.Table]++
+		}
+	}
+	return ops, streamless, nil
+}
@@ -0,0 +1,80 @@
+// core/msi_snapshot_test.go
+package core
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSnapshotTable_RoundTrip(t *testing.T) {
+	cols := []ColumnInfo{{Name: "Property", Type: "s72", Key: true}, {Name: "Value", Type: "l0"}}
+	table := snapshot("Property", cols, []string{"b", "two\nlines"}, []string{"a", ""}, []string{"c", `"quoted"`})
+
+	var buf bytes.Buffer
+	if err := snapshotTableTransform(table).Format(&buf); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	expected := "msicrafter-transform 2\nCREATE Property (Property s72 KEY, Value l0)\n+ Property (\"a\", NULL)\n"
+	if !strings.HasPrefix(buf.String(), expected) {
+		t.Errorf("Expected the file to start with:\n%s\nGot:\n%s", expected, buf.String())
+	}
+	parsed, err := ParseTextTransform(strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	got, err := snapshotTable("Property", parsed.Ops)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !reflect.DeepEqual(got.schema, table.schema) || len(diffTable("Property", table, got).Changes) != 0 {
+		t.Errorf("Round trip mismatch:\n%+v\n%+v", table, got)
+	}
+
+	if _, err := snapshotTable("Other", parsed.Ops); err == nil {
+		t.Error("Expected an error for a file of another table")
+	}
+	bad := append(parsed.Ops, TransformOp{Op: OpDelete, Table: "Property", Key: []string{"a"}})
+	if _, err := snapshotTable("Property", bad); err == nil {
+		t.Error("Expected an error for an operation other than an insert")
+	}
+}
+
+func TestDriftLines(t *testing.T) {
+	snapshot := []string{`"Binary.Icon" <4 bytes sha256:00>`, `"old.cab" <3 bytes sha256:11>`, `"same" <1 bytes sha256:22>`}
+	current := []string{`"Binary.Icon" <5 bytes sha256:33>`, `"new.cab" <3 bytes sha256:44>`, `"same" <1 bytes sha256:22>`}
+	expected := []string{
+		`~ "Binary.Icon": <4 bytes sha256:00> → <5 bytes sha256:33>`,
+		`+ "new.cab"`,
+		`- "old.cab"`,
+	}
+	if got := driftLines(snapshot, current, cutStreamLine); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+	if got := driftLines([]string{"Title = A"}, []string{"Title = A"}, cutSummaryLine); len(got) != 0 {
+		t.Errorf("Expected no drift, got %q", got)
+	}
+}
+
+func TestRestoreOps_Streams(t *testing.T) {
+	binary := []ColumnInfo{{Name: "Name", Type: "s72", Key: true}, {Name: "Data", Type: "v0"}}
+	current := map[string]*tableSnapshot{"Binary": snapshot("Binary", binary, []string{"Kept", ""})}
+	saved := map[string]*tableSnapshot{"Binary": snapshot("Binary", binary, []string{"Kept", ""}, []string{"Lost", ""})}
+
+	ops, streamless, err := restoreOps(current, saved)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(ops) != 1 || ops[0].Op != OpInsert || ops[0].Values[0] != "Lost" {
+		t.Errorf("Expected the lost row to be inserted again, got %v", ops)
+	}
+	if !reflect.DeepEqual(streamless, map[string]int{"Binary": 1}) {
+		t.Errorf("Expected one row restored without stream data, got %v", streamless)
+	}
+
+	current["Binary"] = snapshot("Binary", append(binary, ColumnInfo{Name: "Extra", Type: "S0"}), []string{"Kept", "", ""})
+	if _, _, err := restoreOps(current, saved); err == nil || !strings.Contains(err.Error(), "snapshot restore") {
+		t.Errorf("Expected a removed column to be rejected for the restore, got %v", err)
+	}
+}
@@ -0,0 +1,425 @@
+// core/msi_storage.go
+package core
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Column type bits as stored in the Type column of _Columns.
+const (
+	colTypeWidth       = 0x00FF
+	colTypeValid       = 0x0100
+	colTypeLocalizable = 0x0200
+	colTypeNonBinary   = 0x0400
+	colTypeString      = 0x0800
+	colTypeNullable    = 0x1000
+	colTypeKey         = 0x2000
+)
+
+// Table streams are named with this prefix before the encoded table name.
+const msiTableStreamPrefix = 0x4840
+
+// Names of the system tables every database and transform stores.
+const (
+	tableStringPool = "_StringPool"
+	tableStringData = "_StringData"
+	tableTables     = "_Tables"
+	tableColumns    = "_Columns"
+)
+
+// summaryInfoStream is the stream holding the summary information.
+const summaryInfoStream = "\x05SummaryInformation"
+
+// tablesSchema and columnsSchema describe the system tables that are not
+// themselves listed in _Columns.
+var (
+	tablesSchema  = []ColumnInfo{{Name: "Name", Type: "s64", Key: true}}
+	columnsSchema = []ColumnInfo{
+		{Name: "Table", Type: "s64", Key: true},
+		{Name: "Number", Type: "i2", Key: true},
+		{Name: "Name", Type: "s64"},
+		{Name: "Type", Type: "i2"},
+	}
+)
+
+// streamNameChar maps the 64 characters that stream names compress.
+func streamNameChar(c rune) (int, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return int(c - '0'), true
+	case c >= 'A' && c <= 'Z':
+		return int(c-'A') + 10, true
+	case c >= 'a' && c <= 'z':
+		return int(c-'a') + 36, true
+	case c == '.':
+		return 62, true
+	case c == '_':
+		return 63, true
+	}
+	return 0, false
+}
+
+func streamNameRune(v int) rune {
+	switch {
+	case v < 10:
+		return rune('0' + v)
+	case v < 36:
+		return rune('A' + v - 10)
+	case v < 62:
+		return rune('a' + v - 36)
+	case v == 62:
+		return '.'
+	}
+	return '_'
+}
+
+// encodeStreamName compresses a stream name the way Windows Installer does:
+// pairs of identifier characters share one UTF-16 unit, which keeps names
+// within the 31 characters a compound file allows. Table streams carry an
+// extra prefix unit.
+func encodeStreamName(name string, table bool) string {
+	var out []rune
+	if table {
+		out = append(out, msiTableStreamPrefix)
+	}
+	runes := []rune(name)
+	for i := 0; i < len(runes); i++ {
+		c, ok := streamNameChar(runes[i])
+		if !ok {
+			out = append(out, runes[i])
+			continue
+		}
+		if i+1 < len(runes) {
+			if next, ok := streamNameChar(runes[i+1]); ok {
+				out = append(out, rune(0x3800+c+next<<6))
+				i++
+				continue
+			}
+		}
+		out = append(out, rune(0x4800+c))
+	}
+	return string(out)
+}
+
+// decodeStreamName reverses encodeStreamName and reports whether the
+// stream holds a table.
+func decodeStreamName(name string) (string, bool) {
+	runes := []rune(name)
+	table := len(runes) > 0 && runes[0] == msiTableStreamPrefix
+	if table {
+		runes = runes[1:]
+	}
+	var out []rune
+	for _, r := range runes {
+		switch {
+		case r >= 0x3800 && r < 0x4800:
+			v := int(r - 0x3800)
+			out = append(out, streamNameRune(v&0x3F), streamNameRune(v>>6&0x3F))
+		case r >= 0x4800 && r < msiTableStreamPrefix:
+			out = append(out, streamNameRune(int(r-0x4800)))
+		default:
+			out = append(out, r)
+		}
+	}
+	return string(out), table
+}
+
+// columnTypeFromBits converts a _Columns type number to a definition such
+// as "s72" or "I2".
+func columnTypeFromBits(bits int) string {
+	width := bits & colTypeWidth
+	letter := 'i'
+	switch {
+	case bits&colTypeString == 0:
+	case bits&colTypeNonBinary == 0:
+		letter, width = 'v', 0
+	case bits&colTypeLocalizable != 0:
+		letter = 'l'
+	default:
+		letter = 's'
+	}
+	if bits&colTypeNullable != 0 {
+		letter -= 'a' - 'A'
+	}
+	return fmt.Sprintf("%c%d", letter, width)
+}
+
+// columnStreamWidth returns the bytes a column takes in a table stream.
+func columnStreamWidth(col ColumnInfo, refSize int) int {
+	switch {
+	case col.IsBinary():
+		return 2
+	case col.IsString():
+		return refSize
+	case col.Width() > 2:
+		return 4
+	}
+	return 2
+}
+
+// msiStringPool is the shared string table of a database or transform.
+// Cells of string columns store indexes into it; 0 is the null string.
+type msiStringPool struct {
+	codepage int
+	longRefs bool
+	strings  []string
+}
+
+// readStringPool parses the _StringPool and _StringData streams.
+func readStringPool(pool, data []byte) (*msiStringPool, error) {
+	p := &msiStringPool{strings: []string{""}}
+	if len(pool) < 4 {
+		return p, nil
+	}
+	le := binary.LittleEndian
+	flags := le.Uint16(pool[2:])
+	p.codepage = int(le.Uint16(pool)) | int(flags&^0x8000)<<16
+	p.longRefs = flags&0x8000 != 0
+	offset := 0
+	for i := 4; i+4 <= len(pool); i += 4 {
+		length := int(le.Uint16(pool[i:]))
+		refs := le.Uint16(pool[i+2:])
+		if length == 0 && refs != 0 {
+			// A string longer than 64K: this entry holds the high word of
+			// its length and the next one the low word.
+			if i+8 > len(pool) {
+				return nil, fmt.Errorf("truncated string pool")
+			}
+			length = int(refs)<<16 | int(le.Uint16(pool[i+4:]))
+			i += 4
+		}
+		if offset+length > len(data) {
+			return nil, fmt.Errorf("string %d runs past the end of the string data", len(p.strings))
+		}
+		p.strings = append(p.strings, decodeCodepage(data[offset:offset+length], p.codepage))
+		offset += length
+	}
+	return p, nil
+}
+
+// refSize returns the width of a string reference in table streams.
+func (p *msiStringPool) refSize() int {
+	if p.longRefs {
+		return 3
+	}
+	return 2
+}
+
+// lookup returns the string with the given index.
+func (p *msiStringPool) lookup(id uint32) (string, error) {
+	if int(id) >= len(p.strings) {
+		return "", fmt.Errorf("string index %d is outside the string pool", id)
+	}
+	return p.strings[id], nil
+}
+
+// cp1252High maps bytes 0x80-0x9F of Windows-1252; the rest of the code
+// page matches Latin-1.
+var cp1252High = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+}
+
+// decodeCodepage converts string data to UTF-8. Packages are almost always
+// ASCII, Windows-1252 or UTF-8; other single-byte code pages are read as
+// Windows-1252.
+func decodeCodepage(b []byte, codepage int) string {
+	if codepage == 65001 || (codepage == 0 && utf8.Valid(b)) {
+		return string(b)
+	}
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		if c >= 0x80 && c < 0xA0 {
+			runes[i] = cp1252High[c-0x80]
+		} else {
+			runes[i] = rune(c)
+		}
+	}
+	return string(runes)
+}
+
+// encodeCodepage converts a string to the given code page, failing for
+// characters the code page cannot hold.
+func encodeCodepage(s string, codepage int) ([]byte, error) {
+	if codepage == 65001 {
+		return []byte(s), nil
+	}
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r < 0x80:
+			out = append(out, byte(r))
+			continue
+		case codepage == 1252 && r >= 0xA0 && r <= 0xFF:
+			out = append(out, byte(r))
+			continue
+		case codepage == 1252:
+			if i := slices.Index(cp1252High[:], r); i >= 0 {
+				out = append(out, byte(0x80+i))
+				continue
+			}
+		}
+		return nil, fmt.Errorf("%q cannot be stored in code page %d", s, codepage)
+	}
+	return out, nil
+}
+
+// readRawInt reads a little-endian integer of 2, 3 or 4 bytes.
+func readRawInt(b []byte, size int) uint32 {
+	var v uint32
+	for i := size - 1; i >= 0; i-- {
+		v = v<<8 | uint32(b[i])
+	}
+	return v
+}
+
+// decodeCell converts a stored cell to its string form. Integers are kept
+// with a bias so that 0 can mean null.
+func (p *msiStringPool) decodeCell(col ColumnInfo, raw uint32, width int) (string, error) {
+	switch {
+	case col.IsBinary():
+		return "", nil
+	case col.IsString():
+		return p.lookup(raw)
+	case raw == 0:
+		return "", nil
+	case width == 2:
+		return strconv.Itoa(int(raw) - 0x8000), nil
+	}
+	return strconv.Itoa(int(int32(raw ^ 0x80000000))), nil
+}
+
+// msiStorage is an installer database or transform read straight from its
+// compound file.
+type msiStorage struct {
+	root *cfbStorage
+	pool *msiStringPool
+}
+
+// readMsiStorageFile reads an installer database, patch or transform.
+func readMsiStorageFile(path string) (*msiStorage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	st, err := readMsiStorage(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return st, nil
+}
+
+// readMsiStorage parses a compound file and its string pool.
+func readMsiStorage(data []byte) (*msiStorage, error) {
+	root, err := readCFB(data)
+	if err != nil {
+		return nil, err
+	}
+	st := &msiStorage{root: root}
+	pool, _ := st.tableStream(tableStringPool)
+	strData, _ := st.tableStream(tableStringData)
+	st.pool, err = readStringPool(pool, strData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read string pool: %v", err)
+	}
+	return st, nil
+}
+
+// tableStream returns the stream of a table.
+func (m *msiStorage) tableStream(table string) ([]byte, bool) {
+	data, ok := m.root.Streams[encodeStreamName(table, true)]
+	return data, ok
+}
+
+// cellStreamName names the stream holding the binary cell data of a row:
+// the table and the row's key joined by dots.
+func cellStreamName(table string, key []string) string {
+	return encodeStreamName(table+"."+strings.Join(key, "."), false)
+}
+
+// cellStream returns the stream data of a row's binary cells.
+func (m *msiStorage) cellStream(table string, key []string) ([]byte, bool) {
+	data, ok := m.root.Streams[cellStreamName(table, key)]
+	return data, ok
+}
+
+// tableStreamNames lists the tables that have a stream, sorted by name.
+func (m *msiStorage) tableStreamNames() []string {
+	var names []string
+	for name := range m.root.Streams {
+		if decoded, table := decodeStreamName(name); table {
+			names = append(names, decoded)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// readTable decodes a database table stream. Unlike transforms, databases
+// store tables column by column.
+func (m *msiStorage) readTable(table string, cols []ColumnInfo) ([][]string, error) {
+	data, ok := m.tableStream(table)
+	if !ok {
+		return nil, nil
+	}
+	refSize := m.pool.refSize()
+	rowSize := 0
+	for _, c := range cols {
+		rowSize += columnStreamWidth(c, refSize)
+	}
+	if rowSize == 0 || len(data)%rowSize != 0 {
+		return nil, fmt.Errorf("table '%s': stream size %d is not a multiple of the row size %d", table, len(data), rowSize)
+	}
+	rows := make([][]string, len(data)/rowSize)
+	for i := range rows {
+		rows[i] = make([]string, len(cols))
+	}
+	offset := 0
+	for c, col := range cols {
+		width := columnStreamWidth(col, refSize)
+		for r := range rows {
+			raw := readRawInt(data[offset+r*width:], width)
+			v, err := m.pool.decodeCell(col, raw, width)
+			if err != nil {
+				return nil, fmt.Errorf("table '%s' column '%s': %v", table, col.Name, err)
+			}
+			rows[r][c] = v
+		}
+		offset += width * len(rows)
+	}
+	return rows, nil
+}
+
+// readSchemas returns the columns of every table listed in _Columns.
+func (m *msiStorage) readSchemas() (map[string][]ColumnInfo, error) {
+	rows, err := m.readTable(tableColumns, columnsSchema)
+	if err != nil {
+		return nil, err
+	}
+	type numbered struct {
+		number int
+		col    ColumnInfo
+	}
+	byTable := map[string][]numbered{}
+	for _, row := range rows {
+		number, _ := strconv.Atoi(row[1])
+		bits, _ := strconv.Atoi(row[3])
+		col := ColumnInfo{Name: row[2], Type: columnTypeFromBits(bits), Key: bits&colTypeKey != 0}
+		byTable[row[0]] = append(byTable[row[0]], numbered{number, col})
+	}
+	schemas := make(map[string][]ColumnInfo, len(byTable))
+	for table, cols := range byTable {
+		sort.SliceStable(cols, func(i, j int) bool { return cols[i].number < cols[j].number })
+		for _, c := range cols {
+			schemas[table] = append(schemas[table], c.col)
+		}
+	}
+	return schemas, nil
+}
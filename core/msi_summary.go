@@ -0,0 +1,36 @@
+// core/msi_summary.go
+package core
+
+import (
+	"fmt"
+
+	"github.com/go-ole/go-ole/oleutil"
+)
+
+// Summary information property IDs.
+const (
+	pidRevisionNumber = 9 // the package code of an installer package
+)
+
+// summaryProperty reads one property of the database's summary
+// information stream. Unset properties read as "".
+func (s *MsiSession) summaryProperty(pid int) (string, error) {
+	db, err := s.database()
+	if err != nil {
+		return "", err
+	}
+	infoRaw, err := oleutil.GetProperty(db, "SummaryInformation", 0)
+	if err != nil {
+		return "", fmt.Errorf("failed to open summary information: %v", err)
+	}
+	info := infoRaw.ToIDispatch()
+	defer info.Release()
+	value, err := oleutil.GetProperty(info, "Property", pid)
+	if err != nil {
+		return "", fmt.Errorf("failed to read summary property %d: %v", pid, err)
+	}
+	if value.Value() == nil {
+		return "", nil
+	}
+	return fmt.Sprint(value.Value()), nil
+}
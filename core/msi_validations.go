@@ -3,22 +3,521 @@ package core
 
 import (
 	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 )
 
-// ValidateEdit checks if the fields to be edited are valid.
-// This stub can be extended with actual validation rules.
-func ValidateEdit(table string, fields map[string]string) error {
+// ValidationRule mirrors a row of the _Validation table for one column.
+type ValidationRule struct {
+	Table     string
+	Column    string
+	Nullable  string // "Y", "N" or "@"
+	MinValue  string
+	MaxValue  string
+	KeyTable  string // one or more tables separated by ';'
+	KeyColumn int    // 1-based column in KeyTable, 0 if unset
+	Category  string
+	Set       string // allowed values separated by ';'
+}
+
+// ValidationError describes a single rule violation for one column.
+type ValidationError struct {
+	Table  string
+	Column string
+	Rule   string
+	Value  string
+	Reason string
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("%s.%s: %s rule failed for value '%s': %s", e.Table, e.Column, e.Rule, e.Value, e.Reason)
+}
+
+// ValidationErrors collects every violation found for an edit.
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, v := range e {
+		msgs[i] = v.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// ValidateEdit checks the fields to be written to a table against the table
+// schema and the package's _Validation rows: nullability, integer ranges,
+// foreign keys, allowed value sets and column categories.
+func (s *MsiSession) ValidateEdit(table string, fields map[string]string) error {
 	if table == "" {
 		return fmt.Errorf("table name cannot be empty")
 	}
-	for field, value := range fields {
+	for field := range fields {
 		if strings.TrimSpace(field) == "" {
 			return fmt.Errorf("field name cannot be empty")
 		}
-		if strings.TrimSpace(value) == "" {
-			return fmt.Errorf("value for field %s cannot be empty", field)
+	}
+
+	schema, err := s.GetTableSchema(table)
+	if err != nil {
+		return fmt.Errorf("failed to read schema for '%s': %v", table, err)
+	}
+	rules, err := s.LoadValidationRules(table)
+	if err != nil {
+		return err
+	}
+
+	var violations ValidationErrors
+	for _, field := range sortedKeys(fields) {
+		value := fields[field]
+		col, _, ok := schema.Column(field)
+		if !ok {
+			violations = append(violations, ValidationError{table, field, "Column", value, "no such column"})
+			continue
+		}
+		rule, hasRule := rules[field]
+		if !hasRule {
+			rule = ValidationRule{Table: table, Column: field}
+		}
+		if v := validateValue(col, rule, value); v != nil {
+			violations = append(violations, *v)
+			continue
+		}
+		if hasRule && value != "" && rule.KeyTable != "" && rule.KeyColumn > 0 {
+			found, err := s.keyExists(rule.KeyTable, rule.KeyColumn, value)
+			if err != nil {
+				return err
+			}
+			if !found {
+				violations = append(violations, ValidationError{table, field, "KeyTable", value,
+					fmt.Sprintf("no row in %s with column %d equal to it", rule.KeyTable, rule.KeyColumn)})
+			}
+		}
+	}
+	if len(violations) > 0 {
+		return violations
+	}
+	return nil
+}
+
+// ValidateEdit is a convenience function to validate an edit without manually managing a session.
+func ValidateEdit(msiPath, table string, fields map[string]string) error {
+	session, err := OpenMsiSession(msiPath, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open MSI session: %v", err)
+	}
+	defer session.Close()
+	return session.ValidateEdit(table, fields)
+}
+
+// LoadValidationRules reads the _Validation rows for a table, keyed by column name.
+// Packages without a _Validation table yield an empty set of rules.
+func (s *MsiSession) LoadValidationRules(table string) (map[string]ValidationRule, error) {
+	rules := map[string]ValidationRule{}
+	rows, err := s.QueryWithParams(validationQuery+" WHERE `Table`=?", table)
+	if err != nil {
+		if DebugMode {
+			logWarn(fmt.Sprintf("No _Validation rules available for '%s': %v", table, err))
+		}
+		return rules, nil
+	}
+	for _, row := range rows {
+		if rule, ok := parseValidationRow(row); ok {
+			rules[rule.Column] = rule
+		}
+	}
+	if DebugMode {
+		logInfo(fmt.Sprintf("Loaded %d _Validation rules for '%s'", len(rules), table))
+	}
+	return rules, nil
+}
+
+// validationQuery selects the _Validation columns in the order parseValidationRow expects.
+const validationQuery = "SELECT `Table`, `Column`, `Nullable`, `MinValue`, `MaxValue`, " +
+	"`KeyTable`, `KeyColumn`, `Category`, `Set` FROM `_Validation`"
+
+// parseValidationRow converts a row returned by validationQuery into a rule.
+func parseValidationRow(row TableRow) (ValidationRule, bool) {
+	if len(row.Columns) < 9 {
+		return ValidationRule{}, false
+	}
+	keyCol, _ := strconv.Atoi(row.Columns[6])
+	return ValidationRule{
+		Table:     row.Columns[0],
+		Column:    row.Columns[1],
+		Nullable:  row.Columns[2],
+		MinValue:  row.Columns[3],
+		MaxValue:  row.Columns[4],
+		KeyTable:  row.Columns[5],
+		KeyColumn: keyCol,
+		Category:  row.Columns[7],
+		Set:       row.Columns[8],
+	}, true
+}
+
+// keyExists reports whether any of the semicolon-separated key tables has a
+// row whose keyColumn (1-based) equals value.
+func (s *MsiSession) keyExists(keyTables string, keyColumn int, value string) (bool, error) {
+	for _, kt := range strings.Split(keyTables, ";") {
+		kt = strings.TrimSpace(kt)
+		if kt == "" {
+			continue
+		}
+		schema, err := s.GetTableSchema(kt)
+		if err != nil {
+			// A key table that is absent from the package cannot hold the value.
+			continue
+		}
+		if keyColumn > len(schema.Columns) {
+			return false, fmt.Errorf("_Validation refers to column %d of '%s', which has %d columns", keyColumn, kt, len(schema.Columns))
+		}
+		col := schema.Columns[keyColumn-1]
+		var param interface{} = value
+		if col.IsInteger() {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				continue
+			}
+			param = n
+		}
+		rows, err := s.QueryWithParams(fmt.Sprintf("SELECT `%s` FROM `%s` WHERE `%s`=?", col.Name, kt, col.Name), param)
+		if err != nil {
+			return false, fmt.Errorf("failed to look up key in '%s': %v", kt, err)
+		}
+		if len(rows) > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// validateValue checks a single value against the column definition and its
+// _Validation rule. Foreign keys are checked separately since they need a session.
+func validateValue(col ColumnInfo, rule ValidationRule, value string) *ValidationError {
+	fail := func(r, reason string) *ValidationError {
+		return &ValidationError{rule.Table, col.Name, r, value, reason}
+	}
+
+	if value == "" {
+		nullable := col.IsNullable()
+		if rule.Nullable != "" {
+			nullable = rule.Nullable != "N"
+		}
+		if !nullable {
+			return fail("Nullable", "column does not accept empty values")
+		}
+		return nil
+	}
+
+	if col.IsInteger() {
+		n, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return fail("Type", "expected an integer")
+		}
+		if col.Width() == 2 && (n < -32767 || n > 32767) {
+			return fail("Type", "out of range for a 16-bit integer column")
+		}
+		if rule.MinValue != "" {
+			if min, err := strconv.ParseInt(rule.MinValue, 10, 32); err == nil && n < min {
+				return fail("MinValue", fmt.Sprintf("must be at least %d", min))
+			}
+		}
+		if rule.MaxValue != "" {
+			if max, err := strconv.ParseInt(rule.MaxValue, 10, 32); err == nil && n > max {
+				return fail("MaxValue", fmt.Sprintf("must be at most %d", max))
+			}
+		}
+	} else if col.IsString() && col.Width() > 0 && len([]rune(value)) > col.Width() {
+		return fail("Width", fmt.Sprintf("longer than %d characters", col.Width()))
+	}
+
+	if rule.Set != "" {
+		allowed := strings.Split(rule.Set, ";")
+		if !contains(allowed, value) {
+			return fail("Set", fmt.Sprintf("must be one of %s", strings.Join(allowed, ", ")))
+		}
+	}
+
+	if rule.Category != "" {
+		if err := validateCategory(rule.Category, value); err != nil {
+			return fail("Category "+rule.Category, err.Error())
 		}
 	}
 	return nil
 }
+
+var (
+	identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+	guidPattern       = regexp.MustCompile(`^\{[0-9A-F]{8}-[0-9A-F]{4}-[0-9A-F]{4}-[0-9A-F]{4}-[0-9A-F]{12}\}$`)
+)
+
+// categoryValidators maps _Validation categories to their syntax checks.
+// Categories without an entry (Text, Binary, ...) accept any value.
+var categoryValidators = map[string]func(string) error{
+	"UpperCase": func(v string) error {
+		if v != strings.ToUpper(v) {
+			return fmt.Errorf("must be upper case")
+		}
+		return nil
+	},
+	"LowerCase": func(v string) error {
+		if v != strings.ToLower(v) {
+			return fmt.Errorf("must be lower case")
+		}
+		return nil
+	},
+	"Integer":       func(v string) error { return checkInteger(v, 16) },
+	"DoubleInteger": func(v string) error { return checkInteger(v, 32) },
+	"TimeDate":      func(v string) error { return checkInteger(v, 32) },
+	"Identifier":    checkIdentifier,
+	"Property": func(v string) error {
+		return checkIdentifier(strings.TrimPrefix(v, "%"))
+	},
+	"Filename":         func(v string) error { return checkFilename(v, false) },
+	"WildCardFilename": func(v string) error { return checkFilename(v, true) },
+	"Path":             checkPath,
+	"Paths": func(v string) error {
+		for _, p := range strings.Split(v, ";") {
+			if err := checkPath(p); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	"AnyPath":           checkPath,
+	"DefaultDir":        checkDefaultDir,
+	"RegPath":           checkRegPath,
+	"Formatted":         checkFormatted,
+	"FormattedSDDLText": checkFormatted,
+	"KeyFormatted":      checkFormatted,
+	"Template":          checkFormatted,
+	"Condition":         checkCondition,
+	"GUID":              checkGUID,
+	"Guid":              checkGUID,
+	"Version":           checkVersion,
+	"Language":          checkLanguage,
+	"CustomSource":      checkIdentifier,
+	"Cabinet":           checkCabinet,
+	"Shortcut": func(v string) error {
+		if strings.Contains(v, "[") {
+			return checkFormatted(v)
+		}
+		return checkIdentifier(v)
+	},
+}
+
+// validateCategory checks value against the syntax of a _Validation category.
+func validateCategory(category, value string) error {
+	check, ok := categoryValidators[category]
+	if !ok {
+		return nil
+	}
+	return check(value)
+}
+
+func checkInteger(v string, bits int) error {
+	if _, err := strconv.ParseInt(v, 10, bits); err != nil {
+		return fmt.Errorf("expected a %d-bit integer", bits)
+	}
+	return nil
+}
+
+func checkIdentifier(v string) error {
+	if !identifierPattern.MatchString(v) {
+		return fmt.Errorf("must start with a letter or underscore and contain only letters, digits, '_' and '.'")
+	}
+	return nil
+}
+
+func checkGUID(v string) error {
+	if !guidPattern.MatchString(v) {
+		return fmt.Errorf("must be an upper-case GUID enclosed in braces")
+	}
+	return nil
+}
+
+// checkFilename accepts "short|long" or a single name, where a short name
+// must follow 8.3 rules and a long name must avoid reserved characters.
+func checkFilename(v string, wildcards bool) error {
+	parts := strings.Split(v, "|")
+	if len(parts) > 2 {
+		return fmt.Errorf("at most one '|' separating short and long names is allowed")
+	}
+	if len(parts) == 2 {
+		if err := checkShortFilename(parts[0], wildcards); err != nil {
+			return fmt.Errorf("short name: %v", err)
+		}
+		return checkLongFilename(parts[1], wildcards)
+	}
+	return checkLongFilename(parts[0], wildcards)
+}
+
+func checkShortFilename(v string, wildcards bool) error {
+	invalid := `\?|><:/*"+,;=[] `
+	if wildcards {
+		invalid = `\|><:/"+,;=[] `
+	}
+	if strings.ContainsAny(v, invalid) {
+		return fmt.Errorf("contains characters not allowed in short file names")
+	}
+	name, ext := v, ""
+	if i := strings.Index(v, "."); i >= 0 {
+		name, ext = v[:i], v[i+1:]
+		if strings.Contains(ext, ".") {
+			return fmt.Errorf("short file names allow a single '.'")
+		}
+	}
+	if name == "" || len(name) > 8 || len(ext) > 3 {
+		return fmt.Errorf("must follow the 8.3 format")
+	}
+	return nil
+}
+
+func checkLongFilename(v string, wildcards bool) error {
+	invalid := `\?|><:/*"`
+	if wildcards {
+		invalid = `\|><:/"`
+	}
+	if v == "" {
+		return fmt.Errorf("file name cannot be empty")
+	}
+	if strings.ContainsAny(v, invalid) {
+		return fmt.Errorf("contains characters not allowed in file names")
+	}
+	return nil
+}
+
+func checkPath(v string) error {
+	if strings.ContainsAny(v, `<>|"`) {
+		return fmt.Errorf("contains characters not allowed in paths")
+	}
+	return checkFormatted(v)
+}
+
+// checkDefaultDir accepts "[target:]source" where each side is a file name or '.'.
+func checkDefaultDir(v string) error {
+	for _, part := range strings.Split(v, ":") {
+		if part == "." || part == "SourceDir" || part == "SOURCEDIR" {
+			continue
+		}
+		if err := checkFilename(part, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkRegPath(v string) error {
+	if strings.HasPrefix(v, `\`) {
+		return fmt.Errorf("registry paths must not begin with a backslash")
+	}
+	return checkFormatted(v)
+}
+
+// checkFormatted verifies that [ ] references and { } groups are balanced,
+// honouring the [\x] escape sequence.
+func checkFormatted(v string) error {
+	depth, braces := 0, 0
+	for i := 0; i < len(v); i++ {
+		switch v[i] {
+		case '[':
+			if i+1 < len(v) && v[i+1] == '\\' {
+				if i+3 >= len(v) || v[i+3] != ']' {
+					return fmt.Errorf("malformed escape sequence at position %d", i+1)
+				}
+				i += 3
+				continue
+			}
+			depth++
+		case ']':
+			depth--
+			if depth < 0 {
+				return fmt.Errorf("unmatched ']' at position %d", i+1)
+			}
+		case '{':
+			braces++
+		case '}':
+			braces--
+			if braces < 0 {
+				return fmt.Errorf("unmatched '}' at position %d", i+1)
+			}
+		}
+	}
+	if depth != 0 {
+		return fmt.Errorf("unmatched '['")
+	}
+	if braces != 0 {
+		return fmt.Errorf("unmatched '{'")
+	}
+	return nil
+}
+
+// checkCondition verifies that quotes are closed and parentheses balanced.
+func checkCondition(v string) error {
+	depth := 0
+	inQuote := false
+	for i, r := range v {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+		case inQuote:
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+			if depth < 0 {
+				return fmt.Errorf("unmatched ')' at position %d", i+1)
+			}
+		}
+	}
+	if inQuote {
+		return fmt.Errorf("unterminated string literal")
+	}
+	if depth != 0 {
+		return fmt.Errorf("unmatched '('")
+	}
+	return nil
+}
+
+func checkVersion(v string) error {
+	parts := strings.Split(v, ".")
+	if len(parts) > 4 {
+		return fmt.Errorf("versions have at most four fields")
+	}
+	for _, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 || n > 65535 {
+			return fmt.Errorf("each field must be a number between 0 and 65535")
+		}
+	}
+	return nil
+}
+
+func checkLanguage(v string) error {
+	for _, p := range strings.Split(v, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil || n < 0 || n > 65535 {
+			return fmt.Errorf("must be a comma-separated list of language IDs")
+		}
+	}
+	return nil
+}
+
+// checkCabinet accepts an embedded cabinet ("#stream") or an external file name.
+func checkCabinet(v string) error {
+	if strings.HasPrefix(v, "#") {
+		return checkIdentifier(v[1:])
+	}
+	return checkFilename(v, false)
+}
+
+// sortedKeys returns the keys of m in lexical order so output is deterministic.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
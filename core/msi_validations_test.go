@@ -0,0 +1,133 @@
+// core/msi_validations_test.go
+package core
+
+import (
+	"testing"
+)
+
+func TestValidateCategory(t *testing.T) {
+	cases := []struct {
+		category string
+		value    string
+		valid    bool
+	}{
+		{"Identifier", "MyComponent_1.x64", true},
+		{"Identifier", "1Component", false},
+		{"Identifier", "My-Component", false},
+		{"Property", "%PATH", true},
+		{"GUID", "{12345678-9ABC-DEF0-1234-56789ABCDEF0}", true},
+		{"GUID", "{12345678-9abc-def0-1234-56789abcdef0}", false},
+		{"GUID", "12345678-9ABC-DEF0-1234-56789ABCDEF0", false},
+		{"Filename", "README~1.TXT|Readme File.txt", true},
+		{"Filename", "TOOLONGNAME.TXT|Readme.txt", false},
+		{"Filename", "bad|name|here", false},
+		{"Filename", "what?.txt", false},
+		{"WildCardFilename", "*.log", true},
+		{"DefaultDir", "PROGRA~1|Program Files:.", true},
+		{"RegPath", `Software\[Manufacturer]`, true},
+		{"RegPath", `\Software`, false},
+		{"Formatted", "[INSTALLDIR]bin\\[#File1]", true},
+		{"Formatted", "[INSTALLDIR", false},
+		{"Formatted", `[\[]literal[\]]`, true},
+		{"Condition", `NOT Installed AND (VersionNT >= 601 OR MsiNTProductType = "1")`, true},
+		{"Condition", `(NOT Installed`, false},
+		{"Condition", `Prop = "unterminated`, false},
+		{"Version", "1.2.3.4", true},
+		{"Version", "1.2.3.4.5", false},
+		{"Version", "1.70000", false},
+		{"Language", "1033,1031", true},
+		{"Language", "en-US", false},
+		{"Cabinet", "#Data1.cab", true},
+		{"Cabinet", "data1.cab", true},
+		{"Shortcut", "MainFeature", true},
+		{"Shortcut", "[INSTALLDIR]app.exe", true},
+		{"UpperCase", "ABC", true},
+		{"UpperCase", "Abc", false},
+		{"Text", "anything goes [", true},
+	}
+	for _, tc := range cases {
+		err := validateCategory(tc.category, tc.value)
+		if tc.valid && err != nil {
+			t.Errorf("%s %q: expected valid, got %v", tc.category, tc.value, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("%s %q: expected an error, got nil", tc.category, tc.value)
+		}
+	}
+}
+
+func TestValidateValue_Nullable(t *testing.T) {
+	col := ColumnInfo{Name: "Value", Type: "l0"}
+	rule := ValidationRule{Table: "Property", Column: "Value", Nullable: "N"}
+	if v := validateValue(col, rule, ""); v == nil || v.Rule != "Nullable" {
+		t.Errorf("Expected Nullable violation, got %v", v)
+	}
+
+	rule.Nullable = "Y"
+	if v := validateValue(col, rule, ""); v != nil {
+		t.Errorf("Expected empty value to be accepted for nullable column, got %v", v)
+	}
+
+	// Without a _Validation row the column definition decides.
+	if v := validateValue(ColumnInfo{Name: "Condition", Type: "S255"}, ValidationRule{}, ""); v != nil {
+		t.Errorf("Expected empty value to be accepted for S255 column, got %v", v)
+	}
+}
+
+func TestValidateValue_IntegerRange(t *testing.T) {
+	col := ColumnInfo{Name: "Root", Type: "i2"}
+	rule := ValidationRule{Table: "Registry", Column: "Root", MinValue: "-1", MaxValue: "3"}
+
+	if v := validateValue(col, rule, "2"); v != nil {
+		t.Errorf("Expected 2 to be valid, got %v", v)
+	}
+	if v := validateValue(col, rule, "4"); v == nil || v.Rule != "MaxValue" {
+		t.Errorf("Expected MaxValue violation, got %v", v)
+	}
+	if v := validateValue(col, rule, "-2"); v == nil || v.Rule != "MinValue" {
+		t.Errorf("Expected MinValue violation, got %v", v)
+	}
+	if v := validateValue(col, rule, "HKLM"); v == nil || v.Rule != "Type" {
+		t.Errorf("Expected Type violation, got %v", v)
+	}
+	if v := validateValue(col, ValidationRule{}, "40000"); v == nil || v.Rule != "Type" {
+		t.Errorf("Expected 16-bit range violation, got %v", v)
+	}
+}
+
+func TestValidateValue_SetAndCategory(t *testing.T) {
+	col := ColumnInfo{Name: "Action", Type: "s72"}
+	rule := ValidationRule{Table: "Test", Column: "Action", Set: "Start;Stop"}
+	if v := validateValue(col, rule, "Start"); v != nil {
+		t.Errorf("Expected Start to be in set, got %v", v)
+	}
+	if v := validateValue(col, rule, "Pause"); v == nil || v.Rule != "Set" {
+		t.Errorf("Expected Set violation, got %v", v)
+	}
+
+	rule = ValidationRule{Table: "Component", Column: "ComponentId", Category: "Guid"}
+	v := validateValue(ColumnInfo{Name: "ComponentId", Type: "S38"}, rule, "{not-a-guid}")
+	if v == nil || v.Rule != "Category Guid" || v.Column != "ComponentId" {
+		t.Errorf("Expected Category Guid violation naming ComponentId, got %v", v)
+	}
+}
+
+func TestValidateValue_Width(t *testing.T) {
+	col := ColumnInfo{Name: "Feature", Type: "s5"}
+	if v := validateValue(col, ValidationRule{}, "TooLong"); v == nil || v.Rule != "Width" {
+		t.Errorf("Expected Width violation, got %v", v)
+	}
+}
+
+func TestParseSetFields(t *testing.T) {
+	fields, err := parseSetFields("ProductVersion = 9.9.9, Author='Retro Wizard',Empty=")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if fields["ProductVersion"] != "9.9.9" || fields["Author"] != "Retro Wizard" || fields["Empty"] != "" {
+		t.Errorf("Unexpected fields: %v", fields)
+	}
+	if _, err := parseSetFields("NoEquals"); err == nil {
+		t.Errorf("Expected error for missing '=', got nil")
+	}
+}
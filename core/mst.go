@@ -0,0 +1,421 @@
+// core/mst.go
+package core
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// transformValidationFlags are the applicability checks a binary transform
+// records in the high word of its PID_CHARCOUNT summary property.
+var transformValidationFlags = FlagSet{Prefix: "MSITRANSFORM_VALIDATE_", Flags: []Flag{
+	bit("LANGUAGE", 0x0001), bit("PRODUCT", 0x0002), bit("PLATFORM", 0x0004),
+	bit("MAJORVERSION", 0x0008), bit("MINORVERSION", 0x0010), bit("UPDATEVERSION", 0x0020),
+	bit("NEWLESSBASEVERSION", 0x0040), bit("NEWLESSEQUALBASEVERSION", 0x0080),
+	bit("NEWEQUALBASEVERSION", 0x0100), bit("NEWGREATEREQUALBASEVERSION", 0x0200),
+	bit("NEWGREATERBASEVERSION", 0x0400), bit("UPGRADECODE", 0x0800),
+}}
+
+// transformErrorFlags are the errors a binary transform suppresses while
+// it is applied, recorded in the low word of PID_CHARCOUNT.
+var transformErrorFlags = FlagSet{Prefix: "MSITRANSFORM_ERROR_", Flags: []Flag{
+	bit("ADDEXISTINGROW", 0x0001), bit("DELMISSINGROW", 0x0002), bit("ADDEXISTINGTABLE", 0x0004),
+	bit("DELMISSINGTABLE", 0x0008), bit("UPDATEMISSINGROW", 0x0010), bit("CHANGECODEPAGE", 0x0020),
+	bit("VIEWTRANSFORM", 0x0100),
+}}
+
+// TransformSummary is the summary information of a binary transform.
+type TransformSummary struct {
+	Codepage          int
+	Title             string
+	Subject           string
+	Author            string
+	Comments          string
+	BasePlatform      string // platform;languages the transform applies to
+	TargetPlatform    string // platform;languages of the transformed package
+	BaseProductCode   string
+	BaseVersion       string
+	TargetProductCode string
+	TargetVersion     string
+	UpgradeCode       string
+	MinVersion        int // minimum Windows Installer version × 100
+	Validation        int // MSITRANSFORM_VALIDATE_* flags
+	ErrorConditions   int // MSITRANSFORM_ERROR_* flags suppressed on apply
+}
+
+// transformSummaryFromProps interprets a transform's summary properties.
+// PID_REVNUMBER holds "{base code}version;{target code}version;{upgrade}".
+func transformSummaryFromProps(props map[int]interface{}) TransformSummary {
+	s := TransformSummary{
+		Codepage:        propInt(props, pidCodepage) & 0xFFFF,
+		Title:           propString(props, pidTitle),
+		Subject:         propString(props, pidSubject),
+		Author:          propString(props, pidAuthor),
+		Comments:        propString(props, pidComments),
+		BasePlatform:    propString(props, pidTemplate),
+		TargetPlatform:  propString(props, pidLastAuthor),
+		MinVersion:      propInt(props, pidPageCount),
+		Validation:      propInt(props, pidCharCount) >> 16 & 0xFFFF,
+		ErrorConditions: propInt(props, pidCharCount) & 0xFFFF,
+	}
+	parts := strings.Split(propString(props, pidRevisionNumber), ";")
+	s.BaseProductCode, s.BaseVersion = splitProductCode(parts[0])
+	if len(parts) > 1 {
+		s.TargetProductCode, s.TargetVersion = splitProductCode(parts[1])
+	}
+	if len(parts) > 2 {
+		s.UpgradeCode = parts[2]
+	}
+	return s
+}
+
+// splitProductCode splits "{GUID}1.2.3" into the code and the version.
+func splitProductCode(s string) (string, string) {
+	if strings.HasPrefix(s, "{") {
+		if end := strings.IndexByte(s, '}'); end >= 0 {
+			return s[:end+1], s[end+1:]
+		}
+	}
+	return "", s
+}
+
+// BinaryTransform is a decoded Windows Installer transform (.mst).
+type BinaryTransform struct {
+	Summary TransformSummary
+	Ops     []TransformOp
+	Skipped map[string]int // tables left undecoded for lack of a schema, with their stream size
+}
+
+// transformRow is one record of a transform table stream.
+type transformRow struct {
+	mask   uint32
+	values []string
+	set    []bool // which values the record carries
+}
+
+// ReadBinaryTransform decodes the binary transform at path. Column layouts
+// of tables the transform does not create come from basePath when given
+// and from the standard table catalog otherwise.
+func ReadBinaryTransform(path, basePath string) (*BinaryTransform, error) {
+	mst, err := readMsiStorageFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if basePath == "" {
+		return decodeBinaryTransform(mst, nil, nil)
+	}
+	base, err := readMsiStorageFile(basePath)
+	if err != nil {
+		return nil, err
+	}
+	schemas, err := base.readSchemas()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read base schema: %v", err)
+	}
+	return decodeBinaryTransform(mst, schemas, base)
+}
+
+// ReadTransform loads a text transform, or a binary one decoded against the
+// package at basePath. A binary transform's validation and suppressed
+// errors become the text transform's checks.
+func ReadTransform(path, basePath string) (*TextTransform, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open MST file: %v", err)
+	}
+	if !isCFB(data) {
+		return ReadTextTransform(path)
+	}
+	bt, err := ReadBinaryTransform(path, basePath)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if len(bt.Skipped) > 0 {
+		return nil, fmt.Errorf("%s: cannot decode tables %s", path, strings.Join(sortedKeys(bt.Skipped), ", "))
+	}
+	s := bt.Summary
+	base := TransformBase{ProductCode: s.BaseProductCode, ProductVersion: s.BaseVersion, UpgradeCode: s.UpgradeCode}
+	if _, langs, ok := strings.Cut(s.BasePlatform, ";"); ok {
+		base.ProductLanguage, _, _ = strings.Cut(langs, ",")
+	}
+	return &TextTransform{
+		Version: 2,
+		Base:    base,
+		Checks:  TransformChecks{Validation: s.Validation, Suppress: s.ErrorConditions},
+		Ops:     bt.Ops,
+	}, nil
+}
+
+// decodeBinaryTransform turns the streams of a transform into operations:
+// table creations and added columns first, then row changes table by
+// table, then dropped tables. baseSchemas holds the columns of the tables
+// the transform applies to, or is nil to use the catalog; base, when set,
+// tells whole-row replacements from inserts.
+func decodeBinaryTransform(mst *msiStorage, baseSchemas map[string][]ColumnInfo, base *msiStorage) (*BinaryTransform, error) {
+	t := &BinaryTransform{Skipped: map[string]int{}}
+	if data, ok := mst.root.Streams[summaryInfoStream]; ok {
+		props, err := readPropertySet(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read summary information: %v", err)
+		}
+		t.Summary = transformSummaryFromProps(props)
+	}
+	baseColumns := func(table string) ([]ColumnInfo, bool) {
+		if baseSchemas != nil {
+			cols, ok := baseSchemas[table]
+			return cols, ok
+		}
+		if schema, ok := StandardTableSchema(table); ok {
+			return schema.Columns, true
+		}
+		return nil, false
+	}
+
+	created := map[string]bool{}
+	var dropped []string
+	tableRows, err := mst.transformRows(tableTables, tablesSchema)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range tableRows {
+		if r.mask == 0 {
+			dropped = append(dropped, r.values[0])
+		} else {
+			created[r.values[0]] = true
+		}
+	}
+
+	columnRows, err := mst.transformRows(tableColumns, columnsSchema)
+	if err != nil {
+		return nil, err
+	}
+	schemas := map[string][]ColumnInfo{}
+	added := map[string][]ColumnInfo{}
+	var alteredTables []string
+	for _, r := range columnRows {
+		if r.mask == 0 {
+			continue
+		}
+		table := r.values[0]
+		if _, ok := schemas[table]; !ok && !created[table] {
+			cols, ok := baseColumns(table)
+			if !ok {
+				return nil, fmt.Errorf("transform adds columns to table '%s' whose schema is unknown; pass the base package", table)
+			}
+			schemas[table] = append([]ColumnInfo(nil), cols...)
+			alteredTables = append(alteredTables, table)
+		}
+		bits, _ := strconv.Atoi(r.values[3])
+		col := ColumnInfo{Name: r.values[2], Type: columnTypeFromBits(bits), Key: bits&colTypeKey != 0}
+		// Some tools leave Number null for new columns; they follow the
+		// existing ones in order.
+		if number, err := strconv.Atoi(r.values[1]); err == nil && number != len(schemas[table])+1 {
+			logWarn(fmt.Sprintf("transform places column %s.%s at position %d; treating it as %d", table, col.Name, number, len(schemas[table])+1))
+		}
+		schemas[table] = append(schemas[table], col)
+		added[table] = append(added[table], col)
+	}
+
+	schemaTables := append(sortedKeys(created), alteredTables...)
+	sort.Strings(schemaTables)
+	for _, table := range schemaTables {
+		if created[table] {
+			if len(schemas[table]) == 0 {
+				return nil, fmt.Errorf("transform creates table '%s' without columns", table)
+			}
+			t.Ops = append(t.Ops, TransformOp{Op: OpCreate, Table: table, Columns: schemas[table]})
+			continue
+		}
+		for _, col := range added[table] {
+			t.Ops = append(t.Ops, TransformOp{Op: OpAlter, Table: table, Columns: []ColumnInfo{col}})
+		}
+	}
+
+	for _, table := range mst.tableStreamNames() {
+		switch table {
+		case tableStringPool, tableStringData, tableTables, tableColumns:
+			continue
+		}
+		cols, ok := schemas[table]
+		if !ok {
+			if cols, ok = baseColumns(table); !ok {
+				data, _ := mst.tableStream(table)
+				t.Skipped[table] = len(data)
+				continue
+			}
+		}
+		rows, err := mst.transformRows(table, cols)
+		if err != nil {
+			return nil, err
+		}
+		var existing map[string]bool
+		if base != nil && !created[table] {
+			existing, err = base.rowKeys(table, cols)
+			if err != nil {
+				return nil, err
+			}
+		}
+		schema := &TableSchema{Name: table, Columns: cols}
+		for _, r := range rows {
+			t.Ops = append(t.Ops, rowOp(schema, r, existing))
+		}
+	}
+
+	sort.Strings(dropped)
+	for _, table := range dropped {
+		t.Ops = append(t.Ops, TransformOp{Op: OpDrop, Table: table})
+	}
+	return t, nil
+}
+
+// rowOp converts a transform record to an operation. A zero mask deletes
+// the row named by the key, an even mask updates the flagged columns, and
+// an odd mask carries a whole row, which replaces the row with that key
+// when the base package has one and inserts it otherwise.
+func rowOp(schema *TableSchema, r transformRow, existing map[string]bool) TransformOp {
+	key := schema.KeyValues(TableRow{Columns: r.values})
+	switch {
+	case r.mask == 0:
+		return TransformOp{Op: OpDelete, Table: schema.Name, Key: key}
+	case r.mask&1 == 0 || existing[strings.Join(key, "\x00")]:
+		set := map[string]string{}
+		for i, c := range schema.Columns {
+			if r.set[i] && !c.Key {
+				set[c.Name] = r.values[i]
+			}
+		}
+		return TransformOp{Op: OpUpdate, Table: schema.Name, Key: key, Set: set}
+	}
+	return TransformOp{Op: OpInsert, Table: schema.Name, Values: r.values}
+}
+
+// transformRows decodes a transform table stream. Transforms store records
+// row by row, each led by a 16-bit mask: odd masks carry the first mask>>8
+// columns, other masks carry the key columns plus those whose bit is set.
+func (m *msiStorage) transformRows(table string, cols []ColumnInfo) ([]transformRow, error) {
+	data, ok := m.tableStream(table)
+	if !ok {
+		return nil, nil
+	}
+	refSize := m.pool.refSize()
+	var rows []transformRow
+	for n := 0; n < len(data); {
+		if n+2 > len(data) {
+			return nil, fmt.Errorf("table '%s': truncated transform record at byte %d", table, n)
+		}
+		mask := readRawInt(data[n:], 2)
+		n += 2
+		r := transformRow{mask: mask, values: make([]string, len(cols)), set: make([]bool, len(cols))}
+		if mask&1 != 0 && int(mask>>8) > len(cols) {
+			return nil, fmt.Errorf("table '%s': record at byte %d has %d columns, the table %d", table, n-2, mask>>8, len(cols))
+		}
+		for i, c := range cols {
+			if mask&1 != 0 && i >= int(mask>>8) {
+				break
+			}
+			if mask&1 == 0 && !c.Key && mask&(1<<uint(i)) == 0 {
+				continue
+			}
+			width := columnStreamWidth(c, refSize)
+			if n+width > len(data) {
+				return nil, fmt.Errorf("table '%s': truncated transform record at byte %d", table, n)
+			}
+			v, err := m.pool.decodeCell(c, readRawInt(data[n:], width), width)
+			if err != nil {
+				return nil, fmt.Errorf("table '%s' column '%s': %v", table, c.Name, err)
+			}
+			r.values[i], r.set[i] = v, true
+			n += width
+		}
+		rows = append(rows, r)
+	}
+	for i := range rows {
+		m.fillStreamCells(table, cols, &rows[i])
+	}
+	return rows, nil
+}
+
+// fillStreamCells sets binary cells to the digest of their stream data,
+// which is stored in a separate stream named after the table and row key.
+func (m *msiStorage) fillStreamCells(table string, cols []ColumnInfo, r *transformRow) {
+	key := (&TableSchema{Columns: cols}).KeyValues(TableRow{Columns: r.values})
+	for i, c := range cols {
+		if c.IsBinary() && r.set[i] {
+			if data, ok := m.cellStream(table, key); ok {
+				r.values[i] = streamDigest(data)
+			}
+		}
+	}
+}
+
+// rowKeys returns the primary keys of a table in a database storage.
+func (m *msiStorage) rowKeys(table string, cols []ColumnInfo) (map[string]bool, error) {
+	rows, err := m.readTable(table, cols)
+	if err != nil {
+		return nil, err
+	}
+	schema := &TableSchema{Columns: cols}
+	keys := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		keys[schema.RowKey(TableRow{Columns: row})] = true
+	}
+	return keys, nil
+}
+
+// WriteTransformSummary prints the summary information of a transform.
+func WriteTransformSummary(w io.Writer, s TransformSummary) {
+	product := func(code, version, platform string) string {
+		text := strings.TrimSpace(code + " " + version)
+		if platform != "" {
+			text += " (" + platform + ")"
+		}
+		if text == "" {
+			return "-"
+		}
+		return text
+	}
+	fmt.Fprintf(w, "Base:        %s\n", product(s.BaseProductCode, s.BaseVersion, s.BasePlatform))
+	fmt.Fprintf(w, "Target:      %s\n", product(s.TargetProductCode, s.TargetVersion, s.TargetPlatform))
+	if s.UpgradeCode != "" {
+		fmt.Fprintf(w, "UpgradeCode: %s\n", s.UpgradeCode)
+	}
+	if s.MinVersion != 0 {
+		fmt.Fprintf(w, "Installer:   %d.%d or later\n", s.MinVersion/100, s.MinVersion%100)
+	}
+	flags := func(fs FlagSet, v int) string {
+		if v == 0 {
+			return "none"
+		}
+		return strings.Join(fs.Decode(v), " | ")
+	}
+	fmt.Fprintf(w, "Validation:  %s\n", flags(transformValidationFlags, s.Validation))
+	fmt.Fprintf(w, "Suppressed:  %s\n", flags(transformErrorFlags, s.ErrorConditions))
+}
+
+// ShowTransform prints the summary information and table changes of a
+// binary transform.
+func ShowTransform(mstPath, basePath string) error {
+	return SafeExecute("ShowTransform", func() error {
+		t, err := ReadBinaryTransform(mstPath, basePath)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Transform:   %s\n", mstPath)
+		WriteTransformSummary(os.Stdout, t.Summary)
+		fmt.Println()
+		for _, op := range t.Ops {
+			fmt.Println(op)
+		}
+		for _, table := range sortedKeys(t.Skipped) {
+			fmt.Printf("? %s (%d bytes not decoded: unknown table; pass --base)\n", table, t.Skipped[table])
+		}
+		if len(t.Ops) == 0 && len(t.Skipped) == 0 {
+			fmt.Println("No table changes.")
+		}
+		return nil
+	})
+}
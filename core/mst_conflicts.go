@@ -0,0 +1,440 @@
+// core/mst_conflicts.go
+package core
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// StackedTransform is one transform of a stack applied in order.
+type StackedTransform struct {
+	Name      string
+	Transform *TextTransform
+}
+
+// StackTouch is one transform's change to a table, column, row or cell.
+type StackTouch struct {
+	Transform int    // position in the stack
+	Action    string // e.g. `sets "1"`, "deletes the row"
+	Fails     bool   // the operation fails or is skipped when applied
+}
+
+// StackOverlap is a table, column, row or cell that more than one transform
+// changes.
+type StackOverlap struct {
+	Table   string
+	Key     []string // nil for tables and columns
+	Column  string   // "" for whole tables and rows
+	Touches []StackTouch
+	Winner  int // transform whose change stands, -1 when every one fails
+}
+
+func (o StackOverlap) String() string {
+	s := o.Table
+	if o.Key != nil {
+		s += " [" + strings.Join(o.Key, ",") + "]"
+	}
+	if o.Column != "" {
+		s += "." + o.Column
+	}
+	return s
+}
+
+// StackFailure is an operation that fails once the transforms before it,
+// and the base package when known, have been applied.
+type StackFailure struct {
+	Transform  int
+	Op         TransformOp
+	Reason     string
+	Suppressed bool // the transform suppresses the error, so the operation is skipped
+}
+
+// StackReport is the result of analyzing a stack of transforms.
+type StackReport struct {
+	Names    []string
+	Overlaps []StackOverlap
+	Failures []StackFailure
+}
+
+// ReportTransformConflicts prints the overlapping changes and failing
+// operations of transforms applied in the given order. basePath, when set,
+// is the package they apply to: binary transforms are decoded against it
+// and rows are checked against its tables.
+func ReportTransformConflicts(paths []string, basePath string) error {
+	return SafeExecute("ReportTransformConflicts", func() error {
+		var base *msiStorage
+		if basePath != "" {
+			var err error
+			if base, err = readMsiStorageFile(basePath); err != nil {
+				return err
+			}
+		}
+		stack := make([]StackedTransform, len(paths))
+		for i, path := range paths {
+			t, err := ReadTransform(path, basePath)
+			if err != nil {
+				return err
+			}
+			stack[i] = StackedTransform{Name: filepath.Base(path), Transform: t}
+		}
+		report, err := analyzeTransformStack(stack, base)
+		if err != nil {
+			return err
+		}
+		WriteStackReport(os.Stdout, report)
+		failing := 0
+		for _, f := range report.Failures {
+			if !f.Suppressed {
+				failing++
+			}
+		}
+		if failing > 0 {
+			return fmt.Errorf("%d operation(s) will fail when the transforms are applied in this order", failing)
+		}
+		return nil
+	})
+}
+
+// WriteStackReport prints a stack analysis.
+func WriteStackReport(w io.Writer, r *StackReport) {
+	name := func(i int) string { return fmt.Sprintf("%d. %s", i+1, r.Names[i]) }
+	fmt.Fprintln(w, "Transforms, in order:")
+	for i := range r.Names {
+		fmt.Fprintf(w, "  %s\n", name(i))
+	}
+	fmt.Fprintln(w)
+	if len(r.Overlaps) == 0 {
+		fmt.Fprintln(w, "No overlapping changes.")
+	} else {
+		fmt.Fprintf(w, "Overlapping changes (%d):\n", len(r.Overlaps))
+		for _, o := range r.Overlaps {
+			fmt.Fprintf(w, "  %s\n", o)
+			for _, t := range o.Touches {
+				note := ""
+				if t.Fails {
+					note = "  (fails)"
+				}
+				fmt.Fprintf(w, "    %s %s%s\n", name(t.Transform), t.Action, note)
+			}
+			if o.Winner >= 0 {
+				fmt.Fprintf(w, "    -> %s wins\n", r.Names[o.Winner])
+			} else {
+				fmt.Fprintln(w, "    -> no change applies")
+			}
+		}
+	}
+	fmt.Fprintln(w)
+	if len(r.Failures) == 0 {
+		fmt.Fprintln(w, "No failing operations.")
+		return
+	}
+	fmt.Fprintf(w, "Failing operations (%d):\n", len(r.Failures))
+	for _, f := range r.Failures {
+		fmt.Fprintf(w, "  %s: %s\n", name(f.Transform), f.Op)
+		if f.Suppressed {
+			fmt.Fprintf(w, "    %s; suppressed by the transform, so it is skipped\n", f.Reason)
+		} else {
+			fmt.Fprintf(w, "    %s\n", f.Reason)
+		}
+	}
+}
+
+// stackFact records whether a table, column or row exists and which
+// transform made it so; by is -1 for the base package.
+type stackFact struct {
+	exists bool
+	by     int
+}
+
+// stackState is what is known about the package after some transforms.
+type stackState struct {
+	names       []string
+	base        *msiStorage
+	baseSchemas map[string][]ColumnInfo
+	baseKeys    map[string]map[string]bool
+	tables      map[string]stackFact
+	columns     map[string][]ColumnInfo
+	columnFacts map[string]stackFact // "Table.Column"
+	rows        map[string]map[string]stackFact
+	fresh       map[string]bool // created or dropped by a transform: base rows are gone
+}
+
+func (s *stackState) where(by int) string {
+	if by < 0 {
+		return "in the base package"
+	}
+	return "after " + s.names[by]
+}
+
+// table reports whether a table exists; known is false without a base.
+func (s *stackState) table(name string) (fact stackFact, known bool) {
+	if f, ok := s.tables[name]; ok {
+		return f, true
+	}
+	if s.base == nil {
+		return stackFact{}, false
+	}
+	_, ok := s.baseSchemas[name]
+	return stackFact{exists: ok, by: -1}, true
+}
+
+// tableColumns returns a table's current columns: as created or altered by
+// the transforms, from the base package, or from the standard catalog.
+func (s *stackState) tableColumns(name string) ([]ColumnInfo, error) {
+	if cols, ok := s.columns[name]; ok {
+		return cols, nil
+	}
+	if cols, ok := s.baseSchemas[name]; ok {
+		return cols, nil
+	}
+	if schema, ok := StandardTableSchema(name); ok {
+		return schema.Columns, nil
+	}
+	return nil, fmt.Errorf("table '%s' has unknown columns; pass --base", name)
+}
+
+func (s *stackState) column(table, name string) (stackFact, bool) {
+	if f, ok := s.columnFacts[table+"."+name]; ok {
+		return f, true
+	}
+	cols, ok := s.baseSchemas[table]
+	if !ok || s.fresh[table] {
+		return stackFact{}, false
+	}
+	for _, c := range cols {
+		if c.Name == name {
+			return stackFact{exists: true, by: -1}, true
+		}
+	}
+	return stackFact{}, false
+}
+
+// row reports whether a row exists; known is false when neither the
+// transforms nor the base package tell.
+func (s *stackState) row(table string, key []string) (stackFact, bool, error) {
+	k := strings.Join(key, "\x00")
+	if f, ok := s.rows[table][k]; ok {
+		return f, true, nil
+	}
+	if f, ok := s.tables[table]; ok && s.fresh[table] {
+		return stackFact{exists: false, by: f.by}, true, nil
+	}
+	cols, ok := s.baseSchemas[table]
+	if s.base == nil || !ok {
+		return stackFact{}, false, nil
+	}
+	if s.baseKeys[table] == nil {
+		keys, err := s.base.rowKeys(table, cols)
+		if err != nil {
+			return stackFact{}, false, err
+		}
+		s.baseKeys[table] = keys
+	}
+	return stackFact{exists: s.baseKeys[table][k], by: -1}, true, nil
+}
+
+func (s *stackState) setRow(table string, key []string, f stackFact) {
+	if s.rows[table] == nil {
+		s.rows[table] = map[string]stackFact{}
+	}
+	s.rows[table][strings.Join(key, "\x00")] = f
+}
+
+// stackTarget collects the touches of one table, column, row or cell.
+type stackTarget struct {
+	overlap StackOverlap
+	rowWide bool // a whole-row insert or delete touches the row
+}
+
+// analyzeTransformStack replays the operations of each transform in order,
+// recording which transforms touch each table, column, row and cell and
+// which operations fail on what the earlier ones left. base may be nil.
+func analyzeTransformStack(stack []StackedTransform, base *msiStorage) (*StackReport, error) {
+	report := &StackReport{}
+	s := &stackState{
+		base:        base,
+		baseKeys:    map[string]map[string]bool{},
+		tables:      map[string]stackFact{},
+		columns:     map[string][]ColumnInfo{},
+		columnFacts: map[string]stackFact{},
+		rows:        map[string]map[string]stackFact{},
+		fresh:       map[string]bool{},
+	}
+	for _, st := range stack {
+		report.Names = append(report.Names, st.Name)
+	}
+	s.names = report.Names
+	if base != nil {
+		var err error
+		if s.baseSchemas, err = base.readSchemas(); err != nil {
+			return nil, fmt.Errorf("failed to read base schema: %v", err)
+		}
+	}
+
+	var order []string
+	targets := map[string]*stackTarget{}
+	touch := func(id string, o StackOverlap, t StackTouch, rowWide bool) {
+		target, ok := targets[id]
+		if !ok {
+			target = &stackTarget{overlap: o}
+			targets[id] = target
+			order = append(order, id)
+		}
+		target.overlap.Touches = append(target.overlap.Touches, t)
+		target.rowWide = target.rowWide || rowWide
+	}
+
+	for i, st := range stack {
+		suppress := st.Transform.Checks.Suppress
+		for _, op := range st.Transform.Ops {
+			reason, flag, key, err := s.replay(i, op)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s: %v", st.Name, op, err)
+			}
+			fails := reason != ""
+			if fails {
+				report.Failures = append(report.Failures, StackFailure{Transform: i, Op: op, Reason: reason, Suppressed: flag != 0 && suppress&flag != 0})
+			}
+			rowID := op.Table + "\x01" + strings.Join(key, "\x00")
+			switch op.Op {
+			case OpCreate:
+				touch(op.Table, StackOverlap{Table: op.Table}, StackTouch{i, "creates (" + formatColumnDefs(op.Columns) + ")", fails}, false)
+			case OpDrop:
+				touch(op.Table, StackOverlap{Table: op.Table}, StackTouch{i, "drops the table", fails}, false)
+			case OpAlter:
+				col := op.Columns[0]
+				touch(op.Table+"."+col.Name, StackOverlap{Table: op.Table, Column: col.Name}, StackTouch{i, "adds " + formatColumnDef(col), fails}, false)
+			case OpInsert:
+				touch(rowID, StackOverlap{Table: op.Table, Key: key}, StackTouch{i, "inserts " + formatTuple(op.Values), fails}, true)
+			case OpDelete:
+				touch(rowID, StackOverlap{Table: op.Table, Key: key}, StackTouch{i, "deletes the row", fails}, true)
+			case OpUpdate:
+				var sets []string
+				for _, col := range sortedKeys(op.Set) {
+					sets = append(sets, col+"="+quoteTransformValue(op.Set[col]))
+					touch(rowID+"\x01"+col, StackOverlap{Table: op.Table, Key: key, Column: col}, StackTouch{i, "sets " + quoteTransformValue(op.Set[col]), fails}, false)
+				}
+				touch(rowID, StackOverlap{Table: op.Table, Key: key}, StackTouch{i, "sets " + strings.Join(sets, " "), fails}, false)
+			}
+		}
+	}
+
+	for _, id := range order {
+		target := targets[id]
+		o := target.overlap
+		// A row's cells are reported one by one unless an insert or
+		// delete touches the whole row.
+		if o.Key != nil && o.Column == "" && !target.rowWide {
+			continue
+		}
+		if o.Column != "" && o.Key != nil && targets[strings.TrimSuffix(id, "\x01"+o.Column)].rowWide {
+			continue
+		}
+		transforms := map[int]bool{}
+		o.Winner = -1
+		for _, t := range o.Touches {
+			transforms[t.Transform] = true
+			if !t.Fails {
+				o.Winner = t.Transform
+			}
+		}
+		if len(transforms) > 1 {
+			report.Overlaps = append(report.Overlaps, o)
+		}
+	}
+	return report, nil
+}
+
+// replay applies op to the state. It returns why op fails, with the
+// MSITRANSFORM_ERROR_* bit that suppresses the failure (0 if none), and
+// the primary key of row operations.
+func (s *stackState) replay(i int, op TransformOp) (reason string, flag int, key []string, err error) {
+	table, tableKnown := s.table(op.Table)
+	switch op.Op {
+	case OpCreate:
+		if tableKnown && table.exists {
+			return fmt.Sprintf("table '%s' already exists %s", op.Table, s.where(table.by)), transformErrorAddExistingTable, nil, nil
+		}
+		s.tables[op.Table] = stackFact{exists: true, by: i}
+		s.columns[op.Table] = append([]ColumnInfo(nil), op.Columns...)
+		for _, c := range op.Columns {
+			s.columnFacts[op.Table+"."+c.Name] = stackFact{exists: true, by: i}
+		}
+		s.rows[op.Table] = nil
+		s.fresh[op.Table] = true
+		return "", 0, nil, nil
+	case OpDrop:
+		if tableKnown && !table.exists {
+			return fmt.Sprintf("table '%s' does not exist %s", op.Table, s.where(table.by)), transformErrorDelMissingTable, nil, nil
+		}
+		s.tables[op.Table] = stackFact{exists: false, by: i}
+		delete(s.columns, op.Table)
+		for id := range s.columnFacts {
+			if strings.HasPrefix(id, op.Table+".") {
+				delete(s.columnFacts, id)
+			}
+		}
+		s.rows[op.Table] = nil
+		s.fresh[op.Table] = true
+		return "", 0, nil, nil
+	case OpAlter:
+		col := op.Columns[0]
+		if tableKnown && !table.exists {
+			return fmt.Sprintf("table '%s' does not exist %s", op.Table, s.where(table.by)), transformErrorDelMissingTable, nil, nil
+		}
+		if f, ok := s.column(op.Table, col.Name); ok && f.exists {
+			return fmt.Sprintf("column %s.%s already exists %s", op.Table, col.Name, s.where(f.by)), transformErrorAddExistingTable, nil, nil
+		}
+		cols, err := s.tableColumns(op.Table)
+		if err != nil {
+			return "", 0, nil, err
+		}
+		s.columns[op.Table] = append(append([]ColumnInfo(nil), cols...), col)
+		s.columnFacts[op.Table+"."+col.Name] = stackFact{exists: true, by: i}
+		return "", 0, nil, nil
+	}
+
+	key = op.Key
+	if key == nil {
+		cols, err := s.tableColumns(op.Table)
+		if err != nil {
+			return "", 0, nil, err
+		}
+		if key, err = rowKey(&TableSchema{Name: op.Table, Columns: cols}, op.Values); err != nil {
+			return "", 0, nil, err
+		}
+	}
+	flag = transformErrorDelMissingRow
+	if op.Op == OpUpdate {
+		flag = transformErrorUpdateMissingRow
+	}
+	if tableKnown && !table.exists {
+		if op.Op == OpInsert {
+			flag = 0
+		}
+		return fmt.Sprintf("table '%s' does not exist %s", op.Table, s.where(table.by)), flag, key, nil
+	}
+	row, known, err := s.row(op.Table, key)
+	if err != nil {
+		return "", 0, nil, err
+	}
+	rowName := fmt.Sprintf("%s [%s]", op.Table, strings.Join(key, ","))
+	switch op.Op {
+	case OpInsert:
+		if known && row.exists {
+			return fmt.Sprintf("row %s already exists %s", rowName, s.where(row.by)), transformErrorAddExistingRow, key, nil
+		}
+		s.setRow(op.Table, key, stackFact{exists: true, by: i})
+	case OpDelete, OpUpdate:
+		if known && !row.exists {
+			return fmt.Sprintf("row %s does not exist %s", rowName, s.where(row.by)), flag, key, nil
+		}
+		if op.Op == OpDelete {
+			s.setRow(op.Table, key, stackFact{exists: false, by: i})
+		}
+	}
+	return "", 0, key, nil
+}
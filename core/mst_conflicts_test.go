@@ -0,0 +1,103 @@
+// core/mst_conflicts_test.go
+package core
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func stackOf(t *testing.T, texts ...string) []StackedTransform {
+	t.Helper()
+	var stack []StackedTransform
+	for i, text := range texts {
+		tr, err := ParseTextTransform(strings.NewReader("msicrafter-transform 2\n" + text))
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		stack = append(stack, StackedTransform{Name: string(rune('a'+i)) + ".mst", Transform: tr})
+	}
+	return stack
+}
+
+func TestAnalyzeTransformStack(t *testing.T) {
+	stack := stackOf(t,
+		"~ Property (\"ProductLanguage\") Value=\"1031\"\n+ Property (\"CORP\", \"1\")\n~ Property (\"ARPHELPLINK\") Value=\"x\"\n",
+		"~ Property (\"ProductLanguage\") Value=\"1033\"\n- Property (\"CORP\")\n~ Property (\"ARPCONTACT\") Value=\"y\"\n",
+		"~ Property (\"CORP\") Value=\"2\"\n",
+		"suppress row-missing\n- Property (\"CORP\")\n",
+	)
+	report, err := analyzeTransformStack(stack, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if len(report.Overlaps) != 2 {
+		t.Fatalf("Expected 2 overlaps, got %+v", report.Overlaps)
+	}
+	lang, corp := report.Overlaps[0], report.Overlaps[1]
+	if lang.String() != "Property [ProductLanguage].Value" || lang.Winner != 1 || len(lang.Touches) != 2 {
+		t.Errorf("Unexpected cell overlap: %s %+v", lang, lang)
+	}
+	if corp.String() != "Property [CORP]" || corp.Winner != 1 || len(corp.Touches) != 4 {
+		t.Errorf("Unexpected row overlap: %s %+v", corp, corp)
+	}
+	if !corp.Touches[2].Fails || corp.Touches[2].Action != `sets Value="2"` {
+		t.Errorf("Expected c.mst's update to fail, got %+v", corp.Touches[2])
+	}
+
+	if len(report.Failures) != 2 {
+		t.Fatalf("Expected 2 failures, got %+v", report.Failures)
+	}
+	if f := report.Failures[0]; f.Transform != 2 || f.Suppressed || f.Reason != "row Property [CORP] does not exist after b.mst" {
+		t.Errorf("Unexpected failure: %+v", f)
+	}
+	if f := report.Failures[1]; f.Transform != 3 || !f.Suppressed {
+		t.Errorf("Expected d.mst's delete to be suppressed, got %+v", f)
+	}
+
+	var buf bytes.Buffer
+	WriteStackReport(&buf, report)
+	for _, want := range []string{"  1. a.mst\n", "    2. b.mst sets \"1033\"\n", "    -> b.mst wins\n", "  3. c.mst: ~ Property (\"CORP\") Value=\"2\"\n"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected the report to contain %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestAnalyzeTransformStack_Base(t *testing.T) {
+	pool, data := testStringPool("Custom", "Id", "Value", "Manufacturer", "Old")
+	base, err := readMsiStorage(testStorage(map[string][]byte{
+		encodeStreamName(tableStringPool, true): pool,
+		encodeStreamName(tableStringData, true): data,
+		encodeStreamName(tableColumns, true):    cells(1, 1, 0x8001, 0x8002, 2, 3, 0x8000+0x2D48, 0x8000+0x0F00),
+		encodeStreamName("Custom", true):        cells(4, 5),
+	}))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	stack := stackOf(t,
+		"+ Custom (\"Manufacturer\", \"x\")\n+ Custom (\"New\", \"y\")\nALTER Custom ADD Value S64\n~ Custom (\"Missing\") Value=\"z\"\n",
+		"DROP Custom\nCREATE Custom (Id s72 KEY)\n- Custom (\"New\")\n",
+	)
+	report, err := analyzeTransformStack(stack, base)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	var got []string
+	for _, f := range report.Failures {
+		got = append(got, f.Reason)
+	}
+	expected := []string{
+		"row Custom [Manufacturer] already exists in the base package",
+		"column Custom.Value already exists in the base package",
+		"row Custom [Missing] does not exist in the base package",
+		"row Custom [New] does not exist after b.mst",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected failures:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+	if len(report.Overlaps) != 1 || report.Overlaps[0].String() != "Custom [New]" {
+		t.Errorf("Expected the New row to overlap, got %+v", report.Overlaps)
+	}
+}
@@ -0,0 +1,263 @@
+// core/mst_invert.go
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// InvertTransform writes to outputMST the text transform that undoes the
+// transform at mstPath on basePath: applying both in sequence gives back the
+// base package. Operations that do not apply to the base are skipped when
+// the transform is applied, so they are left out of the inverse.
+func InvertTransform(mstPath, basePath, outputMST string) error {
+	return SafeExecute("InvertTransform", func() error {
+		transform, err := ReadTransform(mstPath, basePath)
+		if err != nil {
+			return err
+		}
+		session, err := OpenMsiSession(basePath, 0)
+		if err != nil {
+			return fmt.Errorf("failed to open base MSI session: %v", err)
+		}
+		defer session.Close()
+
+		base := session.transformBase()
+		if err := transform.Checks.validate(transform.Base, base); err != nil {
+			return fmt.Errorf("'%s': %v", basePath, err)
+		}
+		before := map[string]*tableSnapshot{}
+		for _, op := range transform.Ops {
+			if _, ok := before[op.Table]; ok || !session.HasTable(op.Table) {
+				continue
+			}
+			schema, err := session.GetTableSchema(op.Table)
+			if err != nil {
+				return err
+			}
+			rows, err := session.QueryWithParams(fmt.Sprintf("SELECT * FROM `%s`", op.Table))
+			if err != nil {
+				return fmt.Errorf("failed to read '%s': %v", op.Table, err)
+			}
+			before[op.Table] = &tableSnapshot{schema: schema, rows: rows}
+		}
+
+		after, skipped, err := replayTransform(transform.Ops, before)
+		if err != nil {
+			return err
+		}
+		for _, s := range skipped {
+			logWarn(fmt.Sprintf("left out of the inverse, as it does not apply to '%s': %s", basePath, s))
+		}
+		ops, err := invertOps(before, after)
+		if err != nil {
+			return err
+		}
+		inverse := &TextTransform{
+			Version: 2,
+			Base:    invertedBase(base, after["Property"]),
+			Checks:  TransformChecks{Validation: invertedValidation(transform.Checks.Validation)},
+			Ops:     ops,
+		}
+		if err := inverse.WriteFile(outputMST); err != nil {
+			return err
+		}
+		fmt.Printf("Inverted %d operation(s) into %d operation(s) in '%s'\n", len(transform.Ops), len(ops), outputMST)
+		return nil
+	})
+}
+
+// replayTransform applies ops to copies of the tables in before, as
+// ApplyTransform would, and returns the tables afterwards (nil for dropped
+// ones) and the operations that were skipped because they conflict.
+func replayTransform(ops []TransformOp, before map[string]*tableSnapshot) (map[string]*tableSnapshot, []string, error) {
+	tables := map[string]*tableSnapshot{}
+	for name, t := range before {
+		if t == nil {
+			continue
+		}
+		rows := make([]TableRow, len(t.rows))
+		for i, row := range t.rows {
+			rows[i] = TableRow{Columns: append([]string(nil), row.Columns...)}
+		}
+		tables[name] = &tableSnapshot{schema: t.schema, rows: rows}
+	}
+	var skipped []string
+	skip := func(op TransformOp, format string, args ...interface{}) {
+		skipped = append(skipped, fmt.Sprintf("%s (%s)", op, fmt.Sprintf(format, args...)))
+	}
+
+	for _, op := range ops {
+		t := tables[op.Table]
+		switch op.Op {
+		case OpCreate:
+			if t != nil {
+				skip(op, "table '%s' already exists", op.Table)
+				continue
+			}
+			tables[op.Table] = &tableSnapshot{schema: &TableSchema{Name: op.Table, Columns: op.Columns}}
+			continue
+		case OpDrop:
+			if t == nil {
+				skip(op, "table '%s' does not exist", op.Table)
+				continue
+			}
+			tables[op.Table] = nil
+			continue
+		}
+		if t == nil {
+			skip(op, "table '%s' does not exist", op.Table)
+			continue
+		}
+
+		if op.Op == OpAlter {
+			col := op.Columns[0]
+			if _, _, ok := t.schema.Column(col.Name); ok {
+				skip(op, "column %s.%s already exists", op.Table, col.Name)
+				continue
+			}
+			schema := &TableSchema{Name: op.Table, Columns: append(append([]ColumnInfo(nil), t.schema.Columns...), col)}
+			for i := range t.rows {
+				t.rows[i].Columns = append(t.rows[i].Columns, "")
+			}
+			t.schema = schema
+			continue
+		}
+
+		key := op.Key
+		if key == nil {
+			var err error
+			if key, err = rowKey(t.schema, op.Values); err != nil {
+				return nil, nil, fmt.Errorf("%s: %v", op, err)
+			}
+		}
+		id := strings.Join(key, "\x00")
+		found := -1
+		for i, row := range t.rows {
+			if t.schema.RowKey(row) == id {
+				found = i
+				break
+			}
+		}
+		switch op.Op {
+		case OpInsert:
+			if found >= 0 {
+				skip(op, "the row already exists")
+				continue
+			}
+			if bad := invalidValues(t.schema.Columns, op.Values); bad != nil {
+				skip(op, "%v", bad)
+				continue
+			}
+			t.rows = append(t.rows, TableRow{Columns: append([]string(nil), op.Values...)})
+		case OpDelete:
+			if found < 0 {
+				skip(op, "the row does not exist")
+				continue
+			}
+			t.rows = append(t.rows[:found], t.rows[found+1:]...)
+		case OpUpdate:
+			if found < 0 {
+				skip(op, "the row does not exist")
+				continue
+			}
+			row := append([]string(nil), t.rows[found].Columns...)
+			var bad error
+			for _, name := range sortedKeys(op.Set) {
+				col, i, ok := t.schema.Column(name)
+				if !ok {
+					bad = fmt.Errorf("unknown column '%s'", name)
+					break
+				}
+				if bad = checkColumnValue(col, op.Set[name]); bad != nil {
+					break
+				}
+				row[i] = op.Set[name]
+			}
+			if bad != nil {
+				skip(op, "%v", bad)
+				continue
+			}
+			t.rows[found].Columns = row
+		}
+	}
+	return tables, skipped, nil
+}
+
+func invalidValues(cols []ColumnInfo, values []string) error {
+	for i, col := range cols {
+		if i < len(values) {
+			if err := checkColumnValue(col, values[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// invertOps returns the operations that turn the tables after a transform
+// back into the tables before it. A table whose columns changed cannot be
+// altered back, so it is dropped and created again with its original rows.
+func invertOps(before, after map[string]*tableSnapshot) ([]TransformOp, error) {
+	diff := &MsiDiff{}
+	var recreate []TransformOp
+	for _, name := range sortedKeys(after) {
+		if after[name] == nil && before[name] == nil {
+			continue
+		}
+		t := diffTable(name, after[name], before[name])
+		if t.Status == TableUnchanged {
+			continue
+		}
+		if len(t.Schema) == 0 {
+			diff.Tables = append(diff.Tables, t)
+			continue
+		}
+		schema := before[name].schema
+		if hasBinaryColumn(schema) && len(before[name].rows) > 0 {
+			return nil, fmt.Errorf("table '%s' must be created again to undo its column changes, but the stream data of its rows cannot be written to a text transform", name)
+		}
+		recreate = append(recreate,
+			TransformOp{Op: OpDrop, Table: name},
+			TransformOp{Op: OpCreate, Table: name, Columns: schema.Columns})
+		for _, row := range before[name].rows {
+			recreate = append(recreate, TransformOp{Op: OpInsert, Table: name, Values: row.Columns})
+		}
+	}
+	ops, err := transformOps(diff)
+	if err != nil {
+		return nil, err
+	}
+	return append(ops, recreate...), nil
+}
+
+// invertedBase is the package a transform produces from base: the same
+// package with the properties the transform changed.
+func invertedBase(base TransformBase, property *tableSnapshot) TransformBase {
+	if property == nil {
+		return base
+	}
+	values := map[string]string{}
+	for _, row := range property.rows {
+		if len(row.Columns) >= 2 {
+			values[row.Columns[0]] = row.Columns[1]
+		}
+	}
+	base.ProductCode = values["ProductCode"]
+	base.ProductVersion = values["ProductVersion"]
+	base.UpgradeCode = values["UpgradeCode"]
+	base.ProductLanguage = values["ProductLanguage"]
+	return base
+}
+
+// invertedValidation keeps the validations of a transform for its inverse,
+// which applies only to the package the transform produced: a version
+// comparison becomes an exact match.
+func invertedValidation(flags int) int {
+	version := flags & transformValidateVersionMask
+	flags &^= transformValidateVersionMask | transformValidateCompareMask
+	if version != 0 {
+		flags |= version | transformValidateEqualVersion
+	}
+	return flags
+}
@@ -0,0 +1,102 @@
+// core/mst_invert_test.go
+package core
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// tableContents lists a table's columns and its rows in key order.
+func tableContents(t *tableSnapshot) []string {
+	if t == nil {
+		return nil
+	}
+	var rows []string
+	for _, r := range t.rows {
+		rows = append(rows, formatTuple(r.Columns))
+	}
+	sort.Strings(rows)
+	return append([]string{formatColumnDefs(t.schema.Columns)}, rows...)
+}
+
+func TestInvertOps(t *testing.T) {
+	property := []ColumnInfo{{Name: "Property", Type: "s72", Key: true}, {Name: "Value", Type: "l0"}}
+	registry := []ColumnInfo{{Name: "Registry", Type: "s72", Key: true}, {Name: "Root", Type: "i2"}}
+	custom := []ColumnInfo{{Name: "Id", Type: "s72", Key: true}}
+	before := map[string]*tableSnapshot{
+		"Property": snapshot("Property", property, []string{"A", "1"}, []string{"B", "2"}, []string{"C", "3"}),
+		"Registry": snapshot("Registry", registry, []string{"R1", "2"}),
+		"Legacy":   snapshot("Legacy", custom, []string{"L1"}),
+	}
+	ops := []TransformOp{
+		{Op: OpUpdate, Table: "Property", Key: []string{"A"}, Set: map[string]string{"Value": "10"}},
+		{Op: OpDelete, Table: "Property", Key: []string{"B"}},
+		{Op: OpInsert, Table: "Property", Values: []string{"D", "4"}},
+		{Op: OpInsert, Table: "Property", Values: []string{"C", "30"}},
+		{Op: OpAlter, Table: "Registry", Columns: []ColumnInfo{{Name: "Extra", Type: "S0"}}},
+		{Op: OpUpdate, Table: "Registry", Key: []string{"R1"}, Set: map[string]string{"Extra": "x"}},
+		{Op: OpUpdate, Table: "Registry", Key: []string{"R1"}, Set: map[string]string{"Root": "abc"}},
+		{Op: OpCreate, Table: "Custom", Columns: custom},
+		{Op: OpInsert, Table: "Custom", Values: []string{"c1"}},
+		{Op: OpDrop, Table: "Legacy"},
+		{Op: OpDelete, Table: "Missing", Key: []string{"x"}},
+	}
+	after, skipped, err := replayTransform(ops, before)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(skipped) != 3 {
+		t.Errorf("Expected the existing row, the bad integer and the missing table to be skipped, got %v", skipped)
+	}
+	inverse, err := invertOps(before, after)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	var got []string
+	for _, op := range inverse {
+		got = append(got, op.String())
+	}
+	expected := []string{
+		`CREATE Legacy (Id s72 KEY)`,
+		`+ Legacy ("L1")`,
+		`- Property ("D")`,
+		`~ Property ("A") Value="1"`,
+		`+ Property ("B", "2")`,
+		`DROP Custom`,
+		`DROP Registry`,
+		`CREATE Registry (Registry s72 KEY, Root i2)`,
+		`+ Registry ("R1", "2")`,
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected:\n%s\nGot:\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+
+	restored, skipped, err := replayTransform(inverse, after)
+	if err != nil || len(skipped) > 0 {
+		t.Fatalf("Expected the inverse to apply cleanly, got %v %v", skipped, err)
+	}
+	for _, name := range sortedKeys(restored) {
+		if !reflect.DeepEqual(tableContents(restored[name]), tableContents(before[name])) {
+			t.Errorf("%s: expected %v, got %v", name, tableContents(before[name]), tableContents(restored[name]))
+		}
+	}
+}
+
+func TestInvertedValidation(t *testing.T) {
+	tests := []struct{ validate, expected string }{
+		{"product version minor >=", "product version minor ="},
+		{"upgrade-code language", "upgrade-code language"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		flags, err := parseTransformValidation(splitCheckWords(tt.validate))
+		if err != nil {
+			t.Fatalf("%q: %v", tt.validate, err)
+		}
+		if got := formatTransformValidation(invertedValidation(flags)); got != tt.expected {
+			t.Errorf("%q: expected %q, got %q", tt.validate, tt.expected, got)
+		}
+	}
+}
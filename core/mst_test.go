@@ -0,0 +1,270 @@
+// core/mst_test.go
+package core
+
+import (
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testStorage writes streams to the root of a compound file.
+func testStorage(streams map[string][]byte) []byte {
+	root := newCFBStorage()
+	root.Streams = streams
+	return writeCFB(root)
+}
+
+// testStringPool builds _StringPool and _StringData streams; string i+1 is
+// strs[i] and "" leaves an unused slot.
+func testStringPool(strs ...string) (pool, data []byte) {
+	pool = make([]byte, 4, 4+4*len(strs))
+	for _, s := range strs {
+		refs := uint16(1)
+		if s == "" {
+			refs = 0
+		}
+		pool = binary.LittleEndian.AppendUint16(pool, uint16(len(s)))
+		pool = binary.LittleEndian.AppendUint16(pool, refs)
+		data = append(data, s...)
+	}
+	return pool, data
+}
+
+// cells packs 16-bit cell values, as used by tables with short string refs.
+func cells(values ...int) []byte {
+	var b []byte
+	for _, v := range values {
+		b = binary.LittleEndian.AppendUint16(b, uint16(v))
+	}
+	return b
+}
+
+// testPropertySet builds a summary information stream with the given
+// integer and string properties.
+func testPropertySet(props map[int]interface{}) []byte {
+	le := binary.LittleEndian
+	var pids []int
+	for pid := range props {
+		pids = append(pids, pid)
+	}
+	var values []byte
+	offsets := map[int]int{}
+	for _, pid := range pids {
+		offsets[pid] = len(values)
+		switch v := props[pid].(type) {
+		case int:
+			values = le.AppendUint32(values, vtI4)
+			values = le.AppendUint32(values, uint32(v))
+		case string:
+			values = le.AppendUint32(values, vtLPSTR)
+			values = le.AppendUint32(values, uint32(len(v)+1))
+			values = append(values, v...)
+			values = append(values, make([]byte, 4-len(v)%4)...)
+		}
+	}
+	head := 8 + 8*len(pids)
+	sec := le.AppendUint32(nil, uint32(head+len(values)))
+	sec = le.AppendUint32(sec, uint32(len(pids)))
+	for _, pid := range pids {
+		sec = le.AppendUint32(sec, uint32(pid))
+		sec = le.AppendUint32(sec, uint32(head+offsets[pid]))
+	}
+	stream := make([]byte, 48)
+	le.PutUint16(stream, 0xFFFE)
+	le.PutUint32(stream[24:], 1)
+	le.PutUint32(stream[44:], 48)
+	return append(append(stream, sec...), values...)
+}
+
+func TestStreamNames(t *testing.T) {
+	for _, name := range []string{"_StringPool", "Property", "Binary.Icon_1", "a-b"} {
+		encoded := encodeStreamName(name, true)
+		decoded, table := decodeStreamName(encoded)
+		if decoded != name || !table {
+			t.Errorf("%s: round trip gave %q (table %v)", name, decoded, table)
+		}
+	}
+	if n := len([]rune(encodeStreamName("Property", true))); n != 5 {
+		t.Errorf("Expected the prefix plus 4 packed units, got %d", n)
+	}
+	if _, table := decodeStreamName(encodeStreamName("Binary.Icon", false)); table {
+		t.Error("Expected a data stream name, got a table stream")
+	}
+}
+
+func TestColumnTypeFromBits(t *testing.T) {
+	tests := map[int]string{0x2D48: "s72", 0x1502: "I2", 0x0104: "i4", 0x0900: "v0", 0x1900: "V0", 0x1F00: "L0", 0x0DFF: "s255"}
+	for bits, expected := range tests {
+		if got := columnTypeFromBits(bits); got != expected {
+			t.Errorf("0x%04X: expected %s, got %s", bits, expected, got)
+		}
+	}
+}
+
+func TestReadStringPool(t *testing.T) {
+	pool, data := testStringPool("Property", "", "Value")
+	p, err := readStringPool(pool, data)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !reflect.DeepEqual(p.strings, []string{"", "Property", "", "Value"}) || p.refSize() != 2 {
+		t.Errorf("Unexpected pool %q with %d-byte refs", p.strings, p.refSize())
+	}
+
+	long := strings.Repeat("x", 0x10001)
+	pool = cells(1252, 0x8000, 0, 1, 1, 1)
+	p, err = readStringPool(pool, []byte(long))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(p.strings) != 2 || p.strings[1] != long || !p.longRefs || p.codepage != 1252 {
+		t.Errorf("Expected one long string with 3-byte refs, got %d strings, refs %d", len(p.strings), p.refSize())
+	}
+	if _, err := readStringPool(cells(0, 0, 10, 1), []byte("short")); err == nil {
+		t.Error("Expected an error for a string past the end of the data")
+	}
+}
+
+func TestDecodeBinaryTransform(t *testing.T) {
+	pool, data := testStringPool("Custom", "Id", "Note", "Property", "NEWPROP", "hello",
+		"Manufacturer", "Acme", "OLDPROP", "Legacy", "Extra")
+	column := func(table, number, name, bits int) []byte {
+		return cells(0x0401, table, 0x8000+number, name, 0x8000+bits)
+	}
+	streams := map[string][]byte{
+		encodeStreamName(tableStringPool, true): pool,
+		encodeStreamName(tableStringData, true): data,
+		encodeStreamName(tableTables, true):     append(cells(0x0101, 1), cells(0, 10)...),
+		encodeStreamName(tableColumns, true): concat(
+			column(1, 1, 2, 0x2D48),
+			column(1, 2, 3, 0x1D00),
+			column(4, 3, 11, 0x1D00),
+		),
+		encodeStreamName("Custom", true): cells(0x0201, 6, 0),
+		encodeStreamName("Property", true): concat(
+			cells(0x0301, 5, 6, 0),
+			cells(0x0002, 7, 8),
+			cells(0x0000, 9),
+		),
+		encodeStreamName("Unknown", true): cells(0x0101, 1),
+		summaryInfoStream: testPropertySet(map[int]interface{}{
+			pidTemplate:       "Intel;1033",
+			pidRevisionNumber: "{AAAAAAAA-0000-0000-0000-000000000000}1.0.0;{BBBBBBBB-0000-0000-0000-000000000000}1.1.0;{CCCCCCCC-0000-0000-0000-000000000000}",
+			pidCharCount:      (0x0002|0x0008|0x0200)<<16 | 0x0001 | 0x0002,
+		}),
+	}
+	mst, err := readMsiStorage(testStorage(streams))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	tr, err := decodeBinaryTransform(mst, nil, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	var got []string
+	for _, op := range tr.Ops {
+		got = append(got, op.String())
+	}
+	expected := []string{
+		`CREATE Custom (Id s72 KEY, Note S0)`,
+		`ALTER Property ADD Extra S0`,
+		`+ Custom ("hello", NULL)`,
+		`+ Property ("NEWPROP", "hello", NULL)`,
+		`~ Property ("Manufacturer") Value="Acme"`,
+		`- Property ("OLDPROP")`,
+		`DROP Legacy`,
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected:\n%s\nGot:\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+	if tr.Skipped["Unknown"] != 4 {
+		t.Errorf("Expected the Unknown table to be skipped, got %v", tr.Skipped)
+	}
+
+	s := tr.Summary
+	if s.BaseProductCode != "{AAAAAAAA-0000-0000-0000-000000000000}" || s.BaseVersion != "1.0.0" ||
+		s.TargetVersion != "1.1.0" || s.UpgradeCode != "{CCCCCCCC-0000-0000-0000-000000000000}" || s.BasePlatform != "Intel;1033" {
+		t.Errorf("Unexpected summary: %+v", s)
+	}
+	var out strings.Builder
+	WriteTransformSummary(&out, s)
+	for _, want := range []string{
+		"MSITRANSFORM_VALIDATE_PRODUCT | MSITRANSFORM_VALIDATE_MAJORVERSION | MSITRANSFORM_VALIDATE_NEWGREATEREQUALBASEVERSION",
+		"MSITRANSFORM_ERROR_ADDEXISTINGROW | MSITRANSFORM_ERROR_DELMISSINGROW",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected summary output to contain %q, got:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestDecodeBinaryTransform_Base(t *testing.T) {
+	basePool, baseData := testStringPool("Custom", "Id", "Value", "Manufacturer", "Old")
+	base, err := readMsiStorage(testStorage(map[string][]byte{
+		encodeStreamName(tableStringPool, true): basePool,
+		encodeStreamName(tableStringData, true): baseData,
+		// Database tables are stored column by column.
+		encodeStreamName(tableColumns, true): cells(1, 1, 0x8001, 0x8002, 2, 3, 0x8000+0x2D48, 0x8000+0x0F00),
+		encodeStreamName("Custom", true):     cells(4, 5),
+	}))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	pool, data := testStringPool("Manufacturer", "Acme", "NEW", "x")
+	mst, err := readMsiStorage(testStorage(map[string][]byte{
+		encodeStreamName(tableStringPool, true): pool,
+		encodeStreamName(tableStringData, true): data,
+		encodeStreamName("Custom", true):        concat(cells(0x0201, 1, 2), cells(0x0201, 3, 4)),
+	}))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	schemas, err := base.readSchemas()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	tr, err := decodeBinaryTransform(mst, schemas, base)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	expected := []TransformOp{
+		{Op: OpUpdate, Table: "Custom", Key: []string{"Manufacturer"}, Set: map[string]string{"Value": "Acme"}},
+		{Op: OpInsert, Table: "Custom", Values: []string{"NEW", "x"}},
+	}
+	if !reflect.DeepEqual(tr.Ops, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, tr.Ops)
+	}
+}
+
+func TestReadCFB_Errors(t *testing.T) {
+	if _, err := readCFB([]byte("plain text transform")); err == nil {
+		t.Error("Expected an error for a file that is not a compound file")
+	}
+	image := testStorage(map[string][]byte{"A": []byte("data")})
+	// Sector 0 is the FAT, 1 the mini FAT and 2 the directory: loop the
+	// directory's chain back onto itself.
+	binary.LittleEndian.PutUint32(image[512+4*2:], 2)
+	if _, err := readCFB(image); err == nil {
+		t.Error("Expected an error for a looping sector chain")
+	}
+
+	// A DIFAT sector cut short by a truncated file.
+	image = testStorage(map[string][]byte{"A": []byte("data")})
+	last := uint32((len(image)-512)/512 - 1)
+	binary.LittleEndian.PutUint32(image[0x44:], last)
+	binary.LittleEndian.PutUint32(image[0x48:], 1)
+	if _, err := readCFB(image[:len(image)-100]); err == nil || !strings.Contains(err.Error(), "DIFAT") {
+		t.Errorf("Expected a DIFAT error for a truncated file, got %v", err)
+	}
+}
+
+func concat(parts ...[]byte) []byte {
+	var out []byte
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
@@ -0,0 +1,441 @@
+// core/mst_write.go
+package core
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// msiTransformCLSID marks the root storage of an installer transform.
+var msiTransformCLSID = [16]byte{0x82, 0x10, 0x0C, 0x00, 0x00, 0x00, 0x00, 0x00, 0xC0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x46}
+
+// Flags written to generated binary transforms: the transform applies only
+// to the product and version it was generated from, and rows that were
+// already added, deleted or changed are not an error.
+const (
+	defaultTransformValidation = 0x0002 | 0x0008 | 0x0010 | 0x0020 | 0x0100 // PRODUCT, MAJOR/MINOR/UPDATEVERSION, NEWEQUALBASEVERSION
+	transformValidateUpgrade   = 0x0800
+	defaultTransformErrors     = 0x0001 | 0x0002 | 0x0010 // ADDEXISTINGROW, DELMISSINGROW, UPDATEMISSINGROW
+)
+
+// columnTypeBits converts a column definition to its _Columns type number.
+func columnTypeBits(col ColumnInfo) int {
+	bits := colTypeValid
+	switch {
+	case col.IsBinary():
+		bits |= colTypeString
+	case col.IsString():
+		bits |= colTypeString | colTypeNonBinary | col.Width()&colTypeWidth
+		if col.IsLocalizable() {
+			bits |= colTypeLocalizable
+		}
+	case col.Width() > 2:
+		bits |= 4
+	default:
+		bits |= colTypeNonBinary | 2
+	}
+	if col.IsNullable() {
+		bits |= colTypeNullable
+	}
+	if col.Key {
+		bits |= colTypeKey
+	}
+	return bits
+}
+
+// stringPoolBuilder collects the strings a transform references.
+type stringPoolBuilder struct {
+	ids     map[string]uint32
+	strings []string
+	refs    []int
+}
+
+func newStringPoolBuilder() *stringPoolBuilder {
+	return &stringPoolBuilder{ids: map[string]uint32{}}
+}
+
+// intern adds a reference to s; the empty string is the null string.
+func (b *stringPoolBuilder) intern(s string) {
+	if s == "" {
+		return
+	}
+	id, ok := b.ids[s]
+	if !ok {
+		b.strings = append(b.strings, s)
+		b.refs = append(b.refs, 0)
+		id = uint32(len(b.strings))
+		b.ids[s] = id
+	}
+	b.refs[id-1]++
+}
+
+// refSize returns the width of string references: three bytes once the
+// indexes no longer fit in two.
+func (b *stringPoolBuilder) refSize() int {
+	if len(b.strings) >= 0xFFFF {
+		return 3
+	}
+	return 2
+}
+
+// encode returns the _StringPool and _StringData streams.
+func (b *stringPoolBuilder) encode(codepage int) (pool, data []byte, err error) {
+	le := binary.LittleEndian
+	flags := uint16(codepage >> 16)
+	if b.refSize() == 3 {
+		flags |= 0x8000
+	}
+	pool = le.AppendUint16(pool, uint16(codepage))
+	pool = le.AppendUint16(pool, flags)
+	for i, s := range b.strings {
+		encoded, err := encodeCodepage(s, codepage)
+		if err != nil {
+			return nil, nil, err
+		}
+		refs := b.refs[i]
+		if refs > 0xFFFF {
+			refs = 0xFFFF
+		}
+		if n := len(encoded); n > 0xFFFF {
+			pool = le.AppendUint16(pool, 0)
+			pool = le.AppendUint16(pool, uint16(n>>16))
+			pool = le.AppendUint16(pool, uint16(n))
+		} else {
+			pool = le.AppendUint16(pool, uint16(n))
+		}
+		pool = le.AppendUint16(pool, uint16(refs))
+		data = append(data, encoded...)
+	}
+	return pool, data, nil
+}
+
+// transformEncoder lays out the records of a binary transform. Binary
+// cells hold the digest of their stream in source, whose data is copied
+// into the transform.
+type transformEncoder struct {
+	pool    *stringPoolBuilder
+	columns map[string][]ColumnInfo
+	rows    map[string][]transformRow
+	source  *msiStorage
+	streams map[string][]byte
+}
+
+// add queues a record for table; set marks the cells it carries.
+func (e *transformEncoder) add(table string, cols []ColumnInfo, mask uint32, values []string, set []bool) error {
+	for i, c := range cols {
+		if !set[i] {
+			continue
+		}
+		switch {
+		case c.IsBinary():
+			if err := e.addStream(table, cols, values, values[i]); err != nil {
+				return fmt.Errorf("table '%s' column '%s': %v", table, c.Name, err)
+			}
+		case c.IsString():
+			e.pool.intern(values[i])
+		}
+	}
+	e.columns[table] = cols
+	e.rows[table] = append(e.rows[table], transformRow{mask: mask, values: values, set: set})
+	return nil
+}
+
+// addStream copies the stream of a row whose binary cell holds digest
+// from the source package.
+func (e *transformEncoder) addStream(table string, cols []ColumnInfo, values []string, digest string) error {
+	if digest == "" {
+		return nil
+	}
+	key := (&TableSchema{Columns: cols}).KeyValues(TableRow{Columns: values})
+	if e.source == nil {
+		return fmt.Errorf("stream data of row '%s' is not available", strings.Join(key, ","))
+	}
+	data, ok := e.source.cellStream(table, key)
+	if !ok || streamDigest(data) != digest {
+		return fmt.Errorf("stream data of row '%s' does not match the modified package", strings.Join(key, ","))
+	}
+	e.streams[cellStreamName(table, key)] = data
+	return nil
+}
+
+// addRow queues a whole row, which inserts or replaces the row.
+func (e *transformEncoder) addRow(table string, cols []ColumnInfo, values []string) error {
+	if len(values) != len(cols) {
+		return fmt.Errorf("table '%s': row has %d values, the table %d columns", table, len(values), len(cols))
+	}
+	set := make([]bool, len(cols))
+	for i := range set {
+		set[i] = true
+	}
+	return e.add(table, cols, uint32(len(cols))<<8|1, values, set)
+}
+
+// addKeyed queues a record carrying the key plus the given columns; with
+// no columns it deletes the row.
+func (e *transformEncoder) addKeyed(table string, cols []ColumnInfo, key []string, changes map[string]string) error {
+	schema := &TableSchema{Name: table, Columns: cols}
+	if len(key) != len(schema.KeyColumns()) {
+		return fmt.Errorf("table '%s': expected %d key values, got %d", table, len(schema.KeyColumns()), len(key))
+	}
+	values := make([]string, len(cols))
+	set := make([]bool, len(cols))
+	mask := uint32(0)
+	k := 0
+	for i, c := range cols {
+		if c.Key {
+			values[i], set[i] = key[k], true
+			k++
+		}
+	}
+	for name, v := range changes {
+		_, i, ok := schema.Column(name)
+		if !ok {
+			return fmt.Errorf("table '%s' has no column '%s'", table, name)
+		}
+		if i >= 16 {
+			return fmt.Errorf("table '%s': column '%s' is past the 16 columns a transform update can address", table, name)
+		}
+		values[i], set[i] = v, true
+		mask |= 1 << uint(i)
+	}
+	return e.add(table, cols, mask, values, set)
+}
+
+// encodeCell converts a cell to its stored form.
+func (e *transformEncoder) encodeCell(col ColumnInfo, v string, width int) (uint32, error) {
+	switch {
+	case col.IsBinary():
+		return 0, nil
+	case col.IsString():
+		return e.pool.ids[v], nil
+	case v == "":
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("column '%s': '%s' is not an integer", col.Name, v)
+	}
+	if width == 2 {
+		if n < -0x7FFF || n > 0x7FFF {
+			return 0, fmt.Errorf("column '%s': %d does not fit a 16-bit integer", col.Name, n)
+		}
+		return uint32(n + 0x8000), nil
+	}
+	if n < -0x7FFFFFFF || n > 0x7FFFFFFF {
+		return 0, fmt.Errorf("column '%s': %d does not fit a 32-bit integer", col.Name, n)
+	}
+	return uint32(int32(n)) ^ 0x80000000, nil
+}
+
+// stream serializes the queued records of a table.
+func (e *transformEncoder) stream(table string) ([]byte, error) {
+	refSize := e.pool.refSize()
+	cols := e.columns[table]
+	var out []byte
+	for _, r := range e.rows[table] {
+		out = binary.LittleEndian.AppendUint16(out, uint16(r.mask))
+		for i, c := range cols {
+			if !r.set[i] {
+				continue
+			}
+			width := columnStreamWidth(c, refSize)
+			raw, err := e.encodeCell(c, r.values[i], width)
+			if err != nil {
+				return nil, fmt.Errorf("table '%s': %v", table, err)
+			}
+			for b := 0; b < width; b++ {
+				out = append(out, byte(raw>>(8*b)))
+			}
+		}
+	}
+	return out, nil
+}
+
+// encodeBinaryTransform writes operations in the Windows Installer
+// transform layout. schemas holds the columns of every table the
+// operations touch as they are after the transform; streams, if set, is
+// the package whose stream data binary cells refer to by digest.
+func encodeBinaryTransform(ops []TransformOp, schemas map[string][]ColumnInfo, streams *msiStorage, summary TransformSummary, codepage int) ([]byte, error) {
+	e := &transformEncoder{pool: newStringPoolBuilder(), columns: map[string][]ColumnInfo{}, rows: map[string][]transformRow{},
+		source: streams, streams: map[string][]byte{}}
+	addColumn := func(table string, number int, col ColumnInfo) error {
+		return e.addRow(tableColumns, columnsSchema, []string{table, strconv.Itoa(number), col.Name, strconv.Itoa(columnTypeBits(col))})
+	}
+	for _, op := range ops {
+		var err error
+		cols, ok := schemas[op.Table]
+		switch op.Op {
+		case OpCreate:
+			if err = e.addRow(tableTables, tablesSchema, []string{op.Table}); err != nil {
+				break
+			}
+			for i, col := range op.Columns {
+				if err = addColumn(op.Table, i+1, col); err != nil {
+					break
+				}
+			}
+		case OpAlter:
+			_, i, found := (&TableSchema{Columns: cols}).Column(op.Columns[0].Name)
+			if !found {
+				return nil, fmt.Errorf("%s: column is missing from the table's schema", op)
+			}
+			err = addColumn(op.Table, i+1, op.Columns[0])
+		case OpDrop:
+			err = e.add(tableTables, tablesSchema, 0, []string{op.Table}, []bool{true})
+		case OpInsert, OpDelete, OpUpdate:
+			if !ok {
+				return nil, fmt.Errorf("%s: unknown table schema", op)
+			}
+			switch op.Op {
+			case OpInsert:
+				err = e.addRow(op.Table, cols, op.Values)
+			case OpDelete:
+				err = e.addKeyed(op.Table, cols, op.Key, nil)
+			default:
+				if len(op.Set) == 0 {
+					continue
+				}
+				err = e.addKeyed(op.Table, cols, op.Key, op.Set)
+			}
+		default:
+			err = fmt.Errorf("unsupported operation %s", op.Op)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	root := newCFBStorage()
+	root.CLSID = msiTransformCLSID
+	for table := range e.rows {
+		data, err := e.stream(table)
+		if err != nil {
+			return nil, err
+		}
+		root.Streams[encodeStreamName(table, true)] = data
+	}
+	for name, data := range e.streams {
+		root.Streams[name] = data
+	}
+	pool, data, err := e.pool.encode(codepage)
+	if err != nil {
+		return nil, err
+	}
+	root.Streams[encodeStreamName(tableStringPool, true)] = pool
+	root.Streams[encodeStreamName(tableStringData, true)] = data
+	props, err := transformSummaryProps(summary)
+	if err != nil {
+		return nil, err
+	}
+	root.Streams[summaryInfoStream] = writePropertySet(props)
+	return writeCFB(root), nil
+}
+
+// transformSummaryProps converts a transform summary to summary
+// information properties.
+func transformSummaryProps(s TransformSummary) (map[int]interface{}, error) {
+	codepage := s.Codepage
+	if codepage == 0 {
+		codepage = 1252
+	}
+	props := map[int]interface{}{
+		pidCodepage:  codepage,
+		pidCharCount: s.Validation<<16 | s.ErrorConditions,
+	}
+	if s.MinVersion != 0 {
+		props[pidPageCount] = s.MinVersion
+	}
+	revision := s.BaseProductCode + s.BaseVersion + ";" + s.TargetProductCode + s.TargetVersion + ";" + s.UpgradeCode
+	for pid, v := range map[int]string{
+		pidTitle:          s.Title,
+		pidSubject:        s.Subject,
+		pidAuthor:         s.Author,
+		pidComments:       s.Comments,
+		pidTemplate:       s.BasePlatform,
+		pidLastAuthor:     s.TargetPlatform,
+		pidRevisionNumber: revision,
+	} {
+		if v == "" {
+			continue
+		}
+		encoded, err := encodeCodepage(v, codepage)
+		if err != nil {
+			return nil, fmt.Errorf("summary information: %v", err)
+		}
+		props[pid] = string(encoded)
+	}
+	return props, nil
+}
+
+// newTransformSummary describes a transform from base to target with the
+// default validation and error-suppression flags.
+func newTransformSummary(base, target *MsiSession) TransformSummary {
+	s := TransformSummary{
+		Title:             "Transform",
+		BaseProductCode:   base.propertyValue("ProductCode"),
+		BaseVersion:       base.propertyValue("ProductVersion"),
+		TargetProductCode: target.propertyValue("ProductCode"),
+		TargetVersion:     target.propertyValue("ProductVersion"),
+		UpgradeCode:       target.propertyValue("UpgradeCode"),
+		Validation:        defaultTransformValidation,
+		ErrorConditions:   defaultTransformErrors,
+	}
+	if s.UpgradeCode != "" {
+		s.Validation |= transformValidateUpgrade
+	}
+	s.Subject, _ = target.summaryProperty(pidSubject)
+	s.Author, _ = target.summaryProperty(pidAuthor)
+	s.BasePlatform, _ = base.summaryProperty(pidTemplate)
+	s.TargetPlatform, _ = target.summaryProperty(pidTemplate)
+	for _, session := range []*MsiSession{base, target} {
+		pages, _ := session.summaryProperty(pidPageCount)
+		if n, err := strconv.Atoi(pages); err == nil && n > s.MinVersion {
+			s.MinVersion = n
+		}
+	}
+	return s
+}
+
+// writeBinaryTransform encodes the operations of a diff as a binary
+// transform, with the stream data of binary cells read from target, and
+// writes it to path once decoding it reproduces them.
+func writeBinaryTransform(path string, diff *MsiDiff, ops []TransformOp, target *msiStorage, summary TransformSummary) error {
+	oldSchemas := map[string][]ColumnInfo{}
+	newSchemas := map[string][]ColumnInfo{}
+	for _, t := range diff.Changed() {
+		if t.Status == TableRemoved {
+			continue
+		}
+		newSchemas[t.Table] = t.schema.Columns
+		if t.Status != TableAdded {
+			// Added columns are at the end of the table; transformOps
+			// rejects anything else.
+			oldSchemas[t.Table] = t.schema.Columns[:len(t.schema.Columns)-len(t.Schema)]
+		}
+	}
+	data, err := encodeBinaryTransform(ops, newSchemas, target, summary, target.pool.codepage)
+	if err != nil {
+		return err
+	}
+	mst, err := readMsiStorage(data)
+	if err != nil {
+		return fmt.Errorf("failed to read back the binary transform: %v", err)
+	}
+	decoded, err := decodeBinaryTransform(mst, oldSchemas, nil)
+	if err != nil {
+		return fmt.Errorf("failed to decode the binary transform: %v", err)
+	}
+	for i := 0; i < len(ops) || i < len(decoded.Ops); i++ {
+		if i >= len(ops) || i >= len(decoded.Ops) || !reflect.DeepEqual(ops[i], decoded.Ops[i]) {
+			return fmt.Errorf("binary transform does not reproduce the diff at operation %d: wrote %d, read back %d operations", i+1, len(ops), len(decoded.Ops))
+		}
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write transform '%s': %v", path, err)
+	}
+	return nil
+}
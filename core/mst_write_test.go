@@ -0,0 +1,185 @@
+// core/mst_write_test.go
+package core
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteCFB_RoundTrip(t *testing.T) {
+	sub := newCFBStorage()
+	sub.CLSID = msiTransformCLSID
+	sub.Streams["inner"] = []byte("nested")
+	root := newCFBStorage()
+	root.Storages["Embedded"] = sub
+	root.Streams["empty"] = []byte{}
+	root.Streams["large"] = bytes.Repeat([]byte("0123456789"), 1000)
+	for _, name := range []string{"a", "B", "cc", "Dd", "eee", "\x05SummaryInformation"} {
+		root.Streams[name] = []byte("small " + name)
+	}
+
+	got, err := readCFB(writeCFB(root))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !reflect.DeepEqual(got, root) {
+		t.Errorf("Round trip mismatch:\n%+v\n%+v", root, got)
+	}
+}
+
+func TestWriteCFB_DIFAT(t *testing.T) {
+	// 109 FAT sectors address about 7 MB; a larger stream needs DIFAT sectors.
+	root := newCFBStorage()
+	root.Streams["big"] = bytes.Repeat([]byte{0xA5}, 8<<20)
+	got, err := readCFB(writeCFB(root))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !bytes.Equal(got.Streams["big"], root.Streams["big"]) {
+		t.Error("Large stream did not survive the round trip")
+	}
+}
+
+func TestColumnTypeBits(t *testing.T) {
+	for _, typ := range []string{"s72", "S255", "l0", "L64", "i2", "I2", "i4", "I4", "v0", "V0"} {
+		bits := columnTypeBits(ColumnInfo{Type: typ, Key: true})
+		if got := columnTypeFromBits(bits); got != typ || bits&colTypeKey == 0 {
+			t.Errorf("%s: encoded as 0x%04X, decoded as %s", typ, bits, got)
+		}
+	}
+	if bits := columnTypeBits(ColumnInfo{Type: "s72", Key: true}); bits != 0x2D48 {
+		t.Errorf("Expected s72 key to be 0x2D48, got 0x%04X", bits)
+	}
+}
+
+func TestEncodeBinaryTransform_RoundTrip(t *testing.T) {
+	custom := []ColumnInfo{
+		{Name: "Id", Type: "s72", Key: true},
+		{Name: "Note", Type: "L0"},
+		{Name: "Count", Type: "I2"},
+		{Name: "Size", Type: "i4"},
+	}
+	property := []ColumnInfo{
+		{Name: "Property", Type: "s72", Key: true},
+		{Name: "Value", Type: "l0"},
+		{Name: "Extra", Type: "S255"},
+	}
+	ops := []TransformOp{
+		{Op: OpCreate, Table: "Custom", Columns: custom},
+		{Op: OpAlter, Table: "Property", Columns: property[2:]},
+		{Op: OpInsert, Table: "Custom", Values: []string{"a", "é ünicode €", "-5", "100000"}},
+		{Op: OpInsert, Table: "Custom", Values: []string{"b", "", "", "-2147483647"}},
+		{Op: OpInsert, Table: "Property", Values: []string{"NEW", "v", ""}},
+		{Op: OpUpdate, Table: "Property", Key: []string{"Manufacturer"}, Set: map[string]string{"Value": "Acme", "Extra": "x"}},
+		{Op: OpDelete, Table: "Property", Key: []string{"OLD"}},
+		{Op: OpDrop, Table: "Legacy"},
+	}
+	summary := TransformSummary{
+		Codepage:          1252,
+		Title:             "Transform",
+		BasePlatform:      "x64;1033",
+		TargetPlatform:    "x64;1033",
+		BaseProductCode:   "{11111111-1111-1111-1111-111111111111}",
+		BaseVersion:       "1.0.0",
+		TargetProductCode: "{11111111-1111-1111-1111-111111111111}",
+		TargetVersion:     "1.0.1",
+		UpgradeCode:       "{22222222-2222-2222-2222-222222222222}",
+		MinVersion:        500,
+		Validation:        defaultTransformValidation | transformValidateUpgrade,
+		ErrorConditions:   defaultTransformErrors,
+	}
+	data, err := encodeBinaryTransform(ops, map[string][]ColumnInfo{"Custom": custom, "Property": property}, nil, summary, 1252)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	mst, err := readMsiStorage(data)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if mst.root.CLSID != msiTransformCLSID {
+		t.Errorf("Expected the transform CLSID, got %x", mst.root.CLSID)
+	}
+	decoded, err := decodeBinaryTransform(mst, map[string][]ColumnInfo{"Property": property[:2]}, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !reflect.DeepEqual(decoded.Ops, ops) {
+		var want, got []string
+		for _, op := range ops {
+			want = append(want, op.String())
+		}
+		for _, op := range decoded.Ops {
+			got = append(got, op.String())
+		}
+		t.Errorf("Expected:\n%s\nGot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+	if !reflect.DeepEqual(decoded.Summary, summary) {
+		t.Errorf("Expected summary %+v, got %+v", summary, decoded.Summary)
+	}
+}
+
+func TestEncodeBinaryTransform_Streams(t *testing.T) {
+	binary := []ColumnInfo{{Name: "Name", Type: "s72", Key: true}, {Name: "Data", Type: "v0"}}
+	target := &msiStorage{root: newCFBStorage()}
+	target.root.Streams[cellStreamName("Binary", []string{"NewCA"})] = []byte("new dll")
+	target.root.Streams[cellStreamName("Binary", []string{"OldCA"})] = []byte("replaced dll")
+	ops := []TransformOp{
+		{Op: OpUpdate, Table: "Binary", Key: []string{"OldCA"}, Set: map[string]string{"Data": streamDigest([]byte("replaced dll"))}},
+		{Op: OpInsert, Table: "Binary", Values: []string{"NewCA", streamDigest([]byte("new dll"))}},
+	}
+	schemas := map[string][]ColumnInfo{"Binary": binary}
+	data, err := encodeBinaryTransform(ops, schemas, target, TransformSummary{}, 1252)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	mst, err := readMsiStorage(data)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if data, _ := mst.cellStream("Binary", []string{"NewCA"}); string(data) != "new dll" {
+		t.Errorf("Expected the new row's stream in the transform, got %q", data)
+	}
+	decoded, err := decodeBinaryTransform(mst, schemas, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !reflect.DeepEqual(decoded.Ops, ops) {
+		t.Errorf("Expected %v, got %v", ops, decoded.Ops)
+	}
+
+	stale := []TransformOp{{Op: OpInsert, Table: "Binary", Values: []string{"NewCA", streamDigest([]byte("other"))}}}
+	if _, err := encodeBinaryTransform(stale, schemas, target, TransformSummary{}, 1252); err == nil {
+		t.Error("Expected a digest that doesn't match the package's stream to be rejected")
+	}
+}
+
+func TestEncodeBinaryTransform_Errors(t *testing.T) {
+	wide := []ColumnInfo{{Name: "K", Type: "s72", Key: true}}
+	for i := 1; i < 20; i++ {
+		wide = append(wide, ColumnInfo{Name: "C" + string(rune('A'+i)), Type: "S0"})
+	}
+	binary := []ColumnInfo{{Name: "Name", Type: "s72", Key: true}, {Name: "Data", Type: "v0"}}
+	schemas := map[string][]ColumnInfo{"Wide": wide, "Binary": binary}
+	tests := []struct {
+		name     string
+		op       TransformOp
+		codepage int
+	}{
+		{"update past column 16", TransformOp{Op: OpUpdate, Table: "Wide", Key: []string{"k"}, Set: map[string]string{"CT": "x"}}, 0},
+		{"stream data without a package", TransformOp{Op: OpInsert, Table: "Binary", Values: []string{"Icon", "<12 bytes sha256:0000000000000000>"}}, 0},
+		{"unknown table", TransformOp{Op: OpDelete, Table: "Missing", Key: []string{"k"}}, 0},
+		{"bad integer", TransformOp{Op: OpInsert, Table: "Custom", Values: []string{"a", "x"}}, 0},
+		{"code page", TransformOp{Op: OpInsert, Table: "Binary", Values: []string{"日本", ""}}, 1252},
+	}
+	schemas["Custom"] = []ColumnInfo{{Name: "Id", Type: "s72", Key: true}, {Name: "N", Type: "I2"}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := encodeBinaryTransform([]TransformOp{tt.op}, schemas, nil, TransformSummary{}, tt.codepage); err == nil {
+				t.Errorf("Expected an error for %s", tt.op)
+			}
+		})
+	}
+}
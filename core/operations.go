@@ -5,55 +5,155 @@ import (
 	"fmt"
 )
 
-// CompareMSI compares two MSI files and prints differences.
-func CompareMSI(msi1, msi2 string) error {
+// CompareMSI compares two MSI files row by row and writes the differences
+// in the given format (see WriteDiff) to outputPath, or stdout if empty.
+func CompareMSI(msi1, msi2, format, outputPath string) error {
 	return SafeExecute("CompareMSI", func() error {
-		session1, err := OpenMsiSession(msi1, 0)
+		diff, err := DiffMSI(msi1, msi2)
 		if err != nil {
-			return fmt.Errorf("failed to open MSI1 session: %v", err)
+			return err
 		}
-		defer session1.Close()
+		return WriteDiffFile(outputPath, diff, format)
+	})
+}
 
-		session2, err := OpenMsiSession(msi2, 0)
+// GenerateTransform diffs two MSI files by primary key and writes the
+// changes that turn the original into the modified package: as a v2 text
+// transform that ApplyTransform reads, or with binary set as a Windows
+// Installer transform for msiexec TRANSFORMS=. Non-nil checks replace the
+// default validation and suppressed errors: none for text transforms, the
+// product and exact version for binary ones.
+func GenerateTransform(originalMSI, modifiedMSI, outputMST string, binary bool, checks *TransformChecks) error {
+	return SafeExecute("GenerateTransform", func() error {
+		oldSession, err := OpenMsiSession(originalMSI, 0)
+		if err != nil {
+			return fmt.Errorf("failed to open original MSI session: %v", err)
+		}
+		defer oldSession.Close()
+		newSession, err := OpenMsiSession(modifiedMSI, 0)
 		if err != nil {
-			return fmt.Errorf("failed to open MSI2 session: %v", err)
+			return fmt.Errorf("failed to open modified MSI session: %v", err)
 		}
-		defer session2.Close()
+		defer newSession.Close()
 
-		tables1, err := discoverTables(session1)
+		diff, err := DiffSessions(oldSession, newSession)
 		if err != nil {
-			return fmt.Errorf("failed to list tables in MSI1: %v", err)
+			return err
+		}
+		var ops []TransformOp
+		if binary {
+			ops, err = binaryTransformOps(diff)
+		} else {
+			ops, err = transformOps(diff)
 		}
-		tables2, err := discoverTables(session2)
 		if err != nil {
-			return fmt.Errorf("failed to list tables in MSI2: %v", err)
+			return err
 		}
-
-		// Convert discoveredTable slices to slices of names.
-		names1 := discoveredTablesToNames(tables1)
-		names2 := discoveredTablesToNames(tables2)
-
-		fmt.Println("Table differences:")
-		for _, t := range tables1 {
-			if !contains(names2, t.Name) {
-				fmt.Printf("Table '%s' in MSI1 but not MSI2\n", t.Name)
+		if len(ops) == 0 {
+			logWarn(fmt.Sprintf("'%s' and '%s' have no differences; writing an empty transform", originalMSI, modifiedMSI))
+		}
+		base := oldSession.transformBase()
+		if checks != nil {
+			if err := checks.requireBase(base); err != nil {
+				return fmt.Errorf("'%s': %v", originalMSI, err)
 			}
 		}
-		for _, t := range tables2 {
-			if !contains(names1, t.Name) {
-				fmt.Printf("Table '%s' in MSI2 but not MSI1\n", t.Name)
+		if binary {
+			// Strings are stored in the code page of the modified package,
+			// and stream data is copied from it.
+			target, err := readMsiStorageFile(modifiedMSI)
+			if err != nil {
+				return err
+			}
+			summary := newTransformSummary(oldSession, newSession)
+			if checks != nil {
+				summary.Validation = checks.Validation
+				summary.ErrorConditions = checks.Suppress & 0xFFFF
+				if checks.Suppress&transformErrorTypeMismatch != 0 {
+					logWarn("binary transforms cannot suppress type mismatches; ignoring type-mismatch")
+				}
+			}
+			err = writeBinaryTransform(outputMST, diff, ops, target, summary)
+			if err != nil {
+				return err
+			}
+		} else {
+			transform := &TextTransform{Version: 2, Base: base, Ops: ops}
+			if checks != nil {
+				transform.Checks = *checks
+			}
+			if err := transform.WriteFile(outputMST); err != nil {
+				return err
 			}
 		}
+		logInfo(fmt.Sprintf("Wrote %d operation(s) to transform '%s'", len(ops), outputMST))
 		return nil
 	})
 }
 
-// GenerateTransform creates an MST file from two MSI files.
-func GenerateTransform(originalMSI, modifiedMSI, outputMST string) error {
-	return SafeExecute("GenerateTransform", func() error {
-		fmt.Printf("Would generate transform from '%s' and '%s' to '%s'\n", originalMSI, modifiedMSI, outputMST)
-		return fmt.Errorf("transform generation not implemented")
-	})
+// transformOps returns the operations that turn the old side of a diff into
+// the new one: created and altered tables first, then row changes, then
+// dropped tables. Column removals, type or key changes, columns added
+// anywhere but the end, new rows with stream data and changed stream data
+// cannot be expressed in a text transform and are reported as errors.
+func transformOps(diff *MsiDiff) ([]TransformOp, error) {
+	return diffOps(diff, "text transform", false)
+}
+
+// binaryTransformOps is transformOps for a binary transform, which carries
+// stream data: binary cells hold the digest of their stream.
+func binaryTransformOps(diff *MsiDiff) ([]TransformOp, error) {
+	return diffOps(diff, "binary transform", true)
+}
+
+// diffOps returns the operations of a diff for kind, which names what they
+// are written to in errors; streams allows binary cells.
+func diffOps(diff *MsiDiff, kind string, streams bool) ([]TransformOp, error) {
+	var schemaOps, rowOps, dropOps []TransformOp
+	for _, t := range diff.Changed() {
+		switch t.Status {
+		case TableAdded:
+			schemaOps = append(schemaOps, TransformOp{Op: OpCreate, Table: t.Table, Columns: t.schema.Columns})
+		case TableRemoved:
+			dropOps = append(dropOps, TransformOp{Op: OpDrop, Table: t.Table})
+			continue
+		}
+		added := 0
+		for _, c := range t.Schema {
+			if c.OldType != "" || (c.NewKey && t.Status == TableChanged) {
+				return nil, fmt.Errorf("table '%s': %s cannot be expressed in a %s; only added non-key columns can", t.Table, c, kind)
+			}
+			added++
+		}
+		for i, c := range t.Schema {
+			col := t.schema.Columns[len(t.schema.Columns)-added+i]
+			if col.Name != c.Column {
+				return nil, fmt.Errorf("table '%s': added column '%s' is not at the end of the table, where ALTER TABLE adds it", t.Table, c.Column)
+			}
+			schemaOps = append(schemaOps, TransformOp{Op: OpAlter, Table: t.Table, Columns: []ColumnInfo{col}})
+		}
+		for _, c := range t.Changes {
+			switch {
+			case streams:
+			case c.Op == OpInsert && hasBinaryColumn(t.schema):
+				return nil, fmt.Errorf("%s: stream data of new '%s' rows cannot be written to a text transform", c, t.Table)
+			case c.Op == OpUpdate && changesStream(t.schema, c):
+				return nil, fmt.Errorf("%s: changed stream data of '%s' rows cannot be written to a text transform", c, t.Table)
+			}
+		}
+		rowOps = append(rowOps, changeOps(t.Changes)...)
+	}
+	return append(append(schemaOps, rowOps...), dropOps...), nil
+}
+
+// changesStream reports whether an update changes a binary cell.
+func changesStream(schema *TableSchema, c RowChange) bool {
+	for _, cell := range c.Cells() {
+		if col, _, ok := schema.Column(cell.Column); ok && col.IsBinary() {
+			return true
+		}
+	}
+	return false
 }
 
 // contains returns true if the given slice contains the specified item.
@@ -65,12 +165,3 @@ func contains(slice []string, item string) bool {
 	}
 	return false
 }
-
-// discoveredTablesToNames converts a slice of discoveredTable to a slice of table name strings.
-func discoveredTablesToNames(tables []discoveredTable) []string {
-	names := make([]string, 0, len(tables))
-	for _, dt := range tables {
-		names = append(names, dt.Name)
-	}
-	return names
-}
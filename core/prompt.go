@@ -0,0 +1,173 @@
+// core/prompt.go
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"msicrafter/retro"
+)
+
+// Choice is an answer to a per-item prompt.
+type Choice int
+
+const (
+	ChoiceNo   Choice = iota // skip this item
+	ChoiceYes                // apply this item
+	ChoiceAll                // apply this and every remaining item
+	ChoiceQuit               // stop without applying anything
+)
+
+// PromptPolicy decides how confirmations are answered.
+type PromptPolicy int
+
+const (
+	PromptAsk PromptPolicy = iota // ask on the terminal, or read the answers file
+	PromptYes                     // answer yes to everything (--yes)
+	PromptNo                      // answer no to everything (--no)
+)
+
+// Prompter answers every confirmation in msicrafter: from a fixed policy,
+// from an answers file for scripted runs, or by asking on the terminal.
+// Without a terminal it fails instead of reading EOF as "no".
+type Prompter struct {
+	Policy  PromptPolicy
+	answers []string // scripted answers, consumed in order
+	in      *bufio.Reader
+	out     io.Writer
+	isTTY   bool
+}
+
+// Prompts is the prompter used by all commands.
+var Prompts = NewPrompter(os.Stdin, os.Stdout, stdinIsTerminal())
+
+// NewPrompter creates a prompter reading answers from in.
+func NewPrompter(in io.Reader, out io.Writer, isTTY bool) *Prompter {
+	return &Prompter{in: bufio.NewReader(in), out: out, isTTY: isTTY}
+}
+
+// ConfigurePrompts sets the global policy from --yes, --no and --answers.
+func ConfigurePrompts(yes, no bool, answersPath string) error {
+	if yes && no {
+		return fmt.Errorf("--yes and --no cannot be combined")
+	}
+	switch {
+	case yes:
+		Prompts.Policy = PromptYes
+	case no:
+		Prompts.Policy = PromptNo
+	default:
+		Prompts.Policy = PromptAsk
+	}
+	if answersPath == "" {
+		return nil
+	}
+	data, err := os.ReadFile(answersPath)
+	if err != nil {
+		return fmt.Errorf("failed to read answers file: %v", err)
+	}
+	return Prompts.LoadAnswers(string(data))
+}
+
+// LoadAnswers queues scripted answers, one per line. Blank lines and lines
+// starting with '#' are ignored.
+func (p *Prompter) LoadAnswers(text string) error {
+	for n, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if _, ok := parseChoice(line); !ok {
+			return fmt.Errorf("answers file line %d: '%s' is not yes, no, all or quit", n+1, line)
+		}
+		p.answers = append(p.answers, line)
+	}
+	return nil
+}
+
+// Confirm asks a yes/no question.
+func (p *Prompter) Confirm(question string) (bool, error) {
+	choice, err := p.ask(question, "y/n", false)
+	return choice == ChoiceYes || choice == ChoiceAll, err
+}
+
+// ConfirmEach asks about one item of a series, offering yes, no, all and quit.
+func (p *Prompter) ConfirmEach(question string) (Choice, error) {
+	return p.ask(question, "y/n/a/q", true)
+}
+
+func (p *Prompter) ask(question, options string, perItem bool) (Choice, error) {
+	prompt := fmt.Sprintf("%s%s%s (%s): ", retro.Yellow, question, retro.Reset, options)
+	switch p.Policy {
+	case PromptYes:
+		fmt.Fprintln(p.out, prompt+"yes [--yes]")
+		return ChoiceYes, nil
+	case PromptNo:
+		fmt.Fprintln(p.out, prompt+"no [--no]")
+		return ChoiceNo, nil
+	}
+
+	if len(p.answers) > 0 {
+		answer := p.answers[0]
+		p.answers = p.answers[1:]
+		choice, _ := parseChoice(answer)
+		if !perItem && (choice == ChoiceAll || choice == ChoiceQuit) {
+			return ChoiceNo, fmt.Errorf("answer '%s' is not valid for '%s'; expected yes or no", answer, question)
+		}
+		fmt.Fprintln(p.out, prompt+answer+" [answers file]")
+		return choice, nil
+	}
+	if !p.isTTY {
+		return ChoiceNo, fmt.Errorf("confirmation needed for '%s' but stdin is not a terminal; use --yes, --no or --answers", question)
+	}
+
+	for {
+		fmt.Fprint(p.out, prompt)
+		line, err := p.in.ReadString('\n')
+		choice, ok := parseChoice(strings.TrimSpace(line))
+		if ok && (perItem || choice == ChoiceYes || choice == ChoiceNo) {
+			return choice, nil
+		}
+		if err != nil {
+			return ChoiceNo, fmt.Errorf("no answer for '%s': %v", question, err)
+		}
+		fmt.Fprintf(p.out, "Please answer %s.\n", options)
+	}
+}
+
+// parseChoice reads y/yes, n/no, a/all or q/quit in any case.
+func parseChoice(answer string) (Choice, bool) {
+	switch strings.ToLower(answer) {
+	case "y", "yes":
+		return ChoiceYes, true
+	case "n", "no":
+		return ChoiceNo, true
+	case "a", "all":
+		return ChoiceAll, true
+	case "q", "quit":
+		return ChoiceQuit, true
+	}
+	return ChoiceNo, false
+}
+
+// stdinIsTerminal reports whether stdin is an interactive console.
+func stdinIsTerminal() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// confirmOrCancel asks a yes/no question and returns an error naming the
+// cancelled action unless the answer is yes.
+func confirmOrCancel(question, action string) error {
+	ok, err := Prompts.Confirm(question)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%s cancelled by user", action)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+// core/prompt_test.go
+package core
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestPrompterPolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy PromptPolicy
+		want   bool
+	}{
+		{"yes", PromptYes, true},
+		{"no", PromptNo, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPrompter(strings.NewReader(""), io.Discard, false)
+			p.Policy = tt.policy
+			got, err := p.Confirm("Apply?")
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPrompterNoTerminal(t *testing.T) {
+	p := NewPrompter(strings.NewReader(""), io.Discard, false)
+	if _, err := p.Confirm("Apply?"); err == nil || !strings.Contains(err.Error(), "--yes") {
+		t.Errorf("Expected an error pointing at --yes, got: %v", err)
+	}
+}
+
+func TestPrompterAnswers(t *testing.T) {
+	p := NewPrompter(strings.NewReader(""), io.Discard, false)
+	if err := p.LoadAnswers("# scripted run\nyes\n\nn\nall\nquit\n"); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if ok, err := p.Confirm("first?"); err != nil || !ok {
+		t.Errorf("Expected yes, got %v (%v)", ok, err)
+	}
+	if ok, err := p.Confirm("second?"); err != nil || ok {
+		t.Errorf("Expected no, got %v (%v)", ok, err)
+	}
+	for _, want := range []Choice{ChoiceAll, ChoiceQuit} {
+		if got, err := p.ConfirmEach("query?"); err != nil || got != want {
+			t.Errorf("Expected %v, got %v (%v)", want, got, err)
+		}
+	}
+	if _, err := p.Confirm("exhausted?"); err == nil {
+		t.Error("Expected an error once the answers run out")
+	}
+
+	if err := p.LoadAnswers("yes\nmaybe\n"); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Expected an error for line 2, got: %v", err)
+	}
+	p = NewPrompter(strings.NewReader(""), io.Discard, false)
+	p.LoadAnswers("all")
+	if _, err := p.Confirm("Apply?"); err == nil {
+		t.Error("Expected 'all' to be rejected for a yes/no question")
+	}
+}
+
+func TestPrompterTerminal(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		each  bool
+		want  Choice
+	}{
+		{"yes", "y\n", false, ChoiceYes},
+		{"retry", "maybe\nNO\n", false, ChoiceNo},
+		{"all not offered", "a\nyes\n", false, ChoiceYes},
+		{"all", "a\n", true, ChoiceAll},
+		{"quit", "Quit\n", true, ChoiceQuit},
+		{"no newline", "y", true, ChoiceYes},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPrompter(strings.NewReader(tt.input), io.Discard, true)
+			var got Choice
+			var err error
+			if tt.each {
+				got, err = p.ConfirmEach("Apply?")
+			} else {
+				var ok bool
+				ok, err = p.Confirm("Apply?")
+				if ok {
+					got = ChoiceYes
+				}
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	p := NewPrompter(strings.NewReader(""), io.Discard, true)
+	if _, err := p.Confirm("Apply?"); err == nil {
+		t.Error("Expected an error at end of input")
+	}
+}
@@ -0,0 +1,165 @@
+// core/propset.go
+package core
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Property types used by the summary information stream.
+const (
+	vtI2       = 2
+	vtI4       = 3
+	vtLPSTR    = 30
+	vtFILETIME = 64
+)
+
+// Summary information property IDs shared by packages and transforms.
+const (
+	pidCodepage   = 1
+	pidTitle      = 2
+	pidSubject    = 3
+	pidAuthor     = 4
+	pidComments   = 6
+	pidTemplate   = 7  // base platform and languages of a transform
+	pidLastAuthor = 8  // target platform and languages of a transform
+	pidPageCount  = 14 // minimum installer version
+	pidCharCount  = 16 // transform validation and error-suppression flags
+)
+
+// readPropertySet parses the first section of an OLE property set stream
+// such as \x05SummaryInformation. Values are int, string or time.Time.
+func readPropertySet(data []byte) (map[int]interface{}, error) {
+	le := binary.LittleEndian
+	if len(data) < 48 || le.Uint16(data) != 0xFFFE {
+		return nil, fmt.Errorf("not a property set stream")
+	}
+	if le.Uint32(data[24:]) < 1 {
+		return nil, fmt.Errorf("property set has no sections")
+	}
+	secOff := int(le.Uint32(data[44:]))
+	if secOff+8 > len(data) {
+		return nil, fmt.Errorf("property set section is outside the stream")
+	}
+	sec := data[secOff:]
+	count := int(le.Uint32(sec[4:]))
+	if 8+8*count > len(sec) {
+		return nil, fmt.Errorf("truncated property set section")
+	}
+	props := map[int]interface{}{}
+	for i := 0; i < count; i++ {
+		pid := int(le.Uint32(sec[8+8*i:]))
+		off := int(le.Uint32(sec[12+8*i:]))
+		if off+8 > len(sec) {
+			return nil, fmt.Errorf("property %d is outside the section", pid)
+		}
+		v := sec[off:]
+		switch le.Uint32(v) {
+		case vtI2:
+			props[pid] = int(int16(le.Uint16(v[4:])))
+		case vtI4:
+			props[pid] = int(int32(le.Uint32(v[4:])))
+		case vtLPSTR:
+			n := int(le.Uint32(v[4:]))
+			if 8+n > len(v) {
+				return nil, fmt.Errorf("property %d runs past the section", pid)
+			}
+			props[pid] = strings.TrimRight(string(v[8:8+n]), "\x00")
+		case vtFILETIME:
+			if len(v) < 12 {
+				return nil, fmt.Errorf("property %d runs past the section", pid)
+			}
+			props[pid] = filetimeToTime(le.Uint64(v[4:]))
+		}
+	}
+	// Strings are stored in the code page named by PID 1.
+	if cp, ok := props[pidCodepage].(int); ok {
+		for pid, v := range props {
+			if s, ok := v.(string); ok {
+				props[pid] = decodeCodepage([]byte(s), cp&0xFFFF)
+			}
+		}
+	}
+	return props, nil
+}
+
+// filetimeEpochDelta is 1970-01-01 in 100ns intervals since 1601-01-01.
+const filetimeEpochDelta = 116444736000000000
+
+// filetimeToTime converts 100ns intervals since 1601 to a time.
+func filetimeToTime(ft uint64) time.Time {
+	if ft < filetimeEpochDelta {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(ft-filetimeEpochDelta)*100).UTC()
+}
+
+func propString(props map[int]interface{}, pid int) string {
+	s, _ := props[pid].(string)
+	return s
+}
+
+func propInt(props map[int]interface{}, pid int) int {
+	n, _ := props[pid].(int)
+	return n
+}
+
+// summaryInfoFMTID identifies the summary information property set.
+var summaryInfoFMTID = [16]byte{0xE0, 0x85, 0x9F, 0xF2, 0xF9, 0x4F, 0x68, 0x10, 0xAB, 0x91, 0x08, 0x00, 0x2B, 0x27, 0xB3, 0xD9}
+
+// writePropertySet serializes summary properties as a property set stream
+// with one section. PID 1 (the code page) is written as a 16-bit integer,
+// other integers as 32-bit ones; strings must already be in that code page.
+func writePropertySet(props map[int]interface{}) []byte {
+	le := binary.LittleEndian
+	var pids []int
+	for pid := range props {
+		pids = append(pids, pid)
+	}
+	sort.Ints(pids)
+	var values []byte
+	offsets := make([]int, len(pids))
+	for i, pid := range pids {
+		offsets[i] = len(values)
+		switch v := props[pid].(type) {
+		case int:
+			if pid == pidCodepage {
+				values = le.AppendUint32(values, vtI2)
+				values = le.AppendUint32(values, uint32(uint16(v)))
+			} else {
+				values = le.AppendUint32(values, vtI4)
+				values = le.AppendUint32(values, uint32(int32(v)))
+			}
+		case string:
+			values = le.AppendUint32(values, vtLPSTR)
+			values = le.AppendUint32(values, uint32(len(v)+1))
+			values = append(values, v...)
+			values = append(values, make([]byte, 4-len(v)%4)...)
+		case time.Time:
+			values = le.AppendUint32(values, vtFILETIME)
+			values = le.AppendUint64(values, timeToFiletime(v))
+		}
+	}
+	head := 8 + 8*len(pids)
+	stream := make([]byte, 48, 48+head+len(values))
+	le.PutUint16(stream, 0xFFFE)
+	le.PutUint32(stream[4:], 0x00020006) // Win32, OS version 6.0
+	le.PutUint32(stream[24:], 1)
+	copy(stream[28:], summaryInfoFMTID[:])
+	le.PutUint32(stream[44:], 48)
+	stream = le.AppendUint32(stream, uint32(head+len(values)))
+	stream = le.AppendUint32(stream, uint32(len(pids)))
+	for i, pid := range pids {
+		stream = le.AppendUint32(stream, uint32(pid))
+		stream = le.AppendUint32(stream, uint32(head+offsets[i]))
+	}
+	return append(stream, values...)
+}
+
+// timeToFiletime is the inverse of filetimeToTime.
+func timeToFiletime(t time.Time) uint64 {
+	return uint64(t.UnixNano()/100) + filetimeEpochDelta
+}
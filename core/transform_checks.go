@@ -0,0 +1,311 @@
+// core/transform_checks.go
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MSITRANSFORM_VALIDATE_* bits checked before a text transform is applied.
+const (
+	transformValidateLanguage     = 0x0001
+	transformValidateProduct      = 0x0002
+	transformValidateVersionMask  = 0x0038 // MAJOR/MINOR/UPDATEVERSION
+	transformValidateCompareMask  = 0x07C0 // LESS...GREATERVERSION
+	transformValidateEqualVersion = 0x0100
+)
+
+// MSITRANSFORM_ERROR_* bits suppressed while a text transform is applied.
+// transformErrorTypeMismatch has no Windows Installer counterpart; it sits
+// above the 16-bit error word and is dropped from binary transforms.
+const (
+	transformErrorAddExistingRow   = 0x0001
+	transformErrorDelMissingRow    = 0x0002
+	transformErrorAddExistingTable = 0x0004
+	transformErrorDelMissingTable  = 0x0008
+	transformErrorUpdateMissingRow = 0x0010
+	transformErrorTypeMismatch     = 0x10000
+)
+
+// Keywords of the "validate" and "suppress" lines of a v2 text transform.
+var (
+	transformValidateWords = []Flag{
+		bit("product", transformValidateProduct),
+		bit("upgrade-code", transformValidateUpgrade),
+		bit("language", transformValidateLanguage),
+	}
+	transformVersionFields   = []Flag{bit("major", 0x0008), bit("minor", 0x0010), bit("update", 0x0020)}
+	transformVersionCompares = []Flag{
+		bit("<", 0x0040), bit("<=", 0x0080), bit("=", 0x0100), bit(">=", 0x0200), bit(">", 0x0400),
+	}
+	transformSuppressWords = []Flag{
+		bit("row-exists", transformErrorAddExistingRow),
+		bit("row-missing", transformErrorDelMissingRow|transformErrorUpdateMissingRow),
+		bit("table-exists", transformErrorAddExistingTable),
+		bit("table-missing", transformErrorDelMissingTable),
+		bit("type-mismatch", transformErrorTypeMismatch),
+	}
+)
+
+// TransformChecks are the conditions a package must meet before a
+// transform applies to it, and the errors ignored while it is applied.
+type TransformChecks struct {
+	Validation int // MSITRANSFORM_VALIDATE_* flags
+	Suppress   int // MSITRANSFORM_ERROR_* flags and transformErrorTypeMismatch
+}
+
+// ParseTransformChecks reads validation and suppression lists written as
+// in a text transform, e.g. "product version minor >=" and
+// "row-exists,row-missing". Words are separated by spaces or commas.
+func ParseTransformChecks(validate, suppress string) (TransformChecks, error) {
+	var checks TransformChecks
+	var err error
+	if checks.Validation, err = parseTransformValidation(splitCheckWords(validate)); err != nil {
+		return checks, err
+	}
+	if checks.Suppress, err = parseTransformSuppress(splitCheckWords(suppress)); err != nil {
+		return checks, err
+	}
+	return checks, nil
+}
+
+func splitCheckWords(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool { return r == ' ' || r == '\t' || r == ',' })
+}
+
+func lookupCheckWord(flags []Flag, word string) (int, bool) {
+	for _, f := range flags {
+		if strings.EqualFold(f.Name, word) {
+			return f.Value, true
+		}
+	}
+	return 0, false
+}
+
+func checkWordNames(flags []Flag) string {
+	names := make([]string, len(flags))
+	for i, f := range flags {
+		names[i] = f.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// parseTransformValidation converts validation words to flags. A version
+// check is three words: "version", the last field compared and the
+// comparison of the package's version with the base version.
+func parseTransformValidation(words []string) (int, error) {
+	flags := 0
+	for i := 0; i < len(words); i++ {
+		if strings.EqualFold(words[i], "version") {
+			if i+2 >= len(words) {
+				return 0, fmt.Errorf("expected 'version <%s> <%s>'", strings.ReplaceAll(checkWordNames(transformVersionFields), ", ", "|"), strings.ReplaceAll(checkWordNames(transformVersionCompares), ", ", "|"))
+			}
+			field, ok := lookupCheckWord(transformVersionFields, words[i+1])
+			if !ok {
+				return 0, fmt.Errorf("unknown version field '%s'; expected %s", words[i+1], checkWordNames(transformVersionFields))
+			}
+			compare, ok := lookupCheckWord(transformVersionCompares, words[i+2])
+			if !ok {
+				return 0, fmt.Errorf("unknown version comparison '%s'; expected %s", words[i+2], checkWordNames(transformVersionCompares))
+			}
+			if flags&transformValidateVersionMask != 0 {
+				return 0, fmt.Errorf("only one version check is allowed")
+			}
+			flags |= field | compare
+			i += 2
+			continue
+		}
+		f, ok := lookupCheckWord(transformValidateWords, words[i])
+		if !ok {
+			return 0, fmt.Errorf("unknown validation '%s'; expected %s or version", words[i], checkWordNames(transformValidateWords))
+		}
+		flags |= f
+	}
+	return flags, nil
+}
+
+// parseTransformSuppress converts suppression words to flags.
+func parseTransformSuppress(words []string) (int, error) {
+	flags := 0
+	for _, w := range words {
+		f, ok := lookupCheckWord(transformSuppressWords, w)
+		if !ok {
+			return 0, fmt.Errorf("unknown error '%s'; expected %s", w, checkWordNames(transformSuppressWords))
+		}
+		flags |= f
+	}
+	return flags, nil
+}
+
+// formatTransformValidation is the inverse of parseTransformValidation. A
+// version field without a comparison is written as an equality check.
+func formatTransformValidation(flags int) string {
+	var words []string
+	for _, f := range transformValidateWords {
+		if flags&f.Value != 0 {
+			words = append(words, f.Name)
+		}
+	}
+	if field, compare := transformVersionCheck(flags); field != "" {
+		words = append(words, "version", field, compare)
+	}
+	return strings.Join(words, " ")
+}
+
+// formatTransformSuppress is the inverse of parseTransformSuppress.
+func formatTransformSuppress(flags int) string {
+	var words []string
+	for _, f := range transformSuppressWords {
+		if flags&f.Value != 0 {
+			words = append(words, f.Name)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// transformVersionCheck names the most precise version field and the
+// comparison a validation checks; field is "" when versions are not checked.
+func transformVersionCheck(flags int) (field, compare string) {
+	for _, f := range transformVersionFields {
+		if flags&f.Value != 0 {
+			field = f.Name
+		}
+	}
+	compare = "="
+	for _, f := range transformVersionCompares {
+		if flags&f.Value != 0 {
+			compare = f.Name
+			break
+		}
+	}
+	return field, compare
+}
+
+// requireBase reports a validation whose base value the transform lacks.
+func (c TransformChecks) requireBase(base TransformBase) error {
+	for _, check := range []struct {
+		flag  int
+		name  string
+		value string
+	}{
+		{transformValidateProduct, "ProductCode", base.ProductCode},
+		{transformValidateUpgrade, "UpgradeCode", base.UpgradeCode},
+		{transformValidateLanguage, "ProductLanguage", base.ProductLanguage},
+		{transformValidateVersionMask, "ProductVersion", base.ProductVersion},
+	} {
+		if c.Validation&check.flag != 0 && check.value == "" {
+			return fmt.Errorf("transform validates %s but has no base %s", check.name, check.name)
+		}
+	}
+	return nil
+}
+
+// validate checks that target, the package a transform is applied to,
+// meets the validation conditions relative to the transform's base.
+func (c TransformChecks) validate(base, target TransformBase) error {
+	if err := c.requireBase(base); err != nil {
+		return err
+	}
+	var failures []string
+	for _, check := range []struct {
+		flag         int
+		name         string
+		want, actual string
+	}{
+		{transformValidateProduct, "ProductCode", base.ProductCode, target.ProductCode},
+		{transformValidateUpgrade, "UpgradeCode", base.UpgradeCode, target.UpgradeCode},
+		{transformValidateLanguage, "ProductLanguage", base.ProductLanguage, target.ProductLanguage},
+	} {
+		if c.Validation&check.flag != 0 && !strings.EqualFold(check.want, check.actual) {
+			failures = append(failures, fmt.Sprintf("%s is '%s', expected '%s'", check.name, check.actual, check.want))
+		}
+	}
+	if field, compare := transformVersionCheck(c.Validation); field != "" {
+		fields := 1
+		for i, f := range transformVersionFields {
+			if f.Name == field {
+				fields = i + 1
+			}
+		}
+		cmp, err := compareVersionFields(target.ProductVersion, base.ProductVersion, fields)
+		if err != nil {
+			failures = append(failures, err.Error())
+		} else if !versionCompareHolds(cmp, compare) {
+			failures = append(failures, fmt.Sprintf("ProductVersion %s is not %s %s (comparing %s versions)", target.ProductVersion, compare, base.ProductVersion, field))
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("transform does not apply to this package: %s", strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// compareVersionFields compares the first n fields of two dotted versions,
+// reading missing fields as 0. It returns -1, 0 or 1.
+func compareVersionFields(a, b string, n int) (int, error) {
+	av, err := versionFields(a, n)
+	if err != nil {
+		return 0, err
+	}
+	bv, err := versionFields(b, n)
+	if err != nil {
+		return 0, err
+	}
+	for i := range av {
+		switch {
+		case av[i] < bv[i]:
+			return -1, nil
+		case av[i] > bv[i]:
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func versionFields(v string, n int) ([]int, error) {
+	parts := strings.Split(strings.TrimSpace(v), ".")
+	fields := make([]int, n)
+	for i := 0; i < n && i < len(parts); i++ {
+		f, err := strconv.Atoi(parts[i])
+		if err != nil || f < 0 {
+			return nil, fmt.Errorf("'%s' is not a valid version", v)
+		}
+		fields[i] = f
+	}
+	return fields, nil
+}
+
+func versionCompareHolds(cmp int, compare string) bool {
+	switch compare {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">=":
+		return cmp >= 0
+	case ">":
+		return cmp > 0
+	}
+	return cmp == 0
+}
+
+// checkColumnValue reports a value that cannot be stored in col: a
+// non-integer or out-of-range value for an integer column.
+func checkColumnValue(col ColumnInfo, v string) error {
+	if v == "" || !col.IsInteger() {
+		return nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return fmt.Errorf("column '%s' (%s): '%s' is not an integer", col.Name, col.Type, v)
+	}
+	limit := 0x7FFFFFFF
+	if col.Width() == 2 {
+		limit = 0x7FFF
+	}
+	if n < -limit || n > limit {
+		return fmt.Errorf("column '%s' (%s): %d is out of range", col.Name, col.Type, n)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+// core/transform_checks_test.go
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTransformChecks(t *testing.T) {
+	checks, err := ParseTransformChecks("product, upgrade-code version minor >=", "row-exists,row-missing type-mismatch")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if want := transformValidateProduct | transformValidateUpgrade | 0x0010 | 0x0200; checks.Validation != want {
+		t.Errorf("Expected validation 0x%X, got 0x%X", want, checks.Validation)
+	}
+	if want := transformErrorAddExistingRow | transformErrorDelMissingRow | transformErrorUpdateMissingRow | transformErrorTypeMismatch; checks.Suppress != want {
+		t.Errorf("Expected suppress 0x%X, got 0x%X", want, checks.Suppress)
+	}
+	if got := formatTransformValidation(checks.Validation); got != "product upgrade-code version minor >=" {
+		t.Errorf("Unexpected validation words: %s", got)
+	}
+	if got := formatTransformSuppress(checks.Suppress); got != "row-exists row-missing type-mismatch" {
+		t.Errorf("Unexpected suppress words: %s", got)
+	}
+
+	for _, spec := range [][2]string{
+		{"productcode", ""},
+		{"version minor", ""},
+		{"version build =", ""},
+		{"version major = version minor <", ""},
+		{"", "row-duplicate"},
+	} {
+		if _, err := ParseTransformChecks(spec[0], spec[1]); err == nil {
+			t.Errorf("Expected an error for %q / %q", spec[0], spec[1])
+		}
+	}
+}
+
+func TestTransformChecks_Validate(t *testing.T) {
+	base := TransformBase{ProductCode: "{A}", ProductVersion: "2.1.5", UpgradeCode: "{U}", ProductLanguage: "1033"}
+	tests := []struct {
+		name     string
+		validate string
+		target   TransformBase
+		failure  string // substring of the error, "" when the check passes
+	}{
+		{"no checks", "", TransformBase{ProductCode: "{B}"}, ""},
+		{"product matches case-insensitively", "product", TransformBase{ProductCode: "{a}"}, ""},
+		{"product differs", "product", TransformBase{ProductCode: "{B}"}, "ProductCode is '{B}', expected '{A}'"},
+		{"upgrade code differs", "upgrade-code", TransformBase{UpgradeCode: "{V}"}, "UpgradeCode"},
+		{"language differs", "language", TransformBase{ProductLanguage: "1031"}, "ProductLanguage"},
+		{"major equal", "version major =", TransformBase{ProductVersion: "2.9"}, ""},
+		{"minor equal", "version minor =", TransformBase{ProductVersion: "2.9"}, "is not = 2.1.5"},
+		{"update equal", "version update =", TransformBase{ProductVersion: "2.1.5.7"}, ""},
+		{"update greater", "version update >", TransformBase{ProductVersion: "2.1.6"}, ""},
+		{"update not less", "version update <", TransformBase{ProductVersion: "2.1.6"}, "is not <"},
+		{"minor less or equal", "version minor <=", TransformBase{ProductVersion: "2"}, ""},
+		{"minor greater or equal", "version minor >=", TransformBase{ProductVersion: "1.99"}, "is not >="},
+		{"bad version", "version major =", TransformBase{ProductVersion: "v2"}, "not a valid version"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checks, err := ParseTransformChecks(tt.validate, "")
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			err = checks.validate(base, tt.target)
+			switch {
+			case tt.failure == "" && err != nil:
+				t.Errorf("Expected the check to pass, got: %v", err)
+			case tt.failure != "" && (err == nil || !strings.Contains(err.Error(), tt.failure)):
+				t.Errorf("Expected an error containing %q, got: %v", tt.failure, err)
+			}
+		})
+	}
+
+	checks := TransformChecks{Validation: transformValidateUpgrade}
+	if err := checks.validate(TransformBase{ProductCode: "{A}"}, base); err == nil || !strings.Contains(err.Error(), "no base UpgradeCode") {
+		t.Errorf("Expected a missing base error, got: %v", err)
+	}
+}
+
+func TestCheckColumnValue(t *testing.T) {
+	tests := []struct {
+		col   ColumnInfo
+		value string
+		ok    bool
+	}{
+		{ColumnInfo{Name: "N", Type: "I2"}, "", true},
+		{ColumnInfo{Name: "N", Type: "I2"}, "-32767", true},
+		{ColumnInfo{Name: "N", Type: "I2"}, "40000", false},
+		{ColumnInfo{Name: "N", Type: "i4"}, "40000", true},
+		{ColumnInfo{Name: "N", Type: "i4"}, "abc", false},
+		{ColumnInfo{Name: "S", Type: "s72"}, "abc", true},
+	}
+	for _, tt := range tests {
+		if err := checkColumnValue(tt.col, tt.value); (err == nil) != tt.ok {
+			t.Errorf("%s %q: expected ok=%v, got %v", tt.col.Type, tt.value, tt.ok, err)
+		}
+	}
+}
@@ -0,0 +1,687 @@
+// core/transform_text.go
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// transformHeader starts every v2 text transform. Files without it are read
+// as v1: bare "+ Table => v1|v2" and "- Table => v1|v2" lines.
+const transformHeader = "msicrafter-transform"
+
+// Schema operations of a text transform.
+const (
+	OpCreate ChangeOp = "CREATE"
+	OpAlter  ChangeOp = "ALTER"
+	OpDrop   ChangeOp = "DROP"
+)
+
+// TransformBase identifies the package a transform was generated against.
+type TransformBase struct {
+	ProductCode     string
+	ProductVersion  string
+	PackageCode     string
+	UpgradeCode     string
+	ProductLanguage string
+}
+
+// fields returns the base's name/value pairs in header order.
+func (b TransformBase) fields() [][2]string {
+	return [][2]string{
+		{"ProductCode", b.ProductCode},
+		{"ProductVersion", b.ProductVersion},
+		{"PackageCode", b.PackageCode},
+		{"UpgradeCode", b.UpgradeCode},
+		{"ProductLanguage", b.ProductLanguage},
+	}
+}
+
+// TextTransform is a parsed text transform.
+type TextTransform struct {
+	Version int
+	Base    TransformBase
+	Checks  TransformChecks
+	Ops     []TransformOp
+}
+
+// TransformSyntaxError reports where a v2 transform is malformed.
+type TransformSyntaxError struct {
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *TransformSyntaxError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Msg)
+}
+
+// transformBase reads the identity of the session's package for a
+// transform header.
+func (s *MsiSession) transformBase() TransformBase {
+	base := TransformBase{
+		ProductCode:     s.propertyValue("ProductCode"),
+		ProductVersion:  s.propertyValue("ProductVersion"),
+		UpgradeCode:     s.propertyValue("UpgradeCode"),
+		ProductLanguage: s.propertyValue("ProductLanguage"),
+	}
+	if code, err := s.summaryProperty(pidRevisionNumber); err == nil {
+		base.PackageCode = code
+	}
+	return base
+}
+
+// propertyValue returns a value of the Property table, or "" when the
+// property is not set.
+func (s *MsiSession) propertyValue(name string) string {
+	if row, err := s.FindRow("Property", []string{name}); err == nil && row != nil && len(row.Columns) > 1 {
+		return row.Columns[1]
+	}
+	return ""
+}
+
+// ReadTextTransform loads a v1 or v2 text transform.
+func ReadTextTransform(path string) (*TextTransform, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open MST file: %v", err)
+	}
+	defer f.Close()
+	t, err := ParseTextTransform(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return t, nil
+}
+
+// ParseTextTransform reads a text transform. A transform whose first line
+// (ignoring blank and comment lines) is the "msicrafter-transform 2" header
+// is parsed strictly as v2; anything else is read as v1, skipping invalid
+// lines with a warning.
+func ParseTextTransform(r io.Reader) (*TextTransform, error) {
+	var lines []string
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	for sc.Scan() {
+		lines = append(lines, strings.TrimSuffix(sc.Text(), "\r"))
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("error reading MST: %v", err)
+	}
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if strings.HasPrefix(trimmed, transformHeader) {
+			return parseTransformV2(lines)
+		}
+		break
+	}
+	return parseTransformV1(lines), nil
+}
+
+func parseTransformV1(lines []string) *TextTransform {
+	t := &TextTransform{Version: 1}
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		op, err := ParseTransformLine(line)
+		if err != nil {
+			log.Printf("[WARN] skipping invalid diff line '%s': %v", line, err)
+			continue
+		}
+		t.Ops = append(t.Ops, op)
+	}
+	return t
+}
+
+func parseTransformV2(lines []string) (*TextTransform, error) {
+	t := &TextTransform{Version: 2}
+	sawHeader, sawOp := false, false
+	for i, line := range lines {
+		p := &lineParser{line: line, num: i + 1}
+		p.skipSpace()
+		if p.done() {
+			continue
+		}
+		if !sawHeader {
+			if err := p.header(); err != nil {
+				return nil, err
+			}
+			sawHeader = true
+			continue
+		}
+		word := p.peekWord()
+		if word == "base" || word == "validate" || word == "suppress" {
+			if sawOp {
+				return nil, p.errorf("%s lines must come before any operation", word)
+			}
+			var err error
+			switch word {
+			case "base":
+				err = p.base(&t.Base)
+			case "validate":
+				err = p.checks(&t.Checks.Validation, parseTransformValidation)
+			case "suppress":
+				err = p.checks(&t.Checks.Suppress, parseTransformSuppress)
+			}
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		op, err := p.operation()
+		if err != nil {
+			return nil, err
+		}
+		sawOp = true
+		t.Ops = append(t.Ops, op)
+	}
+	return t, nil
+}
+
+// lineParser scans one line of a v2 transform.
+type lineParser struct {
+	line string
+	pos  int
+	num  int
+}
+
+func (p *lineParser) errorf(format string, args ...interface{}) error {
+	return &TransformSyntaxError{Line: p.num, Column: p.pos + 1, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *lineParser) skipSpace() {
+	for p.pos < len(p.line) && (p.line[p.pos] == ' ' || p.line[p.pos] == '\t') {
+		p.pos++
+	}
+	if p.pos < len(p.line) && p.line[p.pos] == '#' {
+		p.pos = len(p.line)
+	}
+}
+
+func (p *lineParser) done() bool {
+	return p.pos >= len(p.line)
+}
+
+// expect consumes the punctuation c.
+func (p *lineParser) expect(c byte) error {
+	p.skipSpace()
+	if p.done() || p.line[p.pos] != c {
+		return p.errorf("expected '%c'", c)
+	}
+	p.pos++
+	return nil
+}
+
+// accept consumes c if it is next.
+func (p *lineParser) accept(c byte) bool {
+	p.skipSpace()
+	if !p.done() && p.line[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func isWordChar(c byte) bool {
+	return isIdentChar(c) || c == '-'
+}
+
+func (p *lineParser) peekWord() string {
+	end := p.pos
+	for end < len(p.line) && isWordChar(p.line[end]) {
+		end++
+	}
+	return p.line[p.pos:end]
+}
+
+// ident reads a table, column or keyword name.
+func (p *lineParser) ident(what string) (string, error) {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.line) && isIdentChar(p.line[p.pos]) {
+		p.pos++
+	}
+	if p.pos == start {
+		return "", p.errorf("expected %s", what)
+	}
+	return p.line[start:p.pos], nil
+}
+
+// end checks nothing but a comment follows.
+func (p *lineParser) end() error {
+	p.skipSpace()
+	if !p.done() {
+		return p.errorf("unexpected '%s'", p.line[p.pos:])
+	}
+	return nil
+}
+
+func (p *lineParser) header() error {
+	word := p.peekWord()
+	if word != transformHeader {
+		return p.errorf("expected '%s 2' header", transformHeader)
+	}
+	p.pos += len(word)
+	p.skipSpace()
+	version := p.peekWord()
+	if version != "2" {
+		return p.errorf("unsupported transform version '%s'", version)
+	}
+	p.pos += len(version)
+	return p.end()
+}
+
+func (p *lineParser) base(base *TransformBase) error {
+	p.pos += len("base")
+	name, err := p.ident("base property name")
+	if err != nil {
+		return err
+	}
+	p.skipSpace()
+	value, err := p.value()
+	if err != nil {
+		return err
+	}
+	switch name {
+	case "ProductCode":
+		base.ProductCode = value
+	case "ProductVersion":
+		base.ProductVersion = value
+	case "PackageCode":
+		base.PackageCode = value
+	case "UpgradeCode":
+		base.UpgradeCode = value
+	case "ProductLanguage":
+		base.ProductLanguage = value
+	default:
+		return p.errorf("unknown base property '%s'; expected ProductCode, ProductVersion, PackageCode, UpgradeCode or ProductLanguage", name)
+	}
+	return p.end()
+}
+
+// checks reads the words of a "validate" or "suppress" line and adds the
+// flags they name to flags.
+func (p *lineParser) checks(flags *int, parse func([]string) (int, error)) error {
+	p.pos += len(p.peekWord())
+	p.skipSpace()
+	start := p.pos
+	var words []string
+	for {
+		p.skipSpace()
+		if p.done() {
+			break
+		}
+		if p.line[p.pos] == ',' {
+			p.pos++
+			continue
+		}
+		end := p.pos
+		for end < len(p.line) && !strings.ContainsRune(" \t,#", rune(p.line[end])) {
+			end++
+		}
+		words = append(words, p.line[p.pos:end])
+		p.pos = end
+	}
+	p.pos = start
+	if len(words) == 0 {
+		return p.errorf("expected at least one check")
+	}
+	f, err := parse(words)
+	if err != nil {
+		return p.errorf("%v", err)
+	}
+	*flags |= f
+	return nil
+}
+
+// value reads a quoted string, an integer or NULL. NULL and "" both read as
+// the empty string, which MSI stores as null.
+func (p *lineParser) value() (string, error) {
+	p.skipSpace()
+	if p.done() {
+		return "", p.errorf("expected a value")
+	}
+	switch c := p.line[p.pos]; {
+	case c == '"':
+		return p.quoted()
+	case c == '-' || (c >= '0' && c <= '9'):
+		start := p.pos
+		p.pos++
+		for p.pos < len(p.line) && p.line[p.pos] >= '0' && p.line[p.pos] <= '9' {
+			p.pos++
+		}
+		if p.line[start:p.pos] == "-" {
+			p.pos = start
+			return "", p.errorf("expected a value")
+		}
+		return p.line[start:p.pos], nil
+	}
+	if word := p.peekWord(); word == "NULL" {
+		p.pos += len(word)
+		return "", nil
+	}
+	return "", p.errorf("expected a quoted string, integer or NULL")
+}
+
+func (p *lineParser) quoted() (string, error) {
+	start := p.pos
+	p.pos++ // opening quote
+	var sb strings.Builder
+	for p.pos < len(p.line) {
+		c := p.line[p.pos]
+		switch c {
+		case '"':
+			p.pos++
+			return sb.String(), nil
+		case '\\':
+			if p.pos+1 >= len(p.line) {
+				p.pos++
+				return "", p.errorf("unfinished escape")
+			}
+			esc, ok := map[byte]byte{'\\': '\\', '"': '"', 'n': '\n', 'r': '\r', 't': '\t'}[p.line[p.pos+1]]
+			if !ok {
+				return "", p.errorf("unknown escape '\\%c'", p.line[p.pos+1])
+			}
+			sb.WriteByte(esc)
+			p.pos += 2
+		default:
+			sb.WriteByte(c)
+			p.pos++
+		}
+	}
+	p.pos = start
+	return "", p.errorf("unterminated string")
+}
+
+// tuple reads "(value, value, ...)".
+func (p *lineParser) tuple() ([]string, error) {
+	if err := p.expect('('); err != nil {
+		return nil, err
+	}
+	values := []string{}
+	if p.accept(')') {
+		return values, nil
+	}
+	for {
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+		if p.accept(')') {
+			return values, nil
+		}
+		if err := p.expect(','); err != nil {
+			return nil, p.errorf("expected ',' or ')'")
+		}
+	}
+}
+
+var columnTypePattern = regexp.MustCompile(`^[sSlLiIjJvV][0-9]+$`)
+
+// columnDef reads "Name type [KEY]".
+func (p *lineParser) columnDef() (ColumnInfo, error) {
+	name, err := p.ident("column name")
+	if err != nil {
+		return ColumnInfo{}, err
+	}
+	typ, err := p.ident("column type")
+	if err != nil {
+		return ColumnInfo{}, err
+	}
+	if !columnTypePattern.MatchString(typ) {
+		p.pos -= len(typ)
+		return ColumnInfo{}, p.errorf("invalid column type '%s'; expected e.g. s72, S0, L255, i2, I4 or v0", typ)
+	}
+	col := ColumnInfo{Name: name, Type: typ}
+	p.skipSpace()
+	if p.peekWord() == "KEY" {
+		p.pos += len("KEY")
+		col.Key = true
+	}
+	return col, nil
+}
+
+// operation reads one +, -, ~, CREATE, ALTER or DROP line.
+func (p *lineParser) operation() (TransformOp, error) {
+	var op TransformOp
+	switch c := p.line[p.pos]; c {
+	case '+', '-', '~':
+		op.Op = ChangeOp(string(c))
+		p.pos++
+	default:
+		word, err := p.ident("operation")
+		if err != nil {
+			return op, p.errorf("expected an operation: +, -, ~, CREATE, ALTER or DROP")
+		}
+		switch ChangeOp(word) {
+		case OpCreate, OpAlter, OpDrop:
+			op.Op = ChangeOp(word)
+		default:
+			p.pos -= len(word)
+			return op, p.errorf("unknown operation '%s'", word)
+		}
+	}
+	table, err := p.ident("table name")
+	if err != nil {
+		return op, err
+	}
+	op.Table = table
+
+	switch op.Op {
+	case OpInsert:
+		op.Values, err = p.tuple()
+	case OpDelete:
+		op.Key, err = p.tuple()
+	case OpUpdate:
+		if op.Key, err = p.tuple(); err != nil {
+			return op, err
+		}
+		op.Set = map[string]string{}
+		for p.skipSpace(); !p.done(); p.skipSpace() {
+			col, err := p.ident("Column=value")
+			if err != nil {
+				return op, err
+			}
+			if err := p.expect('='); err != nil {
+				return op, err
+			}
+			v, err := p.value()
+			if err != nil {
+				return op, err
+			}
+			if _, dup := op.Set[col]; dup {
+				return op, p.errorf("column '%s' is set twice", col)
+			}
+			op.Set[col] = v
+		}
+		if len(op.Set) == 0 {
+			return op, p.errorf("update sets no columns")
+		}
+	case OpCreate:
+		if err := p.expect('('); err != nil {
+			return op, err
+		}
+		for {
+			col, err := p.columnDef()
+			if err != nil {
+				return op, err
+			}
+			op.Columns = append(op.Columns, col)
+			if p.accept(')') {
+				break
+			}
+			if err := p.expect(','); err != nil {
+				return op, p.errorf("expected ',' or ')'")
+			}
+		}
+		if len((&TableSchema{Columns: op.Columns}).KeyColumns()) == 0 {
+			return op, p.errorf("table '%s' needs at least one KEY column", op.Table)
+		}
+	case OpAlter:
+		p.skipSpace()
+		if p.peekWord() != "ADD" {
+			return op, p.errorf("expected ADD")
+		}
+		p.pos += len("ADD")
+		col, err := p.columnDef()
+		if err != nil {
+			return op, err
+		}
+		op.Columns = []ColumnInfo{col}
+	}
+	if err != nil {
+		return op, err
+	}
+	return op, p.end()
+}
+
+// quoteTransformValue renders a value for a v2 transform; empty values are
+// written as NULL.
+func quoteTransformValue(v string) string {
+	if v == "" {
+		return "NULL"
+	}
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for i := 0; i < len(v); i++ {
+		switch c := v[i]; c {
+		case '\\', '"':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+func formatTuple(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = quoteTransformValue(v)
+	}
+	return "(" + strings.Join(quoted, ", ") + ")"
+}
+
+func formatColumnDef(col ColumnInfo) string {
+	if col.Key {
+		return col.Name + " " + col.Type + " KEY"
+	}
+	return col.Name + " " + col.Type
+}
+
+func formatColumnDefs(cols []ColumnInfo) string {
+	defs := make([]string, len(cols))
+	for i, col := range cols {
+		defs[i] = formatColumnDef(col)
+	}
+	return strings.Join(defs, ", ")
+}
+
+// formatTransformOp renders one operation as a v2 line.
+func formatTransformOp(op TransformOp) string {
+	switch op.Op {
+	case OpInsert:
+		return fmt.Sprintf("+ %s %s", op.Table, formatTuple(op.Values))
+	case OpDelete:
+		return fmt.Sprintf("- %s %s", op.Table, formatTuple(op.Key))
+	case OpUpdate:
+		var sets []string
+		for _, col := range sortedKeys(op.Set) {
+			sets = append(sets, col+"="+quoteTransformValue(op.Set[col]))
+		}
+		return fmt.Sprintf("~ %s %s %s", op.Table, formatTuple(op.Key), strings.Join(sets, " "))
+	case OpCreate:
+		return fmt.Sprintf("CREATE %s (%s)", op.Table, formatColumnDefs(op.Columns))
+	case OpAlter:
+		return fmt.Sprintf("ALTER %s ADD %s", op.Table, formatColumnDef(op.Columns[0]))
+	case OpDrop:
+		return fmt.Sprintf("DROP %s", op.Table)
+	}
+	return ""
+}
+
+// Format writes the transform in the v2 text format.
+func (t *TextTransform) Format(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%s 2\n", transformHeader)
+	for _, f := range t.Base.fields() {
+		if f[1] != "" {
+			fmt.Fprintf(bw, "base %s %s\n", f[0], quoteTransformValue(f[1]))
+		}
+	}
+	if t.Checks.Validation != 0 {
+		fmt.Fprintf(bw, "validate %s\n", formatTransformValidation(t.Checks.Validation))
+	}
+	if t.Checks.Suppress != 0 {
+		fmt.Fprintf(bw, "suppress %s\n", formatTransformSuppress(t.Checks.Suppress))
+	}
+	for _, op := range t.Ops {
+		fmt.Fprintln(bw, formatTransformOp(op))
+	}
+	return bw.Flush()
+}
+
+// WriteFile writes the transform to path in the v2 text format.
+func (t *TextTransform) WriteFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to write transform '%s': %v", path, err)
+	}
+	if err := t.Format(f); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write transform '%s': %v", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write transform '%s': %v", path, err)
+	}
+	return nil
+}
+
+// changeOps converts row changes to transform operations: inserts carry the
+// new row, deletes the primary key and updates the key and changed columns.
+func changeOps(changes []RowChange) []TransformOp {
+	ops := make([]TransformOp, 0, len(changes))
+	for _, c := range changes {
+		op := TransformOp{Op: c.Op, Table: c.Table}
+		switch c.Op {
+		case OpInsert:
+			op.Values = c.New
+		case OpDelete:
+			op.Key = c.Key
+		case OpUpdate:
+			op.Key = c.Key
+			op.Set = map[string]string{}
+			for _, cell := range c.Cells() {
+				op.Set[cell.Column] = cell.New
+			}
+		}
+		ops = append(ops, op)
+	}
+	return ops
+}
+
+// WriteTextTransform writes row changes to path as a v2 text transform
+// generated against base.
+func WriteTextTransform(path string, base TransformBase, changes []RowChange) error {
+	t := &TextTransform{Version: 2, Base: base, Ops: changeOps(changes)}
+	return t.WriteFile(path)
+}
@@ -0,0 +1,142 @@
+// core/transform_text_test.go
+package core
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTextTransform_V2(t *testing.T) {
+	text := `# generated for review
+msicrafter-transform 2
+base ProductCode "{11111111-2222-3333-4444-555555555555}"
+base ProductVersion "1.0.0"
+base UpgradeCode "{AAAAAAAA-2222-3333-4444-555555555555}"
+validate product, upgrade-code   # checked before anything is applied
+validate version update >=
+suppress row-exists row-missing
+
+CREATE Custom (Id s72 KEY, Note L0, Count I2)
+ALTER Registry ADD Extra S255
++ Property ("NEWPROP", "a|b \"quoted\"\tx")   # trailing comment
+- Registry ("Reg1")
+~ Shortcut ("SC1") Icon_=NULL Arguments="--x=1" Attributes=-5
+DROP Legacy
+`
+	tr, err := ParseTextTransform(strings.NewReader(text))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if tr.Version != 2 || tr.Base.ProductCode != "{11111111-2222-3333-4444-555555555555}" || tr.Base.ProductVersion != "1.0.0" {
+		t.Errorf("Unexpected header: %d %+v", tr.Version, tr.Base)
+	}
+	if want := (TransformChecks{Validation: 0x0002 | 0x0800 | 0x0020 | 0x0200, Suppress: 0x0001 | 0x0002 | 0x0010}); tr.Checks != want {
+		t.Errorf("Expected checks %+v, got %+v", want, tr.Checks)
+	}
+	expected := []TransformOp{
+		{Op: OpCreate, Table: "Custom", Columns: []ColumnInfo{
+			{Name: "Id", Type: "s72", Key: true}, {Name: "Note", Type: "L0"}, {Name: "Count", Type: "I2"},
+		}},
+		{Op: OpAlter, Table: "Registry", Columns: []ColumnInfo{{Name: "Extra", Type: "S255"}}},
+		{Op: OpInsert, Table: "Property", Values: []string{"NEWPROP", "a|b \"quoted\"\tx"}},
+		{Op: OpDelete, Table: "Registry", Key: []string{"Reg1"}},
+		{Op: OpUpdate, Table: "Shortcut", Key: []string{"SC1"}, Set: map[string]string{"Icon_": "", "Arguments": "--x=1", "Attributes": "-5"}},
+		{Op: OpDrop, Table: "Legacy"},
+	}
+	if !reflect.DeepEqual(tr.Ops, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, tr.Ops)
+	}
+}
+
+func TestTextTransform_RoundTrip(t *testing.T) {
+	original := &TextTransform{
+		Version: 2,
+		Base:    TransformBase{ProductCode: "{A}", PackageCode: "{B}", ProductVersion: "1.2", UpgradeCode: "{U}", ProductLanguage: "1033"},
+		Checks: TransformChecks{
+			Validation: transformValidateProduct | transformValidateLanguage | 0x0008 | 0x0040,
+			Suppress:   transformErrorAddExistingTable | transformErrorTypeMismatch,
+		},
+		Ops: []TransformOp{
+			{Op: OpCreate, Table: "Custom", Columns: []ColumnInfo{{Name: "Id", Type: "s72", Key: true}, {Name: "V", Type: "S0"}}},
+			{Op: OpInsert, Table: "Custom", Values: []string{"x", "line1\nline2 \\ | => \"q\""}},
+			{Op: OpInsert, Table: "Custom", Values: []string{"y", ""}},
+			{Op: OpUpdate, Table: "Custom", Key: []string{"x"}, Set: map[string]string{"V": " padded "}},
+			{Op: OpDelete, Table: "Custom", Key: []string{"y"}},
+		},
+	}
+	var buf bytes.Buffer
+	if err := original.Format(&buf); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	parsed, err := ParseTextTransform(&buf)
+	if err != nil {
+		t.Fatalf("Expected no error parsing:\n%s\ngot: %v", buf.String(), err)
+	}
+	if !reflect.DeepEqual(parsed, original) {
+		t.Errorf("Round trip mismatch:\n%+v\n%+v", original, parsed)
+	}
+}
+
+func TestParseTextTransform_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		line int
+		col  int
+	}{
+		{"bad version", "msicrafter-transform 3\n", 1, 22},
+		{"unknown op", "msicrafter-transform 2\nMERGE Property\n", 2, 1},
+		{"unterminated string", "msicrafter-transform 2\n+ Property (\"A\", \"open)\n", 2, 18},
+		{"bad escape", "msicrafter-transform 2\n+ Property (\"a\\q\")\n", 2, 15},
+		{"missing comma", "msicrafter-transform 2\n+ Property (\"A\" \"B\")\n", 2, 17},
+		{"bare word", "msicrafter-transform 2\n+ Property (A)\n", 2, 13},
+		{"update without set", "msicrafter-transform 2\n~ Property (\"A\")\n", 2, 17},
+		{"duplicate set", "msicrafter-transform 2\n~ Property (\"A\") Value=1 Value=2\n", 2, 33},
+		{"bad type", "msicrafter-transform 2\nCREATE T (Id x72 KEY)\n", 2, 14},
+		{"no key", "msicrafter-transform 2\nCREATE T (Id s72)\n", 2, 18},
+		{"late base", "msicrafter-transform 2\nDROP T\nbase ProductCode \"{A}\"\n", 3, 1},
+		{"unknown check", "msicrafter-transform 2\nvalidate product platform\n", 2, 10},
+		{"empty suppress", "msicrafter-transform 2\nsuppress # nothing\n", 2, 19},
+		{"late validate", "msicrafter-transform 2\nDROP T\nvalidate product\n", 3, 1},
+		{"trailing text", "msicrafter-transform 2\nDROP T now\n", 2, 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseTextTransform(strings.NewReader(tt.text))
+			syntaxErr, ok := err.(*TransformSyntaxError)
+			if !ok {
+				t.Fatalf("Expected a syntax error, got: %v", err)
+			}
+			if syntaxErr.Line != tt.line || syntaxErr.Column != tt.col {
+				t.Errorf("Expected line %d column %d, got %v", tt.line, tt.col, syntaxErr)
+			}
+		})
+	}
+}
+
+func TestParseTextTransform_V1(t *testing.T) {
+	text := "+ Property => A|1\n\nnot a transform line\n~ Property => A => Value=2\n"
+	tr, err := ParseTextTransform(strings.NewReader(text))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if tr.Version != 1 || len(tr.Ops) != 2 {
+		t.Errorf("Expected 2 v1 operations with the invalid line skipped, got %d %+v", tr.Version, tr.Ops)
+	}
+}
+
+func TestCreateTableSQL(t *testing.T) {
+	sql := createTableSQL("Custom", []ColumnInfo{
+		{Name: "Id", Type: "s72", Key: true},
+		{Name: "Note", Type: "L0"},
+		{Name: "Count", Type: "I2"},
+		{Name: "Size", Type: "i4"},
+		{Name: "Data", Type: "V0"},
+	})
+	expected := "CREATE TABLE `Custom` (`Id` CHAR(72) NOT NULL, `Note` LONGCHAR LOCALIZABLE, `Count` SHORT, `Size` LONG NOT NULL, `Data` OBJECT PRIMARY KEY `Id`)"
+	if sql != expected {
+		t.Errorf("Expected %s, got %s", expected, sql)
+	}
+}
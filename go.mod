@@ -5,11 +5,11 @@ go 1.21
 require (
 	github.com/go-ole/go-ole v1.2.6
 	github.com/urfave/cli/v2 v2.25.7
+	golang.org/x/sys v0.0.0-20190916202348-b4ddaad3f8a3
 )
 
 require (
 	github.com/cpuguy83/go-md2man/v2 v2.0.2 // indirect
 	github.com/russross/blackfriday/v2 v2.1.0 // indirect
 	github.com/xrash/smetrics v0.0.0-20201216005158-039620a65673 // indirect
-	golang.org/x/sys v0.0.0-20190916202348-b4ddaad3f8a3 // indirect
 )
@@ -17,8 +17,11 @@ var (
 )
 
 func main() {
-    retro.ShowSplash()
-    log.Printf("msicrafter version: %s", version)
+    // Git reads the output of textconv and merge drivers; keep it clean.
+    if len(os.Args) < 2 || os.Args[1] != "git" {
+        retro.ShowSplash()
+        log.Printf("msicrafter version: %s", version)
+    }
 
     if err := core.InitCOM(); err != nil {
         log.Fatalf("[FATAL] COM initialization failed: %v", err)
@@ -34,9 +37,30 @@ func main() {
                 Name:  "debug",
                 Usage: "Enable verbose debug logging",
             },
+            &urfavecli.DurationFlag{
+                Name:  "wait",
+                Usage: "How long to wait for another msicrafter's lock on the MSI (e.g. 30s)",
+            },
+            &urfavecli.BoolFlag{
+                Name:    "yes",
+                Aliases: []string{"y"},
+                Usage:   "Answer yes to every confirmation prompt",
+            },
+            &urfavecli.BoolFlag{
+                Name:  "no",
+                Usage: "Answer no to every confirmation prompt",
+            },
+            &urfavecli.StringFlag{
+                Name:  "answers",
+                Usage: "Read confirmation answers (yes/no/all/quit), one per line, from `FILE`",
+            },
         },
         Before: func(c *urfavecli.Context) error {
             core.DebugMode = c.Bool("debug")
+            core.LockWait = c.Duration("wait")
+            if err := core.ConfigurePrompts(c.Bool("yes"), c.Bool("no"), c.String("answers")); err != nil {
+                return err
+            }
             if core.DebugMode {
                 log.SetFlags(log.LstdFlags | log.Lshortfile)
                 log.Println("[DEBUG] Debug mode enabled.")